@@ -0,0 +1,44 @@
+// Package adapters provides ready-made receivers that connect secrets synced by a vaultsync agent to common clients.
+//
+// The adapters read the current values from the agent, so clients always use the latest credentials
+// without keeping their own copy behind a mutex.
+package adapters
+
+import (
+	"fmt"
+
+	"github.com/pergus/vaultsync"
+)
+
+// Default field names of username and password credentials.
+const (
+	DefaultUsernameField = "username"
+	DefaultPasswordField = "password"
+)
+
+// field function returns a field of a synced secret as a string.
+func field(agent *vaultsync.Agent, path string, name string) (string, error) {
+	value, ok := agent.SecretField(path, name)
+	if !ok {
+		return "", fmt.Errorf("field %v of secret %v is not synced", name, path)
+	}
+	return toString(value), nil
+}
+
+// credentials function returns the username and password fields of a synced secret.
+// Both fields are read at once, so a rotation never pairs a new username with an old password.
+func credentials(agent *vaultsync.Agent, path string, usernameField string, passwordField string) (string, string, error) {
+	values, ok := agent.SecretFields(path, usernameField, passwordField)
+	if !ok {
+		return "", "", fmt.Errorf("fields %v and %v of secret %v are not synced", usernameField, passwordField, path)
+	}
+	return toString(values[0]), toString(values[1]), nil
+}
+
+// toString function returns a field value as a string.
+func toString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,39 @@
+package adapters_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pergus/vaultsync/adapters"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+func TestRedisCredentialsProviderContext(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/redis", map[string]interface{}{"username": "app", "password": "s3cret"})
+
+	agent := vaultsynctest.NewAgent(t, s)
+	provider := adapters.RedisCredentialsProviderContext(agent, "secret/data/redis")
+
+	// Before the first sync the provider fails instead of returning empty credentials.
+	if _, _, err := provider(context.Background()); err == nil {
+		t.Fatal("credentials of an unsynced path returned no error")
+	}
+
+	vaultsynctest.Sync(t, agent)
+	username, password, err := provider(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "app" || password != "s3cret" {
+		t.Fatalf("credentials are %q/%q, want app/s3cret", username, password)
+	}
+
+	// A rotation of both fields is seen as a whole.
+	s.SetSecret("secret/data/redis", map[string]interface{}{"username": "app2", "password": "n3w"})
+	vaultsynctest.Sync(t, agent)
+	if username, password, _ = provider(context.Background()); username != "app2" || password != "n3w" {
+		t.Fatalf("credentials are %q/%q, want app2/n3w", username, password)
+	}
+}
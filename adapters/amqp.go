@@ -0,0 +1,99 @@
+package adapters
+
+import (
+	"net/url"
+	"reflect"
+	"sync"
+
+	"github.com/pergus/vaultsync"
+)
+
+// AMQPURL struct builds an AMQP connection URL from a base URL and the credentials of a synced secret path.
+type AMQPURL struct {
+	agent *vaultsync.Agent
+	path  string
+	base  *url.URL
+
+	mu       sync.Mutex
+	last     [2]interface{}
+	changed  bool
+	onChange []func(amqpURL string)
+}
+
+// NewAMQPURL function creates an AMQP URL builder for a base URL such as amqp://rabbitmq:5672/vhost
+// and registers it with the agent. Credentials in the base URL are replaced.
+func NewAMQPURL(agent *vaultsync.Agent, path string, baseURL string) (*AMQPURL, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &AMQPURL{
+		agent: agent,
+		path:  path,
+		base:  base,
+	}
+	agent.RegisterSink(u, path)
+	return u, nil
+}
+
+// URL method returns the connection URL with the current credentials.
+func (u *AMQPURL) URL() (string, error) {
+	username, password, err := credentials(u.agent, u.path, DefaultUsernameField, DefaultPasswordField)
+	if err != nil {
+		return "", err
+	}
+	amqpURL := *u.base
+	amqpURL.User = url.UserPassword(username, password)
+	return amqpURL.String(), nil
+}
+
+// OnChange method registers a function called with the new URL after the credentials rotated,
+// typically to reconnect.
+func (u *AMQPURL) OnChange(fn func(amqpURL string)) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.onChange = append(u.onChange, fn)
+}
+
+// UpdateSecret method records whether the credentials changed. It implements vaultsync.SecretReceiver.
+func (u *AMQPURL) UpdateSecret(id string, fieldName string, value interface{}) {
+	i := 0
+	switch fieldName {
+	case DefaultUsernameField:
+	case DefaultPasswordField:
+		i = 1
+	default:
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.last[i] != nil && !reflect.DeepEqual(u.last[i], value) {
+		u.changed = true
+	}
+	u.last[i] = value
+}
+
+// Flush method calls the OnChange functions once all fields of a rotation are received. It implements vaultsync.SecretSink.
+func (u *AMQPURL) Flush() error {
+	u.mu.Lock()
+	changed := u.changed
+	u.changed = false
+	onChange := append([]func(string){}, u.onChange...)
+	u.mu.Unlock()
+
+	if !changed {
+		return nil
+	}
+	amqpURL, err := u.URL()
+	if err != nil {
+		return err
+	}
+	for _, fn := range onChange {
+		fn(amqpURL)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package adapters
+
+import (
+	"net/http"
+
+	"github.com/pergus/vaultsync"
+)
+
+// BasicAuthTransport struct is an http.RoundTripper that adds HTTP basic authentication
+// with the current credentials of a synced secret path to every request.
+type BasicAuthTransport struct {
+	agent *vaultsync.Agent
+	path  string
+	base  http.RoundTripper
+}
+
+// NewBasicAuthTransport function creates a basic authentication transport wrapping base and registers the path with the agent.
+// A nil base uses http.DefaultTransport.
+func NewBasicAuthTransport(agent *vaultsync.Agent, path string, base http.RoundTripper) *BasicAuthTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	agent.RegisterUpdateSecret(path, nopReceiver{})
+	return &BasicAuthTransport{
+		agent: agent,
+		path:  path,
+		base:  base,
+	}
+}
+
+// RoundTrip method implements http.RoundTripper.
+func (t *BasicAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	username, password, err := credentials(t.agent, t.path, DefaultUsernameField, DefaultPasswordField)
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(username, password)
+	return t.base.RoundTrip(req)
+}
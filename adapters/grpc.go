@@ -0,0 +1,50 @@
+package adapters
+
+import (
+	"context"
+
+	"github.com/pergus/vaultsync"
+)
+
+// RPCCredentials struct implements the credentials.PerRPCCredentials interface of gRPC with the current value of a field of a synced secret path.
+// It is passed to grpc.WithPerRPCCredentials, so calls send the rotated token without reconnecting.
+type RPCCredentials struct {
+	agent  *vaultsync.Agent
+	path   string
+	field  string
+	key    string
+	prefix string
+}
+
+// NewRPCCredentials function creates per-RPC credentials that send the value of field as the metadata key.
+func NewRPCCredentials(agent *vaultsync.Agent, path string, field string, key string) *RPCCredentials {
+	agent.RegisterUpdateSecret(path, nopReceiver{})
+	return &RPCCredentials{
+		agent: agent,
+		path:  path,
+		field: field,
+		key:   key,
+	}
+}
+
+// NewBearerRPCCredentials function creates per-RPC credentials that send the value of field as a bearer token in the authorization metadata.
+func NewBearerRPCCredentials(agent *vaultsync.Agent, path string, field string) *RPCCredentials {
+	c := NewRPCCredentials(agent, path, field, "authorization")
+	c.prefix = "Bearer "
+	return c
+}
+
+// GetRequestMetadata method returns the metadata with the current value. It implements credentials.PerRPCCredentials.
+func (c *RPCCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	value, err := field(c.agent, c.path, c.field)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{c.key: c.prefix + value}, nil
+}
+
+// RequireTransportSecurity method implements credentials.PerRPCCredentials.
+// Secrets are never sent over connections without transport security.
+func (c *RPCCredentials) RequireTransportSecurity() bool {
+	return true
+}
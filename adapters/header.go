@@ -0,0 +1,56 @@
+package adapters
+
+import (
+	"net/http"
+
+	"github.com/pergus/vaultsync"
+)
+
+// HeaderTransport struct is an http.RoundTripper that sets a request header to the current value of a field of a synced secret path,
+// such as an API key or bearer token.
+type HeaderTransport struct {
+	agent  *vaultsync.Agent
+	path   string
+	field  string
+	header string
+	prefix string
+	base   http.RoundTripper
+}
+
+// NewHeaderTransport function creates a transport wrapping base that sets header to the value of field, for example X-Api-Key.
+// A nil base uses http.DefaultTransport.
+func NewHeaderTransport(agent *vaultsync.Agent, path string, field string, header string, base http.RoundTripper) *HeaderTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	agent.RegisterUpdateSecret(path, nopReceiver{})
+	return &HeaderTransport{
+		agent:  agent,
+		path:   path,
+		field:  field,
+		header: header,
+		base:   base,
+	}
+}
+
+// NewBearerTransport function creates a transport wrapping base that sends the value of field as a bearer token in the Authorization header.
+func NewBearerTransport(agent *vaultsync.Agent, path string, field string, base http.RoundTripper) *HeaderTransport {
+	t := NewHeaderTransport(agent, path, field, "Authorization", base)
+	t.prefix = "Bearer "
+	return t
+}
+
+// RoundTrip method implements http.RoundTripper.
+func (t *HeaderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	value, err := field(t.agent, t.path, t.field)
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set(t.header, t.prefix+value)
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,38 @@
+package adapters
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/pergus/vaultsync"
+)
+
+// RedisCredentialsProvider function returns a function for the CredentialsProvider option of go-redis.
+// Every new connection authenticates with the current username and password of the secret path.
+// The option cannot fail a connection attempt, so if the path has not been synced the error is logged with the logger
+// of the agent and empty credentials are returned. Prefer RedisCredentialsProviderContext, which returns the error.
+func RedisCredentialsProvider(agent *vaultsync.Agent, path string) func() (string, string) {
+	agent.RegisterUpdateSecret(path, nopReceiver{})
+	return func() (string, string) {
+		username, password, err := credentials(agent, path, DefaultUsernameField, DefaultPasswordField)
+		if err != nil {
+			agent.Logger().Error("RedisCredentialsProvider", slog.Any("error", err))
+		}
+		return username, password
+	}
+}
+
+// RedisCredentialsProviderContext function returns a function for the CredentialsProviderContext option of go-redis.
+// It fails the connection attempt if the path has not been synced.
+func RedisCredentialsProviderContext(agent *vaultsync.Agent, path string) func(ctx context.Context) (string, string, error) {
+	agent.RegisterUpdateSecret(path, nopReceiver{})
+	return func(ctx context.Context) (string, string, error) {
+		return credentials(agent, path, DefaultUsernameField, DefaultPasswordField)
+	}
+}
+
+// nopReceiver struct registers a path with the agent for adapters that read values on demand.
+type nopReceiver struct{}
+
+// UpdateSecret method ignores the update.
+func (nopReceiver) UpdateSecret(id string, fieldName string, value interface{}) {}
@@ -0,0 +1,378 @@
+package vaultsync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// adminConfig struct defines the admin API, served on a loopback address such as 127.0.0.1:8202 or on a unix socket given as unix:///path.
+type adminConfig struct {
+	Address string `hcl:"address"`
+	Debug   bool   `hcl:"debug,optional"`
+}
+
+// adminReadHeaderTimeout is how long the admin API waits for the headers of a request.
+const adminReadHeaderTimeout = 10 * time.Second
+
+// WithAdminAddress function serves the admin API on a loopback address, such as 127.0.0.1:8202, or on a unix socket given as unix:///path.
+// It takes precedence over the admin block of the configuration file.
+func WithAdminAddress(address string) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.adminAddress = address
+	}
+}
+
+// validateAdminAddress function checks that the admin API is only reachable from the host, it has no authentication.
+func validateAdminAddress(address string) error {
+	if path, ok := strings.CutPrefix(address, "unix://"); ok {
+		if path == "" {
+			return fmt.Errorf("admin address %q has no socket path", address)
+		}
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid admin address %q:%w", address, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("admin address %q is not a loopback address or unix socket", address)
+	}
+	return nil
+}
+
+// adminAddressOf method returns the address of the admin API from the options or the configuration file, empty if it is disabled.
+func (a *Agent) adminAddressOf() string {
+	if a.adminAddress != "" {
+		return a.adminAddress
+	}
+	if a.config.Vault.Admin != nil {
+		return a.config.Vault.Admin.Address
+	}
+	return ""
+}
+
+// listenAdmin method opens the listener of the admin API, so Run fails before starting anything if the address is taken.
+// A unix socket left over from a previous run is removed, the new socket is only accessible by the user of the agent.
+func (a *Agent) listenAdmin() (net.Listener, error) {
+	address := a.adminAddressOf()
+	if address == "" {
+		return nil, nil
+	}
+	if err := validateAdminAddress(address); err != nil {
+		return nil, err
+	}
+
+	path, ok := strings.CutPrefix(address, "unix://")
+	if !ok {
+		return net.Listen("tcp", address)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("admin socket:%w", err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("admin socket:%w", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("admin socket:%w", err)
+	}
+	return listener, nil
+}
+
+// serveAdmin method serves the admin API until ctx is done.
+func (a *Agent) serveAdmin(ctx context.Context, wg *sync.WaitGroup, listener net.Listener) {
+	defer wg.Done()
+
+	// Browsers cannot connect to a unix socket, so its requests are accepted with any Host.
+	handler := guardAdmin(a.adminMux(), listener.Addr().Network() == "unix")
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: adminReadHeaderTimeout}
+
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+		case <-stopped:
+		}
+	}()
+
+	a.log.Info("serveAdmin", slog.String("address", listener.Addr().String()))
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		a.log.Error("serveAdmin", slog.Any("error", err))
+	}
+	a.log.Info("serveAdmin", slog.String("status", "cancel"))
+}
+
+// adminPathStatus struct is the JSON form of PathStatus returned by the admin API. It never contains secret values.
+type adminPathStatus struct {
+	Path          string          `json:"path"`
+	LastSync      time.Time       `json:"last_sync"`
+	CachedAt      time.Time       `json:"cached_at"`
+	LastError     string          `json:"last_error,omitempty"`
+	LastErrorTime time.Time       `json:"last_error_time"`
+	ErrorKind     ErrorKind       `json:"error_kind,omitempty"`
+	NextRetry     time.Time       `json:"next_retry"`
+	Version       int             `json:"version"`
+	LastChange    time.Time       `json:"last_change"`
+	AgeSeconds    float64         `json:"age_seconds"`
+	ExpiresAt     time.Time       `json:"expires_at"`
+	Stale         bool            `json:"stale"`
+	StaleSince    time.Time       `json:"stale_since"`
+	History       []VersionRecord `json:"history,omitempty"`
+}
+
+// adminStatus struct is the status of the agent returned by the admin API.
+type adminStatus struct {
+	Paused bool              `json:"paused"`
+	Paths  []adminPathStatus `json:"paths"`
+}
+
+// adminLogLevels struct is the log level of the agent and the levels set for its components, returned by the admin API.
+type adminLogLevels struct {
+	Level      string            `json:"level"`
+	Components map[string]string `json:"components"`
+}
+
+// adminLogLevels method returns the log levels for the admin API.
+func (a *Agent) adminLogLevels() adminLogLevels {
+	levels := adminLogLevels{Level: a.LogLevel().String(), Components: make(map[string]string)}
+	for component, level := range a.ComponentLogLevels() {
+		levels.Components[component] = level.String()
+	}
+	return levels
+}
+
+// newAdminPathStatus function converts a PathStatus for the admin API, dropping the values kept in the version history.
+func newAdminPathStatus(status PathStatus) adminPathStatus {
+	ps := adminPathStatus{
+		Path:          status.Path,
+		LastSync:      status.LastSync,
+		CachedAt:      status.CachedAt,
+		LastErrorTime: status.LastErrorTime,
+		ErrorKind:     status.ErrorKind,
+		NextRetry:     status.NextRetry,
+		Version:       status.Version,
+		LastChange:    status.LastChange,
+		AgeSeconds:    status.Age.Seconds(),
+		ExpiresAt:     status.ExpiresAt,
+		Stale:         status.Stale,
+		StaleSince:    status.StaleSince,
+		History:       status.History,
+	}
+	if status.LastError != nil {
+		ps.LastError = status.LastError.Error()
+	}
+	for i := range ps.History {
+		ps.History[i].Data = nil
+	}
+	return ps
+}
+
+// AdminHandler method returns the handler of the admin API, to mount it on a server of the application instead of WithAdminAddress.
+// The API has no authentication, only serve it where untrusted clients cannot reach it. It never returns secret values.
+// Requests with an Origin header, which browsers send for web pages, and requests whose Host is not localhost or a loopback
+// address, as sent after DNS rebinding, are rejected with 403, so a web page cannot reach the API through the browser.
+//
+//	GET  /v1/paths          registered paths
+//	GET  /v1/status         status of the agent and all paths
+//	GET  /v1/status/{path}  status of one path
+//	POST /v1/refresh        sync all paths now, see Refresh
+//	POST /v1/pause          stop scheduled syncs, see Pause
+//	POST /v1/resume         resume scheduled syncs
+//	GET  /v1/token          accessor, identity and policies of the token of the agent, see TokenInfo
+//	GET  /v1/log-level      current log level and the levels set for components
+//	PUT  /v1/log-level      set the log level, with a body such as {"level": "debug"}, or the level of a component
+//	                        with {"component": "fetcher", "level": "debug"}, an empty level resets the component
+//
+// With WithDebugEndpoints it also serves DebugHandler under /debug/.
+func (a *Agent) AdminHandler() http.Handler {
+	return guardAdmin(a.adminMux(), false)
+}
+
+// guardAdmin function rejects the requests a web page can make through the browser of the operator: requests with an
+// Origin header, sent by browsers for cross-site requests, and, unless anyHost is set, requests whose Host is not
+// a loopback name, which is how DNS rebinding reaches a loopback server.
+func guardAdmin(next http.Handler, anyHost bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Origin") != "" {
+			writeError(w, http.StatusForbidden, fmt.Errorf("requests from browsers are not allowed"))
+			return
+		}
+		if !anyHost && !isLoopbackHost(r.Host) {
+			writeError(w, http.StatusForbidden, fmt.Errorf("host %q is not a loopback address", r.Host))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLoopbackHost function reports whether the Host of a request, with or without port, is localhost or a loopback address.
+func isLoopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// adminMux method returns the routes of the admin API.
+func (a *Agent) adminMux() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /v1/paths", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, a.trackedPaths())
+	})
+
+	mux.HandleFunc("GET /v1/status", func(w http.ResponseWriter, r *http.Request) {
+		status := adminStatus{Paused: a.Paused(), Paths: []adminPathStatus{}}
+		for _, ps := range a.Status() {
+			status.Paths = append(status.Paths, newAdminPathStatus(ps))
+		}
+		writeJSON(w, http.StatusOK, status)
+	})
+
+	mux.HandleFunc("GET /v1/status/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		path := r.PathValue("path")
+		for _, ps := range a.Status() {
+			if ps.Path == path {
+				writeJSON(w, http.StatusOK, newAdminPathStatus(ps))
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, fmt.Errorf("path %v is not registered", path))
+	})
+
+	mux.HandleFunc("POST /v1/refresh", func(w http.ResponseWriter, r *http.Request) {
+		a.Refresh()
+		a.log.Info("AdminHandler", slog.String("status", "refresh requested"))
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	mux.HandleFunc("POST /v1/pause", func(w http.ResponseWriter, r *http.Request) {
+		a.Pause()
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("POST /v1/resume", func(w http.ResponseWriter, r *http.Request) {
+		a.Resume()
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("GET /v1/token", func(w http.ResponseWriter, r *http.Request) {
+		info, err := a.TokenInfo(r.Context())
+		if errors.Is(err, ErrNotAuthenticated) {
+			writeError(w, http.StatusServiceUnavailable, err)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, info)
+	})
+
+	mux.HandleFunc("GET /v1/log-level", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, a.adminLogLevels())
+	})
+
+	mux.HandleFunc("PUT /v1/log-level", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Level     string `json:"level"`
+			Component string `json:"component"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if body.Component != "" && body.Level == "" {
+			if err := a.ResetComponentLogLevel(body.Component); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			a.log.Info("AdminHandler", slog.String("component", body.Component), slog.String("log level", "reset"))
+			writeJSON(w, http.StatusOK, a.adminLogLevels())
+			return
+		}
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(body.Level)); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if body.Component == "" {
+			a.SetLogLevel(level)
+		} else if err := a.SetComponentLogLevel(body.Component, level); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		a.log.Info("AdminHandler", slog.String("component", body.Component), slog.String("log level", level.String()))
+		writeJSON(w, http.StatusOK, a.adminLogLevels())
+	})
+
+	if a.debugEnabled() {
+		mux.Handle("/debug/", a.DebugHandler())
+	}
+
+	return mux
+}
+
+// writeJSON function writes v as the JSON body of a response.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError function writes err as the JSON body of a response.
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+// Refresh method asks the running agent to sync all paths now instead of waiting for their schedule. It does not wait for the sync,
+// requests made while a sync is pending are merged. Paths are synced even if the agent is paused.
+func (a *Agent) Refresh() {
+	a.requestSync()
+}
+
+// Pause method stops the scheduled syncs until Resume is called, for example while Vault is under maintenance.
+// Refresh still syncs all paths, and the authentication token is still renewed.
+func (a *Agent) Pause() {
+	a.mu.Lock()
+	a.paused = true
+	a.mu.Unlock()
+	a.log.Info("Pause", slog.String("status", "scheduled syncs paused"))
+}
+
+// Resume method resumes the scheduled syncs stopped by Pause.
+func (a *Agent) Resume() {
+	a.mu.Lock()
+	a.paused = false
+	a.mu.Unlock()
+	a.log.Info("Resume", slog.String("status", "scheduled syncs resumed"))
+}
+
+// Paused method reports whether the scheduled syncs are paused.
+func (a *Agent) Paused() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.paused
+}
@@ -0,0 +1,215 @@
+package vaultsync_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+// adminRequest function serves a request to handler from a loopback client and returns the response.
+func adminRequest(handler http.Handler, method string, target string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Host = "127.0.0.1:8202"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+// decodeJSON function decodes the body of a response into v.
+func decodeJSON(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAdminRoutes(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+	agent := vaultsynctest.NewAgent(t, s)
+	agent.RegisterUpdateSecret("secret/data/app", vaultsynctest.NewRecorder())
+	if err := agent.Run(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Stop()
+	handler := agent.AdminHandler()
+
+	t.Run("paths", func(t *testing.T) {
+		w := adminRequest(handler, "GET", "/v1/paths", "")
+		var paths []string
+		decodeJSON(t, w, &paths)
+		if w.Code != http.StatusOK || len(paths) != 1 || paths[0] != "secret/data/app" {
+			t.Fatalf("got %d %v", w.Code, paths)
+		}
+	})
+
+	t.Run("status", func(t *testing.T) {
+		w := adminRequest(handler, "GET", "/v1/status", "")
+		var status struct {
+			Paused bool `json:"paused"`
+			Paths  []struct {
+				Path    string `json:"path"`
+				Version int    `json:"version"`
+			} `json:"paths"`
+		}
+		decodeJSON(t, w, &status)
+		if w.Code != http.StatusOK || status.Paused || len(status.Paths) != 1 || status.Paths[0].Version != 1 {
+			t.Fatalf("got %d %+v", w.Code, status)
+		}
+		if strings.Contains(w.Body.String(), "s3cret") {
+			t.Fatal("status contains the secret")
+		}
+	})
+
+	t.Run("status of a path", func(t *testing.T) {
+		w := adminRequest(handler, "GET", "/v1/status/secret/data/app", "")
+		var status struct {
+			Path string `json:"path"`
+		}
+		decodeJSON(t, w, &status)
+		if w.Code != http.StatusOK || status.Path != "secret/data/app" {
+			t.Fatalf("got %d %+v", w.Code, status)
+		}
+		if w := adminRequest(handler, "GET", "/v1/status/secret/data/other", ""); w.Code != http.StatusNotFound {
+			t.Fatalf("got %d for an unregistered path, want 404", w.Code)
+		}
+	})
+
+	t.Run("refresh", func(t *testing.T) {
+		reads := s.Reads("secret/data/app")
+		if w := adminRequest(handler, "POST", "/v1/refresh", ""); w.Code != http.StatusAccepted {
+			t.Fatalf("got %d, want 202", w.Code)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for s.Reads("secret/data/app") == reads {
+			if time.Now().After(deadline) {
+				t.Fatal("no sync after refresh")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+
+	t.Run("pause and resume", func(t *testing.T) {
+		if w := adminRequest(handler, "POST", "/v1/pause", ""); w.Code != http.StatusNoContent || !agent.Paused() {
+			t.Fatalf("got %d paused %v", w.Code, agent.Paused())
+		}
+		if w := adminRequest(handler, "POST", "/v1/resume", ""); w.Code != http.StatusNoContent || agent.Paused() {
+			t.Fatalf("got %d paused %v", w.Code, agent.Paused())
+		}
+	})
+
+	t.Run("token", func(t *testing.T) {
+		w := adminRequest(handler, "GET", "/v1/token", "")
+		var info vaultsync.TokenInfo
+		decodeJSON(t, w, &info)
+		if w.Code != http.StatusOK || info.EntityID != vaultsynctest.EntityID || info.Accessor == "" {
+			t.Fatalf("got %d %+v", w.Code, info)
+		}
+		if strings.Contains(w.Body.String(), "hvs.") {
+			t.Fatal("token info contains the token")
+		}
+	})
+
+	t.Run("log level", func(t *testing.T) {
+		w := adminRequest(handler, "PUT", "/v1/log-level", `{"component": "fetcher", "level": "warn"}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("got %d %v", w.Code, w.Body)
+		}
+		w = adminRequest(handler, "GET", "/v1/log-level", "")
+		var levels struct {
+			Level      string            `json:"level"`
+			Components map[string]string `json:"components"`
+		}
+		decodeJSON(t, w, &levels)
+		if levels.Components[vaultsync.ComponentFetcher] != "WARN" {
+			t.Fatalf("got %+v", levels)
+		}
+		if w := adminRequest(handler, "PUT", "/v1/log-level", `{"level": "loud"}`); w.Code != http.StatusBadRequest {
+			t.Fatalf("got %d for an invalid level, want 400", w.Code)
+		}
+	})
+}
+
+func TestAdminRejectsBrowserRequests(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	agent := vaultsynctest.NewAgent(t, s)
+	handler := agent.AdminHandler()
+
+	for _, host := range []string{"localhost:8202", "127.0.0.1", "[::1]:8202"} {
+		r := httptest.NewRequest("GET", "/v1/paths", nil)
+		r.Host = host
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("got %d for host %v, want 200", w.Code, host)
+		}
+	}
+
+	// A page served by a host that resolves to 127.0.0.1 after DNS rebinding.
+	r := httptest.NewRequest("GET", "/v1/token", nil)
+	r.Host = "attacker.example:8202"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("got %d for a rebound host, want 403", w.Code)
+	}
+
+	// A cross-site form post from a page in the browser of the operator.
+	r = httptest.NewRequest("POST", "/v1/pause", nil)
+	r.Host = "127.0.0.1:8202"
+	r.Header.Set("Origin", "https://attacker.example")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden || agent.Paused() {
+		t.Fatalf("got %d paused %v for a cross-site request, want 403", w.Code, agent.Paused())
+	}
+}
+
+func TestAdminUnixSocket(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	socket := filepath.Join(t.TempDir(), "admin.sock")
+	agent := vaultsynctest.NewAgent(t, s, vaultsync.WithAdminAddress("unix://"+socket))
+	if err := agent.Run(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Stop()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	get := func(origin string) int {
+		r, err := http.NewRequest("GET", "http://unix/v1/paths", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		resp, err := client.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := get(""); code != http.StatusOK {
+		t.Fatalf("got %d over the unix socket, want 200", code)
+	}
+	if code := get("https://attacker.example"); code != http.StatusForbidden {
+		t.Fatalf("got %d with an Origin, want 403", code)
+	}
+}
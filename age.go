@@ -0,0 +1,90 @@
+package vaultsync
+
+import (
+	"log/slog"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// WithMaxSecretAge function sets how old a secret may get before it is reported as not rotated, for rotation-compliance
+// monitoring such as "this password hasn't rotated in 90 days". handler is called once with the path and its age when
+// a secret crosses maxAge, and again if it crosses it after the next rotation. It is called from the sync goroutine and
+// must not block, it may be nil to only log a warning. It takes precedence over max_secret_age in the configuration file.
+func WithMaxSecretAge(maxAge time.Duration, handler func(path string, age time.Duration)) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.maxSecretAge = maxAge
+		opts.maxAgeHandler = handler
+	}
+}
+
+// secretCreatedTime function returns the creation time of the version of a KV v2 secret, zero if it is unknown.
+func secretCreatedTime(secret *vault.Secret) time.Time {
+	if secret == nil {
+		return time.Time{}
+	}
+	metadata, ok := secret.Data["metadata"].(map[string]interface{})
+	if !ok {
+		return time.Time{}
+	}
+	s, _ := metadata["created_time"].(string)
+	created, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return created
+}
+
+// recordChange method records when the secret of a path last changed. That is the creation time of its KV v2 version
+// if Vault returns one, otherwise the time the agent first saw the current values.
+func (a *Agent) recordChange(path string, created time.Time, rotated bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state, ok := a.paths[path]
+	if !ok {
+		return
+	}
+	switch {
+	case !created.IsZero():
+		if !created.Equal(state.changedAt) {
+			state.changedAt = created
+			state.ageReported = false
+		}
+	case rotated || state.changedAt.IsZero():
+		state.changedAt = a.clock.Now()
+		state.ageReported = false
+	}
+}
+
+// reportSecretAges method sets the age gauge of every path whose last change is known
+// and reports the secrets that crossed the maximum age.
+func (a *Agent) reportSecretAges(log *slog.Logger) {
+	type oldSecret struct {
+		path string
+		age  time.Duration
+	}
+	var old []oldSecret
+
+	a.mu.Lock()
+	now := a.clock.Now()
+	for path, state := range a.paths {
+		if state.changedAt.IsZero() {
+			continue
+		}
+		age := now.Sub(state.changedAt)
+		a.metrics.SetGauge(metricSecretAge, age.Seconds(), pathLabels(path))
+		if a.maxSecretAge > 0 && age > a.maxSecretAge && !state.ageReported {
+			state.ageReported = true
+			old = append(old, oldSecret{path: path, age: age})
+		}
+	}
+	a.mu.Unlock()
+
+	for _, secret := range old {
+		log.Warn("reportSecretAges", slog.String("secret-path", secret.path), slog.String("status", "secret not rotated"), slog.Duration("age", secret.age), slog.Duration("max-age", a.maxSecretAge))
+		if a.maxAgeHandler != nil {
+			a.maxAgeHandler(secret.path, secret.age)
+		}
+	}
+}
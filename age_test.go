@@ -0,0 +1,70 @@
+package vaultsync_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+func TestMaxSecretAge(t *testing.T) {
+	m := vaultsynctest.NewMockClient()
+	m.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+
+	const maxAge = 90 * 24 * time.Hour
+	clock := vaultsynctest.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	var reported []time.Duration
+	agent := vaultsynctest.NewMockAgent(t, m, vaultsync.WithClock(clock), vaultsync.WithMaxSecretAge(maxAge, func(path string, age time.Duration) {
+		reported = append(reported, age)
+	}))
+	agent.RegisterUpdateSecret("secret/data/app", vaultsynctest.NewRecorder())
+	vaultsynctest.Sync(t, agent)
+
+	// Without a creation time from Vault the secret changed when the agent first saw it.
+	if status := agent.Status()[0]; !status.LastChange.Equal(clock.Now()) || status.Age != 0 {
+		t.Fatalf("new secret: got last change %v, age %v", status.LastChange, status.Age)
+	}
+
+	clock.Advance(maxAge + time.Hour)
+	vaultsynctest.Sync(t, agent)
+	if status := agent.Status()[0]; status.Age != maxAge+time.Hour {
+		t.Fatalf("old secret: got age %v", status.Age)
+	}
+	if len(reported) != 1 || reported[0] != maxAge+time.Hour {
+		t.Fatalf("got reports %v, want one report of the old secret", reported)
+	}
+
+	// An old secret is reported once, and again once it got old after a rotation.
+	vaultsynctest.Sync(t, agent)
+	if len(reported) != 1 {
+		t.Fatalf("got reports %v, want the old secret reported once", reported)
+	}
+	m.SetSecret("secret/data/app", map[string]interface{}{"password": "rotated"})
+	vaultsynctest.Sync(t, agent)
+	if status := agent.Status()[0]; status.Age != 0 {
+		t.Fatalf("rotated secret: got age %v", status.Age)
+	}
+	clock.Advance(maxAge + time.Hour)
+	vaultsynctest.Sync(t, agent)
+	if len(reported) != 2 {
+		t.Fatalf("got reports %v, want the rotated secret reported when it got old", reported)
+	}
+}
+
+func TestSecretAgeFromKVVersion(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+	created := time.Now()
+
+	// The secret was created before the agent started, its age is taken from the KV version.
+	clock := vaultsynctest.NewFakeClock(created.Add(24 * time.Hour))
+	agent := vaultsynctest.NewAgent(t, s, vaultsync.WithClock(clock))
+	agent.RegisterUpdateSecret("secret/data/app", vaultsynctest.NewRecorder())
+	vaultsynctest.Sync(t, agent)
+
+	if status := agent.Status()[0]; status.LastChange.Sub(created).Abs() > time.Minute || status.Age < 23*time.Hour {
+		t.Fatalf("got last change %v, age %v, want the creation time of the version", status.LastChange, status.Age)
+	}
+}
@@ -0,0 +1,141 @@
+package vaultsync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+)
+
+// auditLogSize is the number of rotation events kept in memory.
+const auditLogSize = 1000
+
+// RotationEvent struct describes a secret rotation. It never contains secret values.
+type RotationEvent struct {
+	CycleID   string    `json:"cycle_id"`  // Correlation ID of the sync cycle that detected the rotation.
+	Path      string    `json:"path"`      // Vault secret path.
+	Fields    []string  `json:"fields"`    // Names of the fields that were added, changed or removed.
+	Version   int       `json:"version"`   // KV v2 version of the rotated secret, 0 if unknown.
+	Timestamp time.Time `json:"timestamp"` // Time the rotation was synced.
+	Receivers []string  `json:"receivers"` // Types of the receivers that were notified.
+}
+
+// fingerprint function returns a SHA-256 hash of a secret value.
+func fingerprint(value interface{}) string {
+	b, err := json.Marshal(value)
+	if err != nil {
+		b = []byte(fmt.Sprint(value))
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// detectChanges method compares the fields of a secret with the previous sync of the path.
+// It returns the changed fields with the fingerprints of their old and new values, sorted by name, and true if the
+// secret was rotated, that is if the path has been synced before and at least one field changed.
+func (a *Agent) detectChanges(path string, data map[string]interface{}) ([]FieldChange, bool) {
+	fingerprints := make(map[string]string, len(data))
+	for key, value := range data {
+		fingerprints[key] = fingerprint(value)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state, ok := a.paths[path]
+	if !ok {
+		state = &pathState{}
+		a.paths[path] = state
+	}
+	previous := state.fingerprints
+
+	var changes []FieldChange
+	for key, sum := range fingerprints {
+		if previous[key] != sum {
+			changes = append(changes, FieldChange{Field: key, Old: previous[key], New: sum})
+		}
+	}
+	for key, sum := range previous {
+		if _, ok := fingerprints[key]; !ok {
+			changes = append(changes, FieldChange{Field: key, Old: sum})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+
+	// The values are redacted from the logs before they are dispatched. Only the values of this path are replaced,
+	// and only when they changed, which keeps a cycle over many paths linear.
+	if previous == nil || len(changes) > 0 || state.secretStrings == nil {
+		values := secretStrings(data)
+		a.secretValues.replace(state.secretStrings, values)
+		state.fingerprints = fingerprints
+		state.secretStrings = values
+	}
+	if previous == nil {
+		return nil, false
+	}
+	return changes, len(changes) > 0
+}
+
+// recordRotation method appends a rotation event to the audit log and returns the event.
+// If audit_file is configured the event is also appended to that file as a JSON line.
+func (a *Agent) recordRotation(cycleID string, path string, fields []string, version int) RotationEvent {
+	event := RotationEvent{
+		CycleID:   cycleID,
+		Path:      path,
+		Fields:    fields,
+		Version:   version,
+		Timestamp: a.clock.Now(),
+	}
+	for _, receiver := range a.secretSync.receivers[path] {
+		event.Receivers = append(event.Receivers, receiverName(receiver))
+	}
+
+	a.mu.Lock()
+	a.auditLog = append(a.auditLog, event)
+	if len(a.auditLog) > auditLogSize {
+		a.auditLog = a.auditLog[len(a.auditLog)-auditLogSize:]
+	}
+	a.mu.Unlock()
+
+	log := a.logs.dispatcher.With(slog.String("cycle", cycleID))
+	log.Info("recordRotation", slog.String("secret-path", path), slog.Any("fields", fields), slog.Int("version", version))
+
+	if a.config.Vault.AuditFile != "" {
+		if err := appendAuditFile(a.config.Vault.AuditFile, event); err != nil {
+			log.Error("recordRotation", slog.String("audit-file", a.config.Vault.AuditFile), slog.Any("error", err))
+		}
+	}
+
+	return event
+}
+
+// appendAuditFile function appends a rotation event as a JSON line to the given file.
+func appendAuditFile(filename string, event RotationEvent) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(b, '\n'))
+	return err
+}
+
+// AuditLog method returns the most recent rotation events, oldest first.
+func (a *Agent) AuditLog() []RotationEvent {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	events := make([]RotationEvent, len(a.auditLog))
+	copy(events, a.auditLog)
+	return events
+}
@@ -0,0 +1,64 @@
+package vaultsync
+
+import "time"
+
+// AuthEventKind is the kind of an AuthEvent.
+type AuthEventKind string
+
+// Kinds of AuthEvent.
+const (
+	AuthEventRenewed         AuthEventKind = "renewed"         // The token was renewed.
+	AuthEventReauthenticated AuthEventKind = "reauthenticated" // The agent logged in again and has a new token.
+	AuthEventExpiring        AuthEventKind = "expiring"        // The token can no longer be renewed, the agent logs in again.
+)
+
+// AuthEvent struct describes a change of the authentication token of the agent. It never contains the token.
+type AuthEvent struct {
+	Kind       AuthEventKind // Kind of the event.
+	AuthMethod string        // Authentication method of the agent.
+	Renewable  bool          // Whether the token can be renewed.
+	TTL        time.Duration // Remaining TTL of the token, 0 if it does not expire or has expired.
+	Err        error         // For expiring events, the error that made the renewal fail, nil if the token reached its max TTL.
+	Time       time.Time     // Time of the event.
+}
+
+// authEventsBuffer is the number of events AuthEvents buffers for a slow reader.
+const authEventsBuffer = 16
+
+// AuthEvents method returns a channel that receives an AuthEvent whenever the token of the agent is renewed, the agent
+// logs in again, or the token can no longer be renewed and is about to expire, so applications can show the state of
+// their Vault token in their own dashboards. Every call returns the same channel, which must be requested before Run
+// and is closed once the agent has stopped. Events are buffered, those that do not fit because the channel is not
+// read are dropped and counted in vaultsync.auth.events_dropped.
+func (a *Agent) AuthEvents() <-chan AuthEvent {
+	if a.authEvents == nil {
+		a.authEvents = make(chan AuthEvent, authEventsBuffer)
+	}
+	return a.authEvents
+}
+
+// sendAuthEvent method sends an event to the AuthEvents channel without blocking the token goroutine.
+func (a *Agent) sendAuthEvent(event AuthEvent) {
+	if a.authEvents == nil {
+		return
+	}
+	event.AuthMethod = a.config.Vault.AuthMethod
+	event.Time = a.clock.Now()
+	select {
+	case a.authEvents <- event:
+	default:
+		a.metrics.IncrCounter(metricAuthEventDrops, 1, map[string]string{"kind": string(event.Kind)})
+	}
+}
+
+// authExpiring method sends an expiring event for a token with ttl remaining that can no longer be renewed.
+func (a *Agent) authExpiring(renewable bool, ttl time.Duration, err error) {
+	a.sendAuthEvent(AuthEvent{Kind: AuthEventExpiring, Renewable: renewable, TTL: max(ttl, 0), Err: err})
+}
+
+// closeAuthEvents method closes the AuthEvents channel once the token goroutine has stopped.
+func (a *Agent) closeAuthEvents() {
+	if a.authEvents != nil {
+		close(a.authEvents)
+	}
+}
@@ -0,0 +1,53 @@
+package vaultsync_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+func TestAuthEvents(t *testing.T) {
+	m := vaultsynctest.NewMockClient()
+	m.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+	clock := vaultsynctest.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	agent := vaultsynctest.NewMockAgent(t, m, vaultsync.WithClock(clock))
+	agent.RegisterUpdateSecret("secret/data/app", vaultsynctest.NewRecorder())
+	events := agent.AuthEvents()
+	if err := agent.Run(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The token of the mock is not renewable and expires after an hour, the agent logs in again after two thirds of it.
+	var got []vaultsync.AuthEvent
+	for elapsed := time.Duration(0); len(got) < 2; elapsed += time.Minute {
+		if elapsed >= 59*time.Minute {
+			t.Fatalf("got events %+v, want expiring and reauthenticated", got)
+		}
+		clock.Advance(time.Minute)
+		time.Sleep(20 * time.Millisecond)
+	drain:
+		for {
+			select {
+			case event := <-events:
+				got = append(got, event)
+			default:
+				break drain
+			}
+		}
+	}
+
+	expiring, login := got[0], got[1]
+	if expiring.Kind != vaultsync.AuthEventExpiring || expiring.Renewable || expiring.TTL != 20*time.Minute || expiring.Err != nil {
+		t.Fatalf("got %+v, want an expiring event with 20m left", expiring)
+	}
+	if login.Kind != vaultsync.AuthEventReauthenticated || login.TTL != time.Hour || login.Time.IsZero() {
+		t.Fatalf("got %+v, want a reauthenticated event with a new TTL of an hour", login)
+	}
+
+	agent.Stop()
+	for range events {
+	}
+}
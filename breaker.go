@@ -0,0 +1,118 @@
+package vaultsync
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned instead of calling Vault while the circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open, vault is not called")
+
+// circuitBreakerConfig struct defines the circuit breaker around Vault calls.
+type circuitBreakerConfig struct {
+	FailureThreshold int      `hcl:"failure_threshold,optional"`
+	ResetTimeout     duration `hcl:"reset_timeout,optional"`
+}
+
+// circuitState type defines the state of a circuit breaker.
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuitBreaker struct stops calls to Vault after consecutive failures. Once the reset timeout has passed a single
+// probe call is let through; the circuit closes if it succeeds and opens again if it fails.
+// A nil circuitBreaker lets all calls through.
+type circuitBreaker struct {
+	threshold    int
+	resetTimeout time.Duration
+	clock        Clock
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+}
+
+// newCircuitBreaker function creates a circuit breaker, it returns nil if no circuit breaker is configured.
+// The failure threshold defaults to 5 and the reset timeout to 60 seconds.
+func newCircuitBreaker(cbc *circuitBreakerConfig, clock Clock) *circuitBreaker {
+	if cbc == nil {
+		return nil
+	}
+	cb := &circuitBreaker{threshold: cbc.FailureThreshold, resetTimeout: cbc.ResetTimeout.value(), clock: clock}
+	if cb.threshold <= 0 {
+		cb.threshold = 5
+	}
+	if cb.resetTimeout <= 0 {
+		cb.resetTimeout = 60 * time.Second
+	}
+	return cb
+}
+
+// allow method returns ErrCircuitOpen if a call must not be made.
+func (cb *circuitBreaker) allow() error {
+	if cb == nil {
+		return nil
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case circuitOpen:
+		if cb.clock.Now().Sub(cb.openedAt) < cb.resetTimeout {
+			return ErrCircuitOpen
+		}
+		cb.state = circuitHalfOpen
+		return nil
+	case circuitHalfOpen:
+		// A probe is in flight.
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// record method records the outcome of a call. Only errors indicating that Vault is unavailable count as failures.
+// It reports whether the circuit opened or closed, and whether it is open.
+func (cb *circuitBreaker) record(err error) (changed bool, open bool) {
+	if cb == nil {
+		return false, false
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if !classifyError(err).unavailable() {
+		cb.failures = 0
+		changed = cb.state != circuitClosed
+		cb.state = circuitClosed
+		return changed, false
+	}
+
+	cb.failures++
+	if cb.state == circuitHalfOpen || (cb.state == circuitClosed && cb.failures >= cb.threshold) {
+		changed = cb.state == circuitClosed
+		cb.state = circuitOpen
+		cb.openedAt = cb.clock.Now()
+	}
+	return changed, cb.state == circuitOpen
+}
+
+// recordVaultCall method records the outcome of a Vault call with the circuit breaker and logs state changes.
+func (a *Agent) recordVaultCall(err error) {
+	changed, open := a.breaker.record(err)
+	if !changed {
+		return
+	}
+	if open {
+		a.metrics.SetGauge(metricCircuitOpen, 1, nil)
+		a.log.Warn("circuitBreaker", slog.String("status", "open"), slog.Any("error", err))
+	} else {
+		a.metrics.SetGauge(metricCircuitOpen, 0, nil)
+		a.log.Info("circuitBreaker", slog.String("status", "closed"))
+	}
+}
@@ -0,0 +1,87 @@
+package vaultsync_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+// newBreakerAgent function creates an agent with a circuit breaker that opens after two failures and probes after a minute.
+func newBreakerAgent(t *testing.T, s *vaultsynctest.Server, clock *vaultsynctest.FakeClock) *vaultsync.Agent {
+	t.Helper()
+
+	filename, err := s.WriteConfig(t.TempDir(), 3600, `
+  circuit_breaker {
+    failure_threshold = 2
+    reset_timeout     = "1m"
+  }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent, err := vaultsync.New(vaultsync.WithConfigFile(filename), vaultsync.WithClock(clock), vaultsync.WithMaxRetries(0), quiet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent.RegisterUpdateSecret("secret/data/app", vaultsynctest.NewRecorder())
+	return agent
+}
+
+func TestCircuitBreakerOpensAndProbes(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+	clock := vaultsynctest.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	agent := newBreakerAgent(t, s, clock)
+	ctx := context.Background()
+
+	s.SetFailing(true)
+	agent.SyncOnce(ctx)
+	agent.SyncOnce(ctx)
+	s.SetFailing(false)
+
+	// The circuit is open, Vault is not called although it is available again.
+	agent.SyncOnce(ctx)
+	if err := agent.Status()[0].LastError; !errors.Is(err, vaultsync.ErrCircuitOpen) {
+		t.Fatalf("got %v, want ErrCircuitOpen", err)
+	}
+	if reads := s.Reads("secret/data/app"); reads != 0 {
+		t.Fatalf("got %d reads while the circuit is open", reads)
+	}
+
+	// After the reset timeout a probe is let through and closes the circuit.
+	clock.Advance(time.Minute)
+	if err := agent.SyncOnce(ctx); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if err := agent.SyncOnce(ctx); err != nil {
+		t.Fatalf("sync after the circuit closed: %v", err)
+	}
+	if reads := s.Reads("secret/data/app"); reads != 2 {
+		t.Fatalf("got %d reads, want the probe and the next sync", reads)
+	}
+}
+
+func TestCircuitBreakerIgnoresCanceledRequests(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+	clock := vaultsynctest.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	agent := newBreakerAgent(t, s, clock)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 3; i++ {
+		agent.SyncOnce(canceled)
+	}
+	if status := agent.Status(); status[0].ErrorKind != vaultsync.ErrorKindCanceled {
+		t.Fatalf("got error kind %q, want canceled", status[0].ErrorKind)
+	}
+
+	if err := agent.SyncOnce(context.Background()); err != nil {
+		t.Fatalf("canceled requests opened the circuit: %v", err)
+	}
+}
@@ -0,0 +1,204 @@
+package vaultsync
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// cacheConfig struct defines the encrypted on-disk cache of the last synced secrets.
+type cacheConfig struct {
+	Path    string `hcl:"path"`
+	KeyFile string `hcl:"key_file"`
+}
+
+// cacheEntry struct is the cached state of a secret path.
+type cacheEntry struct {
+	Version  int                    `json:"version"`
+	SyncedAt time.Time              `json:"synced_at"`
+	Data     map[string]interface{} `json:"data"`
+	Binary   []string               `json:"binary,omitempty"` // Fields holding []byte, stored in Data as base64.
+}
+
+// decodeBinary method turns the binary fields of the entry, which JSON stores as base64, back into []byte.
+func (e cacheEntry) decodeBinary() error {
+	for _, field := range e.Binary {
+		s, ok := e.Data[field].(string)
+		if !ok {
+			return fmt.Errorf("binary field %v is not base64", field)
+		}
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return fmt.Errorf("binary field %v:%w", field, err)
+		}
+		e.Data[field] = b
+	}
+	return nil
+}
+
+// cacheKey function reads the 256 bit AES key of the cache.
+func cacheKey(filename string) ([]byte, error) {
+	key, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("cache key %v must be 32 bytes, got %d", filename, len(key))
+	}
+	return key, nil
+}
+
+// cacheCipher function creates the AES-GCM cipher of the cache.
+func cacheCipher(filename string) (cipher.AEAD, error) {
+	key, err := cacheKey(filename)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	clear(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// writeCache method encrypts the data of all synced paths and writes it to the cache file.
+func (a *Agent) writeCache(log *slog.Logger) {
+	cc := a.config.Vault.Cache
+	if cc == nil {
+		return
+	}
+
+	a.mu.RLock()
+	entries := make(map[string]cacheEntry, len(a.paths))
+	for path, state := range a.paths {
+		if state.data == nil {
+			continue
+		}
+		entry := cacheEntry{Version: state.version, SyncedAt: state.syncedAt(), Data: make(map[string]interface{}, len(state.data))}
+		for field, value := range state.data {
+			value = unsealValue(value)
+			if _, ok := value.([]byte); ok {
+				entry.Binary = append(entry.Binary, field)
+			}
+			entry.Data[field] = value
+		}
+		entries[path] = entry
+	}
+	a.mu.RUnlock()
+
+	if err := writeCacheFile(cc, entries); err != nil {
+		log.Error("writeCache", slog.String("cache", cc.Path), slog.Any("error", err))
+	}
+}
+
+// writeCacheFile function encrypts entries with AES-GCM and writes them atomically, readable only by the owner.
+func writeCacheFile(cc *cacheConfig, entries map[string]cacheEntry) error {
+	aead, err := cacheCipher(cc.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	ciphertext := aead.Seal(nonce, nonce, plaintext, nil)
+	clear(plaintext)
+
+	return writeFileAtomic(cc.Path, ciphertext, fileOwner{mode: 0600, uid: -1, gid: -1})
+}
+
+// readCacheFile function reads and decrypts the cache file.
+func readCacheFile(cc *cacheConfig) (map[string]cacheEntry, error) {
+	aead, err := cacheCipher(cc.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := os.ReadFile(cc.Path)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("cache %v is truncated", cc.Path)
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cache %v cannot be decrypted:%w", cc.Path, err)
+	}
+	defer clear(plaintext)
+
+	var entries map[string]cacheEntry
+	if err := json.Unmarshal(plaintext, &entries); err != nil {
+		return nil, err
+	}
+	for path, entry := range entries {
+		if err := entry.decodeBinary(); err != nil {
+			return nil, fmt.Errorf("cache %v path %v:%w", cc.Path, path, err)
+		}
+	}
+	return entries, nil
+}
+
+// loadCache method reads the cache after the initial login failed, so Run can start with the cached secrets.
+func (a *Agent) loadCache() error {
+	cc := a.config.Vault.Cache
+	if cc == nil {
+		return fmt.Errorf("no cache configured")
+	}
+	entries, err := readCacheFile(cc)
+	if err != nil {
+		return err
+	}
+	a.cached = entries
+	return nil
+}
+
+// startFromCache method dispatches the cached secrets of the registered paths to the receivers and flushes the sinks.
+// The paths are not marked as synced, they stay stale and WaitReady waits until they are synced from Vault.
+func (a *Agent) startFromCache(log *slog.Logger) {
+	paths := a.trackedPaths()
+	failed := make(map[string]error)
+	for _, path := range paths {
+		entry, ok := a.cached[path]
+		if !ok {
+			failed[path] = ErrSecretNotFound
+			log.Warn("startFromCache", slog.String("secret-path", path), slog.String("status", "not cached"))
+			continue
+		}
+		stored, err := a.sealData(entry.Data)
+		if err != nil {
+			failed[path] = err
+			log.Error("startFromCache", slog.String("secret-path", path), slog.Any("error", err))
+			continue
+		}
+		changes, _ := a.detectChanges(path, entry.Data)
+		a.dispatch(log, path, entry.Data, entry.Version, deletedFields(changedFields(changes), entry.Data))
+
+		a.mu.Lock()
+		state := a.paths[path]
+		state.cachedAt = entry.SyncedAt
+		state.version = entry.Version
+		destroyData(state.data)
+		state.data = stored
+		a.mu.Unlock()
+	}
+	a.cached = nil
+
+	a.dispatchGroups(log, paths, failed)
+	a.flushSinks(log)
+	log.Warn("startFromCache", slog.String("status", "started with cached secrets, vault is unreachable"))
+}
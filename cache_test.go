@@ -0,0 +1,132 @@
+package vaultsync_test
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+// writeCacheConfig function writes a configuration of the server with an encrypted cache and returns its file name.
+func writeCacheConfig(t *testing.T, s *vaultsynctest.Server) string {
+	t.Helper()
+	dir := t.TempDir()
+	key := make([]byte, 32)
+	rand.Read(key)
+	keyFile := filepath.Join(dir, "cache.key")
+	if err := os.WriteFile(keyFile, key, 0600); err != nil {
+		t.Fatal(err)
+	}
+	filename, err := s.WriteConfig(dir, 3600, fmt.Sprintf(`
+  cache {
+    path     = %q
+    key_file = %q
+  }`, filepath.Join(dir, "cache"), keyFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestStartFromCache(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+
+	filename := writeCacheConfig(t, s)
+
+	// A first agent syncs and writes the cache.
+	first, err := vaultsync.New(vaultsync.WithConfigFile(filename), quiet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.RegisterUpdateSecret("secret/data/app", vaultsynctest.NewRecorder())
+	if err := first.SyncOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A second agent starts while Vault is unavailable.
+	s.SetFailing(true)
+	clock := vaultsynctest.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	agent, err := vaultsync.New(vaultsync.WithConfigFile(filename), vaultsync.WithClock(clock), vaultsync.WithMaxRetries(0), quiet)
+	if err != nil {
+		t.Fatalf("start from cache: %v", err)
+	}
+	recorder := vaultsynctest.NewRecorder()
+	agent.RegisterUpdateSecret("secret/data/app", recorder)
+	if err := agent.Run(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Stop()
+	recorder.AssertValue(t, "secret/data/app", "password", "s3cret")
+
+	status := agent.Status()[0]
+	if !status.LastSync.IsZero() || status.CachedAt.IsZero() || !status.Stale {
+		t.Fatalf("cached path: got last sync %v, cached at %v, stale %v", status.LastSync, status.CachedAt, status.Stale)
+	}
+	if agent.Health() == nil {
+		t.Fatal("agent serving cached values is healthy")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := agent.WaitReady(ctx); err == nil {
+		t.Fatal("WaitReady returned before the path was synced from Vault")
+	}
+
+	// Once Vault is back the agent logs in and syncs.
+	s.SetFailing(false)
+	s.SetField("secret/data/app", "password", "rotated")
+	deadline := time.After(5 * time.Second)
+	for ready := false; !ready; {
+		select {
+		case <-agent.Ready():
+			ready = true
+		case <-deadline:
+			t.Fatal("agent did not sync after Vault became available")
+		case <-time.After(10 * time.Millisecond):
+			clock.Advance(10 * time.Second)
+		}
+	}
+	recorder.AssertValue(t, "secret/data/app", "password", "rotated")
+	if status := agent.Status()[0]; status.LastSync.IsZero() || !status.CachedAt.IsZero() || status.Stale {
+		t.Fatalf("synced path: got last sync %v, cached at %v, stale %v", status.LastSync, status.CachedAt, status.Stale)
+	}
+}
+
+func TestCacheKeepsBinaryFields(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"keystore": "/u3+7QAC"})
+	filename := writeCacheConfig(t, s)
+
+	first, err := vaultsync.New(vaultsync.WithConfigFile(filename), quiet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.RegisterTransform("secret/data/app", vaultsync.DecodeBase64("keystore"))
+	first.RegisterUpdateSecret("secret/data/app", vaultsynctest.NewRecorder())
+	if err := first.SyncOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Receivers of an agent started from the cache get the bytes, not their base64 encoding.
+	s.SetFailing(true)
+	agent, err := vaultsync.New(vaultsync.WithConfigFile(filename), vaultsync.WithMaxRetries(0), quiet)
+	if err != nil {
+		t.Fatalf("start from cache: %v", err)
+	}
+	agent.RegisterTransform("secret/data/app", vaultsync.DecodeBase64("keystore"))
+	recorder := vaultsynctest.NewRecorder()
+	agent.RegisterUpdateSecret("secret/data/app", recorder)
+	if err := agent.Run(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Stop()
+	recorder.AssertValue(t, "secret/data/app", "keystore", []byte{0xfe, 0xed, 0xfe, 0xed, 0x00, 0x02})
+}
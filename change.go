@@ -0,0 +1,70 @@
+package vaultsync
+
+import "time"
+
+// FieldChange struct describes a field of a secret that was added, changed or removed. It never contains the values,
+// only their fingerprints as returned by Fingerprint.
+type FieldChange struct {
+	Field string // Name of the field.
+	Old   string // Fingerprint of the previous value, empty if the field was added.
+	New   string // Fingerprint of the new value, empty if the field was removed.
+}
+
+// SecretChange struct describes a rotation of a secret, passed to the hooks of OnChange. It never contains secret values.
+type SecretChange struct {
+	CycleID   string        // Correlation ID of the sync cycle that detected the change.
+	Path      string        // Vault secret path.
+	Version   int           // KV v2 version of the new secret, 0 if unknown.
+	Fields    []FieldChange // Changed fields, sorted by name.
+	Timestamp time.Time     // Time the change was synced.
+}
+
+// Changed method reports whether a field was added, changed or removed.
+func (c SecretChange) Changed(field string) bool {
+	for _, fc := range c.Fields {
+		if fc.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// OnChange method registers a hook that is called when the secret of a path changed, after its receivers got the new
+// values. The change lists the fields that changed with fingerprints of their old and new values, so the application can
+// decide how to react, e.g. only log in again when the password changed but the user did not, instead of rebuilding a
+// connection pool. The first sync of a path is not a change. Hooks are called in registration order from the sync
+// goroutine, must not block and must be registered before Run.
+func (a *Agent) OnChange(id string, hook func(change SecretChange)) {
+	id = a.expandPath(id)
+	a.changeHooks[id] = append(a.changeHooks[id], hook)
+}
+
+// runChangeHooks method calls the change hooks of the path of a rotation.
+func (a *Agent) runChangeHooks(event RotationEvent, changes []FieldChange) {
+	hooks := a.changeHooks[event.Path]
+	if len(hooks) == 0 {
+		return
+	}
+	change := SecretChange{
+		CycleID:   event.CycleID,
+		Path:      event.Path,
+		Version:   event.Version,
+		Fields:    changes,
+		Timestamp: event.Timestamp,
+	}
+	for _, hook := range hooks {
+		hook(change)
+	}
+}
+
+// changedFields function returns the names of the changed fields.
+func changedFields(changes []FieldChange) []string {
+	if len(changes) == 0 {
+		return nil
+	}
+	fields := make([]string, len(changes))
+	for i, fc := range changes {
+		fields[i] = fc.Field
+	}
+	return fields
+}
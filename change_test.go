@@ -0,0 +1,49 @@
+package vaultsync_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+func TestOnChange(t *testing.T) {
+	m := vaultsynctest.NewMockClient()
+	m.SetSecret("secret/data/db", map[string]interface{}{"user": "app", "password": "first", "old": "x"})
+
+	agent := vaultsynctest.NewMockAgent(t, m)
+	agent.RegisterUpdateSecret("secret/data/db", vaultsynctest.NewRecorder())
+	var changes []vaultsync.SecretChange
+	agent.OnChange("secret/data/db", func(change vaultsync.SecretChange) {
+		changes = append(changes, change)
+	})
+
+	vaultsynctest.Sync(t, agent)
+	vaultsynctest.Sync(t, agent)
+	if len(changes) != 0 {
+		t.Fatalf("got %v changes without a rotation", len(changes))
+	}
+
+	m.SetSecret("secret/data/db", map[string]interface{}{"user": "app", "password": "second", "new": "y"})
+	vaultsynctest.Sync(t, agent)
+	if len(changes) != 1 {
+		t.Fatalf("got %v changes, want 1", len(changes))
+	}
+
+	change := changes[0]
+	want := []vaultsync.FieldChange{
+		{Field: "new", New: vaultsync.Fingerprint("y")},
+		{Field: "old", Old: vaultsync.Fingerprint("x")},
+		{Field: "password", Old: vaultsync.Fingerprint("first"), New: vaultsync.Fingerprint("second")},
+	}
+	if !reflect.DeepEqual(change.Fields, want) {
+		t.Fatalf("got fields %+v, want %+v", change.Fields, want)
+	}
+	if change.Path != "secret/data/db" || change.Version != 2 || change.CycleID == "" {
+		t.Fatalf("got change %+v", change)
+	}
+	if !change.Changed("password") || change.Changed("user") {
+		t.Fatal("Changed does not match the changed fields")
+	}
+}
@@ -0,0 +1,211 @@
+package vaultsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrNoChild is returned by RunChild if no child process is configured.
+var ErrNoChild = errors.New("no child process configured")
+
+// defaultKillTimeout is the time a child process gets to exit before it is killed.
+const defaultKillTimeout = 10 * time.Second
+
+// childConfig struct defines a child process that gets the selected secrets as environment variables.
+// When a secret changes the child is restarted, or sent reload_signal if it is set.
+type childConfig struct {
+	Command      []string          `hcl:"command"`
+	ReloadSignal string            `hcl:"reload_signal,optional"`
+	KillTimeout  duration          `hcl:"kill_timeout,optional"`
+	Secrets      []envSecretConfig `hcl:"secret,block"`
+}
+
+// childSink struct tracks the environment of the child process.
+type childSink struct {
+	config childConfig
+
+	mu      sync.Mutex
+	fields  secretFields
+	env     []string
+	changed chan struct{}
+}
+
+// newChildSink function creates the sink of a child process.
+func newChildSink(config childConfig) (*childSink, error) {
+	if len(config.Command) == 0 {
+		return nil, fmt.Errorf("child has no command")
+	}
+	if config.ReloadSignal != "" {
+		if _, err := parseSignal(config.ReloadSignal); err != nil {
+			return nil, err
+		}
+	}
+
+	return &childSink{
+		config:  config,
+		fields:  newSecretFields(),
+		changed: make(chan struct{}, 1),
+	}, nil
+}
+
+// UpdateSecret method collects a field of a selected secret.
+func (cs *childSink) UpdateSecret(id string, fieldName string, value interface{}) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.fields.set(id, fieldName, value)
+}
+
+// Flush method rebuilds the environment if a selected secret changed and signals the supervisor.
+func (cs *childSink) Flush() error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.fields.prune()
+	if !cs.fields.dirty || !cs.fields.has(envSecretPaths(cs.config.Secrets)...) {
+		return nil
+	}
+
+	vars, err := envVars(cs.config.Secrets, &cs.fields)
+	if err != nil {
+		return err
+	}
+	cs.env = make([]string, 0, len(vars))
+	for name, value := range vars {
+		cs.env = append(cs.env, name+"="+value)
+	}
+	cs.fields.clean()
+
+	select {
+	case cs.changed <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// environment method returns the secret environment variables, or nil if the secrets are not synced yet.
+func (cs *childSink) environment() []string {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.env == nil {
+		return nil
+	}
+	return append([]string(nil), cs.env...)
+}
+
+// RunChild method runs the configured child process with the selected secrets in its environment.
+// Run must have been called first so the secrets are synced. When a secret changes the child is restarted,
+// or sent the reload signal if one is configured. RunChild returns the exit code of the child when it exits
+// on its own, or after terminating it when ctx is cancelled.
+func (a *Agent) RunChild(ctx context.Context) (int, error) {
+	cs := a.child
+	if cs == nil {
+		return 1, ErrNoChild
+	}
+
+	// Drop the change notification of the initial sync, the first start uses those values.
+	select {
+	case <-cs.changed:
+	default:
+	}
+
+	for {
+		env := cs.environment()
+		if env == nil {
+			return 1, fmt.Errorf("secrets of child process are not synced")
+		}
+
+		cmd := exec.Command(cs.config.Command[0], cs.config.Command[1:]...)
+		cmd.Env = append(os.Environ(), env...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Start(); err != nil {
+			return 1, err
+		}
+		a.logs.sinks.Info("RunChild", slog.String("status", "started"), slog.Int("pid", cmd.Process.Pid))
+
+		done := make(chan error, 1)
+		go func() { done <- cmd.Wait() }()
+
+		restart, err := a.superviseChild(ctx, cs, cmd, done)
+		if !restart {
+			return exitCode(cmd, err), nil
+		}
+		a.logs.sinks.Info("RunChild", slog.String("status", "restarting"))
+	}
+}
+
+// superviseChild method waits for the child to exit, the context to be cancelled or the secrets to change.
+// It returns true if the child was stopped to be restarted.
+func (a *Agent) superviseChild(ctx context.Context, cs *childSink, cmd *exec.Cmd, done chan error) (bool, error) {
+	for {
+		select {
+		case err := <-done:
+			a.logs.sinks.Info("RunChild", slog.String("status", "exited"), slog.Int("exit code", exitCode(cmd, err)))
+			return false, err
+
+		case <-ctx.Done():
+			return false, stopChild(cmd, done, cs.config.KillTimeout.value())
+
+		case <-cs.changed:
+			if cs.config.ReloadSignal == "" {
+				stopChild(cmd, done, cs.config.KillTimeout.value())
+				return true, nil
+			}
+
+			sig, _ := parseSignal(cs.config.ReloadSignal)
+			if err := cmd.Process.Signal(sig); err != nil {
+				a.logs.sinks.Error("RunChild", slog.String("signal", cs.config.ReloadSignal), slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// stopChild function asks the child to terminate and kills it if it hasn't exited within the kill timeout.
+func stopChild(cmd *exec.Cmd, done chan error, killTimeout time.Duration) error {
+	if killTimeout <= 0 {
+		killTimeout = defaultKillTimeout
+	}
+
+	if sig, err := parseSignal("SIGTERM"); err == nil {
+		cmd.Process.Signal(sig)
+	} else {
+		cmd.Process.Kill()
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(killTimeout):
+		cmd.Process.Kill()
+		return <-done
+	}
+}
+
+// exitCode function returns the exit code of a finished command, 128 plus the signal number if it was killed by a signal.
+func exitCode(cmd *exec.Cmd, err error) int {
+	if cmd.ProcessState == nil {
+		if err != nil {
+			return 1
+		}
+		return 0
+	}
+	if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) || err == nil {
+		return cmd.ProcessState.ExitCode()
+	}
+	return 1
+}
@@ -0,0 +1,174 @@
+package vaultsync
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	cleanhttp "github.com/hashicorp/go-cleanhttp"
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+	vault "github.com/hashicorp/vault/api"
+)
+
+// httpConfig struct defines the HTTP transport used to reach Vault.
+type httpConfig struct {
+	Proxy           string   `hcl:"proxy,optional"`
+	DialTimeout     duration `hcl:"dial_timeout,optional"`
+	KeepAlive       duration `hcl:"keep_alive,optional"`
+	IdleConnTimeout duration `hcl:"idle_conn_timeout,optional"`
+	MaxIdleConns    int      `hcl:"max_idle_conns,optional"`
+
+	Timeout      duration `hcl:"timeout,optional"`
+	MaxRetries   *int     `hcl:"max_retries,optional"`
+	MinRetryWait duration `hcl:"min_retry_wait,optional"`
+	MaxRetryWait duration `hcl:"max_retry_wait,optional"`
+	Backoff      string   `hcl:"backoff,optional"`
+}
+
+// WithHTTPClient function sets the HTTP client used to reach Vault, for example with a custom transport or proxy.
+// It takes precedence over the http block of the configuration file.
+func WithHTTPClient(client *http.Client) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.httpClient = client
+	}
+}
+
+// WithHeader function adds a header sent with every request to Vault, for example a tenant tag for a gateway in front of Vault.
+// It can be given several times and adds to the headers of the configuration file.
+func WithHeader(name string, value string) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		if opts.headers == nil {
+			opts.headers = make(http.Header)
+		}
+		opts.headers.Add(name, value)
+	}
+}
+
+// addHeaders method adds the headers of the configuration file and of WithHeader to the client.
+func (a *Agent) addHeaders() {
+	for name, value := range a.config.Vault.Headers {
+		a.client.AddHeader(name, value)
+	}
+	for name, values := range a.headers {
+		for _, value := range values {
+			a.client.AddHeader(name, value)
+		}
+	}
+}
+
+// newVaultConfig method creates the configuration of the Vault client.
+func (a *Agent) newVaultConfig() (*vault.Config, error) {
+	cfg := &vault.Config{
+		Address: a.config.Vault.Server,
+		// Clients cloned for health checks and standby reads send the custom headers too.
+		CloneHeaders: true,
+	}
+
+	switch {
+	case a.httpClient != nil:
+		cfg.HttpClient = a.httpClient
+	case a.config.Vault.HTTP != nil:
+		client, err := newHTTPClient(a.config.Vault.HTTP)
+		if err != nil {
+			return nil, err
+		}
+		cfg.HttpClient = client
+	}
+
+	if hc := a.config.Vault.HTTP; hc != nil {
+		cfg.Timeout = hc.Timeout.value()
+		cfg.MinRetryWait = hc.MinRetryWait.value()
+		cfg.MaxRetryWait = hc.MaxRetryWait.value()
+		if hc.MaxRetries != nil {
+			cfg.MaxRetries = *hc.MaxRetries
+		}
+		backoff, err := parseBackoff(hc.Backoff)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Backoff = backoff
+	}
+	if a.timeout != nil {
+		cfg.Timeout = *a.timeout
+	}
+	if a.maxRetries != nil {
+		cfg.MaxRetries = *a.maxRetries
+	}
+
+	return cfg, nil
+}
+
+// WithTimeout function sets the timeout of every request to Vault. It takes precedence over timeout in the http block.
+func WithTimeout(timeout time.Duration) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.timeout = &timeout
+	}
+}
+
+// WithMaxRetries function sets how often a request to Vault that failed with a server error or rate limiting is retried.
+// It takes precedence over max_retries in the http block.
+func WithMaxRetries(retries int) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.maxRetries = &retries
+	}
+}
+
+// parseBackoff function returns the backoff between retries, linear_jitter or exponential.
+// An empty name selects the default of the Vault client, linear_jitter.
+func parseBackoff(name string) (retryablehttp.Backoff, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case "linear_jitter":
+		return retryablehttp.LinearJitterBackoff, nil
+	case "exponential":
+		return retryablehttp.DefaultBackoff, nil
+	}
+	return nil, fmt.Errorf("invalid backoff %q", name)
+}
+
+// newHTTPClient function creates an HTTP client from the http block. Unset values keep the defaults of the Vault client,
+// and without a proxy the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are used.
+func newHTTPClient(hc *httpConfig) (*http.Client, error) {
+	transport := cleanhttp.DefaultPooledTransport()
+
+	if hc.Proxy != "" {
+		proxy, err := url.Parse(hc.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q:%w", hc.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	dialTimeout, keepAlive := hc.DialTimeout.value(), hc.KeepAlive.value()
+	if dialTimeout > 0 || keepAlive > 0 {
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}
+		if dialTimeout > 0 {
+			dialer.Timeout = dialTimeout
+		}
+		if keepAlive > 0 {
+			dialer.KeepAlive = keepAlive
+		}
+		transport.DialContext = dialer.DialContext
+	}
+	if idleConnTimeout := hc.IdleConnTimeout.value(); idleConnTimeout > 0 {
+		transport.IdleConnTimeout = idleConnTimeout
+	}
+	if hc.MaxIdleConns > 0 {
+		transport.MaxIdleConns = hc.MaxIdleConns
+		transport.MaxIdleConnsPerHost = hc.MaxIdleConns
+	}
+
+	return &http.Client{
+		Transport: transport,
+		// Vault handles redirects itself.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}, nil
+}
@@ -0,0 +1,65 @@
+package vaultsync_test
+
+import (
+	"testing"
+
+	vault "github.com/hashicorp/vault/api"
+
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+// benchmarkClient function returns a Vault client logged in to s.
+func benchmarkClient(b *testing.B, s *vaultsynctest.Server) *vault.Client {
+	b.Helper()
+	cfg := vault.DefaultConfig()
+	cfg.Address = s.URL
+	client, err := vault.NewClient(cfg)
+	if err != nil {
+		b.Fatal(err)
+	}
+	secret, err := client.Logical().Write("auth/approle/login", map[string]interface{}{
+		"role_id":   vaultsynctest.Username,
+		"secret_id": vaultsynctest.Password,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	client.SetToken(secret.Auth.ClientToken)
+	return client
+}
+
+// BenchmarkParallelReads measures concurrent reads through the single client shared by the goroutines of the agent
+// against a client cloned per worker, for sizing parallel fetching.
+func BenchmarkParallelReads(b *testing.B) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"username": "app", "password": "s3cret"})
+	client := benchmarkClient(b, s)
+
+	b.Run("shared", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := client.Logical().Read("secret/data/app"); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+	b.Run("clone", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			worker, err := client.CloneWithHeaders()
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			worker.SetToken(client.Token())
+			for pb.Next() {
+				if _, err := worker.Logical().Read("secret/data/app"); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+}
@@ -0,0 +1,53 @@
+package vaultsync
+
+import "time"
+
+// Clock interface abstracts the time source of the agent: the renew period, token TTL waits, login retries,
+// webhook backoff, the circuit breaker and the timestamps of the sync status. Tests can replace it with a fake clock,
+// such as vaultsynctest.FakeClock, to drive these deterministically instead of sleeping.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer interface is the part of time.Timer used by the agent.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// WithClock function sets the clock of the agent. It defaults to the system clock.
+func WithClock(clock Clock) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.clock = clock
+	}
+}
+
+// systemClock struct implements Clock with the time package.
+type systemClock struct{}
+
+// Now method returns the current local time.
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer method creates a timer that fires after d.
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+// systemTimer struct implements Timer with time.Timer.
+type systemTimer struct {
+	*time.Timer
+}
+
+// C method returns the channel the time is delivered on when the timer fires.
+func (t systemTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+// after function returns a channel that receives the time of clock once d has elapsed, like time.After.
+func after(clock Clock, d time.Duration) <-chan time.Time {
+	return clock.NewTimer(d).C()
+}
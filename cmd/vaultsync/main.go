@@ -0,0 +1,343 @@
+// Command vaultsync runs the vaultsync agent as a standalone daemon driven by a configuration file.
+//
+// Usage:
+//
+//	vaultsync run      [-config file] [-log-level level] [-status-file file]
+//	vaultsync once     [-config file] [-log-level level] [-timeout duration]
+//	vaultsync validate [-config file]
+//	vaultsync preflight [-config file] [-log-level level]
+//	vaultsync status   [-status-file file]
+//
+// Exit codes are 0 on success, 1 on errors such as failed authentication, 2 on usage errors
+// and 3 if once fails to sync or preflight finds unreadable paths.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pergus/vaultsync"
+)
+
+const (
+	defaultConfigFile = "vault-config.hcl"
+	defaultStatusFile = "vaultsync-status.json"
+
+	// statusInterval is how often run writes the status file.
+	statusInterval = 10 * time.Second
+)
+
+// Exit codes.
+const (
+	exitOK         = 0
+	exitError      = 1
+	exitUsage      = 2
+	exitSyncFailed = 3
+)
+
+// pathStatus struct is the JSON form of vaultsync.PathStatus written to the status file.
+type pathStatus struct {
+	Path       string                    `json:"path"`
+	LastSync   time.Time                 `json:"last_sync"`
+	LastError  string                    `json:"last_error,omitempty"`
+	ErrorKind  vaultsync.ErrorKind       `json:"error_kind,omitempty"`
+	Version    int                       `json:"version"`
+	Stale      bool                      `json:"stale"`
+	StaleSince time.Time                 `json:"stale_since"`
+	History    []vaultsync.VersionRecord `json:"history,omitempty"`
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// run function dispatches to the subcommand and returns the exit code.
+func run(args []string) int {
+	if len(args) == 0 {
+		usage()
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "run":
+		return cmdRun(args[1:])
+	case "once":
+		return cmdOnce(args[1:])
+	case "validate":
+		return cmdValidate(args[1:])
+	case "preflight":
+		return cmdPreflight(args[1:])
+	case "status":
+		return cmdStatus(args[1:])
+	case "help", "-h", "-help", "--help":
+		usage()
+		return exitOK
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+		usage()
+		return exitUsage
+	}
+}
+
+// usage function prints the list of subcommands.
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: vaultsync <command> [flags]
+
+Commands:
+  run       sync secrets continuously until interrupted
+  once      sync all secrets once and exit
+  validate  validate the configuration file without contacting Vault
+  preflight check that the token can read every secret path, without reading secrets
+  status    print the status written by a running agent
+
+Run "vaultsync <command> -h" for the flags of a command.`)
+}
+
+// cmdRun function runs the agent until SIGINT or SIGTERM. If a child process is configured
+// the agent runs it and exits with its exit code.
+func cmdRun(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	configFile := flags.String("config", defaultConfigFile, "configuration file")
+	logLevel := flags.String("log-level", "info", "log level: debug, info, warn or error")
+	statusFile := flags.String("status-file", defaultStatusFile, "file the status of all secret paths is written to, empty to disable")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	vs, err := newAgent(*configFile, *logLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return exitError
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	if err := vs.Run(ctx, &wg); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return exitError
+	}
+
+	if *statusFile != "" {
+		wg.Add(1)
+		go writeStatusPeriodically(ctx, &wg, vs, *statusFile)
+	}
+
+	code, err := vs.RunChild(ctx)
+	switch {
+	case errors.Is(err, vaultsync.ErrNoChild):
+		<-ctx.Done()
+		code = exitOK
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "error:", err)
+		code = exitError
+	}
+
+	cancel()
+	wg.Wait()
+	return code
+}
+
+// cmdOnce function syncs all secrets once. It exits with exitError if the agent can't be created,
+// for example because authentication failed, and with exitSyncFailed if any path failed to sync.
+func cmdOnce(args []string) int {
+	flags := flag.NewFlagSet("once", flag.ContinueOnError)
+	configFile := flags.String("config", defaultConfigFile, "configuration file")
+	logLevel := flags.String("log-level", "info", "log level: debug, info, warn or error")
+	timeout := flags.Duration("timeout", 5*time.Minute, "maximum time to spend syncing")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	vs, err := newAgent(*configFile, *logLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return exitError
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	err = vs.SyncOnce(ctx)
+	var syncErr *vaultsync.SyncError
+	switch {
+	case errors.As(err, &syncErr):
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return exitSyncFailed
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// cmdValidate function validates the configuration file.
+func cmdValidate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	configFile := flags.String("config", defaultConfigFile, "configuration file")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if err := vaultsync.ValidateConfigFile(*configFile); err != nil {
+		fmt.Fprintf(os.Stderr, "%v: %v\n", *configFile, err)
+		return exitError
+	}
+	fmt.Printf("%v: ok\n", *configFile)
+	return exitOK
+}
+
+// cmdPreflight function authenticates and reports for every secret path whether the token can read it.
+// It exits with exitSyncFailed if any path is not readable.
+func cmdPreflight(args []string) int {
+	flags := flag.NewFlagSet("preflight", flag.ContinueOnError)
+	configFile := flags.String("config", defaultConfigFile, "configuration file")
+	logLevel := flags.String("log-level", "warn", "log level: debug, info, warn or error")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	vs, err := newAgent(*configFile, *logLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return exitError
+	}
+
+	results, err := vs.Preflight(context.Background())
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tRESULT\tCAPABILITIES\tERROR")
+	for _, result := range results {
+		outcome := "pass"
+		if !result.Readable {
+			outcome = "FAIL"
+		}
+		errText := ""
+		if result.Err != nil {
+			errText = result.Err.Error()
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", result.Path, outcome, strings.Join(result.Capabilities, ","), errText)
+	}
+	w.Flush()
+
+	if err != nil {
+		return exitSyncFailed
+	}
+	return exitOK
+}
+
+// cmdStatus function prints the status file written by a running agent.
+// It exits with an error code if any path is stale or failed its last sync.
+func cmdStatus(args []string) int {
+	flags := flag.NewFlagSet("status", flag.ContinueOnError)
+	statusFile := flags.String("status-file", defaultStatusFile, "status file written by vaultsync run")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	b, err := os.ReadFile(*statusFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return exitError
+	}
+	var statuses []pathStatus
+	if err := json.Unmarshal(b, &statuses); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v: %v\n", *statusFile, err)
+		return exitError
+	}
+
+	code := exitOK
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tVERSION\tLAST SYNC\tSTALE\tERROR")
+	for _, status := range statuses {
+		lastSync := "never"
+		if !status.LastSync.IsZero() {
+			lastSync = status.LastSync.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", status.Path, status.Version, lastSync, status.Stale, status.LastError)
+		if status.Stale || status.LastError != "" {
+			code = exitError
+		}
+	}
+	w.Flush()
+	return code
+}
+
+// newAgent function creates an agent logging JSON to stderr at the given level.
+// The agent notifies systemd when it runs as a Type=notify service.
+func newAgent(configFile string, logLevel string) (*vaultsync.Agent, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.ToUpper(logLevel))); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", logLevel)
+	}
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+
+	return vaultsync.New(vaultsync.WithConfigFile(configFile), vaultsync.WithLogger(logger), vaultsync.WithSystemdNotify())
+}
+
+// writeStatusPeriodically function writes the status file until ctx is cancelled, and once more before returning.
+func writeStatusPeriodically(ctx context.Context, wg *sync.WaitGroup, vs *vaultsync.Agent, filename string) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(statusInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := writeStatus(vs, filename); err != nil {
+			fmt.Fprintln(os.Stderr, "error: writing status file:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// writeStatus function writes the status of all paths as JSON, replacing the file atomically.
+func writeStatus(vs *vaultsync.Agent, filename string) error {
+	var statuses []pathStatus
+	for _, status := range vs.Status() {
+		ps := pathStatus{
+			Path:       status.Path,
+			LastSync:   status.LastSync,
+			Version:    status.Version,
+			Stale:      status.Stale,
+			StaleSince: status.StaleSince,
+			ErrorKind:  status.ErrorKind,
+		}
+		// The status file never contains secret values.
+		for _, record := range status.History {
+			record.Data = nil
+			ps.History = append(ps.History, record)
+		}
+		if status.LastError != nil {
+			ps.LastError = status.LastError.Error()
+		}
+		statuses = append(statuses, ps)
+	}
+
+	b, err := json.MarshalIndent(statuses, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
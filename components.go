@@ -0,0 +1,147 @@
+package vaultsync
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// Components of the agent. Each logs with a component attribute holding its name, and its level can be set on its own
+// with WithComponentLogLevel or SetComponentLogLevel.
+const (
+	ComponentAuth       = "auth"       // Login, token renewal and revocation.
+	ComponentScheduler  = "scheduler"  // Sync cycles and the renew loop.
+	ComponentFetcher    = "fetcher"    // Reading secrets and watching their leases.
+	ComponentDispatcher = "dispatcher" // Delivering secrets to receivers, hooks, notifiers and subscribers.
+	ComponentSinks      = "sinks"      // Files, templates, plugins and the child process.
+)
+
+// components are the names of the components of the agent.
+var components = []string{ComponentAuth, ComponentScheduler, ComponentFetcher, ComponentDispatcher, ComponentSinks}
+
+// componentLevel struct is the log level of a component, the level of the agent unless it is overridden.
+type componentLevel struct {
+	set      atomic.Bool
+	level    slog.LevelVar
+	fallback slog.Leveler
+}
+
+// Level method implements slog.Leveler.
+func (l *componentLevel) Level() slog.Level {
+	if l.set.Load() {
+		return l.level.Level()
+	}
+	return l.fallback.Level()
+}
+
+// componentLoggers struct holds the logger of every component.
+type componentLoggers struct {
+	auth       *slog.Logger
+	scheduler  *slog.Logger
+	fetcher    *slog.Logger
+	dispatcher *slog.Logger
+	sinks      *slog.Logger
+}
+
+// with method returns the loggers with the attributes added, e.g. the ID of a sync cycle.
+func (l componentLoggers) with(attrs ...any) componentLoggers {
+	return componentLoggers{
+		auth:       l.auth.With(attrs...),
+		scheduler:  l.scheduler.With(attrs...),
+		fetcher:    l.fetcher.With(attrs...),
+		dispatcher: l.dispatcher.With(attrs...),
+		sinks:      l.sinks.With(attrs...),
+	}
+}
+
+// WithComponentLogLevel function sets the log level of a component, one of the Component constants, instead of the level
+// of the agent, e.g. debug for the fetcher without the chatter of the token renewals. Levels are parsed like the admin API,
+// e.g. debug, info, warn or error. Unknown components and levels make New fail.
+func WithComponentLogLevel(component string, level string) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		if opts.componentLogLevels == nil {
+			opts.componentLogLevels = make(map[string]string)
+		}
+		opts.componentLogLevels[component] = level
+	}
+}
+
+// SetComponentLogLevel method changes the log level of a component of the running agent.
+func (a *Agent) SetComponentLogLevel(component string, level slog.Level) error {
+	cl, ok := a.componentLevels[component]
+	if !ok {
+		return unknownComponent(component)
+	}
+	cl.level.Set(level)
+	cl.set.Store(true)
+	return nil
+}
+
+// ResetComponentLogLevel method makes a component log at the level of the agent again.
+func (a *Agent) ResetComponentLogLevel(component string) error {
+	cl, ok := a.componentLevels[component]
+	if !ok {
+		return unknownComponent(component)
+	}
+	cl.set.Store(false)
+	return nil
+}
+
+// ComponentLogLevels method returns the log levels of the components whose level is set instead of the level of the agent.
+func (a *Agent) ComponentLogLevels() map[string]slog.Level {
+	levels := make(map[string]slog.Level)
+	for component, cl := range a.componentLevels {
+		if cl.set.Load() {
+			levels[component] = cl.level.Level()
+		}
+	}
+	return levels
+}
+
+// unknownComponent function returns the error for a component that doesn't exist.
+func unknownComponent(component string) error {
+	known := append([]string(nil), components...)
+	sort.Strings(known)
+	return fmt.Errorf("unknown log component %q, use one of %v", component, strings.Join(known, ", "))
+}
+
+// newLoggers method builds the logger of the agent and of its components on the handler of the configured logger.
+// The levels filter before the handler, which never sees secret values.
+func (a *Agent) newLoggers(handler slog.Handler) error {
+	a.componentLevels = make(map[string]*componentLevel, len(components))
+	for _, component := range components {
+		a.componentLevels[component] = &componentLevel{fallback: a.logLevelVar}
+	}
+	for component, level := range a.componentLogLevels {
+		var l slog.Level
+		if err := l.UnmarshalText([]byte(level)); err != nil {
+			return fmt.Errorf("log level of component %v:%w", component, err)
+		}
+		if err := a.SetComponentLogLevel(component, l); err != nil {
+			return err
+		}
+	}
+
+	logger := func(level slog.Leveler) *slog.Logger {
+		log := slog.New(newRedactingHandler(newLevelHandler(handler, level), func() *secretValues { return a.secretValues }))
+		if a.agentName != "" {
+			log = log.With(slog.String("agent", a.agentName))
+		}
+		return log
+	}
+	component := func(name string) *slog.Logger {
+		return logger(a.componentLevels[name]).With(slog.String("component", name))
+	}
+
+	a.log = logger(a.logLevelVar)
+	a.logs = componentLoggers{
+		auth:       component(ComponentAuth),
+		scheduler:  component(ComponentScheduler),
+		fetcher:    component(ComponentFetcher),
+		dispatcher: component(ComponentDispatcher),
+		sinks:      component(ComponentSinks),
+	}
+	return nil
+}
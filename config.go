@@ -0,0 +1,319 @@
+package vaultsync
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclsimple"
+	vault "github.com/hashicorp/vault/api"
+)
+
+// ValidateConfigFile function loads and validates a configuration file without contacting Vault.
+// Every profile of the file is validated.
+func ValidateConfigFile(filename string) error {
+	if _, err := os.Stat(filename); err != nil {
+		return err
+	}
+
+	if strings.HasSuffix(filename, ".json") {
+		c := &config{}
+		if err := hclsimple.DecodeFile(filename, nil, c); err != nil {
+			return err
+		}
+		c.setDefaults()
+		return c.validate()
+	}
+
+	profiles, body, err := parseProfiles(filename)
+	if err != nil {
+		return err
+	}
+	if len(profiles) == 0 {
+		return fmt.Errorf("no config block")
+	}
+	if block, ok := profiles[""]; ok {
+		c := &config{}
+		if err := decodeProfile(body, block, c); err != nil {
+			return err
+		}
+		c.setDefaults()
+		if err := c.validate(); err != nil {
+			return err
+		}
+	}
+	for _, profile := range profileNames(profiles) {
+		c := &config{}
+		if err := decodeProfile(body, profiles[profile], c); err != nil {
+			return fmt.Errorf("profile %v: %w", profile, err)
+		}
+		c.setDefaults()
+		if err := c.validate(); err != nil {
+			return fmt.Errorf("profile %v: %w", profile, err)
+		}
+	}
+	return nil
+}
+
+// validate method checks the configuration for errors that can be found without contacting Vault.
+func (c *config) validate() error {
+	v := c.Vault
+
+	if v.Server == "" {
+		return fmt.Errorf("server is not set, set it in the configuration file or with %v", vault.EnvVaultAddress)
+	}
+	switch v.AuthMethod {
+	case "approle", "ldap", "userpass":
+		if v.Username == "" || v.Password == "" {
+			return fmt.Errorf("authentication method %v needs a username and a password", v.AuthMethod)
+		}
+	case "token":
+		if v.RevokeTokenOnStop {
+			return fmt.Errorf("revoke_token_on_stop cannot be used with token, the token belongs to the user")
+		}
+	case "token_file", "agent_proxy":
+		if v.AuthMethod == "token_file" && v.TokenFile == "" {
+			return fmt.Errorf("authentication method token_file needs a token_file")
+		}
+		if v.RevokeTokenOnStop {
+			return fmt.Errorf("revoke_token_on_stop cannot be used with %v, the token belongs to the Vault Agent", v.AuthMethod)
+		}
+	default:
+		return fmt.Errorf("undefined vault authentication method %q", v.AuthMethod)
+	}
+	if strings.HasPrefix(v.Server, "unix://") && (v.ReadServer != "" || len(v.FailoverServers) > 0) {
+		return fmt.Errorf("read_server and failover_servers cannot be used with a unix socket server")
+	}
+	if err := v.validateDurations(); err != nil {
+		return err
+	}
+	if v.RenewSecretsPeriod.value() <= 0 {
+		return fmt.Errorf("renew_secrets_period must be positive")
+	}
+	if _, err := parseRenewBehavior(v.TokenRenewBehavior); err != nil {
+		return err
+	}
+	if err := validateStartupPreflight(v.StartupPreflight); err != nil {
+		return err
+	}
+	if v.HTTP != nil {
+		if _, err := newHTTPClient(v.HTTP); err != nil {
+			return err
+		}
+		if _, err := parseBackoff(v.HTTP.Backoff); err != nil {
+			return err
+		}
+		if v.HTTP.MaxRetries != nil && *v.HTTP.MaxRetries < 0 {
+			return fmt.Errorf("http max_retries must not be negative")
+		}
+	}
+	if v.MaxSecretSize < 0 || v.MaxSecretKeys < 0 {
+		return fmt.Errorf("max_secret_size and max_secret_keys must not be negative")
+	}
+	for mount, version := range v.KVMounts {
+		if version != 1 && version != 2 {
+			return fmt.Errorf("kv_mounts: mount %v has invalid KV version %d", mount, version)
+		}
+	}
+	for path, spec := range v.Extractors {
+		if _, err := parseExtractor(spec); err != nil {
+			return fmt.Errorf("extractors: path %v:%w", path, err)
+		}
+	}
+	if _, err := newScheduler(0, v.SyncSchedule, v.PathSchedules); err != nil {
+		return err
+	}
+	if v.Cache != nil && (v.Cache.Path == "" || v.Cache.KeyFile == "") {
+		return fmt.Errorf("cache needs a path and a key_file")
+	}
+
+	for _, webhook := range v.Webhooks {
+		if webhook.URL == "" {
+			return fmt.Errorf("webhook has no url")
+		}
+	}
+
+	for _, tc := range v.Templates {
+		if _, err := newTemplateSink(tc, nil); err != nil {
+			return fmt.Errorf("template %v: %w", tc.Destination, err)
+		}
+	}
+
+	for _, ec := range v.EnvFiles {
+		if _, err := newEnvFileSink(ec); err != nil {
+			return fmt.Errorf("env file %v: %w", ec.Destination, err)
+		}
+	}
+
+	for _, dc := range v.Directories {
+		if _, err := parseFileOwner(dc.Perms, dc.Owner, dc.Group, 0600); err != nil {
+			return fmt.Errorf("directory %v: %w", dc.Destination, err)
+		}
+	}
+
+	if v.Child != nil {
+		if _, err := newChildSink(*v.Child); err != nil {
+			return err
+		}
+	}
+
+	for _, pc := range v.Plugins {
+		if err := pc.validate(v.PluginDirectory); err != nil {
+			return err
+		}
+	}
+
+	if v.PushServer != nil {
+		if err := v.PushServer.validate(); err != nil {
+			return err
+		}
+	}
+	for _, ic := range v.Identities {
+		if err := ic.validate(); err != nil {
+			return err
+		}
+	}
+	if v.Admin != nil {
+		if err := validateAdminAddress(v.Admin.Address); err != nil {
+			return err
+		}
+	}
+
+	for _, ec := range v.Execs {
+		if len(ec.Command) == 0 {
+			return fmt.Errorf("exec for %v has no command", ec.Path)
+		}
+	}
+
+	for _, sc := range v.Signals {
+		if err := sc.validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyConfig method sets up the notifiers, sinks and hooks declared in the configuration.
+func (a *Agent) applyConfig() error {
+	a.resolvePathVariables()
+	if err := a.expandConfigPaths(); err != nil {
+		return err
+	}
+	if err := a.applyExtractors(); err != nil {
+		return err
+	}
+
+	a.breaker = newCircuitBreaker(a.config.Vault.CircuitBreaker, a.clock)
+	if a.config.Vault.RevokeTokenOnStop {
+		a.revokeTokenOnStop = true
+	}
+	if a.config.Vault.RevokeLeasesOnStop {
+		a.revokeLeasesOnStop = true
+	}
+	if a.config.Vault.ManageLeases {
+		a.manageLeases = true
+	}
+	if a.expiryWarning == 0 {
+		a.expiryWarning = a.config.Vault.ExpiryWarning.value()
+	}
+	if a.maxSecretAge == 0 {
+		a.maxSecretAge = a.config.Vault.MaxSecretAge.value()
+	}
+	if a.maxSecretSize == 0 {
+		a.maxSecretSize = a.config.Vault.MaxSecretSize
+	}
+	if a.maxSecretKeys == 0 {
+		a.maxSecretKeys = a.config.Vault.MaxSecretKeys
+	}
+	for mount, version := range a.config.Vault.KVMounts {
+		mount = strings.Trim(mount, "/")
+		if _, ok := a.kvMounts[mount]; !ok {
+			if a.kvMounts == nil {
+				a.kvMounts = make(map[string]int)
+			}
+			a.kvMounts[mount] = version
+		}
+	}
+	for mount, version := range a.kvMounts {
+		if version != 1 && version != 2 {
+			return fmt.Errorf("mount %v has invalid KV version %d", mount, version)
+		}
+	}
+	if err := a.newScheduler(); err != nil {
+		return err
+	}
+
+	for _, webhook := range a.config.Vault.Webhooks {
+		a.notifiers = append(a.notifiers, newWebhookNotifier(webhook, a.clock))
+	}
+
+	for _, tc := range a.config.Vault.Templates {
+		ts, err := newTemplateSink(tc, a.ExpandPath)
+		if err != nil {
+			return fmt.Errorf("failed to load template %v:%v", tc.Destination, err)
+		}
+		a.RegisterSink(ts, ts.paths...)
+	}
+
+	for _, ec := range a.config.Vault.EnvFiles {
+		es, err := newEnvFileSink(ec)
+		if err != nil {
+			return fmt.Errorf("failed to load env file %v:%v", ec.Destination, err)
+		}
+		a.RegisterSink(es, es.paths()...)
+	}
+
+	for _, dc := range a.config.Vault.Directories {
+		ds, err := newDirectorySink(dc)
+		if err != nil {
+			return fmt.Errorf("failed to create directory %v:%v", dc.Destination, err)
+		}
+		a.RegisterSink(ds, dc.Path)
+	}
+
+	if a.config.Vault.Child != nil {
+		child, err := newChildSink(*a.config.Vault.Child)
+		if err != nil {
+			return err
+		}
+		a.child = child
+		a.RegisterSink(child, envSecretPaths(child.config.Secrets)...)
+	}
+
+	if err := a.newIdentities(); err != nil {
+		return err
+	}
+
+	if err := a.newPushServer(); err != nil {
+		return err
+	}
+
+	if err := a.startPlugins(); err != nil {
+		return err
+	}
+
+	// Paths with hooks are synced even if nothing else registers them.
+	for _, ec := range a.config.Vault.Execs {
+		a.trackPath(ec.Path)
+	}
+	for _, sc := range a.config.Vault.Signals {
+		a.trackPath(sc.Path)
+	}
+
+	return nil
+}
+
+// parseRenewBehavior function converts token_renew_behavior to the behavior of the token lifetime watcher.
+// It defaults to ignore_errors, which keeps renewing until the token expires.
+func parseRenewBehavior(behavior string) (vault.RenewBehavior, error) {
+	switch behavior {
+	case "", "ignore_errors":
+		return vault.RenewBehaviorIgnoreErrors, nil
+	case "error_on_errors":
+		return vault.RenewBehaviorErrorOnErrors, nil
+	case "renew_disabled":
+		return vault.RenewBehaviorRenewDisabled, nil
+	}
+	return 0, fmt.Errorf("invalid token_renew_behavior %q", behavior)
+}
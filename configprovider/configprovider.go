@@ -0,0 +1,133 @@
+// Package configprovider exposes secrets synced by a vaultsync agent to configuration libraries.
+//
+// Provider implements the koanf Provider interface and can merge secrets into a viper instance,
+// without importing either library.
+package configprovider
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/pergus/vaultsync"
+)
+
+// ViperMerger interface is the subset of *viper.Viper used to merge secrets into its configuration.
+type ViperMerger interface {
+	MergeConfigMap(cfg map[string]any) error
+}
+
+// Provider struct maps secret paths to configuration keys. With the mapping
+// {"secrets/data/app/db": "database"} the field password of the secret is available as database.password.
+type Provider struct {
+	agent    *vaultsync.Agent
+	mappings map[string]string
+
+	mu       sync.Mutex
+	values   map[string]interface{}
+	changed  bool
+	watchers []func(event interface{}, err error)
+}
+
+// New function creates a provider and registers it with the agent so it is told when the mapped secrets change.
+// It must be called before Run.
+func New(agent *vaultsync.Agent, mappings map[string]string) *Provider {
+	p := &Provider{
+		agent:    agent,
+		mappings: mappings,
+		values:   make(map[string]interface{}),
+	}
+
+	paths := make([]string, 0, len(mappings))
+	for path := range mappings {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	agent.RegisterSink(p, paths...)
+
+	return p
+}
+
+// Read method returns the mapped secrets as a nested map. It implements koanf.Provider.
+func (p *Provider) Read() (map[string]interface{}, error) {
+	config := make(map[string]interface{}, len(p.mappings))
+	for path, key := range p.mappings {
+		data, ok := p.agent.Secret(path)
+		if !ok {
+			return nil, fmt.Errorf("secret %v is not synced", path)
+		}
+		config[key] = data
+	}
+	return config, nil
+}
+
+// ReadBytes method returns the mapped secrets as JSON. It implements koanf.Provider, use it with a JSON parser.
+func (p *Provider) ReadBytes() ([]byte, error) {
+	config, err := p.Read()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(config)
+}
+
+// Watch method calls cb after every sync cycle in which a mapped secret changed, so the caller can reload.
+// The event is always nil. It follows the koanf watch convention.
+func (p *Provider) Watch(cb func(event interface{}, err error)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.watchers = append(p.watchers, cb)
+	return nil
+}
+
+// BindViper method merges the mapped secrets into v now and again whenever they change.
+// Viper is not safe for concurrent use, callers reading v from other goroutines must synchronize.
+func (p *Provider) BindViper(v ViperMerger) error {
+	merge := func() error {
+		config, err := p.Read()
+		if err != nil {
+			return err
+		}
+		return v.MergeConfigMap(config)
+	}
+
+	if err := merge(); err != nil {
+		return err
+	}
+	return p.Watch(func(event interface{}, err error) {
+		if err == nil {
+			merge()
+		}
+	})
+}
+
+// UpdateSecret method records whether a field of a mapped secret changed. It implements vaultsync.SecretReceiver.
+func (p *Provider) UpdateSecret(id string, fieldName string, value interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := id + "\x00" + fieldName
+	if old, ok := p.values[key]; !ok || !reflect.DeepEqual(old, value) {
+		p.values[key] = value
+		p.changed = true
+	}
+}
+
+// Flush method notifies the watchers if a mapped secret changed. It implements vaultsync.SecretSink.
+func (p *Provider) Flush() error {
+	p.mu.Lock()
+	changed := p.changed
+	p.changed = false
+	watchers := append([]func(event interface{}, err error){}, p.watchers...)
+	p.mu.Unlock()
+
+	if !changed {
+		return nil
+	}
+	for _, cb := range watchers {
+		cb(nil, nil)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package vaultsync
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+// Names of the background loops whose state is shown by the debug endpoints.
+const (
+	loopRenewSecrets  = "renewSecrets"
+	loopRenewAuth     = "renewAuthToken"
+	loopStateWaiting  = "waiting"
+	loopStateSyncing  = "syncing"
+	loopStateRenewing = "renewing token"
+	loopStateLogin    = "logging in"
+	loopStateRetry    = "waiting to log in again"
+)
+
+// WithDebugEndpoints function adds the pprof profiles under /debug/pprof/ and the sync statistics under /debug/vars to the admin API,
+// to diagnose a stuck agent without attaching a debugger. It can also be enabled with debug in the admin block of the configuration file.
+func WithDebugEndpoints() AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.debugEndpoints = true
+	}
+}
+
+// loopState struct is the state of a background loop and the time it entered it.
+type loopState struct {
+	State string    `json:"state"`
+	Since time.Time `json:"since"`
+}
+
+// setLoopState method records the state of a background loop.
+func (a *Agent) setLoopState(loop string, state string) {
+	ls := loopState{State: state, Since: a.clock.Now()}
+	a.loops.Set(loop, expvar.Func(func() any { return ls }))
+}
+
+// loopState method returns the state of a background loop, it returns false if the loop has not started.
+func (a *Agent) loopState(loop string) (loopState, bool) {
+	v, ok := a.loops.Get(loop).(expvar.Func)
+	if !ok {
+		return loopState{}, false
+	}
+	ls, ok := v().(loopState)
+	return ls, ok
+}
+
+// debugVars method returns the sync statistics of the agent: counters of the sync cycles and token renewals, the state
+// of the background loops and the number of goroutines of the process.
+func (a *Agent) debugVars() *expvar.Map {
+	vars := new(expvar.Map)
+	vars.Set("stats", &a.stats)
+	vars.Set("loops", &a.loops)
+	vars.Set("goroutines", expvar.Func(func() any { return runtime.NumGoroutine() }))
+	return vars
+}
+
+// debugEnabled method reports whether the admin API serves the debug endpoints.
+func (a *Agent) debugEnabled() bool {
+	return a.debugEndpoints || (a.config.Vault.Admin != nil && a.config.Vault.Admin.Debug)
+}
+
+// DebugHandler method returns a handler serving the pprof profiles under /debug/pprof/ and, under /debug/vars, the variables
+// published with expvar together with the sync statistics of the agent under vaultsync. Statistics of several agents
+// in one process are kept apart, they are not published with expvar.
+func (a *Agent) DebugHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	mux.HandleFunc("GET /debug/vars", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fmt.Fprintf(w, "{\n")
+		expvar.Do(func(kv expvar.KeyValue) {
+			fmt.Fprintf(w, "%q: %s,\n", kv.Key, kv.Value)
+		})
+		fmt.Fprintf(w, "%q: %s\n}\n", "vaultsync", a.debugVars())
+	})
+
+	return mux
+}
@@ -0,0 +1,64 @@
+package vaultsync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+const (
+	// defaultConfigFile is the configuration file used unless WithConfigFile sets another. The agent runs without it if it does not exist.
+	defaultConfigFile = "vault-config.hcl"
+
+	// defaultAuthMethod is the authentication method used if authmethod is not set.
+	defaultAuthMethod = "token"
+
+	// defaultRenewSecretsPeriod is the renew period used if renew_secrets_period is not set.
+	defaultRenewSecretsPeriod = 5 * time.Minute
+
+	// tokenHelperFile is the file, relative to the home directory, the default token helper of the Vault CLI stores the token in.
+	tokenHelperFile = ".vault-token"
+)
+
+// setDefaults method fills in the settings that are not set in the configuration file. The server defaults to VAULT_ADDR,
+// the authentication method to token and the renew period to five minutes.
+func (c *config) setDefaults() {
+	v := &c.Vault
+
+	if v.Server == "" {
+		v.Server = os.Getenv(vault.EnvVaultAddress)
+	}
+	if v.AuthMethod == "" {
+		v.AuthMethod = defaultAuthMethod
+	}
+	if v.RenewSecretsPeriod == "" {
+		v.RenewSecretsPeriod = duration(defaultRenewSecretsPeriod.String())
+	}
+}
+
+// tokenAuth struct implements vault.AuthMethod with the token of the user, read from VAULT_TOKEN or, if not set,
+// from the token helper of the Vault CLI. The token is not renewed, it is read again when it expires.
+type tokenAuth struct{}
+
+// Login method reads the token and looks it up to learn its TTL.
+func (t *tokenAuth) Login(ctx context.Context, client *vault.Client) (*vault.Secret, error) {
+	if token := strings.TrimSpace(os.Getenv(vault.EnvVaultToken)); token != "" {
+		return lookupToken(ctx, client, token, vault.EnvVaultToken)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("%v is not set and the token helper file can't be found:%w", vault.EnvVaultToken, err)
+	}
+	filename := filepath.Join(home, tokenHelperFile)
+	token, err := readTokenFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("%v is not set and the token helper file can't be read:%w", vault.EnvVaultToken, err)
+	}
+	return lookupToken(ctx, client, token, filename)
+}
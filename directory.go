@@ -0,0 +1,111 @@
+package vaultsync
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// directoryConfig struct defines a directory where every field of the secret at path is written to its own file,
+// matching the layout of Docker and Kubernetes mounted secrets.
+type directoryConfig struct {
+	Path        string `hcl:"path"`
+	Destination string `hcl:"destination"`
+	Perms       string `hcl:"perms,optional"`
+	Owner       string `hcl:"owner,optional"`
+	Group       string `hcl:"group,optional"`
+}
+
+// directorySink struct writes each field of a secret to a file named after the field.
+// Files of fields removed from the secret are deleted.
+type directorySink struct {
+	config directoryConfig
+	owner  fileOwner
+
+	mu     sync.Mutex
+	fields secretFields
+}
+
+// newDirectorySink function creates a directory sink and the destination directory if it doesn't exist.
+func newDirectorySink(config directoryConfig) (*directorySink, error) {
+	owner, err := parseFileOwner(config.Perms, config.Owner, config.Group, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(config.Destination, 0700); err != nil {
+		return nil, err
+	}
+
+	return &directorySink{
+		config: config,
+		owner:  owner,
+		fields: newSecretFields(),
+	}, nil
+}
+
+// UpdateSecret method collects a field of the secret.
+func (ds *directorySink) UpdateSecret(id string, fieldName string, value interface{}) {
+	if id != ds.config.Path {
+		return
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	ds.fields.set(id, fieldName, value)
+}
+
+// Flush method writes the files of changed fields and deletes the files of removed fields.
+// Fields whose file could not be written or deleted are retried by the next flush.
+func (ds *directorySink) Flush() error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	ds.fields.prune()
+	if !ds.fields.dirty {
+		return nil
+	}
+
+	var errs []error
+	var failed []string
+	for _, field := range ds.fields.clean() {
+		filename, err := ds.filename(field)
+		if err != nil {
+			// The field name never becomes valid, retrying it would fail every flush.
+			errs = append(errs, err)
+			continue
+		}
+
+		value, ok := ds.fields.data[ds.config.Path][field]
+		if !ok {
+			if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+				failed = append(failed, field)
+			}
+			continue
+		}
+
+		data := []byte(fieldText(value))
+		err = writeFileAtomic(filename, data, ds.owner)
+		clear(data)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("write %v: %w", filename, err))
+			failed = append(failed, field)
+		}
+	}
+	ds.fields.markChanged(failed...)
+
+	return errors.Join(errs...)
+}
+
+// filename method returns the file of a field, rejecting field names that would escape the destination directory.
+func (ds *directorySink) filename(field string) (string, error) {
+	if field == "" || field == "." || field == ".." || strings.ContainsAny(field, `/\`) {
+		return "", fmt.Errorf("field name %q can't be used as file name", field)
+	}
+	return filepath.Join(ds.config.Destination, field), nil
+}
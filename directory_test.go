@@ -0,0 +1,61 @@
+package vaultsync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirectorySinkRetriesFailedWrites(t *testing.T) {
+	dir := t.TempDir()
+	ds, err := newDirectorySink(directoryConfig{Path: "secret/data/app", Destination: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A directory in the way of the file makes the write fail.
+	blocked := filepath.Join(dir, "password")
+	if err := os.Mkdir(blocked, 0700); err != nil {
+		t.Fatal(err)
+	}
+	ds.UpdateSecret("secret/data/app", "password", "s3cret")
+	if err := ds.Flush(); err == nil {
+		t.Fatal("flush succeeded with a directory in the way")
+	}
+
+	// The next flush retries the field although the secret did not change.
+	if err := os.Remove(blocked); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(blocked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "s3cret" {
+		t.Fatalf("got %q, want the value of the field", content)
+	}
+}
+
+func TestDirectorySinkWritesBytes(t *testing.T) {
+	dir := t.TempDir()
+	ds, err := newDirectorySink(directoryConfig{Path: "secret/data/app", Destination: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keystore := []byte{0xfe, 0xed, 0xfe, 0xed, 0x00, 0x02}
+	ds.UpdateSecret("secret/data/app", "keystore", keystore)
+	if err := ds.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "keystore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != string(keystore) {
+		t.Fatalf("got %q, want the raw bytes", content)
+	}
+}
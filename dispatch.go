@@ -0,0 +1,118 @@
+package vaultsync
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// WithDispatchConcurrency function sets how many receivers of a path are updated concurrently. Each receiver still gets
+// the fields of a path one at a time. By default the receivers are updated one after the other in the sync goroutine.
+func WithDispatchConcurrency(n int) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.dispatchConcurrency = n
+	}
+}
+
+// WithDispatchTimeout function sets how long the agent waits for a receiver to take the fields of a path, so a hung receiver,
+// such as one blocked on a network call, cannot stall the sync. The agent moves on when the timeout passes and skips the receiver
+// until its update returns, a Go function call cannot be interrupted. Timeouts are logged and counted in vaultsync.dispatch.timeouts.
+func WithDispatchTimeout(timeout time.Duration) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.dispatchTimeout = timeout
+	}
+}
+
+// receiverKey struct identifies a receiver by its path and its position among the receivers of the path,
+// receivers are not necessarily comparable.
+type receiverKey struct {
+	path  string
+	index int
+}
+
+// dispatch method passes the fields of data to the receivers of path. version is the version of the secret
+// and deleted the fields removed since the previous sync, for the receivers that implement SecretUpdateReceiver.
+func (a *Agent) dispatch(log *slog.Logger, path string, data map[string]interface{}, version int, deleted []string) {
+	delivered, replaced := a.writeFieldFiles(log, path, data)
+	defer a.removeFieldFiles(log, replaced)
+	d := secretDispatch{path: path, version: version, deleted: deleted, timestamp: a.clock.Now()}
+
+	if a.dispatchConcurrency <= 1 && a.dispatchTimeout <= 0 {
+		for _, receiver := range a.secretSync.receivers[path] {
+			d.deliver(receiver, receiverData(receiver, data, delivered))
+		}
+		return
+	}
+
+	concurrency := a.dispatchConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	slots := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for i, receiver := range a.secretSync.receivers[path] {
+		key := receiverKey{path: path, index: i}
+		if !a.startDispatch(key) {
+			log.Warn("dispatch", slog.String("status", "receiver still busy, skipped"), slog.String("secret-path", path), slog.String("receiver", receiverName(receiver)))
+			continue
+		}
+
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				defer a.endDispatch(key)
+				d.deliver(receiver, receiverData(receiver, data, delivered))
+			}()
+			a.waitDispatch(log, path, receiver, done)
+		}()
+	}
+	wg.Wait()
+}
+
+// waitDispatch method waits until a receiver took the fields of path or the dispatch timeout passed.
+func (a *Agent) waitDispatch(log *slog.Logger, path string, receiver SecretReceiver, done chan struct{}) {
+	if a.dispatchTimeout <= 0 {
+		<-done
+		return
+	}
+
+	timer := a.clock.NewTimer(a.dispatchTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C():
+		a.metrics.IncrCounter(metricDispatchTimeouts, 1, pathLabels(path))
+		log.Error("dispatch", slog.String("status", "receiver timed out"), slog.String("secret-path", path), slog.String("receiver", receiverName(receiver)), slog.Duration("timeout", a.dispatchTimeout))
+	}
+}
+
+// startDispatch method marks a receiver busy, it returns false if it still is busy with a previous update.
+func (a *Agent) startDispatch(key receiverKey) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.dispatching[key] {
+		return false
+	}
+	if a.dispatching == nil {
+		a.dispatching = make(map[receiverKey]bool)
+	}
+	a.dispatching[key] = true
+	return true
+}
+
+// endDispatch method marks a receiver done with its update.
+func (a *Agent) endDispatch(key receiverKey) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.dispatching, key)
+}
@@ -0,0 +1,129 @@
+package vaultsync_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+// concurrencyGauge struct records the largest number of receivers updating at once.
+type concurrencyGauge struct {
+	active atomic.Int32
+	max    atomic.Int32
+}
+
+// slowReceiver struct is a receiver that takes delay to take a field, counted by gauge.
+type slowReceiver struct {
+	gauge   *concurrencyGauge
+	delay   time.Duration
+	updates atomic.Int32
+}
+
+// UpdateSecret method implements vaultsync.SecretReceiver.
+func (r *slowReceiver) UpdateSecret(id string, fieldName string, value interface{}) {
+	active := r.gauge.active.Add(1)
+	defer r.gauge.active.Add(-1)
+	for {
+		max := r.gauge.max.Load()
+		if active <= max || r.gauge.max.CompareAndSwap(max, active) {
+			break
+		}
+	}
+	time.Sleep(r.delay)
+	r.updates.Add(1)
+}
+
+// hungReceiver struct is a receiver that blocks until it is released.
+type hungReceiver struct {
+	release chan struct{}
+	once    sync.Once
+	updates atomic.Int32
+}
+
+// UpdateSecret method implements vaultsync.SecretReceiver.
+func (r *hungReceiver) UpdateSecret(id string, fieldName string, value interface{}) {
+	<-r.release
+	r.updates.Add(1)
+}
+
+// unblock method releases the receiver.
+func (r *hungReceiver) unblock() {
+	r.once.Do(func() { close(r.release) })
+}
+
+func TestDispatchConcurrencyLimit(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+	agent := vaultsynctest.NewAgent(t, s, vaultsync.WithDispatchConcurrency(2))
+	gauge := &concurrencyGauge{}
+	var receivers []*slowReceiver
+	for i := 0; i < 6; i++ {
+		receiver := &slowReceiver{gauge: gauge, delay: 20 * time.Millisecond}
+		receivers = append(receivers, receiver)
+		agent.RegisterUpdateSecret("secret/data/app", receiver)
+	}
+
+	vaultsynctest.Sync(t, agent)
+	if max := gauge.max.Load(); max != 2 {
+		t.Fatalf("got %d receivers updating at once, want 2", max)
+	}
+	for i, receiver := range receivers {
+		if receiver.updates.Load() != 1 {
+			t.Fatalf("receiver %d got %d updates, want 1", i, receiver.updates.Load())
+		}
+	}
+}
+
+func TestDispatchTimeout(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+	const timeout = 100 * time.Millisecond
+	agent := vaultsynctest.NewAgent(t, s, vaultsync.WithDispatchConcurrency(2), vaultsync.WithDispatchTimeout(timeout))
+	hung := &hungReceiver{release: make(chan struct{})}
+	defer hung.unblock()
+	gauge := &concurrencyGauge{}
+	slow := []*slowReceiver{{gauge: gauge, delay: 10 * time.Millisecond}, {gauge: gauge, delay: 10 * time.Millisecond}, {gauge: gauge, delay: 10 * time.Millisecond}}
+	recorder := vaultsynctest.NewRecorder()
+	agent.RegisterUpdateSecret("secret/data/app", hung)
+	for _, receiver := range slow {
+		agent.RegisterUpdateSecret("secret/data/app", receiver)
+	}
+	agent.RegisterUpdateSecret("secret/data/app", recorder)
+
+	// The sync completes once the hung receiver timed out, the other receivers got the fields.
+	start := time.Now()
+	vaultsynctest.Sync(t, agent)
+	if elapsed := time.Since(start); elapsed < timeout || elapsed > 5*time.Second {
+		t.Fatalf("sync took %v with a hung receiver and a timeout of %v", elapsed, timeout)
+	}
+	recorder.AssertValue(t, "secret/data/app", "password", "s3cret")
+	// The hung receiver holds one of the two slots until it times out.
+	if max := gauge.max.Load(); max != 1 {
+		t.Fatalf("got %d receivers updating besides the hung one, want 1", max)
+	}
+
+	// The receiver is skipped while it is still busy, without waiting for it.
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "n3w"})
+	start = time.Now()
+	vaultsynctest.Sync(t, agent)
+	if elapsed := time.Since(start); elapsed >= timeout {
+		t.Fatalf("sync waited %v for a receiver that is still busy", elapsed)
+	}
+	recorder.AssertValue(t, "secret/data/app", "password", "n3w")
+
+	// Once it returns it gets the syncs again.
+	hung.unblock()
+	deadline := time.Now().Add(5 * time.Second)
+	for hung.updates.Load() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("receiver got %d updates after it returned, want 2", hung.updates.Load())
+		}
+		vaultsynctest.Sync(t, agent)
+	}
+}
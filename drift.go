@@ -0,0 +1,65 @@
+package vaultsync
+
+import (
+	"log/slog"
+	"time"
+)
+
+// DriftChecker interface is implemented by receivers that can report what they currently hold, so the agent can detect
+// code that modifies or caches secrets behind the receiver's back. SecretFingerprint returns the Fingerprint of the value
+// the receiver holds for a field, and false if it holds none.
+type DriftChecker interface {
+	SecretFingerprint(path string, field string) (string, bool)
+}
+
+// DriftEvent struct describes a receiver that holds a different value than the agent dispatched to it. It never contains secret values.
+type DriftEvent struct {
+	CycleID   string    `json:"cycle_id"`  // Correlation ID of the sync cycle that detected the drift.
+	Path      string    `json:"path"`      // Vault secret path.
+	Field     string    `json:"field"`     // Name of the drifted field.
+	Receiver  string    `json:"receiver"`  // Type of the receiver.
+	Timestamp time.Time `json:"timestamp"` // Time the drift was detected.
+}
+
+// Fingerprint function returns the SHA-256 fingerprint of a secret value, as used by the agent to detect rotations and drift.
+func Fingerprint(value interface{}) string {
+	return fingerprint(value)
+}
+
+// WithDriftHandler function sets a function that is called for every drift detected. It is called from the sync goroutine and must not block.
+func WithDriftHandler(handler func(DriftEvent)) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.driftHandler = handler
+	}
+}
+
+// checkDrift method compares what the receivers of a path that implement DriftChecker hold with the fields dispatched by the previous sync.
+// Each mismatch is logged, counted and passed to the drift handler. The following dispatch puts the receivers back in line.
+func (a *Agent) checkDrift(cycleID string, log *slog.Logger, path string) {
+	a.mu.RLock()
+	var dispatched map[string]string
+	if state, ok := a.paths[path]; ok {
+		dispatched = state.fingerprints
+	}
+	a.mu.RUnlock()
+
+	for _, receiver := range a.secretSync.receivers[path] {
+		checker, ok := receiverOf(receiver).(DriftChecker)
+		if !ok {
+			continue
+		}
+		for field, sum := range dispatched {
+			held, ok := checker.SecretFingerprint(path, field)
+			if !ok || held == sum {
+				continue
+			}
+
+			event := DriftEvent{CycleID: cycleID, Path: path, Field: field, Receiver: receiverName(receiver), Timestamp: a.clock.Now()}
+			a.metrics.IncrCounter(metricDrift, 1, pathLabels(path))
+			log.Warn("checkDrift", slog.String("status", "unexpected drift"), slog.String("secret-path", path), slog.String("field", field), slog.String("receiver", event.Receiver))
+			if a.driftHandler != nil {
+				a.driftHandler(event)
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package vaultsync
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// duration type is a time setting of the configuration file. It is either a number of seconds, renew_secrets_period = 30,
+// or a Go duration string, renew_secrets_period = "5m". Numbers are decoded as strings by HCL.
+type duration string
+
+// parse method returns the duration of the setting, 0 if it is not set.
+func (d duration) parse() (time.Duration, error) {
+	if d == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.ParseFloat(string(d), 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	value, err := time.ParseDuration(string(d))
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q, use a number of seconds or a duration such as \"90s\" or \"5m\"", string(d))
+	}
+	return value, nil
+}
+
+// value method returns the duration of a setting that has been validated, 0 if it is not set.
+func (d duration) value() time.Duration {
+	value, _ := d.parse()
+	return value
+}
+
+// namedDuration struct is a time setting of the configuration file and its name, for validation errors.
+type namedDuration struct {
+	name  string
+	value duration
+}
+
+// durations method returns the time settings of the configuration.
+func (v *vaultConfig) durations() []namedDuration {
+	durations := []namedDuration{
+		{"renew_secrets_period", v.RenewSecretsPeriod},
+		{"stale_threshold", v.StaleThreshold},
+		{"token_renew_increment", v.TokenRenewIncrement},
+		{"token_grace_threshold", v.TokenGraceThreshold},
+		{"startup_auth_timeout", v.StartupAuthTimeout},
+		{"max_secret_age", v.MaxSecretAge},
+		{"expiry_warning", v.ExpiryWarning},
+	}
+	if v.HTTP != nil {
+		durations = append(durations,
+			namedDuration{"http.timeout", v.HTTP.Timeout},
+			namedDuration{"http.min_retry_wait", v.HTTP.MinRetryWait},
+			namedDuration{"http.max_retry_wait", v.HTTP.MaxRetryWait},
+			namedDuration{"http.dial_timeout", v.HTTP.DialTimeout},
+			namedDuration{"http.keep_alive", v.HTTP.KeepAlive},
+			namedDuration{"http.idle_conn_timeout", v.HTTP.IdleConnTimeout})
+	}
+	if v.CircuitBreaker != nil {
+		durations = append(durations, namedDuration{"circuit_breaker.reset_timeout", v.CircuitBreaker.ResetTimeout})
+	}
+	if v.Child != nil {
+		durations = append(durations, namedDuration{"child.kill_timeout", v.Child.KillTimeout})
+	}
+	for _, webhook := range v.Webhooks {
+		durations = append(durations, namedDuration{"webhook.timeout", webhook.Timeout})
+	}
+	for _, tc := range v.Templates {
+		durations = append(durations, namedDuration{"template.command_timeout", tc.CommandTimeout})
+	}
+	for _, ec := range v.Execs {
+		durations = append(durations, namedDuration{"exec.timeout", ec.Timeout})
+	}
+	return durations
+}
+
+// validateDurations method checks that the time settings of the configuration are valid and not negative.
+func (v *vaultConfig) validateDurations() error {
+	for _, d := range v.durations() {
+		value, err := d.value.parse()
+		if err != nil {
+			return fmt.Errorf("%v: %w", d.name, err)
+		}
+		if value < 0 {
+			return fmt.Errorf("%v must not be negative", d.name)
+		}
+	}
+	return nil
+}
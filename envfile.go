@@ -0,0 +1,151 @@
+package vaultsync
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// envFileConfig struct defines a dotenv file holding selected secrets.
+type envFileConfig struct {
+	Destination string            `hcl:"destination"`
+	Perms       string            `hcl:"perms,optional"`
+	Owner       string            `hcl:"owner,optional"`
+	Group       string            `hcl:"group,optional"`
+	Secrets     []envSecretConfig `hcl:"secret,block"`
+}
+
+// envSecretConfig struct selects the fields of a secret written to a dotenv file.
+// Each field becomes a variable named prefix + field in upper case. All fields are written if fields is empty.
+type envSecretConfig struct {
+	Path   string   `hcl:"path"`
+	Prefix string   `hcl:"prefix,optional"`
+	Fields []string `hcl:"fields,optional"`
+}
+
+// envNameInvalid matches characters that are not allowed in environment variable names.
+var envNameInvalid = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// envFileSink struct writes selected secrets to a dotenv file.
+type envFileSink struct {
+	config envFileConfig
+	owner  fileOwner
+
+	mu     sync.Mutex
+	fields secretFields
+}
+
+// newEnvFileSink function creates a dotenv sink from its configuration.
+func newEnvFileSink(config envFileConfig) (*envFileSink, error) {
+	if len(config.Secrets) == 0 {
+		return nil, fmt.Errorf("env file %v has no secrets", config.Destination)
+	}
+
+	owner, err := parseFileOwner(config.Perms, config.Owner, config.Group, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &envFileSink{
+		config: config,
+		owner:  owner,
+		fields: newSecretFields(),
+	}, nil
+}
+
+// paths method returns the secret paths written to the file.
+func (es *envFileSink) paths() []string {
+	return envSecretPaths(es.config.Secrets)
+}
+
+// UpdateSecret method collects a field of a selected secret.
+func (es *envFileSink) UpdateSecret(id string, fieldName string, value interface{}) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	es.fields.set(id, fieldName, value)
+}
+
+// Flush method writes the dotenv file if a selected secret changed and all selected secrets are synced.
+func (es *envFileSink) Flush() error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	es.fields.prune()
+	if !es.fields.dirty || !es.fields.has(es.paths()...) {
+		return nil
+	}
+
+	vars, err := envVars(es.config.Secrets, &es.fields)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + "=" + quoteEnvValue(vars[name]) + "\n")
+	}
+
+	data := []byte(b.String())
+	err = writeFileAtomic(es.config.Destination, data, es.owner)
+	clear(data)
+	if err != nil {
+		return fmt.Errorf("write %v: %w", es.config.Destination, err)
+	}
+	es.fields.clean()
+
+	return nil
+}
+
+// envVars function returns the environment variables of the selected secret fields.
+func envVars(secrets []envSecretConfig, fields *secretFields) (map[string]string, error) {
+	vars := make(map[string]string)
+	for _, secret := range secrets {
+		names := secret.Fields
+		if len(names) == 0 {
+			for field := range fields.data[secret.Path] {
+				names = append(names, field)
+			}
+		}
+		for _, field := range names {
+			value, ok := fields.data[secret.Path][field]
+			if !ok {
+				return nil, fmt.Errorf("secret %v has no field %v", secret.Path, field)
+			}
+			vars[envName(secret.Prefix+field)] = fieldText(value)
+		}
+	}
+	return vars, nil
+}
+
+// envSecretPaths function returns the paths of the selected secrets.
+func envSecretPaths(secrets []envSecretConfig) []string {
+	paths := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		paths = append(paths, secret.Path)
+	}
+	return paths
+}
+
+// envName function converts a name to an environment variable name.
+func envName(name string) string {
+	return envNameInvalid.ReplaceAllString(strings.ToUpper(name), "_")
+}
+
+// quoteEnvValue function quotes a value for a dotenv file.
+// Values are single quoted so they are taken literally, unless they contain a single quote or a newline,
+// in which case they are double quoted with escapes.
+func quoteEnvValue(value string) string {
+	if !strings.ContainsAny(value, "'\n") {
+		return "'" + value + "'"
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "$", `\$`).Replace(value) + `"`
+}
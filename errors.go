@@ -0,0 +1,163 @@
+package vaultsync
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// ErrorKind type classifies why a path failed to sync.
+type ErrorKind string
+
+// Kinds of sync errors. Unavailable, sealed and transport errors mean that Vault could not serve the request, they are retried
+// with backoff before the next scheduled sync and count as failures for the circuit breaker. The other errors need a change in Vault
+// or the agent and are only retried by the scheduled syncs.
+const (
+	ErrorKindNone             ErrorKind = ""                  // The last sync succeeded.
+	ErrorKindPermissionDenied ErrorKind = "permission_denied" // The token may not read the path.
+	ErrorKindNotFound         ErrorKind = "not_found"         // The path does not exist.
+	ErrorKindSealed           ErrorKind = "sealed"            // Vault is sealed.
+	ErrorKindUnavailable      ErrorKind = "unavailable"       // Vault answered with a server error or rate limit, or the circuit breaker is open.
+	ErrorKindTransport        ErrorKind = "transport"         // Vault could not be reached.
+	ErrorKindInvalid          ErrorKind = "invalid"           // The secret failed a transform or validator, or the response could not be used.
+	ErrorKindCanceled         ErrorKind = "canceled"          // The request was canceled, for example because the agent stopped.
+)
+
+// Backoff of the retries of paths that failed with a transient error.
+const (
+	retryMinWait = 5 * time.Second
+	retryMaxWait = 5 * time.Minute
+)
+
+// classifyError function returns the kind of a sync error.
+func classifyError(err error) ErrorKind {
+	if err == nil {
+		return ErrorKindNone
+	}
+	if errors.Is(err, ErrSecretNotFound) {
+		return ErrorKindNotFound
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return ErrorKindUnavailable
+	}
+
+	var respErr *vault.ResponseError
+	if !errors.As(err, &respErr) {
+		if errors.Is(err, context.Canceled) {
+			return ErrorKindCanceled
+		}
+		if errors.Is(err, context.DeadlineExceeded) || isTransportError(err) {
+			return ErrorKindTransport
+		}
+		return ErrorKindInvalid
+	}
+	for _, e := range respErr.Errors {
+		if strings.Contains(e, "sealed") {
+			return ErrorKindSealed
+		}
+	}
+	switch {
+	case respErr.StatusCode == http.StatusForbidden:
+		return ErrorKindPermissionDenied
+	case respErr.StatusCode == http.StatusNotFound:
+		return ErrorKindNotFound
+	case respErr.StatusCode >= 500 || respErr.StatusCode == http.StatusTooManyRequests:
+		return ErrorKindUnavailable
+	}
+	return ErrorKindInvalid
+}
+
+// isTransportError function reports whether an error was returned by the HTTP client rather than by Vault or the agent.
+func isTransportError(err error) bool {
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &urlErr) || errors.As(err, &netErr)
+}
+
+// unavailable method reports whether this kind of error means that Vault could not serve the request.
+func (k ErrorKind) unavailable() bool {
+	switch k {
+	case ErrorKindUnavailable, ErrorKindSealed, ErrorKindTransport:
+		return true
+	}
+	return false
+}
+
+// retryable method reports whether a path that failed with this kind of error is retried before its next scheduled sync.
+func (k ErrorKind) retryable() bool {
+	return k.unavailable()
+}
+
+// retryWait function returns the wait before retrying a path that failed failures times in a row,
+// doubling from retryMinWait up to retryMaxWait or the renew period, whichever is shorter.
+func retryWait(failures int, period time.Duration) time.Duration {
+	limit := retryMaxWait
+	if period > 0 && period < limit {
+		limit = period
+	}
+	wait := retryMinWait
+	for i := 1; i < failures && wait < limit; i++ {
+		wait *= 2
+	}
+	if wait > limit {
+		wait = limit
+	}
+	return wait
+}
+
+// retryDue method returns the paths whose retry is due and clears their retry.
+func (a *Agent) retryDue(now time.Time) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var paths []string
+	for path, state := range a.paths {
+		if !state.retryAt.IsZero() && !state.retryAt.After(now) {
+			state.retryAt = time.Time{}
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// nextWait method returns the time until the scheduler fires or a failed path is retried, whichever comes first.
+func (a *Agent) nextWait(now time.Time) time.Duration {
+	wait := a.scheduler.wait(now)
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for _, state := range a.paths {
+		// A retry that is due while the syncs are paused waits for the next schedule after they resume.
+		if a.paused && !state.retryAt.After(now) {
+			continue
+		}
+		if !state.retryAt.IsZero() && state.retryAt.Sub(now) < wait {
+			wait = state.retryAt.Sub(now)
+		}
+	}
+	return wait
+}
+
+// mergePaths function returns the paths of a followed by those of b that are not in a.
+func mergePaths(a []string, b []string) []string {
+	seen := make(map[string]bool, len(a))
+	for _, path := range a {
+		seen[path] = true
+	}
+	for _, path := range b {
+		if !seen[path] {
+			a = append(a, path)
+			seen[path] = true
+		}
+	}
+	return a
+}
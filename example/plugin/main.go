@@ -0,0 +1,60 @@
+// Command plugin is an example receiver plugin. It writes the fields of each path it receives as a JSON file to the directory
+// set in its config, e.g.
+//
+//	plugin "plugin" {
+//	  paths  = ["secrets/data/netpush/redis"]
+//	  config = { directory = "/run/secrets" }
+//	}
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pergus/vaultsync/plugin"
+)
+
+type jsonReceiver struct {
+	directory string
+	secrets   map[string]map[string]interface{}
+}
+
+func (r *jsonReceiver) Configure(config map[string]string) error {
+	r.directory = config["directory"]
+	if r.directory == "" {
+		return fmt.Errorf("directory is not set")
+	}
+	r.secrets = make(map[string]map[string]interface{})
+	return nil
+}
+
+func (r *jsonReceiver) UpdateSecret(path string, field string, value interface{}) error {
+	if r.secrets[path] == nil {
+		r.secrets[path] = make(map[string]interface{})
+	}
+	r.secrets[path][field] = value
+	return nil
+}
+
+func (r *jsonReceiver) Flush() error {
+	for path, fields := range r.secrets {
+		b, err := json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		filename := filepath.Join(r.directory, strings.ReplaceAll(path, "/", "_")+".json")
+		if err := os.WriteFile(filename, b, 0600); err != nil {
+			return err
+		}
+	}
+	// Messages written to stderr show up in the log of the agent.
+	fmt.Fprintf(os.Stderr, "flushed %d paths\n", len(r.secrets))
+	return nil
+}
+
+func main() {
+	plugin.Serve(&jsonReceiver{})
+}
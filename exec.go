@@ -0,0 +1,103 @@
+package vaultsync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultCommandTimeout is the command timeout if no timeout is configured.
+const defaultCommandTimeout = 30 * time.Second
+
+// commandWaitDelay is how long a command may keep its output open after it exited or was killed. Processes it started
+// in the background inherit the output, and are not waited for.
+const commandWaitDelay = time.Second
+
+// execConfig struct defines a command that runs when the secret at path rotates.
+type execConfig struct {
+	Path    string   `hcl:"path"`
+	Command []string `hcl:"command"`
+	Timeout duration `hcl:"timeout,optional"`
+}
+
+// commandHook struct is a command run after a secret changes.
+type commandHook struct {
+	command []string
+	timeout time.Duration
+}
+
+// newCommandHook function creates a command hook, defaulting the timeout if it is not positive.
+func newCommandHook(command []string, timeout time.Duration) commandHook {
+	if timeout <= 0 {
+		timeout = defaultCommandTimeout
+	}
+	return commandHook{
+		command: command,
+		timeout: timeout,
+	}
+}
+
+// run method runs the command with env added to the agent's environment.
+// The returned error includes the command output to help diagnose failures.
+func (c commandHook) run(env map[string]string) error {
+	if len(c.command) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, c.command[0], c.command[1:]...)
+	cmd.Env = os.Environ()
+	for key, value := range env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.WaitDelay = commandWaitDelay
+
+	// A command that succeeded but left a background process holding its output, such as a reload that starts
+	// a daemon, is not a failure.
+	if err := cmd.Run(); err != nil && !errors.Is(err, exec.ErrWaitDelay) {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %v", c.timeout)
+		}
+		return fmt.Errorf("command %q failed: %w: %s", strings.Join(c.command, " "), err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+// rotationEnv function returns the environment describing a rotation. It never contains secret values.
+func rotationEnv(event RotationEvent) map[string]string {
+	return map[string]string{
+		"VAULTSYNC_PATH":           event.Path,
+		"VAULTSYNC_CHANGED_FIELDS": strings.Join(event.Fields, ","),
+		"VAULTSYNC_VERSION":        strconv.Itoa(event.Version),
+	}
+}
+
+// runExecHooks method runs the commands configured for the rotated path.
+func (a *Agent) runExecHooks(log *slog.Logger, event RotationEvent) {
+	for _, hook := range a.config.Vault.Execs {
+		if hook.Path != event.Path {
+			continue
+		}
+
+		err := newCommandHook(hook.Command, hook.Timeout.value()).run(rotationEnv(event))
+		if err != nil {
+			a.metrics.IncrCounter(metricExecFailures, 1, pathLabels(event.Path))
+			log.Error("runExecHooks", slog.String("secret-path", event.Path), slog.Any("error", err))
+			continue
+		}
+		log.Info("runExecHooks", slog.String("secret-path", event.Path), slog.Any("command", hook.Command))
+	}
+}
@@ -0,0 +1,47 @@
+package vaultsync_test
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+func TestExecHookReturnsByTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+
+	for name, command := range map[string]string{
+		"background process": "sleep 30 &",
+		"hung with a child":  "sleep 30 & sleep 30",
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := vaultsynctest.NewServer()
+			defer s.Close()
+			s.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+			extra := fmt.Sprintf(`  exec {
+    path    = "secret/data/app"
+    command = ["sh", "-c", %q]
+    timeout = "300ms"
+  }`, command)
+			filename, err := s.WriteConfig(t.TempDir(), 3600, extra)
+			if err != nil {
+				t.Fatal(err)
+			}
+			agent := newConfigAgent(t, filename)
+			agent.RegisterUpdateSecret("secret/data/app", vaultsynctest.NewRecorder())
+			vaultsynctest.Sync(t, agent)
+
+			// The command runs in the sync when the secret rotates, the sync must not wait for the background sleep.
+			s.SetSecret("secret/data/app", map[string]interface{}{"password": "n3w"})
+			start := time.Now()
+			vaultsynctest.Sync(t, agent)
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Fatalf("sync took %v, the exec hook waited for the background process", elapsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package vaultsync
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// defaultExpiryWarning is how long before a secret expires the expiring soon hooks are called by default.
+const defaultExpiryWarning = 5 * time.Minute
+
+// WithExpiryWarning function sets how long before a leased or TTL-bound secret expires the hooks registered with
+// OnExpiringSoon are called. It defaults to 5 minutes and takes precedence over expiry_warning in the configuration file.
+func WithExpiryWarning(threshold time.Duration) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.expiryWarning = threshold
+	}
+}
+
+// OnExpiringSoon method registers a hook that is called with the path and the remaining lifetime when the secret of
+// a path, such as dynamic database credentials or a certificate, expires within the expiry warning threshold, so the
+// application can drain connections before the credentials die. The lifetime is the lease duration of the secret or,
+// for a certificate issued by the PKI engine, its expiration. A hook is called once per lease or certificate.
+// Hooks are called in registration order from the expiry goroutine, must not block and must be registered before Run.
+func (a *Agent) OnExpiringSoon(hook func(path string, remaining time.Duration)) {
+	a.expiringSoon = append(a.expiringSoon, hook)
+}
+
+// secretExpiry function returns when a secret read at now expires, zero if it does not expire.
+func secretExpiry(secret *vault.Secret, now time.Time) time.Time {
+	if secret == nil {
+		return time.Time{}
+	}
+	if secret.LeaseDuration > 0 {
+		return now.Add(time.Duration(secret.LeaseDuration) * time.Second)
+	}
+	// Certificates issued by the PKI engine have no lease, their expiration is a Unix time.
+	if expiration, ok := secret.Data["expiration"].(json.Number); ok {
+		if seconds, err := expiration.Int64(); err == nil && seconds > 0 {
+			return time.Unix(seconds, 0)
+		}
+	}
+	return time.Time{}
+}
+
+// recordExpiry method records when the secret of a path expires and wakes up the expiry goroutine.
+func (a *Agent) recordExpiry(path string, expiresAt time.Time) {
+	a.mu.Lock()
+	state, ok := a.paths[path]
+	if ok && !expiresAt.Equal(state.expiresAt) {
+		state.expiresAt = expiresAt
+		state.expiryWarned = false
+	}
+	a.mu.Unlock()
+
+	select {
+	case a.expiryChanged <- struct{}{}:
+	default:
+	}
+}
+
+// expiryThreshold method returns how long before a secret expires the expiring soon hooks are called.
+func (a *Agent) expiryThreshold() time.Duration {
+	if a.expiryWarning > 0 {
+		return a.expiryWarning
+	}
+	return defaultExpiryWarning
+}
+
+// watchExpiry method calls the expiring soon hooks when secrets come within the expiry warning threshold.
+func (a *Agent) watchExpiry(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for {
+		timer := a.clock.NewTimer(a.warnExpiring())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		case <-a.expiryChanged:
+			timer.Stop()
+		}
+	}
+}
+
+// warnExpiring method calls the expiring soon hooks for the secrets that expire within the threshold and returns
+// how long to wait until the next secret does.
+func (a *Agent) warnExpiring() time.Duration {
+	type expiring struct {
+		path      string
+		remaining time.Duration
+	}
+	var warn []expiring
+	// Without expiring secrets the goroutine waits until recordExpiry wakes it up.
+	next := 24 * time.Hour
+
+	threshold := a.expiryThreshold()
+	a.mu.Lock()
+	now := a.clock.Now()
+	for path, state := range a.paths {
+		if state.expiresAt.IsZero() || state.expiryWarned {
+			continue
+		}
+		remaining := state.expiresAt.Sub(now)
+		if remaining > threshold {
+			next = min(next, remaining-threshold)
+			continue
+		}
+		state.expiryWarned = true
+		warn = append(warn, expiring{path: path, remaining: max(remaining, 0)})
+	}
+	a.mu.Unlock()
+
+	for _, secret := range warn {
+		a.log.Warn("watchExpiry", slog.String("secret-path", secret.path), slog.String("status", "secret expiring soon"), slog.Duration("remaining", secret.remaining))
+		for _, hook := range a.expiringSoon {
+			hook(secret.path, secret.remaining)
+		}
+	}
+	return next
+}
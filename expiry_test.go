@@ -0,0 +1,72 @@
+package vaultsync_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+func TestOnExpiringSoon(t *testing.T) {
+	m := vaultsynctest.NewMockClient()
+	m.ReadFunc = func(ctx context.Context, path string) (*vault.Secret, error) {
+		return &vault.Secret{
+			LeaseID:       "database/creds/app/1",
+			LeaseDuration: 600,
+			Data:          map[string]interface{}{"username": "v-app", "password": "s3cret"},
+		}, nil
+	}
+	clock := vaultsynctest.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	agent := vaultsynctest.NewMockAgent(t, m, vaultsync.WithClock(clock), vaultsync.WithExpiryWarning(5*time.Minute))
+	agent.RegisterExtractor("database/creds/app", vaultsync.ExtractData())
+	agent.RegisterUpdateSecret("database/creds/app", vaultsynctest.NewRecorder())
+
+	expiring := make(chan time.Duration, 10)
+	agent.OnExpiringSoon(func(path string, remaining time.Duration) {
+		if path == "database/creds/app" {
+			expiring <- remaining
+		}
+	})
+	if err := agent.Run(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Stop()
+
+	if expiresAt := agent.Status()[0].ExpiresAt; !expiresAt.Equal(clock.Now().Add(10 * time.Minute)) {
+		t.Fatalf("got expiry %v, want the end of the lease", expiresAt)
+	}
+
+	// Nothing is reported while the lease has more than the threshold left.
+	clock.Advance(4 * time.Minute)
+	select {
+	case remaining := <-expiring:
+		t.Fatalf("hook called with %v left before the threshold", remaining)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case remaining := <-expiring:
+			if remaining > 5*time.Minute || remaining <= 0 {
+				t.Fatalf("got remaining %v, want at most the threshold", remaining)
+			}
+			// The hook is called once per lease.
+			clock.Advance(time.Minute)
+			select {
+			case remaining := <-expiring:
+				t.Fatalf("hook called again with %v", remaining)
+			case <-time.After(50 * time.Millisecond):
+			}
+			return
+		case <-deadline:
+			t.Fatal("hook not called when the lease came within the threshold")
+		case <-time.After(10 * time.Millisecond):
+			clock.Advance(10 * time.Second)
+		}
+	}
+}
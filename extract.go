@@ -0,0 +1,71 @@
+package vaultsync
+
+import (
+	"fmt"
+	"strings"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// Extractor type is a function that returns the fields of a secret read from a logical path. It lets paths of engines
+// other than KV, such as identity, terraform or custom plugins, be synced like KV secrets.
+// The fields are transformed, validated and dispatched like those of a KV secret.
+type Extractor func(secret *vault.Secret) (map[string]interface{}, error)
+
+// ExtractData function returns an extractor that takes the fields from the top level of data,
+// as returned by KV v1 and most other engines.
+func ExtractData() Extractor {
+	return ExtractNested()
+}
+
+// ExtractNested function returns an extractor that takes the fields from the map nested under keys in data,
+// for example ExtractNested("data") for the data.data of a KV v2 read. Without keys it takes data itself.
+func ExtractNested(keys ...string) Extractor {
+	return func(secret *vault.Secret) (map[string]interface{}, error) {
+		data := secret.Data
+		if data == nil {
+			return nil, fmt.Errorf("secret has no data:%w", ErrSecretNotFound)
+		}
+		for i, key := range keys {
+			nested, ok := data[key].(map[string]interface{})
+			if !ok || nested == nil {
+				return nil, fmt.Errorf("secret has no data at %v:%w", strings.Join(append([]string{"data"}, keys[:i+1]...), "."), ErrSecretNotFound)
+			}
+			data = nested
+		}
+		return data, nil
+	}
+}
+
+// parseExtractor function returns the extractor of a configured extraction. "data" takes the fields from the top
+// level of data, "data.data" from the map nested under data, and so on.
+func parseExtractor(spec string) (Extractor, error) {
+	keys := strings.Split(spec, ".")
+	if keys[0] != "data" {
+		return nil, fmt.Errorf("extraction %q must start with data", spec)
+	}
+	for _, key := range keys[1:] {
+		if key == "" {
+			return nil, fmt.Errorf("extraction %q has an empty key", spec)
+		}
+	}
+	return ExtractNested(keys[1:]...), nil
+}
+
+// RegisterExtractor method sets how the fields of a logical path are taken from the secret read from Vault,
+// instead of by the KV version of its mount. It takes precedence over extractors in the configuration file.
+func (a *Agent) RegisterExtractor(path string, extractor Extractor) {
+	a.extractors[a.expandPath(path)] = extractor
+}
+
+// applyExtractors method registers the extractors of the configuration file, it must be called after the paths are expanded.
+func (a *Agent) applyExtractors() error {
+	for path, spec := range a.config.Vault.Extractors {
+		extractor, err := parseExtractor(spec)
+		if err != nil {
+			return fmt.Errorf("extractors: path %v:%w", path, err)
+		}
+		a.extractors[a.expandPath(path)] = extractor
+	}
+	return nil
+}
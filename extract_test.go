@@ -0,0 +1,58 @@
+package vaultsync_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+func TestExtractors(t *testing.T) {
+	m := vaultsynctest.NewMockClient()
+	m.ReadFunc = func(ctx context.Context, path string) (*vault.Secret, error) {
+		switch path {
+		case "identity/oidc/token/app":
+			return &vault.Secret{Data: map[string]interface{}{"token": "eyJ", "client_id": "app"}}, nil
+		case "custom/creds/app":
+			return &vault.Secret{Data: map[string]interface{}{"result": map[string]interface{}{"key": "k3y"}}}, nil
+		}
+		return nil, nil
+	}
+	agent := vaultsynctest.NewMockAgent(t, m)
+	recorder := vaultsynctest.NewRecorder()
+	agent.RegisterUpdateSecret("identity/oidc/token/app", recorder)
+	agent.RegisterExtractor("identity/oidc/token/app", vaultsync.ExtractData())
+	agent.RegisterUpdateSecret("custom/creds/app", recorder)
+	agent.RegisterExtractor("custom/creds/app", vaultsync.ExtractNested("result"))
+
+	vaultsynctest.Sync(t, agent)
+	recorder.AssertValue(t, "identity/oidc/token/app", "token", "eyJ")
+	recorder.AssertValue(t, "custom/creds/app", "key", "k3y")
+
+	// A response without the nested map fails the path as not found.
+	agent.RegisterExtractor("custom/creds/app", vaultsync.ExtractNested("missing"))
+	if err := agent.SyncOnce(context.Background()); err == nil {
+		t.Fatal("sync without the nested map succeeded")
+	}
+	for _, status := range agent.Status() {
+		if status.Path == "custom/creds/app" && !errors.Is(status.LastError, vaultsync.ErrSecretNotFound) {
+			t.Fatalf("got %v, want ErrSecretNotFound", status.LastError)
+		}
+	}
+}
+
+func TestExtractorsConfig(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+
+	filename, err := s.WriteConfig(t.TempDir(), 3600, `  extractors = { "custom/creds/app" = "result" }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vaultsync.New(vaultsync.WithConfigFile(filename), quiet); err == nil {
+		t.Fatal("extraction not starting with data was accepted")
+	}
+}
@@ -0,0 +1,82 @@
+package vaultsync
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// healthCheckTimeout is how long a sys/health request may take.
+const healthCheckTimeout = 5 * time.Second
+
+// checkFailover method switches the client to the first usable server if the current server cannot serve requests,
+// for example because it became a DR secondary. It only runs if failover_servers are configured.
+// After a switch the agent logs in again before the cycle reads any path, tokens are not shared between performance replicated clusters,
+// and the identities log in again on the new server on first use.
+func (a *Agent) checkFailover(ctx context.Context, log *slog.Logger) {
+	if len(a.config.Vault.FailoverServers) == 0 {
+		return
+	}
+
+	current := a.client.Address()
+	if a.usableServer(ctx, log, current) {
+		return
+	}
+
+	servers := append([]string{a.config.Vault.Server}, a.config.Vault.FailoverServers...)
+	for _, server := range servers {
+		if server == current || !a.usableServer(ctx, log, server) {
+			continue
+		}
+		if err := a.client.SetAddress(server); err != nil {
+			log.Error("checkFailover", slog.String("server", server), slog.Any("error", err))
+			continue
+		}
+		log.Warn("checkFailover", slog.String("status", "switched server"), slog.String("from", current), slog.String("to", server))
+		a.resetIdentities()
+		if err := a.login(ctx); err != nil {
+			// The token renewal keeps trying to log in.
+			log.Error("checkFailover", slog.String("status", "login failed"), slog.Any("error", err))
+		} else {
+			a.loggedInAgain()
+		}
+		// Make the token renewal renew the new token, or log in if the login failed.
+		a.requestReauth()
+		return
+	}
+	log.Error("checkFailover", slog.String("status", "no usable vault server"))
+}
+
+// usableServer method reports whether the server at address is initialized, unsealed and not a DR secondary.
+func (a *Agent) usableServer(ctx context.Context, log *slog.Logger, address string) bool {
+	client, err := a.client.CloneWithHeaders()
+	if err != nil {
+		return false
+	}
+	if err := client.SetAddress(address); err != nil {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	health, err := client.Sys().HealthWithContext(ctx)
+	if err != nil {
+		log.Warn("usableServer", slog.String("server", address), slog.Any("error", err))
+		return false
+	}
+	if !health.Initialized || health.Sealed || health.ReplicationDRMode == "secondary" {
+		log.Warn("usableServer", slog.String("server", address), slog.Bool("initialized", health.Initialized),
+			slog.Bool("sealed", health.Sealed), slog.String("replication_dr_mode", health.ReplicationDRMode))
+		return false
+	}
+	return true
+}
+
+// requestReauth method asks the token renewal to log in again.
+func (a *Agent) requestReauth() {
+	select {
+	case a.reauth <- struct{}{}:
+	default:
+	}
+}
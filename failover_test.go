@@ -0,0 +1,46 @@
+package vaultsync_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+func TestFailover(t *testing.T) {
+	primary := vaultsynctest.NewServer()
+	defer primary.Close()
+	secondary := vaultsynctest.NewServer()
+	defer secondary.Close()
+	primary.SetSecret("secret/data/app", map[string]interface{}{"password": "primary"})
+	secondary.SetSecret("secret/data/app", map[string]interface{}{"password": "secondary"})
+
+	filename, err := primary.WriteConfig(t.TempDir(), 60, fmt.Sprintf("failover_servers = [%q]", secondary.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent, err := vaultsync.New(vaultsync.WithConfigFile(filename), vaultsync.WithMaxRetries(0), quiet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := vaultsynctest.NewRecorder()
+	agent.RegisterUpdateSecret("secret/data/app", recorder)
+
+	ctx := context.Background()
+	if err := agent.SyncOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+	recorder.AssertValue(t, "secret/data/app", "password", "primary")
+
+	// The secondary does not know the token of the primary, the agent must log in again before reading.
+	primary.SetFailing(true)
+	if err := agent.SyncOnce(ctx); err != nil {
+		t.Fatalf("sync after failover: %v", err)
+	}
+	recorder.AssertValue(t, "secret/data/app", "password", "secondary")
+	if status := agent.Status(); status[0].ErrorKind != vaultsync.ErrorKindNone {
+		t.Fatalf("path failed after failover: %v", status[0].LastError)
+	}
+}
@@ -0,0 +1,166 @@
+package vaultsync
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// WithFieldFileDir function sets the directory in which the temp files of fields delivered as files are created,
+// for example a tmpfs. By default the temp directory of the system is used.
+func WithFieldFileDir(dir string) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.fieldFileDir = dir
+	}
+}
+
+// RegisterFileFields method makes the agent deliver fields of a path as files, for very large values such as
+// kubeconfigs and Java keystores. The value is written to a temp file only readable by the owner of the process,
+// and receivers get the name of the file instead of the value. Sinks still get the value.
+// The file is removed when the value rotates, when the field is removed from the secret and when the agent stops,
+// a receiver must read it when it is updated.
+func (a *Agent) RegisterFileFields(path string, fields ...string) {
+	path = a.expandPath(path)
+	a.fileFields[path] = append(a.fileFields[path], fields...)
+}
+
+// fieldFiles struct holds the temp files of the fields delivered as files.
+type fieldFiles struct {
+	mu    sync.Mutex
+	dir   string
+	files map[string]map[string]fieldFile // Files by path and field.
+}
+
+// fieldFile struct is the temp file of a field and the fingerprint of the value it holds.
+type fieldFile struct {
+	name        string
+	fingerprint string
+}
+
+// writeFieldFiles method writes the file fields of path to temp files. It returns the data for the receivers that
+// are not sinks, with the values of the fields replaced by the names of their files, and the files that are replaced.
+// A file is only written when the value changed, so receivers get the same name while the value is the same.
+func (a *Agent) writeFieldFiles(log *slog.Logger, path string, data map[string]interface{}) (map[string]interface{}, []string) {
+	fields := a.fileFields[path]
+	if len(fields) == 0 {
+		return data, nil
+	}
+
+	ff := &a.fieldFiles
+	ff.mu.Lock()
+	defer ff.mu.Unlock()
+
+	if ff.files == nil {
+		ff.files = make(map[string]map[string]fieldFile)
+	}
+	files := ff.files[path]
+	if files == nil {
+		files = make(map[string]fieldFile)
+		ff.files[path] = files
+	}
+
+	delivered := make(map[string]interface{}, len(data))
+	for field, value := range data {
+		delivered[field] = value
+	}
+
+	var replaced []string
+	for _, field := range fields {
+		value, ok := data[field]
+		old, exists := files[field]
+		if !ok {
+			if exists {
+				replaced = append(replaced, old.name)
+				delete(files, field)
+			}
+			continue
+		}
+
+		sum := fingerprint(value)
+		if exists && old.fingerprint == sum {
+			delivered[field] = old.name
+			continue
+		}
+		name, err := ff.write(a.fieldFileDir, field, value)
+		if err != nil {
+			// The receivers keep the previous file.
+			log.Error("writeFieldFiles", slog.String("secret-path", path), slog.String("field", field), slog.Any("error", err))
+			delete(delivered, field)
+			continue
+		}
+		if exists {
+			replaced = append(replaced, old.name)
+		}
+		files[field] = fieldFile{name: name, fingerprint: sum}
+		delivered[field] = name
+	}
+	return delivered, replaced
+}
+
+// write method writes the value of a field to a new temp file and returns its name. The name ends with the field,
+// so an extension such as .jks is kept. It must be called with mu held.
+func (ff *fieldFiles) write(parent string, field string, value interface{}) (string, error) {
+	if ff.dir == "" {
+		dir, err := os.MkdirTemp(parent, "vaultsync-")
+		if err != nil {
+			return "", err
+		}
+		ff.dir = dir
+	}
+
+	pattern := "*-" + strings.Map(func(r rune) rune {
+		if os.IsPathSeparator(uint8(r)) {
+			return '_'
+		}
+		return r
+	}, field)
+	f, err := os.CreateTemp(ff.dir, pattern)
+	if err != nil {
+		return "", err
+	}
+	content := []byte(fileContent(value))
+	_, err = f.Write(content)
+	clear(content)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// removeFieldFiles method removes the given temp files of fields.
+func (a *Agent) removeFieldFiles(log *slog.Logger, names []string) {
+	for _, name := range names {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			log.Error("removeFieldFiles", slog.String("file", name), slog.Any("error", err))
+		}
+	}
+}
+
+// dropFieldFiles method removes the temp files of all fields delivered as files and their directory.
+func (a *Agent) dropFieldFiles() {
+	ff := &a.fieldFiles
+	ff.mu.Lock()
+	defer ff.mu.Unlock()
+
+	if ff.dir == "" {
+		return
+	}
+	if err := os.RemoveAll(ff.dir); err != nil {
+		a.logs.sinks.Error("dropFieldFiles", slog.String("dir", ff.dir), slog.Any("error", err))
+	}
+	ff.dir = ""
+	ff.files = nil
+}
+
+// receiverData function returns the data passed to a receiver, sinks get the values of fields delivered as files.
+func receiverData(receiver SecretReceiver, data map[string]interface{}, delivered map[string]interface{}) map[string]interface{} {
+	if _, ok := receiverOf(receiver).(SecretSink); ok {
+		return data
+	}
+	return delivered
+}
@@ -0,0 +1,91 @@
+package vaultsync_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+// valueSink struct is a sink recording the last value of each field.
+type valueSink struct {
+	*vaultsynctest.Recorder
+}
+
+func (s *valueSink) Flush() error { return nil }
+
+// fieldFile function returns the file name received for a field and checks the file holds want.
+func fieldFile(t *testing.T, recorder *vaultsynctest.Recorder, field string, want string) string {
+	t.Helper()
+	value, ok := recorder.Value("secret/data/app", field)
+	name, isString := value.(string)
+	if !ok || !isString {
+		t.Fatalf("got %#v, want the name of a file", value)
+	}
+	content, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != want {
+		t.Fatalf("file holds %q, want %q", content, want)
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("file mode is %v, want 0600", info.Mode().Perm())
+	}
+	return name
+}
+
+func TestFileFields(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"kubeconfig": "apiVersion: v1", "user": "app"})
+
+	dir := t.TempDir()
+	agent := vaultsynctest.NewAgent(t, s, vaultsync.WithFieldFileDir(dir))
+	agent.RegisterFileFields("secret/data/app", "kubeconfig")
+	recorder := vaultsynctest.NewRecorder()
+	agent.RegisterUpdateSecret("secret/data/app", recorder)
+	sink := &valueSink{Recorder: vaultsynctest.NewRecorder()}
+	agent.RegisterSink(sink, "secret/data/app")
+
+	if err := agent.Run(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Stop()
+
+	first := fieldFile(t, recorder, "kubeconfig", "apiVersion: v1")
+	if !strings.HasSuffix(first, "-kubeconfig") {
+		t.Errorf("file name %v does not end with the field", first)
+	}
+	recorder.AssertValue(t, "secret/data/app", "user", "app")
+	sink.AssertValue(t, "secret/data/app", "kubeconfig", "apiVersion: v1")
+
+	// An unchanged value keeps its file.
+	vaultsynctest.Sync(t, agent)
+	if name := fieldFile(t, recorder, "kubeconfig", "apiVersion: v1"); name != first {
+		t.Fatalf("unchanged value moved from %v to %v", first, name)
+	}
+
+	// A rotated value gets a new file and the old one is removed.
+	s.SetField("secret/data/app", "kubeconfig", "apiVersion: v2")
+	vaultsynctest.Sync(t, agent)
+	second := fieldFile(t, recorder, "kubeconfig", "apiVersion: v2")
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Fatalf("file of the rotated value still exists: %v", err)
+	}
+
+	agent.Stop()
+	if _, err := os.Stat(second); !os.IsNotExist(err) {
+		t.Fatalf("file still exists after the agent stopped: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("agent left %v in the temp directory", entries)
+	}
+}
@@ -0,0 +1,236 @@
+package vaultsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FS method returns a read-only file system view of the synced secrets.
+// Every secret path is a directory and every field of the secret is a file in it, for example
+// secrets/data/netpush/redis/password. String values are stored as is, []byte values raw and
+// other values as JSON. Each Open sees the secrets as they are at that moment.
+func (a *Agent) FS() fs.FS {
+	return secretFS{agent: a}
+}
+
+// secretFS struct implements fs.FS over the agent's synced secrets.
+type secretFS struct {
+	agent *Agent
+}
+
+// secretFileInfo struct describes a file or directory of the secret file system.
+type secretFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (fi secretFileInfo) Name() string       { return fi.name }
+func (fi secretFileInfo) Size() int64        { return fi.size }
+func (fi secretFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi secretFileInfo) IsDir() bool        { return fi.dir }
+func (fi secretFileInfo) Sys() any           { return nil }
+
+func (fi secretFileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0500
+	}
+	return 0400
+}
+
+func (fi secretFileInfo) Info() (fs.FileInfo, error) { return fi, nil }
+func (fi secretFileInfo) Type() fs.FileMode          { return fi.Mode().Type() }
+
+// secretFile struct is an open file of the secret file system.
+type secretFile struct {
+	info secretFileInfo
+	r    *strings.Reader
+}
+
+func (f *secretFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *secretFile) Read(b []byte) (int, error) { return f.r.Read(b) }
+func (f *secretFile) Close() error               { return nil }
+
+// secretDir struct is an open directory of the secret file system.
+type secretDir struct {
+	info    secretFileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *secretDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *secretDir) Close() error               { return nil }
+
+func (d *secretDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fmt.Errorf("is a directory")}
+}
+
+// ReadDir method implements fs.ReadDirFile.
+func (d *secretDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	d.offset += n
+	return remaining[:n], nil
+}
+
+// secretFieldEntry struct is the directory entry of a field, whose value is only unsealed when its info is read.
+type secretFieldEntry struct {
+	sfs  secretFS
+	name string
+}
+
+func (e secretFieldEntry) Name() string      { return path.Base(e.name) }
+func (e secretFieldEntry) IsDir() bool       { return false }
+func (e secretFieldEntry) Type() fs.FileMode { return 0 }
+
+// Info method implements fs.DirEntry.
+func (e secretFieldEntry) Info() (fs.FileInfo, error) {
+	f, ok := e.sfs.file(e.name)
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: e.name, Err: fs.ErrNotExist}
+	}
+	return f.info, nil
+}
+
+// Open method implements fs.FS.
+func (sfs secretFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if f, ok := sfs.file(name); ok {
+		return f, nil
+	}
+	if d, ok := sfs.dir(name); ok {
+		return d, nil
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// secretDirName function returns the directory of a secret path, or false when the secret has no files.
+func secretDirName(secretPath string, state *pathState) (string, bool) {
+	dir := strings.Trim(secretPath, "/")
+	return dir, state.data != nil && fs.ValidPath(dir)
+}
+
+// file method opens the file name, unsealing only the value of its field.
+func (sfs secretFS) file(name string) (*secretFile, bool) {
+	secretDir, field := path.Split(name)
+	if secretDir == "" {
+		return nil, false
+	}
+	secretDir = strings.TrimSuffix(secretDir, "/")
+
+	a := sfs.agent
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for secretPath, state := range a.paths {
+		if dir, ok := secretDirName(secretPath, state); !ok || dir != secretDir {
+			continue
+		}
+		value, ok := state.data[field]
+		if !ok {
+			continue
+		}
+		content := fileContent(unsealValue(value))
+		info := secretFileInfo{name: field, size: int64(len(content)), modTime: state.syncedAt()}
+		return &secretFile{info: info, r: strings.NewReader(content)}, true
+	}
+	return nil, false
+}
+
+// dir method opens the directory name, listing the names of fields without unsealing their values.
+// Any prefix of a secret path is a directory.
+func (sfs secretFS) dir(name string) (*secretDir, bool) {
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+
+	a := sfs.agent
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	entries := make(map[string]fs.DirEntry)
+	var modTime time.Time
+	for secretPath, state := range a.paths {
+		dir, ok := secretDirName(secretPath, state)
+		if !ok {
+			continue
+		}
+		found := false
+		switch {
+		case dir == name:
+			for field := range state.data {
+				if strings.Contains(field, "/") || !fs.ValidPath(dir+"/"+field) {
+					continue
+				}
+				// A field shadows a directory of the same name, as Open resolves files first.
+				entries[field] = secretFieldEntry{sfs: sfs, name: dir + "/" + field}
+				found = true
+			}
+		case strings.HasPrefix(dir, prefix) && hasFiles(dir, state):
+			child, _, _ := strings.Cut(dir[len(prefix):], "/")
+			// A directory is as recent as its most recently synced secret.
+			entry, ok := entries[child].(secretFileInfo)
+			if _, isField := entries[child].(secretFieldEntry); !isField && (!ok || state.syncedAt().After(entry.modTime)) {
+				entries[child] = secretFileInfo{name: child, dir: true, modTime: state.syncedAt()}
+			}
+			found = true
+		}
+		if found && state.syncedAt().After(modTime) {
+			modTime = state.syncedAt()
+		}
+	}
+	if len(entries) == 0 && name != "." {
+		return nil, false
+	}
+
+	d := &secretDir{info: secretFileInfo{name: path.Base(name), dir: true, modTime: modTime}}
+	for _, entry := range entries {
+		d.entries = append(d.entries, entry)
+	}
+	sort.Slice(d.entries, func(i, j int) bool { return d.entries[i].Name() < d.entries[j].Name() })
+	return d, true
+}
+
+// hasFiles function reports whether the secret at dir has a field that is a file.
+func hasFiles(dir string, state *pathState) bool {
+	for field := range state.data {
+		if !strings.Contains(field, "/") && fs.ValidPath(dir+"/"+field) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileContent function returns the file content of a secret value.
+func fileContent(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
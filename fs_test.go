@@ -0,0 +1,54 @@
+package vaultsync_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+func TestFS(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"user": "app", "password": "s3cret"})
+	s.SetSecret("secret/data/db/main", map[string]interface{}{"port": 5432, "tls": true})
+	agent := vaultsynctest.NewAgent(t, s)
+	for _, path := range []string{"secret/data/app", "secret/data/db/main"} {
+		agent.RegisterUpdateSecret(path, vaultsynctest.NewRecorder())
+	}
+	vaultsynctest.Sync(t, agent)
+
+	fsys := agent.FS()
+	if err := fstest.TestFS(fsys, "secret/data/app/user", "secret/data/app/password", "secret/data/db/main/port", "secret/data/db/main/tls"); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"secret/data/app/password": "s3cret",
+		"secret/data/db/main/port": "5432",
+		"secret/data/db/main/tls":  "true",
+	} {
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want {
+			t.Errorf("%v is %q, want %q", name, b, want)
+		}
+	}
+
+	for _, name := range []string{"secret/data/app/missing", "secret/data/other", "secret/data/app/password/x"} {
+		if _, err := fsys.Open(name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("opening %v returned %v, want fs.ErrNotExist", name, err)
+		}
+	}
+
+	// Each Open sees the current values.
+	s.SetSecret("secret/data/app", map[string]interface{}{"user": "app", "password": "n3w"})
+	vaultsynctest.Sync(t, agent)
+	if b, err := fs.ReadFile(fsys, "secret/data/app/password"); err != nil || string(b) != "n3w" {
+		t.Fatalf("got %q, %v after rotation", b, err)
+	}
+}
@@ -0,0 +1,138 @@
+package vaultsync
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+)
+
+// secretGroup struct is a group of paths whose receivers are only updated when every path of the group was synced in the same cycle.
+type secretGroup struct {
+	name      string
+	paths     []string
+	receivers []SecretReceiver
+	// delivered are the fields delivered per path, to find the fields deleted by the next update.
+	delivered map[string][]string
+}
+
+// RegisterGroup method registers a receiver for several paths whose secrets must match, such as a certificate, its key and
+// the CA kept at different paths. The paths of a group are always synced in the same cycle, and the receiver gets the fields
+// of all of them only when every path was synced successfully, otherwise it keeps the previous secrets instead of a
+// mismatched certificate and key. The fields are passed like to the receivers of RegisterUpdateSecret, path by path, after
+// the receivers of the paths and in the sync goroutine. It returns an error if the group has no name or no paths,
+// or if a group with the name exists.
+func (a *Agent) RegisterGroup(name string, receiver SecretReceiver, ids ...string) error {
+	if name == "" {
+		return fmt.Errorf("group without a name")
+	}
+	if len(ids) == 0 {
+		return fmt.Errorf("group %v has no paths", name)
+	}
+	for _, group := range a.groups {
+		if group.name == name {
+			return fmt.Errorf("group %v already exists", name)
+		}
+	}
+
+	group := &secretGroup{name: name, receivers: []SecretReceiver{receiver}, delivered: make(map[string][]string)}
+	for _, id := range ids {
+		path := a.expandPath(id)
+		group.paths = mergePaths(group.paths, []string{path})
+		a.trackPath(path)
+	}
+	a.groups = append(a.groups, group)
+	return nil
+}
+
+// withGroupMembers method adds to paths the other paths of the groups they belong to, so a group is synced in one cycle.
+func (a *Agent) withGroupMembers(paths []string) []string {
+	if len(a.groups) == 0 {
+		return paths
+	}
+	syncing := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		syncing[path] = true
+	}
+	for _, group := range a.groups {
+		for _, path := range group.paths {
+			if syncing[path] {
+				paths = mergePaths(paths, group.paths)
+				break
+			}
+		}
+	}
+	return paths
+}
+
+// dispatchGroups method passes the secrets of the groups synced in a cycle to their receivers. A group with a path
+// that failed in the cycle is skipped and logged, its receivers keep the secrets of the last complete sync.
+func (a *Agent) dispatchGroups(log *slog.Logger, paths []string, failed map[string]error) {
+	if len(a.groups) == 0 {
+		return
+	}
+	synced := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		synced[path] = failed[path] == nil
+	}
+
+	for _, group := range a.groups {
+		if _, ok := synced[group.paths[0]]; !ok {
+			continue
+		}
+
+		var missing []string
+		secrets := make(map[string]map[string]interface{}, len(group.paths))
+		versions := make(map[string]int, len(group.paths))
+		for _, path := range group.paths {
+			data, version, ok := a.syncedSecret(path)
+			if !synced[path] || !ok {
+				missing = append(missing, path)
+				continue
+			}
+			secrets[path], versions[path] = data, version
+		}
+		if len(missing) > 0 {
+			a.metrics.IncrCounter(metricGroupsSkipped, 1, map[string]string{"group": group.name})
+			log.Warn("dispatchGroups", slog.String("group", group.name), slog.String("status", "not all paths synced, receivers keep the previous secrets"), slog.Any("paths", missing))
+			continue
+		}
+
+		for _, path := range group.paths {
+			data := secrets[path]
+			delivered, replaced := a.writeFieldFiles(log, path, data)
+			d := secretDispatch{path: path, version: versions[path], deleted: deletedFields(group.delivered[path], data), timestamp: a.clock.Now()}
+			for _, receiver := range group.receivers {
+				d.deliver(receiver, receiverData(receiver, data, delivered))
+			}
+			a.removeFieldFiles(log, replaced)
+			group.delivered[path] = fieldNames(data)
+		}
+		log.Debug("dispatchGroups", slog.String("group", group.name), slog.Any("paths", group.paths))
+	}
+}
+
+// syncedSecret method returns the data and the version of the last sync of a path, read together.
+func (a *Agent) syncedSecret(path string) (map[string]interface{}, int, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	state, ok := a.paths[path]
+	if !ok || state.data == nil {
+		return nil, 0, false
+	}
+	data := make(map[string]interface{}, len(state.data))
+	for key, value := range state.data {
+		data[key] = unsealValue(value)
+	}
+	return data, state.version, true
+}
+
+// fieldNames function returns the names of the fields of data, sorted.
+func fieldNames(data map[string]interface{}) []string {
+	names := make([]string, 0, len(data))
+	for field := range data {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+	return names
+}
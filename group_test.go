@@ -0,0 +1,42 @@
+package vaultsync_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+func TestRegisterGroup(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/tls/cert", map[string]interface{}{"pem": "cert-1"})
+	s.SetSecret("secret/data/tls/key", map[string]interface{}{"pem": "key-1"})
+
+	agent := vaultsynctest.NewAgent(t, s)
+	recorder := vaultsynctest.NewRecorder()
+	if err := agent.RegisterGroup("tls", recorder, "secret/data/tls/cert", "secret/data/tls/key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.RegisterGroup("tls", recorder, "secret/data/tls/ca"); err == nil {
+		t.Fatal("no error for a duplicate group")
+	}
+
+	vaultsynctest.Sync(t, agent)
+	recorder.AssertValue(t, "secret/data/tls/cert", "pem", "cert-1")
+	recorder.AssertValue(t, "secret/data/tls/key", "pem", "key-1")
+
+	// The certificate rotated but its key can't be read, the receiver keeps the matching pair.
+	s.SetSecret("secret/data/tls/cert", map[string]interface{}{"pem": "cert-2"})
+	s.DeleteSecret("secret/data/tls/key")
+	if err := agent.SyncOnce(context.Background()); err == nil {
+		t.Fatal("no error for the deleted key")
+	}
+	recorder.AssertValue(t, "secret/data/tls/cert", "pem", "cert-1")
+	recorder.AssertValue(t, "secret/data/tls/key", "pem", "key-1")
+
+	s.SetSecret("secret/data/tls/key", map[string]interface{}{"pem": "key-2"})
+	vaultsynctest.Sync(t, agent)
+	recorder.AssertValue(t, "secret/data/tls/cert", "pem", "cert-2")
+	recorder.AssertValue(t, "secret/data/tls/key", "pem", "key-2")
+}
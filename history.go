@@ -0,0 +1,90 @@
+package vaultsync
+
+import "time"
+
+// defaultHistorySize is the number of versions kept per path unless WithVersionHistory sets another size.
+const defaultHistorySize = 10
+
+// VersionRecord struct describes a version of a secret path seen by the agent.
+type VersionRecord struct {
+	Version  int                    `json:"version"`        // KV v2 version, 0 if unknown.
+	SyncedAt time.Time              `json:"synced_at"`      // Time the version was first synced.
+	Fields   []string               `json:"fields"`         // Fields that changed from the previous version, empty for the first sync.
+	Data     map[string]interface{} `json:"data,omitempty"` // Values of the version, only kept with WithVersionHistory(size, true).
+}
+
+// WithVersionHistory function sets how many versions of each path are kept in memory, 0 disables the history.
+// With values the data of each version is kept as well, so it can be compared during an incident.
+// Values are kept in locked memory if WithLockedMemory is set. The history keeps 10 versions without values by default.
+func WithVersionHistory(size int, values bool) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.historySize = size
+		opts.historyValues = values
+	}
+}
+
+// recordHistory method appends a version to the history of a path if it is the first sync of the path or the secret rotated.
+func (a *Agent) recordHistory(path string, version int, fields []string, rotated bool, data map[string]interface{}) {
+	if a.historySize <= 0 {
+		return
+	}
+
+	record := VersionRecord{Version: version, SyncedAt: a.clock.Now(), Fields: fields}
+	if a.historyValues {
+		sealed, err := a.sealData(copyData(data))
+		if err == nil {
+			record.Data = sealed
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state := a.paths[path]
+	if state == nil || (!rotated && len(state.history) > 0) {
+		destroyData(record.Data)
+		return
+	}
+	state.history = append(state.history, record)
+	if len(state.history) > a.historySize {
+		destroyData(state.history[0].Data)
+		state.history = state.history[len(state.history)-a.historySize:]
+	}
+}
+
+// History method returns the versions of a secret path seen by the agent, oldest first.
+// Values are only included if the history keeps them, see WithVersionHistory.
+func (a *Agent) History(path string) []VersionRecord {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	state, ok := a.paths[path]
+	if !ok {
+		return nil
+	}
+	return copyHistory(state.history)
+}
+
+// copyHistory function returns a copy of a history with unsealed values. It must be called with mu held.
+func copyHistory(history []VersionRecord) []VersionRecord {
+	records := make([]VersionRecord, len(history))
+	for i, record := range history {
+		records[i] = record
+		if record.Data != nil {
+			records[i].Data = make(map[string]interface{}, len(record.Data))
+			for field, value := range record.Data {
+				records[i].Data[field] = unsealValue(value)
+			}
+		}
+	}
+	return records
+}
+
+// copyData function returns a shallow copy of data.
+func copyData(data map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(data))
+	for field, value := range data {
+		c[field] = value
+	}
+	return c
+}
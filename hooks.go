@@ -0,0 +1,72 @@
+package vaultsync
+
+import "time"
+
+// AuthInfo struct describes the authentication token of the agent after it was renewed or replaced by logging in again.
+// It never contains the token.
+type AuthInfo struct {
+	AuthMethod string        // Authentication method of the agent.
+	Login      bool          // True if the agent logged in again, false if the token was renewed.
+	Renewable  bool          // Whether the token can be renewed.
+	TTL        time.Duration // Remaining TTL of the token, 0 if it does not expire.
+	Time       time.Time     // Time of the renewal or login.
+}
+
+// OnBeforeSync method registers a hook that is called with the correlation ID of a sync cycle before the cycle reads any path.
+// Hooks are called in registration order from the sync goroutine and must be registered before Run.
+func (a *Agent) OnBeforeSync(hook func(cycleID string)) {
+	a.beforeSync = append(a.beforeSync, hook)
+}
+
+// OnAfterSync method registers a hook that is called with the summary of a sync cycle once the cycle has dispatched all paths and flushed the sinks.
+// Hooks are called in registration order from the sync goroutine and must be registered before Run.
+func (a *Agent) OnAfterSync(hook func(summary SyncSummary)) {
+	a.afterSync = append(a.afterSync, hook)
+}
+
+// OnAuthRenewed method registers a hook that is called whenever the authentication token is renewed or the agent logs in again.
+// Hooks are called in registration order from the token goroutine, must not block and must be registered before Run.
+func (a *Agent) OnAuthRenewed(hook func(info AuthInfo)) {
+	a.authRenewed = append(a.authRenewed, hook)
+}
+
+// runBeforeSync method calls the before sync hooks.
+func (a *Agent) runBeforeSync(cycleID string) {
+	for _, hook := range a.beforeSync {
+		hook(cycleID)
+	}
+}
+
+// runAfterSync method calls the after sync hooks.
+func (a *Agent) runAfterSync(summary SyncSummary) {
+	for _, hook := range a.afterSync {
+		hook(summary)
+	}
+}
+
+// runAuthRenewed method counts the renewal for the debug endpoints, sends it to AuthEvents and calls the auth renewed hooks with the remaining TTL of the token in seconds.
+func (a *Agent) runAuthRenewed(login bool, renewable bool, ttl int) {
+	if login {
+		a.stats.Add("relogins", 1)
+	} else {
+		a.stats.Add("token_renewals", 1)
+	}
+	kind := AuthEventRenewed
+	if login {
+		kind = AuthEventReauthenticated
+	}
+	a.sendAuthEvent(AuthEvent{Kind: kind, Renewable: renewable, TTL: time.Duration(ttl) * time.Second})
+	if len(a.authRenewed) == 0 {
+		return
+	}
+	info := AuthInfo{
+		AuthMethod: a.config.Vault.AuthMethod,
+		Login:      login,
+		Renewable:  renewable,
+		TTL:        time.Duration(ttl) * time.Second,
+		Time:       a.clock.Now(),
+	}
+	for _, hook := range a.authRenewed {
+		hook(info)
+	}
+}
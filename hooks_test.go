@@ -0,0 +1,116 @@
+package vaultsync_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+// hookLog struct records the calls of the hooks of an agent in order.
+type hookLog struct {
+	mu     sync.Mutex
+	calls  []string
+	cycles []string
+	auth   []vaultsync.AuthInfo
+}
+
+// add method records a call.
+func (l *hookLog) add(call string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = append(l.calls, call)
+}
+
+// waitCalls method waits until n calls were recorded and returns them.
+func (l *hookLog) waitCalls(t *testing.T, n int) []string {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		l.mu.Lock()
+		calls := append([]string(nil), l.calls...)
+		l.mu.Unlock()
+		if len(calls) >= n {
+			return calls
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got calls %v, want %d", calls, n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	m := vaultsynctest.NewMockClient()
+	m.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := vaultsynctest.NewFakeClock(start)
+	agent := vaultsynctest.NewMockAgent(t, m, vaultsync.WithClock(clock))
+	recorder := vaultsynctest.NewRecorder()
+	agent.RegisterUpdateSecret("secret/data/app", recorder)
+
+	log := &hookLog{}
+	for i := 1; i <= 2; i++ {
+		agent.OnBeforeSync(func(cycleID string) {
+			log.mu.Lock()
+			log.cycles = append(log.cycles, cycleID)
+			log.mu.Unlock()
+			log.add(fmt.Sprintf("before%d updates=%d", i, recorder.Updates()))
+		})
+		agent.OnAfterSync(func(summary vaultsync.SyncSummary) {
+			log.add(fmt.Sprintf("after%d updates=%d fetched=%d cycle=%v", i, recorder.Updates(), summary.Fetched, summary.CycleID != ""))
+		})
+		agent.OnAuthRenewed(func(info vaultsync.AuthInfo) {
+			log.mu.Lock()
+			log.auth = append(log.auth, info)
+			log.mu.Unlock()
+			log.add(fmt.Sprintf("auth%d login=%v", i, info.Login))
+		})
+	}
+	if err := agent.Run(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Stop()
+
+	// The hooks run in registration order, the before hooks before the path is read and the after hooks once it was dispatched.
+	want := []string{
+		"before1 updates=0", "before2 updates=0",
+		"after1 updates=1 fetched=1 cycle=true", "after2 updates=1 fetched=1 cycle=true",
+	}
+	if calls := log.waitCalls(t, 4); strings.Join(calls, ",") != strings.Join(want, ",") {
+		t.Fatalf("got calls %v, want %v", calls, want)
+	}
+
+	// The token of the mock is not renewable and expires after an hour, the agent logs in again after two thirds of it
+	// and then syncs all paths.
+	// The agent waits for the token to expire and for the renew period.
+	clock.BlockUntil(2)
+	clock.Advance(40 * time.Minute)
+	want = append(want,
+		"auth1 login=true", "auth2 login=true",
+		"before1 updates=1", "before2 updates=1",
+		"after1 updates=2 fetched=1 cycle=true", "after2 updates=2 fetched=1 cycle=true",
+	)
+	if calls := log.waitCalls(t, len(want)); strings.Join(calls, ",") != strings.Join(want, ",") {
+		t.Fatalf("got calls %v, want %v", calls, want)
+	}
+
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	if len(log.cycles) != 4 || log.cycles[0] != log.cycles[1] || log.cycles[2] != log.cycles[3] || log.cycles[0] == log.cycles[2] {
+		t.Fatalf("got cycle IDs %v, want one per cycle", log.cycles)
+	}
+	info := log.auth[0]
+	if info != log.auth[1] {
+		t.Fatalf("hooks got different infos %+v and %+v", info, log.auth[1])
+	}
+	if info.AuthMethod != "approle" || !info.Login || info.Renewable || info.TTL != vaultsynctest.TokenTTL || !info.Time.Equal(start.Add(40*time.Minute)) {
+		t.Fatalf("got %+v, want a login with approle for a non-renewable token with a TTL of an hour at +40m", info)
+	}
+}
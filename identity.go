@@ -0,0 +1,215 @@
+package vaultsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"sync"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// identityConfig struct defines an identity, such as the AppRole of a team, whose token the agent uses instead of its own
+// for the paths matching the path.Match patterns in paths. Credentials are the same as those of the agent.
+type identityConfig struct {
+	Name       string   `hcl:"name,label"`
+	AuthMethod string   `hcl:"authmethod"`
+	Username   string   `hcl:"username,optional"`
+	Password   string   `hcl:"password,optional"`
+	TokenFile  string   `hcl:"token_file,optional"`
+	Paths      []string `hcl:"paths"`
+}
+
+// validate method checks the identity configuration.
+func (ic identityConfig) validate() error {
+	switch ic.AuthMethod {
+	case "approle", "ldap", "userpass":
+		if ic.Username == "" || ic.Password == "" {
+			return fmt.Errorf("identity %v: authentication method %v needs a username and a password", ic.Name, ic.AuthMethod)
+		}
+	case "token_file":
+		if ic.TokenFile == "" {
+			return fmt.Errorf("identity %v: authentication method token_file needs a token_file", ic.Name)
+		}
+	case "token":
+	default:
+		return fmt.Errorf("identity %v: undefined vault authentication method %q", ic.Name, ic.AuthMethod)
+	}
+	return validateIdentityPaths(ic.Name, ic.Paths)
+}
+
+// validateIdentityPaths function checks the path patterns of an identity.
+func validateIdentityPaths(name string, paths []string) error {
+	if len(paths) == 0 {
+		return fmt.Errorf("identity %v has no paths", name)
+	}
+	for _, pattern := range paths {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("identity %v: invalid path %q:%w", name, pattern, err)
+		}
+	}
+	return nil
+}
+
+// WithIdentity function makes the agent read the paths matching the path.Match patterns in paths, such as secrets/data/team-a/*,
+// with a token of authMethod instead of its own token, so one process can read the secrets of several teams without one
+// over-privileged token. Identities given as options are matched before the identity blocks of the configuration file.
+func WithIdentity(name string, authMethod vault.AuthMethod, paths ...string) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.identityOpts = append(opts.identityOpts, &identity{name: name, authMethod: authMethod, paths: append([]string(nil), paths...)})
+	}
+}
+
+// identity struct is an identity and its current token. It logs in on first use and again before its token expires,
+// or after Vault denied a request with it.
+type identity struct {
+	name       string
+	authMethod vault.AuthMethod
+	paths      []string
+
+	mu      sync.Mutex
+	client  *vault.Client
+	api     Client
+	renewAt time.Time
+}
+
+// newIdentities method sets up the identities of the options and the configuration file.
+func (a *Agent) newIdentities() error {
+	for _, id := range a.identityOpts {
+		if id.authMethod == nil {
+			return fmt.Errorf("identity %v has no authentication method", id.name)
+		}
+		if err := validateIdentityPaths(id.name, id.paths); err != nil {
+			return err
+		}
+		for i, pattern := range id.paths {
+			expanded, err := a.ExpandPath(pattern)
+			if err != nil {
+				return err
+			}
+			id.paths[i] = expanded
+		}
+		a.identities = append(a.identities, id)
+	}
+
+	for _, ic := range a.config.Vault.Identities {
+		authMethod, err := newAuthMethod(ic.AuthMethod, ic.Username, ic.Password, ic.TokenFile)
+		if err != nil {
+			return fmt.Errorf("identity %v:%w", ic.Name, err)
+		}
+		a.identities = append(a.identities, &identity{name: ic.Name, authMethod: authMethod, paths: ic.Paths})
+	}
+	return nil
+}
+
+// identityFor method returns the identity whose paths match path, nil if the path is read with the token of the agent.
+func (a *Agent) identityFor(p string) *identity {
+	for _, id := range a.identities {
+		for _, pattern := range id.paths {
+			if ok, _ := path.Match(pattern, p); ok {
+				return id
+			}
+		}
+	}
+	return nil
+}
+
+// pathClient method returns the Vault client and the client of the agent to use for path, logged in with the identity of the path if it has one.
+func (a *Agent) pathClient(ctx context.Context, path string) (*vault.Client, Client, error) {
+	id := a.identityFor(path)
+	if id == nil {
+		return a.client, a.api, nil
+	}
+	return a.identityLogin(ctx, id)
+}
+
+// identityLogin method returns the clients of an identity, logging in if it has no token yet or two thirds of the TTL of its token passed.
+func (a *Agent) identityLogin(ctx context.Context, id *identity) (*vault.Client, Client, error) {
+	id.mu.Lock()
+	defer id.mu.Unlock()
+
+	if id.client != nil && (id.renewAt.IsZero() || a.clock.Now().Before(id.renewAt)) {
+		return id.client, id.api, nil
+	}
+
+	client, err := a.client.CloneWithHeaders()
+	if err != nil {
+		return nil, nil, err
+	}
+	client.ClearToken()
+	api := a.newClient(client)
+
+	if err := a.breaker.allow(); err != nil {
+		return nil, nil, fmt.Errorf("identity %v:%w", id.name, err)
+	}
+	secret, err := api.Login(ctx, id.authMethod)
+	a.recordVaultCall(err)
+	if err == nil && (secret == nil || secret.Auth == nil) {
+		err = fmt.Errorf("login returned no authentication token")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("identity %v:%w", id.name, err)
+	}
+	client.SetToken(secret.Auth.ClientToken)
+
+	ttl := time.Duration(secret.Auth.LeaseDuration) * time.Second
+	id.client, id.api, id.renewAt = client, api, time.Time{}
+	if ttl > 0 {
+		id.renewAt = a.clock.Now().Add(ttl * 2 / 3)
+	}
+	a.logs.auth.Info("identityLogin", slog.String("identity", id.name), slog.Duration("ttl", ttl))
+	return client, api, nil
+}
+
+// revokeIdentities method revokes the tokens the identities logged in with. Tokens given to an identity with the token or
+// token_file method belong to someone else and are kept.
+func (a *Agent) revokeIdentities(ctx context.Context) {
+	for _, id := range a.identities {
+		switch id.authMethod.(type) {
+		case *tokenAuth, *tokenFileAuth:
+			continue
+		}
+
+		id.mu.Lock()
+		api := id.api
+		id.client, id.api = nil, nil
+		id.mu.Unlock()
+		if api == nil {
+			continue
+		}
+
+		if err := api.RevokeSelf(ctx); err != nil {
+			a.logs.auth.Error("shutdown", slog.String("identity", id.name), slog.String("status", "failed to revoke token"), slog.Any("error", err))
+			continue
+		}
+		a.logs.auth.Info("shutdown", slog.String("identity", id.name), slog.String("status", "token revoked"))
+	}
+}
+
+// resetIdentities method drops the clients of all identities, so they are cloned from the client of the agent and log in again
+// on first use, for example after a failover switched the agent to another server.
+func (a *Agent) resetIdentities() {
+	for _, id := range a.identities {
+		id.mu.Lock()
+		id.client, id.api = nil, nil
+		id.mu.Unlock()
+	}
+}
+
+// checkIdentityError method drops the token of the identity of path if Vault denied a request with it, so the next request logs in again.
+func (a *Agent) checkIdentityError(path string, err error) {
+	var respErr *vault.ResponseError
+	if err == nil || !errors.As(err, &respErr) || respErr.StatusCode != 403 {
+		return
+	}
+	id := a.identityFor(path)
+	if id == nil {
+		return
+	}
+	id.mu.Lock()
+	id.client, id.api = nil, nil
+	id.mu.Unlock()
+}
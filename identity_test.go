@@ -0,0 +1,37 @@
+package vaultsync_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/api/auth/approle"
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+func TestIdentityTokenRevokedOnStop(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/team-a/db", map[string]interface{}{"password": "team-a"})
+
+	auth, err := approle.NewAppRoleAuth(vaultsynctest.Username, &approle.SecretID{FromString: vaultsynctest.Password})
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent := vaultsynctest.NewAgent(t, s, vaultsync.WithIdentity("team-a", auth, "secret/data/team-a/*"), vaultsync.WithRevokeTokenOnStop())
+	recorder := vaultsynctest.NewRecorder()
+	agent.RegisterUpdateSecret("secret/data/team-a/db", recorder)
+
+	if err := agent.Run(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	recorder.AssertValue(t, "secret/data/team-a/db", "password", "team-a")
+	if tokens := s.Tokens(); tokens != 2 {
+		t.Fatalf("got %d tokens, want the tokens of the agent and the identity", tokens)
+	}
+
+	agent.Stop()
+	if tokens := s.Tokens(); tokens != 0 {
+		t.Fatalf("got %d tokens after stop, want all revoked", tokens)
+	}
+}
@@ -0,0 +1,135 @@
+package vaultsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JSONField function returns a transform that adds a field holding a value nested in a field of JSON, so receivers get
+// single values of a secret that stores a JSON document. expr names the field and a JSONPath into it, separated by #:
+//
+//	vaultsync.JSONField("db_password", "config.json#$.database.password")
+//
+// The JSONPath supports the root $, child keys as .key or ['key'] and list indexes as [0]. The field may hold a JSON
+// string or a value already decoded, for example by DecodeJSONField. The sync of the path fails if the value is missing.
+func JSONField(name string, expr string) Transform {
+	field, path, err := parseJSONFieldExpr(expr)
+	return func(data map[string]interface{}) (map[string]interface{}, error) {
+		if err != nil {
+			return nil, err
+		}
+		value, ok := data[field]
+		if !ok {
+			return nil, fmt.Errorf("field %v does not exist", field)
+		}
+		if s, ok := value.(string); ok {
+			if err := json.Unmarshal([]byte(s), &value); err != nil {
+				return nil, fmt.Errorf("field %v is not valid JSON:%w", field, err)
+			}
+		}
+		for _, step := range path {
+			if value, err = step.apply(value); err != nil {
+				return nil, fmt.Errorf("%v:%w", expr, err)
+			}
+		}
+		data[name] = value
+		return data, nil
+	}
+}
+
+// jsonPathStep struct is a step of a JSONPath, a child key or, if key is empty, a list index.
+type jsonPathStep struct {
+	key   string
+	index int
+}
+
+// apply method returns the child of value selected by the step.
+func (s jsonPathStep) apply(value interface{}) (interface{}, error) {
+	if s.key != "" {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("key %v of a value that is not an object", s.key)
+		}
+		child, ok := object[s.key]
+		if !ok {
+			return nil, fmt.Errorf("key %v does not exist", s.key)
+		}
+		return child, nil
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("index %d of a value that is not a list", s.index)
+	}
+	if s.index < 0 || s.index >= len(list) {
+		return nil, fmt.Errorf("index %d out of range", s.index)
+	}
+	return list[s.index], nil
+}
+
+// parseJSONFieldExpr function splits a field#jsonpath expression into the field and the steps of the path.
+func parseJSONFieldExpr(expr string) (string, []jsonPathStep, error) {
+	field, path, ok := strings.Cut(expr, "#")
+	if !ok || field == "" {
+		return "", nil, fmt.Errorf("expression %q is not of the form field#$.path", expr)
+	}
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("expression %q:%w", expr, err)
+	}
+	return field, steps, nil
+}
+
+// parseJSONPath function parses a JSONPath of child keys and list indexes, such as $.servers[0]['host name'].
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	rest, ok := strings.CutPrefix(path, "$")
+	if !ok {
+		return nil, fmt.Errorf("path must start with $")
+	}
+
+	var steps []jsonPathStep
+	for rest != "" {
+		switch {
+		case rest[0] == '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key := rest[1 : end+1]
+			if key == "" {
+				return nil, fmt.Errorf("empty key in path")
+			}
+			steps = append(steps, jsonPathStep{key: key})
+			rest = rest[end+1:]
+
+		case strings.HasPrefix(rest, "['"):
+			end := strings.Index(rest, "']")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated key in path")
+			}
+			key := rest[2:end]
+			if key == "" {
+				return nil, fmt.Errorf("empty key in path")
+			}
+			steps = append(steps, jsonPathStep{key: key})
+			rest = rest[end+2:]
+
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated index in path")
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid index %q in path", rest[1:end])
+			}
+			steps = append(steps, jsonPathStep{index: index})
+			rest = rest[end+1:]
+
+		default:
+			return nil, fmt.Errorf("unexpected %q in path", rest)
+		}
+	}
+	return steps, nil
+}
@@ -0,0 +1,38 @@
+package vaultsync_test
+
+import (
+	"testing"
+
+	"github.com/pergus/vaultsync"
+)
+
+func TestJSONField(t *testing.T) {
+	config := `{"database": {"password": "s3cret", "hosts": ["db1", "db2"]}, "api keys": {"pay": "k3y"}}`
+	tests := []struct {
+		expr string
+		want interface{}
+	}{
+		{"config.json#$.database.password", "s3cret"},
+		{"config.json#$.database.hosts[1]", "db2"},
+		{"config.json#$['api keys'].pay", "k3y"},
+	}
+	for _, test := range tests {
+		data, err := vaultsync.JSONField("value", test.expr)(map[string]interface{}{"config.json": config})
+		if err != nil {
+			t.Errorf("%v: %v", test.expr, err)
+			continue
+		}
+		if data["value"] != test.want {
+			t.Errorf("%v: got %v, want %v", test.expr, data["value"], test.want)
+		}
+		if data["config.json"] != config {
+			t.Errorf("%v: the JSON field was changed", test.expr)
+		}
+	}
+
+	for _, expr := range []string{"config.json#$.database.user", "config.json#$.database.hosts[2]", "config.json#database", "#$.a", "missing#$.a"} {
+		if _, err := vaultsync.JSONField("value", expr)(map[string]interface{}{"config.json": config}); err == nil {
+			t.Errorf("%v: no error", expr)
+		}
+	}
+}
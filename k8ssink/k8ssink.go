@@ -0,0 +1,211 @@
+// Package k8ssink provides a vaultsync.SecretSink that mirrors a Vault secret into a Kubernetes Secret.
+package k8ssink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/pergus/vaultsync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ManagedByLabel marks Kubernetes Secrets owned by vaultsync. Secrets without it are never overwritten.
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+
+	// ManagedByValue is the value of ManagedByLabel on Secrets owned by vaultsync.
+	ManagedByValue = "vaultsync"
+
+	// PathAnnotation records the Vault path a Secret is mirrored from.
+	PathAnnotation = "vaultsync.io/path"
+
+	// requestTimeout bounds each call to the Kubernetes API.
+	requestTimeout = 30 * time.Second
+)
+
+// Config struct defines which Vault path is mirrored into which Kubernetes Secret.
+type Config struct {
+	Path      string            // Vault secret path.
+	Namespace string            // Namespace of the Kubernetes Secret.
+	Name      string            // Name of the Kubernetes Secret.
+	Labels    map[string]string // Extra labels set on the Kubernetes Secret.
+	DryRun    bool              // Validate create and update server side without persisting them.
+}
+
+// Sink struct mirrors a Vault secret into a Kubernetes Secret of type Opaque.
+// Every field of the Vault secret becomes a key of the Kubernetes Secret, and keys of fields deleted in Vault are removed.
+type Sink struct {
+	client kubernetes.Interface
+	config Config
+
+	mu     sync.Mutex
+	path   string
+	fields map[string][]byte
+	seen   map[string]bool
+	dirty  bool
+}
+
+// New function creates a sink. Register it with sink.Register(agent).
+func New(client kubernetes.Interface, config Config) *Sink {
+	return &Sink{
+		client: client,
+		config: config,
+		path:   config.Path,
+		fields: make(map[string][]byte),
+		seen:   make(map[string]bool),
+	}
+}
+
+// Register method expands the placeholders of the Vault path with the path variables of the agent
+// and registers the sink with the agent for the expanded path.
+func (s *Sink) Register(agent *vaultsync.Agent) error {
+	path, err := agent.ExpandPath(s.config.Path)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.path = path
+	s.mu.Unlock()
+
+	agent.RegisterSink(s, path)
+	return nil
+}
+
+// Path method returns the Vault path mirrored by the sink, with its placeholders expanded once the sink is registered.
+func (s *Sink) Path() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.path
+}
+
+// UpdateSecret method collects a field of the mirrored secret.
+func (s *Sink) UpdateSecret(id string, fieldName string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id != s.path {
+		return
+	}
+	s.seen[fieldName] = true
+
+	// Binary values decoded with DecodeBase64 are stored as they are, the Kubernetes Secret holds the raw bytes.
+	var b []byte
+	if v, ok := value.([]byte); ok {
+		b = bytes.Clone(v)
+	} else {
+		b = []byte(fmt.Sprint(value))
+	}
+	old, ok := s.fields[fieldName]
+	if ok && reflect.DeepEqual(old, b) {
+		clear(b)
+		return
+	}
+	// Overwrite the replaced value so it does not linger in memory.
+	clear(old)
+	s.fields[fieldName] = b
+	s.dirty = true
+}
+
+// prune method removes the fields that were not received again in a cycle where the secret was synced,
+// so keys deleted in Vault are removed from the Kubernetes Secret. It must be called with mu held.
+func (s *Sink) prune() {
+	if len(s.seen) == 0 {
+		return
+	}
+	for field, value := range s.fields {
+		if !s.seen[field] {
+			clear(value)
+			delete(s.fields, field)
+			s.dirty = true
+		}
+	}
+	s.seen = make(map[string]bool)
+}
+
+// Flush method creates or updates the Kubernetes Secret if the Vault secret changed.
+// An existing Secret that is not labeled as managed by vaultsync is left untouched and an error is returned.
+// The Secret is written again by the next flush if the write failed.
+func (s *Sink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune()
+	if !s.dirty {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	var dryRun []string
+	if s.config.DryRun {
+		dryRun = []string{metav1.DryRunAll}
+	}
+
+	secrets := s.client.CoreV1().Secrets(s.config.Namespace)
+	existing, err := secrets.Get(ctx, s.config.Name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		_, err = secrets.Create(ctx, s.secret(nil), metav1.CreateOptions{DryRun: dryRun})
+		if err != nil {
+			return fmt.Errorf("create secret %v/%v: %w", s.config.Namespace, s.config.Name, err)
+		}
+
+	case err != nil:
+		return fmt.Errorf("get secret %v/%v: %w", s.config.Namespace, s.config.Name, err)
+
+	default:
+		if existing.Labels[ManagedByLabel] != ManagedByValue {
+			return fmt.Errorf("secret %v/%v is not managed by vaultsync", s.config.Namespace, s.config.Name)
+		}
+		_, err = secrets.Update(ctx, s.secret(existing), metav1.UpdateOptions{DryRun: dryRun})
+		if err != nil {
+			return fmt.Errorf("update secret %v/%v: %w", s.config.Namespace, s.config.Name, err)
+		}
+	}
+
+	s.dirty = false
+	return nil
+}
+
+// secret method builds the desired Kubernetes Secret, based on existing if it is not nil.
+func (s *Sink) secret(existing *corev1.Secret) *corev1.Secret {
+	secret := &corev1.Secret{}
+	if existing != nil {
+		secret = existing.DeepCopy()
+	}
+
+	secret.Name = s.config.Name
+	secret.Namespace = s.config.Namespace
+	secret.Type = corev1.SecretTypeOpaque
+
+	if secret.Labels == nil {
+		secret.Labels = make(map[string]string)
+	}
+	for key, value := range s.config.Labels {
+		secret.Labels[key] = value
+	}
+	secret.Labels[ManagedByLabel] = ManagedByValue
+
+	if secret.Annotations == nil {
+		secret.Annotations = make(map[string]string)
+	}
+	secret.Annotations[PathAnnotation] = s.path
+
+	secret.Data = make(map[string][]byte, len(s.fields))
+	for key, value := range s.fields {
+		secret.Data[key] = value
+	}
+
+	return secret
+}
@@ -0,0 +1,53 @@
+package k8ssink_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/k8ssink"
+	"github.com/pergus/vaultsync/vaultsynctest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSinkMirrorsSecret(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/prod/redis", map[string]interface{}{"username": "app", "password": "s3cret"})
+
+	agent := vaultsynctest.NewAgent(t, s, vaultsync.WithPathVariables(map[string]string{"env": "prod"}))
+	client := fake.NewSimpleClientset()
+	sink := k8ssink.New(client, k8ssink.Config{Path: "secret/data/{env}/redis", Namespace: "app", Name: "redis"})
+	if err := sink.Register(agent); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func() map[string][]byte {
+		t.Helper()
+		secret, err := client.CoreV1().Secrets("app").Get(context.Background(), "redis", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path := secret.Annotations[k8ssink.PathAnnotation]; path != "secret/data/prod/redis" {
+			t.Fatalf("path annotation is %q", path)
+		}
+		return secret.Data
+	}
+
+	vaultsynctest.Sync(t, agent)
+	if data := get(); string(data["username"]) != "app" || string(data["password"]) != "s3cret" {
+		t.Fatalf("secret data is %v", data)
+	}
+
+	// A field deleted in Vault is removed from the Kubernetes Secret.
+	s.SetSecret("secret/data/prod/redis", map[string]interface{}{"password": "n3w"})
+	vaultsynctest.Sync(t, agent)
+	data := get()
+	if _, ok := data["username"]; ok {
+		t.Fatal("deleted field username is still in the secret")
+	}
+	if string(data["password"]) != "n3w" {
+		t.Fatalf("password is %q, want n3w", data["password"])
+	}
+}
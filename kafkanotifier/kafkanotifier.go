@@ -0,0 +1,51 @@
+// Package kafkanotifier provides a vaultsync.Notifier that publishes rotation events to Kafka.
+package kafkanotifier
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pergus/vaultsync"
+	"github.com/segmentio/kafka-go"
+)
+
+// Notifier struct publishes rotation events as JSON messages to a Kafka topic.
+// Messages are keyed by secret path so rotations of the same path stay ordered.
+type Notifier struct {
+	writer *kafka.Writer
+}
+
+// New function creates a notifier publishing to topic on the given brokers.
+func New(brokers []string, topic string) *Notifier {
+	return &Notifier{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+		},
+	}
+}
+
+// NewWithWriter function creates a notifier using a preconfigured Kafka writer, for example one with TLS or SASL.
+func NewWithWriter(writer *kafka.Writer) *Notifier {
+	return &Notifier{writer: writer}
+}
+
+// Notify method publishes the rotation event.
+func (n *Notifier) Notify(ctx context.Context, event vaultsync.RotationEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return n.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(event.Path),
+		Value: data,
+	})
+}
+
+// Close method flushes pending messages and closes the underlying writer.
+func (n *Notifier) Close() error {
+	return n.writer.Close()
+}
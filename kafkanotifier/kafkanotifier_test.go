@@ -0,0 +1,130 @@
+package kafkanotifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pergus/vaultsync"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/protocol"
+	metadataAPI "github.com/segmentio/kafka-go/protocol/metadata"
+	produceAPI "github.com/segmentio/kafka-go/protocol/produce"
+)
+
+// record struct is a record produced to the fake broker.
+type record struct {
+	topic string
+	acks  int16
+	key   string
+	value []byte
+}
+
+// fakeBroker struct is a kafka.RoundTripper answering the metadata and produce requests of a writer,
+// as a single broker with one partition per topic.
+type fakeBroker struct {
+	mu      sync.Mutex
+	records []record
+	err     error
+}
+
+// RoundTrip method implements kafka.RoundTripper.
+func (b *fakeBroker) RoundTrip(ctx context.Context, addr net.Addr, req kafka.Request) (kafka.Response, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch req := req.(type) {
+	case *metadataAPI.Request:
+		res := &metadataAPI.Response{Brokers: []metadataAPI.ResponseBroker{{NodeID: 1, Host: "127.0.0.1", Port: 9092}}}
+		for _, topic := range req.TopicNames {
+			res.Topics = append(res.Topics, metadataAPI.ResponseTopic{
+				Name:       topic,
+				Partitions: []metadataAPI.ResponsePartition{{PartitionIndex: 0, LeaderID: 1}},
+			})
+		}
+		return res, nil
+
+	case *produceAPI.Request:
+		if b.err != nil {
+			return nil, b.err
+		}
+		res := &produceAPI.Response{}
+		for _, topic := range req.Topics {
+			for _, partition := range topic.Partitions {
+				for {
+					r, err := partition.RecordSet.Records.ReadRecord()
+					if err == io.EOF {
+						break
+					}
+					if err != nil {
+						return nil, err
+					}
+					key, _ := protocol.ReadAll(r.Key)
+					value, _ := protocol.ReadAll(r.Value)
+					b.records = append(b.records, record{topic: topic.Topic, acks: req.Acks, key: string(key), value: value})
+				}
+			}
+			res.Topics = append(res.Topics, produceAPI.ResponseTopic{Topic: topic.Topic, Partitions: []produceAPI.ResponsePartition{{Partition: 0}}})
+		}
+		return res, nil
+	}
+	return nil, fmt.Errorf("unexpected request %T", req)
+}
+
+// newTestNotifier function creates a notifier with New whose writer sends to broker.
+func newTestNotifier(t *testing.T, broker *fakeBroker) *Notifier {
+	t.Helper()
+
+	n := New([]string{"127.0.0.1:9092"}, "vault.rotations")
+	n.writer.Transport = broker
+	n.writer.BatchTimeout = time.Millisecond
+	n.writer.MaxAttempts = 1
+	t.Cleanup(func() { n.Close() })
+	return n
+}
+
+func TestNotifyProducesEvent(t *testing.T) {
+	broker := &fakeBroker{}
+	n := newTestNotifier(t, broker)
+
+	event := vaultsync.RotationEvent{CycleID: "cycle-1", Path: "secret/data/app", Fields: []string{"password"}, Version: 3, Timestamp: time.Now().UTC()}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := n.Notify(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	if len(broker.records) != 1 {
+		t.Fatalf("got %d records, want 1", len(broker.records))
+	}
+	r := broker.records[0]
+	// Records are keyed by path so the rotations of a path stay ordered, and written to all replicas.
+	if r.topic != "vault.rotations" || r.key != event.Path || r.acks != int16(kafka.RequireAll) {
+		t.Fatalf("got topic %v key %v acks %v", r.topic, r.key, r.acks)
+	}
+	var got vaultsync.RotationEvent
+	if err := json.Unmarshal(r.value, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Path != event.Path || got.Version != event.Version || len(got.Fields) != 1 || got.Fields[0] != "password" {
+		t.Fatalf("got %+v, want %+v", got, event)
+	}
+}
+
+func TestNotifyReturnsProduceErrors(t *testing.T) {
+	broker := &fakeBroker{err: fmt.Errorf("broker unavailable")}
+	n := newTestNotifier(t, broker)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := n.Notify(ctx, vaultsync.RotationEvent{Path: "secret/data/app"}); err == nil {
+		t.Fatal("Notify succeeded while the broker failed")
+	}
+}
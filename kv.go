@@ -0,0 +1,187 @@
+package vaultsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// ErrVersionConflict is returned by check-and-set writes when the secret's current version does not match the expected version.
+var ErrVersionConflict = errors.New("secret version conflict")
+
+// WriteSecret method writes data as a new version of a KV v2 secret, using the agent's authenticated client.
+// The path is the data path of the secret, as used when registering receivers, e.g. secrets/data/app/db.
+// It returns the version that was created. Registered receivers see the new data on the next sync.
+func (a *Agent) WriteSecret(ctx context.Context, path string, data map[string]interface{}) (int, error) {
+	return a.writeSecret(ctx, path, map[string]interface{}{"data": data})
+}
+
+// WriteSecretCAS method writes data as a new version of a KV v2 secret only if the current version of the secret is version.
+// A version of 0 only writes if the secret does not exist. ErrVersionConflict is returned if another writer got there first.
+func (a *Agent) WriteSecretCAS(ctx context.Context, path string, data map[string]interface{}, version int) (int, error) {
+	return a.writeSecret(ctx, path, map[string]interface{}{
+		"data":    data,
+		"options": map[string]interface{}{"cas": version},
+	})
+}
+
+// PatchSecret method updates the given fields of a KV v2 secret with a JSON merge patch, leaving the other fields unchanged.
+// A field set to nil is removed. The patch is applied by Vault, so there is no read-modify-write race. It returns the created version.
+func (a *Agent) PatchSecret(ctx context.Context, path string, data map[string]interface{}) (int, error) {
+	_, api, err := a.pathClient(ctx, path)
+	if err != nil {
+		return 0, fmt.Errorf("patch secret %v:%w", path, err)
+	}
+	secret, err := api.Patch(ctx, path, map[string]interface{}{"data": data})
+	if err != nil {
+		if isVersionConflict(err) {
+			return 0, fmt.Errorf("patch secret %v:%w", path, ErrVersionConflict)
+		}
+		return 0, fmt.Errorf("patch secret %v:%w", path, err)
+	}
+	if secret == nil {
+		return 0, nil
+	}
+	return versionNumber(secret.Data["version"]), nil
+}
+
+// writeSecret method writes a KV v2 request body and returns the created version.
+func (a *Agent) writeSecret(ctx context.Context, path string, body map[string]interface{}) (int, error) {
+	_, api, err := a.pathClient(ctx, path)
+	if err != nil {
+		return 0, fmt.Errorf("write secret %v:%w", path, err)
+	}
+	secret, err := api.Write(ctx, path, body)
+	if err != nil {
+		if isVersionConflict(err) {
+			return 0, fmt.Errorf("write secret %v:%w", path, ErrVersionConflict)
+		}
+		return 0, fmt.Errorf("write secret %v:%w", path, err)
+	}
+	if secret == nil {
+		return 0, nil
+	}
+	return versionNumber(secret.Data["version"]), nil
+}
+
+// isVersionConflict function reports whether a Vault error is a check-and-set mismatch.
+func isVersionConflict(err error) bool {
+	var respErr *vault.ResponseError
+	if !errors.As(err, &respErr) || respErr.StatusCode != 400 {
+		return false
+	}
+	for _, e := range respErr.Errors {
+		if strings.Contains(e, "check-and-set") {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteSecret method soft deletes versions of a KV v2 secret. Without versions the latest version is deleted.
+// Deleted versions can be restored with UndeleteSecret.
+func (a *Agent) DeleteSecret(ctx context.Context, path string, versions ...int) error {
+	if len(versions) == 0 {
+		_, api, err := a.pathClient(ctx, path)
+		if err == nil {
+			_, err = api.Delete(ctx, path)
+		}
+		if err != nil {
+			return fmt.Errorf("delete secret %v:%w", path, err)
+		}
+		return nil
+	}
+	return a.versionOperation(ctx, path, "delete", versions)
+}
+
+// UndeleteSecret method restores soft deleted versions of a KV v2 secret.
+func (a *Agent) UndeleteSecret(ctx context.Context, path string, versions ...int) error {
+	return a.versionOperation(ctx, path, "undelete", versions)
+}
+
+// DestroySecretVersions method permanently removes the data of versions of a KV v2 secret.
+func (a *Agent) DestroySecretVersions(ctx context.Context, path string, versions ...int) error {
+	return a.versionOperation(ctx, path, "destroy", versions)
+}
+
+// versionOperation method runs a KV v2 delete, undelete or destroy operation on versions of a secret.
+func (a *Agent) versionOperation(ctx context.Context, path string, operation string, versions []int) error {
+	if len(versions) == 0 {
+		return fmt.Errorf("%v secret %v: no versions given", operation, path)
+	}
+	opPath, err := kvOperationPath(path, operation)
+	if err != nil {
+		return err
+	}
+	_, api, err := a.pathClient(ctx, path)
+	if err != nil {
+		return fmt.Errorf("%v secret %v:%w", operation, path, err)
+	}
+	if _, err := api.Write(ctx, opPath, map[string]interface{}{"versions": versions}); err != nil {
+		return fmt.Errorf("%v secret %v:%w", operation, path, err)
+	}
+	return nil
+}
+
+// kvOperationPath function converts a KV v2 data path, mount/data/name, to the path of another endpoint, mount/operation/name.
+func kvOperationPath(path string, operation string) (string, error) {
+	mount, name, ok := strings.Cut(strings.TrimPrefix(path, "/"), "/data/")
+	if !ok || mount == "" || name == "" {
+		return "", fmt.Errorf("%v is not a KV v2 data path", path)
+	}
+	return mount + "/" + operation + "/" + name, nil
+}
+
+// WithRollbackSync function makes Rollback start a sync cycle of the running agent right away,
+// so the receivers get the rolled back data without waiting for the renew period.
+func WithRollbackSync() AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.rollbackSync = true
+	}
+}
+
+// Rollback method reverts a KV v2 secret to an earlier version by writing the data of that version as a new version,
+// like vault kv rollback. The write is a check-and-set against the version read first, so a concurrent rotation
+// makes it fail with ErrVersionConflict. It returns the version that was created.
+func (a *Agent) Rollback(ctx context.Context, path string, version int) (int, error) {
+	_, api, err := a.pathClient(ctx, path)
+	if err != nil {
+		return 0, fmt.Errorf("rollback %v:%w", path, err)
+	}
+	current, err := api.Read(ctx, path)
+	if err != nil {
+		return 0, fmt.Errorf("rollback %v:%w", path, err)
+	}
+	cas := 0
+	if current != nil {
+		cas = secretVersion(current)
+	}
+
+	old, err := api.ReadWithData(ctx, path, map[string][]string{"version": {strconv.Itoa(version)}})
+	if err != nil {
+		return 0, fmt.Errorf("rollback %v to version %d:%w", path, version, err)
+	}
+	var data map[string]interface{}
+	if old != nil {
+		data, _ = old.Data["data"].(map[string]interface{})
+	}
+	if data == nil {
+		return 0, fmt.Errorf("rollback %v: version %d has no data, it does not exist or was deleted or destroyed", path, version)
+	}
+
+	created, err := a.WriteSecretCAS(ctx, path, data, cas)
+	if err != nil {
+		return 0, err
+	}
+	a.log.Info("Rollback", slog.String("secret-path", path), slog.Int("from", cas), slog.Int("to", version), slog.Int("version", created))
+
+	if a.rollbackSync {
+		a.requestSync()
+	}
+	return created, nil
+}
@@ -0,0 +1,115 @@
+package vaultsync_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+// racingClient struct is a client whose reads of old versions run concurrently with a rotation of the secret.
+type racingClient struct {
+	vaultsync.Client
+	rotate func()
+}
+
+// ReadWithData method rotates the secret before reading the version.
+func (c *racingClient) ReadWithData(ctx context.Context, path string, data map[string][]string) (*vault.Secret, error) {
+	c.rotate()
+	return c.Client.ReadWithData(ctx, path, data)
+}
+
+func TestRollback(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "one"})
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "two"})
+	agent := vaultsynctest.NewAgent(t, s)
+
+	version, err := agent.Rollback(context.Background(), "secret/data/app", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := s.Secret("secret/data/app"); version != 3 || data["password"] != "one" {
+		t.Fatalf("got version %d with %v, want version 3 with the data of version 1", version, data)
+	}
+}
+
+func TestRollbackConflict(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "one"})
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "two"})
+	agent := vaultsynctest.NewAgent(t, s, vaultsync.WithClient(func(c vaultsync.Client) vaultsync.Client {
+		return &racingClient{Client: c, rotate: func() { s.SetSecret("secret/data/app", map[string]interface{}{"password": "three"}) }}
+	}))
+
+	_, err := agent.Rollback(context.Background(), "secret/data/app", 1)
+	if !errors.Is(err, vaultsync.ErrVersionConflict) {
+		t.Fatalf("got %v, want ErrVersionConflict", err)
+	}
+	if data, _ := s.Secret("secret/data/app"); data["password"] != "three" {
+		t.Fatalf("the rollback overwrote the concurrent rotation: %v", data)
+	}
+}
+
+func TestRollbackToMissingVersion(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "one"})
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "two"})
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "three"})
+	agent := vaultsynctest.NewAgent(t, s)
+	ctx := context.Background()
+	if err := agent.DestroySecretVersions(ctx, "secret/data/app", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.DeleteSecret(ctx, "secret/data/app", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, version := range []int{1, 2, 9} {
+		if _, err := agent.Rollback(ctx, "secret/data/app", version); err == nil || !strings.Contains(err.Error(), "has no data") {
+			t.Errorf("rollback to version %d returned %v, want an error", version, err)
+		}
+	}
+	// Nothing was written.
+	if version, err := agent.Rollback(ctx, "secret/data/app", 3); err != nil || version != 4 {
+		t.Fatalf("got version %d, %v, want version 4", version, err)
+	}
+}
+
+func TestRollbackSync(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "one"})
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "two"})
+	agent := vaultsynctest.NewAgent(t, s, vaultsync.WithRollbackSync())
+	recorder := vaultsynctest.NewRecorder()
+	agent.RegisterUpdateSecret("secret/data/app", recorder)
+	if err := agent.Run(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Stop()
+	recorder.AssertValue(t, "secret/data/app", "password", "two")
+
+	// The renew period is an hour, the receiver gets the rolled back data from the sync Rollback starts.
+	if _, err := agent.Rollback(context.Background(), "secret/data/app", 1); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if value, _ := recorder.Value("secret/data/app", "password"); value == "one" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("receiver did not get the rolled back data")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,35 @@
+package vaultsync
+
+// WithLazyConnect function lets New succeed while Vault is unreachable, sealed or unavailable. The agent keeps logging in
+// in the background once Run is called and syncs all paths as soon as it has a token, Ready is closed when every path has synced.
+// Errors such as wrong credentials still make New fail. It takes precedence over lazy_connect in the configuration file.
+func WithLazyConnect() AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.lazyConnect = true
+	}
+}
+
+// lazyConnectOf method reports whether the agent may start without a token.
+func (a *Agent) lazyConnectOf() bool {
+	return a.lazyConnect || a.config.Vault.LazyConnect
+}
+
+// Ready method returns a channel that is closed once every registered path has been synced successfully from Vault,
+// the values loaded from the cache do not count. Use WaitReady to wait with a deadline.
+func (a *Agent) Ready() <-chan struct{} {
+	return a.ready
+}
+
+// checkReady method closes the ready channel once every registered path has been synced. It must be called with mu held.
+func (a *Agent) checkReady() {
+	if a.isReady {
+		return
+	}
+	for _, state := range a.paths {
+		if state.lastSync.IsZero() {
+			return
+		}
+	}
+	a.isReady = true
+	close(a.ready)
+}
@@ -0,0 +1,97 @@
+package vaultsync_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+// quiet discards the logs of agents created with New.
+var quiet = vaultsync.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+func TestLazyConnect(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+	s.SetFailing(true)
+
+	clock := vaultsynctest.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	agent := vaultsynctest.NewAgent(t, s, vaultsync.WithClock(clock), vaultsync.WithMaxRetries(0), vaultsync.WithLazyConnect())
+	recorder := vaultsynctest.NewRecorder()
+	agent.RegisterUpdateSecret("secret/data/app", recorder)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := agent.Run(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Stop()
+
+	select {
+	case <-agent.Ready():
+		t.Fatal("agent is ready before it could log in")
+	default:
+	}
+	if _, ok := recorder.Value("secret/data/app", "password"); ok {
+		t.Fatal("secret dispatched before the agent could log in")
+	}
+
+	s.SetFailing(false)
+	deadline := time.After(5 * time.Second)
+	for ready := false; !ready; {
+		select {
+		case <-agent.Ready():
+			ready = true
+		case <-deadline:
+			t.Fatal("agent not ready after Vault became available")
+		case <-time.After(10 * time.Millisecond):
+			clock.Advance(10 * time.Second)
+		}
+	}
+	recorder.AssertValue(t, "secret/data/app", "password", "s3cret")
+}
+
+func TestLazyConnectUnreachable(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	filename, err := s.WriteConfig(t.TempDir(), 60, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	if _, err := vaultsync.New(vaultsync.WithConfigFile(filename), quiet, vaultsync.WithMaxRetries(0)); err == nil {
+		t.Fatal("New succeeded without Vault and without lazy connect")
+	}
+	if _, err := vaultsync.New(vaultsync.WithConfigFile(filename), quiet, vaultsync.WithMaxRetries(0), vaultsync.WithLazyConnect()); err != nil {
+		t.Fatalf("New with lazy connect failed without Vault: %v", err)
+	}
+}
+
+func TestLazyConnectWrongCredentials(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+
+	filename := filepath.Join(t.TempDir(), "vaultsync.hcl")
+	config := fmt.Sprintf(`config {
+  server     = %q
+  authmethod = "approle"
+  username   = %q
+  password   = "wrong"
+}
+`, s.URL, vaultsynctest.Username)
+	if err := os.WriteFile(filename, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := vaultsync.New(vaultsync.WithConfigFile(filename), quiet, vaultsync.WithLazyConnect()); err == nil {
+		t.Fatal("New with lazy connect succeeded with wrong credentials")
+	}
+}
@@ -0,0 +1,49 @@
+package vaultsync
+
+import (
+	"context"
+	"log/slog"
+)
+
+// WithRevokeLeasesOnStop function makes the agent revoke the leases of dynamic secrets, such as database or cloud credentials,
+// when it stops. It can also be enabled with revoke_leases_on_stop in the configuration file.
+func WithRevokeLeasesOnStop() AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.revokeLeasesOnStop = true
+	}
+}
+
+// recordLease method records the lease of the secret last read from a path.
+func (a *Agent) recordLease(path string, leaseID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if state, ok := a.paths[path]; ok {
+		state.leaseID = leaseID
+	}
+}
+
+// revokeLeases method revokes the current lease of every path, logging failures.
+func (a *Agent) revokeLeases(ctx context.Context) {
+	a.mu.Lock()
+	leases := make(map[string]string)
+	for path, state := range a.paths {
+		if state.leaseID != "" {
+			leases[path] = state.leaseID
+			state.leaseID = ""
+		}
+	}
+	a.mu.Unlock()
+
+	for path, leaseID := range leases {
+		client, _, err := a.pathClient(ctx, path)
+		if err == nil {
+			err = client.Sys().RevokeWithContext(ctx, leaseID)
+		}
+		if err != nil {
+			a.log.Error("revokeLeases", slog.String("secret-path", path), slog.Any("error", err))
+			continue
+		}
+		a.log.Info("revokeLeases", slog.String("secret-path", path), slog.String("status", "lease revoked"))
+	}
+}
@@ -0,0 +1,198 @@
+package vaultsync
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// WithLeaseManager function makes the agent keep the leases of dynamic secrets, such as database or cloud credentials,
+// alive instead of reading a new secret every renew period. Each leased secret gets a lifetime watcher, like the token
+// of the agent: renewable leases are renewed until they reach their max TTL, and the path is read again when its lease
+// can no longer be extended. It can also be enabled with manage_leases in the configuration file.
+func WithLeaseManager() AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.manageLeases = true
+	}
+}
+
+// leaseManager struct holds the lifetime watchers of the leased secrets of a running agent.
+type leaseManager struct {
+	mu       sync.Mutex
+	running  bool
+	watchers map[string]*leaseWatcher // Watchers by path.
+	expired  map[string]bool          // Paths whose lease ended and that must be read again.
+	wake     chan struct{}            // Wakes up the sync goroutine when a lease ended.
+	wg       sync.WaitGroup
+}
+
+// leaseWatcher struct is the lifetime watcher of the lease of a path.
+type leaseWatcher struct {
+	leaseID string
+	watcher *vault.LifetimeWatcher
+	stop    chan struct{}
+}
+
+// newLeaseManager function creates a lease manager that watches no leases until it is started.
+func newLeaseManager() *leaseManager {
+	return &leaseManager{
+		watchers: make(map[string]*leaseWatcher),
+		expired:  make(map[string]bool),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// start method lets the lease manager watch leases, it is called when the agent starts running.
+func (lm *leaseManager) start() {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	lm.running = true
+}
+
+// stopAll method stops all lifetime watchers and waits until they stopped, it is called when the agent stops.
+func (lm *leaseManager) stopAll() {
+	lm.mu.Lock()
+	lm.running = false
+	for path, lw := range lm.watchers {
+		lw.close()
+		delete(lm.watchers, path)
+	}
+	lm.mu.Unlock()
+
+	lm.wg.Wait()
+}
+
+// close method stops the lifetime watcher.
+func (lw *leaseWatcher) close() {
+	close(lw.stop)
+	lw.watcher.Stop()
+}
+
+// unmanaged method returns the paths that have no watched lease, the paths with a watched lease are only read again
+// when their lease ends.
+func (lm *leaseManager) unmanaged(paths []string) []string {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	if len(lm.watchers) == 0 {
+		return paths
+	}
+	var unmanaged []string
+	for _, path := range paths {
+		if lm.watchers[path] == nil {
+			unmanaged = append(unmanaged, path)
+		}
+	}
+	return unmanaged
+}
+
+// takeExpired method returns the paths whose lease ended and forgets them.
+func (lm *leaseManager) takeExpired() []string {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	var paths []string
+	for path := range lm.expired {
+		paths = append(paths, path)
+	}
+	clear(lm.expired)
+	return paths
+}
+
+// manageLease method watches the lease of a secret read from path, replacing the watcher of a previous lease.
+// Nothing is watched unless the lease manager is enabled and the agent is running.
+func (a *Agent) manageLease(ctx context.Context, log *slog.Logger, path string, secret *vault.Secret) {
+	if !a.manageLeases {
+		return
+	}
+	lm := a.leases
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	if !lm.running {
+		return
+	}
+	if lw := lm.watchers[path]; lw != nil {
+		if lw.leaseID == secret.LeaseID {
+			return
+		}
+		lw.close()
+		delete(lm.watchers, path)
+	}
+	if secret.LeaseID == "" {
+		return
+	}
+
+	client, _, err := a.pathClient(ctx, path)
+	var watcher *vault.LifetimeWatcher
+	if err == nil {
+		// Non-renewable leases are watched too, the watcher returns when they are about to expire.
+		watcher, err = client.NewLifetimeWatcher(&vault.LifetimeWatcherInput{
+			Secret:        leaseOf(secret),
+			RenewBehavior: vault.RenewBehaviorIgnoreErrors,
+		})
+	}
+	if err != nil {
+		log.Error("manageLease", slog.String("secret-path", path), slog.Any("error", err))
+		return
+	}
+
+	lw := &leaseWatcher{leaseID: secret.LeaseID, watcher: watcher, stop: make(chan struct{})}
+	lm.watchers[path] = lw
+	lm.wg.Add(2)
+	go func() {
+		defer lm.wg.Done()
+		watcher.Start()
+	}()
+	go a.watchLease(path, lw)
+	log.Info("manageLease", slog.String("secret-path", path), slog.Bool("renewable", secret.Renewable), slog.Int("lease duration", secret.LeaseDuration))
+}
+
+// leaseOf function returns the lease of a secret without its data, so the watcher doesn't keep the credentials alive
+// until the lease ends.
+func leaseOf(secret *vault.Secret) *vault.Secret {
+	return &vault.Secret{LeaseID: secret.LeaseID, LeaseDuration: secret.LeaseDuration, Renewable: secret.Renewable}
+}
+
+// watchLease method records the renewals of a lease and asks for the path to be read again when the lease ended.
+func (a *Agent) watchLease(path string, lw *leaseWatcher) {
+	lm := a.leases
+	defer lm.wg.Done()
+
+	for {
+		select {
+		case <-lw.stop:
+			return
+
+		case info := <-lw.watcher.RenewCh():
+			a.metrics.IncrCounter(metricLeaseRenewals, 1, pathLabels(path))
+			a.logs.fetcher.Info("watchLease", slog.String("secret-path", path), slog.String("status", "renewed"), slog.Int("remaining duration", info.Secret.LeaseDuration))
+			a.recordExpiry(path, a.clock.Now().Add(time.Duration(info.Secret.LeaseDuration)*time.Second))
+
+		case err := <-lw.watcher.DoneCh():
+			select {
+			case <-lw.stop:
+				return
+			default:
+			}
+			a.logs.fetcher.Info("watchLease", slog.String("secret-path", path), slog.String("status", "lease ended, reading the secret again"), slog.Any("error", err))
+
+			lm.mu.Lock()
+			if lm.watchers[path] == lw {
+				delete(lm.watchers, path)
+				lm.expired[path] = true
+			}
+			lm.mu.Unlock()
+
+			select {
+			case lm.wake <- struct{}{}:
+			default:
+			}
+			return
+		}
+	}
+}
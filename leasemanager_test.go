@@ -0,0 +1,47 @@
+package vaultsync_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+func TestLeaseManager(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetDynamicSecret("database/creds/app", map[string]interface{}{"username": "v-app", "password": "s3cret"}, time.Second, 2*time.Second, true)
+
+	agent := vaultsynctest.NewAgent(t, s, vaultsync.WithLeaseManager())
+	agent.RegisterExtractor("database/creds/app", vaultsync.ExtractData())
+	recorder := vaultsynctest.NewRecorder()
+	agent.RegisterUpdateSecret("database/creds/app", recorder)
+	if err := agent.Run(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Stop()
+	recorder.AssertValue(t, "database/creds/app", "username", "v-app")
+
+	// The lease is renewed until it reaches its max TTL, then the secret is read again for a new lease.
+	deadline := time.After(10 * time.Second)
+	for s.Reads("database/creds/app") < 2 {
+		select {
+		case <-deadline:
+			t.Fatalf("secret not read again, %d lease renewals", s.LeaseRenewals())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if s.LeaseRenewals() == 0 {
+		t.Fatal("secret read again without renewing its lease")
+	}
+
+	// Once the agent stopped the leases are no longer renewed.
+	agent.Stop()
+	renewals := s.LeaseRenewals()
+	time.Sleep(1500 * time.Millisecond)
+	if s.LeaseRenewals() != renewals {
+		t.Fatal("lease renewed after the agent stopped")
+	}
+}
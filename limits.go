@@ -0,0 +1,60 @@
+package vaultsync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrSecretTooLarge is returned for a secret path whose data exceeds the size or key-count limit.
+var ErrSecretTooLarge = errors.New("secret too large")
+
+// WithSecretLimits function sets the largest secret the agent accepts, so a path pointing at a giant blob by mistake is
+// not kept in memory or dispatched to the receivers. maxSize is the size in bytes of the keys and values of a secret,
+// maxKeys the number of its fields. 0 means no limit. It takes precedence over max_secret_size and max_secret_keys
+// in the configuration file.
+func WithSecretLimits(maxSize int, maxKeys int) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.maxSecretSize = maxSize
+		opts.maxSecretKeys = maxKeys
+	}
+}
+
+// checkLimits method returns an error wrapping ErrSecretTooLarge if data exceeds the limits of the agent.
+func (a *Agent) checkLimits(data map[string]interface{}) error {
+	if a.maxSecretKeys > 0 && len(data) > a.maxSecretKeys {
+		return fmt.Errorf("secret has %d keys, the limit is %d:%w", len(data), a.maxSecretKeys, ErrSecretTooLarge)
+	}
+	if a.maxSecretSize > 0 {
+		if size := dataSize(data); size > a.maxSecretSize {
+			return fmt.Errorf("secret has %d bytes, the limit is %d:%w", size, a.maxSecretSize, ErrSecretTooLarge)
+		}
+	}
+	return nil
+}
+
+// dataSize function returns the size in bytes of the keys and values of a decoded secret value.
+// Numbers, booleans and null count as 8 bytes.
+func dataSize(value interface{}) int {
+	switch v := value.(type) {
+	case string:
+		return len(v)
+	case []byte:
+		return len(v)
+	case json.Number:
+		return len(v)
+	case map[string]interface{}:
+		size := 0
+		for key, e := range v {
+			size += len(key) + dataSize(e)
+		}
+		return size
+	case []interface{}:
+		size := 0
+		for _, e := range v {
+			size += dataSize(e)
+		}
+		return size
+	}
+	return 8
+}
@@ -0,0 +1,42 @@
+package vaultsync_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+func TestSecretLimits(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"username": "app", "password": "s3cret"})
+
+	agent := vaultsynctest.NewAgent(t, s, vaultsync.WithSecretLimits(64, 2))
+	recorder := vaultsynctest.NewRecorder()
+	agent.RegisterUpdateSecret("secret/data/app", recorder)
+	vaultsynctest.Sync(t, agent)
+
+	tests := []struct {
+		name string
+		data map[string]interface{}
+	}{
+		{"too many keys", map[string]interface{}{"username": "app", "password": "rotated", "extra": "x"}},
+		{"too large", map[string]interface{}{"password": strings.Repeat("x", 100)}},
+	}
+	for _, test := range tests {
+		s.SetSecret("secret/data/app", test.data)
+		if err := agent.SyncOnce(context.Background()); err == nil {
+			t.Fatalf("%v: sync succeeded", test.name)
+		}
+		status := agent.Status()[0]
+		if !errors.Is(status.LastError, vaultsync.ErrSecretTooLarge) || status.ErrorKind != vaultsync.ErrorKindInvalid {
+			t.Fatalf("%v: got error %v of kind %v, want ErrSecretTooLarge", test.name, status.LastError, status.ErrorKind)
+		}
+		// The receivers keep the values of the last successful sync.
+		recorder.AssertValue(t, "secret/data/app", "password", "s3cret")
+	}
+}
@@ -0,0 +1,111 @@
+package vaultsync_test
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+func TestWithLogLevelAppliesToWithLogger(t *testing.T) {
+	for _, levelFirst := range []bool{true, false} {
+		var out bytes.Buffer
+		logger := vaultsync.WithLogger(slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})))
+		opts := []vaultsync.AgentOptFunc{logger, vaultsync.WithLogLevel("warn")}
+		if levelFirst {
+			opts = []vaultsync.AgentOptFunc{vaultsync.WithLogLevel("warn"), logger}
+		}
+		agent := vaultsynctest.NewMockAgent(t, vaultsynctest.NewMockClient(), opts...)
+
+		agent.Logger().Info("info record")
+		agent.Logger().Warn("warn record")
+		if strings.Contains(out.String(), "info record") || !strings.Contains(out.String(), "warn record") {
+			t.Fatalf("level option first %v: level not applied to the logger: %s", levelFirst, out.String())
+		}
+
+		agent.SetLogLevel(slog.LevelDebug)
+		agent.Logger().Debug("debug record")
+		if !strings.Contains(out.String(), "debug record") {
+			t.Fatalf("level option first %v: SetLogLevel not applied: %s", levelFirst, out.String())
+		}
+		if agent.LogLevel() != slog.LevelDebug {
+			t.Fatalf("got log level %v, want debug", agent.LogLevel())
+		}
+	}
+}
+
+func TestWithLogOutput(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	filename, err := s.WriteConfig(t.TempDir(), 3600, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if _, err := vaultsync.New(vaultsync.WithConfigFile(filename), vaultsync.WithLogOutput(&out), vaultsync.WithLogLevel("info")); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "msg=NewAgent") {
+		t.Fatalf("default logger did not write to the output: %s", out.String())
+	}
+	if strings.Contains(out.String(), "level=DEBUG") {
+		t.Fatalf("debug records logged at info level: %s", out.String())
+	}
+}
+
+func TestComponentLogLevel(t *testing.T) {
+	m := vaultsynctest.NewMockClient()
+	m.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+
+	var out bytes.Buffer
+	agent := vaultsynctest.NewMockAgent(t, m,
+		vaultsync.WithLogger(slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+		vaultsync.WithLogLevel("warn"),
+		vaultsync.WithComponentLogLevel(vaultsync.ComponentFetcher, "info"),
+	)
+	agent.RegisterUpdateSecret("secret/data/app", vaultsynctest.NewRecorder())
+
+	vaultsynctest.Sync(t, agent)
+	if !strings.Contains(out.String(), "component=fetcher") {
+		t.Fatalf("fetcher did not log at info: %s", out.String())
+	}
+	if strings.Contains(out.String(), "component=scheduler") {
+		t.Fatalf("scheduler logged below the level of the agent: %s", out.String())
+	}
+
+	if err := agent.ResetComponentLogLevel(vaultsync.ComponentFetcher); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	vaultsynctest.Sync(t, agent)
+	if strings.Contains(out.String(), "component=fetcher") {
+		t.Fatalf("fetcher logged below the level of the agent after the reset: %s", out.String())
+	}
+
+	if err := agent.SetComponentLogLevel("vault", slog.LevelDebug); err == nil {
+		t.Fatal("no error for an unknown component")
+	}
+}
+
+func TestComponentLogLevelInvalid(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	filename, err := s.WriteConfig(t.TempDir(), 3600, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opt := range []vaultsync.AgentOptFunc{
+		vaultsync.WithComponentLogLevel("vault", "debug"),
+		vaultsync.WithComponentLogLevel(vaultsync.ComponentAuth, "loud"),
+	} {
+		if _, err := vaultsync.New(vaultsync.WithConfigFile(filename), vaultsync.WithLogOutput(io.Discard), opt); err == nil {
+			t.Fatal("no error for an invalid component log level")
+		}
+	}
+}
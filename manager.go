@@ -0,0 +1,163 @@
+package vaultsync
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Manager struct runs several agents as one, for example to read secrets from two Vault clusters or namespaces with their own
+// configuration files and authentication. Each agent is created with Add and gets a name that labels its logs and metrics.
+type Manager struct {
+	shared []AgentOptFunc
+	names  []string
+	agents map[string]*Agent
+}
+
+// AgentPathStatus struct is the status of a path of one of the agents of a manager.
+type AgentPathStatus struct {
+	Agent string // Name of the agent.
+	PathStatus
+}
+
+// NewManager function creates a manager. The shared options, such as WithLogger or WithMetricsSink, are applied to every agent
+// before the options passed to Add.
+func NewManager(shared ...AgentOptFunc) *Manager {
+	return &Manager{shared: shared, agents: make(map[string]*Agent)}
+}
+
+// Add method creates an agent with the shared options of the manager followed by opts, typically WithConfigFile.
+// The agent logs with an agent attribute and reports its metrics with an agent label holding the name.
+// Secrets are registered on the returned agent, before Run is called on the manager.
+func (m *Manager) Add(name string, opts ...AgentOptFunc) (*Agent, error) {
+	if name == "" {
+		return nil, fmt.Errorf("agent without a name")
+	}
+	if _, ok := m.agents[name]; ok {
+		return nil, fmt.Errorf("agent %v already exists", name)
+	}
+
+	all := make([]AgentOptFunc, 0, len(m.shared)+len(opts)+1)
+	all = append(all, m.shared...)
+	all = append(all, opts...)
+	all = append(all, withAgentName(name))
+
+	agent, err := New(all...)
+	if err != nil {
+		return nil, fmt.Errorf("agent %v:%w", name, err)
+	}
+	m.names = append(m.names, name)
+	m.agents[name] = agent
+	return agent, nil
+}
+
+// withAgentName function labels the logs and metrics of an agent with its name in a manager.
+func withAgentName(name string) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.agentName = name
+		opts.metrics = &agentMetricsSink{sink: opts.metrics, name: name}
+	}
+}
+
+// Agent method returns the agent added with name, nil if there is none.
+func (m *Manager) Agent(name string) *Agent {
+	return m.agents[name]
+}
+
+// Names method returns the names of the agents in the order they were added.
+func (m *Manager) Names() []string {
+	return append([]string(nil), m.names...)
+}
+
+// Run method runs all agents, see Agent.Run. If an agent fails to start, the agents already started are stopped and the error
+// is returned. If wg is not nil it is done once all agents have stopped.
+func (m *Manager) Run(ctx context.Context, wg *sync.WaitGroup) error {
+	for i, name := range m.names {
+		if err := m.agents[name].Run(ctx, wg); err != nil {
+			for _, started := range m.names[:i] {
+				m.agents[started].Stop()
+			}
+			return fmt.Errorf("agent %v:%w", name, err)
+		}
+	}
+	return nil
+}
+
+// Stop method stops all agents and waits for them to finish.
+func (m *Manager) Stop() {
+	for _, name := range m.names {
+		m.agents[name].Stop()
+	}
+}
+
+// Wait method blocks until all agents have stopped.
+func (m *Manager) Wait() {
+	for _, name := range m.names {
+		m.agents[name].Wait()
+	}
+}
+
+// WaitReady method blocks until every path of every agent has been synced once, or ctx is done.
+func (m *Manager) WaitReady(ctx context.Context) error {
+	for _, name := range m.names {
+		if err := m.agents[name].WaitReady(ctx); err != nil {
+			return fmt.Errorf("agent %v:%w", name, err)
+		}
+	}
+	return nil
+}
+
+// Status method returns the status of the paths of all agents, in the order the agents were added and sorted by path.
+func (m *Manager) Status() []AgentPathStatus {
+	var status []AgentPathStatus
+	for _, name := range m.names {
+		for _, ps := range m.agents[name].Status() {
+			status = append(status, AgentPathStatus{Agent: name, PathStatus: ps})
+		}
+	}
+	return status
+}
+
+// Health method returns an error naming the stale paths of every agent, or nil if every path of every agent is fresh.
+func (m *Manager) Health() error {
+	var msgs []string
+	for _, name := range m.names {
+		if err := m.agents[name].Health(); err != nil {
+			msgs = append(msgs, fmt.Sprintf("agent %v: %v", name, err))
+		}
+	}
+	if len(msgs) > 0 {
+		return fmt.Errorf("%s", strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// agentMetricsSink struct adds the name of an agent as label to the metrics of the agent.
+type agentMetricsSink struct {
+	sink MetricsSink
+	name string
+}
+
+// labels method returns labels with the agent label added.
+func (s *agentMetricsSink) labels(labels map[string]string) map[string]string {
+	l := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		l[k] = v
+	}
+	l["agent"] = s.name
+	return l
+}
+
+func (s *agentMetricsSink) IncrCounter(name string, value int64, labels map[string]string) {
+	s.sink.IncrCounter(name, value, s.labels(labels))
+}
+
+func (s *agentMetricsSink) SetGauge(name string, value float64, labels map[string]string) {
+	s.sink.SetGauge(name, value, s.labels(labels))
+}
+
+func (s *agentMetricsSink) ObserveTiming(name string, duration time.Duration, labels map[string]string) {
+	s.sink.ObserveTiming(name, duration, s.labels(labels))
+}
@@ -0,0 +1,199 @@
+package vaultsync_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+// metricsRecorder struct is a metrics sink recording the labels of every sample.
+type metricsRecorder struct {
+	mu      sync.Mutex
+	samples []map[string]string
+}
+
+// record method records the labels of a sample.
+func (r *metricsRecorder) record(labels map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.samples = append(r.samples, labels)
+}
+
+func (r *metricsRecorder) IncrCounter(name string, value int64, labels map[string]string) {
+	r.record(labels)
+}
+func (r *metricsRecorder) SetGauge(name string, value float64, labels map[string]string) {
+	r.record(labels)
+}
+func (r *metricsRecorder) ObserveTiming(name string, d time.Duration, labels map[string]string) {
+	r.record(labels)
+}
+
+// lockedBuffer struct is a buffer several agents can log to.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// configFile function writes a configuration file for an agent of s and returns the option reading it.
+func configFile(t *testing.T, s *vaultsynctest.Server) vaultsync.AgentOptFunc {
+	t.Helper()
+
+	filename, err := s.WriteConfig(t.TempDir(), 3600, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vaultsync.WithConfigFile(filename)
+}
+
+func TestManager(t *testing.T) {
+	primary := vaultsynctest.NewServer()
+	defer primary.Close()
+	primary.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+	secondary := vaultsynctest.NewServer()
+	defer secondary.Close()
+	secondary.SetSecret("secret/data/db", map[string]interface{}{"password": "db-s3cret"})
+
+	var out lockedBuffer
+	metrics := &metricsRecorder{}
+	m := vaultsync.NewManager(vaultsync.WithLogger(slog.New(slog.NewTextHandler(&out, nil))), vaultsync.WithMetricsSink(metrics))
+	defer m.Stop()
+	app, err := m.Add("primary", configFile(t, primary))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := m.Add("secondary", configFile(t, secondary))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Add("primary", configFile(t, primary)); err == nil {
+		t.Fatal("added a second agent named primary")
+	}
+	app.RegisterUpdateSecret("secret/data/app", vaultsynctest.NewRecorder())
+	db.RegisterUpdateSecret("secret/data/db", vaultsynctest.NewRecorder())
+	// The secondary cluster does not have this secret, the path stays stale.
+	db.RegisterUpdateSecret("secret/data/missing", vaultsynctest.NewRecorder())
+	if err := m.Run(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("status", func(t *testing.T) {
+		var got []string
+		for _, status := range m.Status() {
+			got = append(got, status.Agent+" "+status.Path)
+		}
+		want := []string{"primary secret/data/app", "secondary secret/data/db", "secondary secret/data/missing"}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("health", func(t *testing.T) {
+		err := m.Health()
+		if err == nil || err.Error() != "agent secondary: stale secret paths: secret/data/missing" {
+			t.Fatalf("got %v", err)
+		}
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		metrics.mu.Lock()
+		defer metrics.mu.Unlock()
+		agents := map[string]string{"secret/data/app": "primary", "secret/data/db": "secondary", "secret/data/missing": "secondary"}
+		labeled := 0
+		for _, labels := range metrics.samples {
+			if labels["agent"] != "primary" && labels["agent"] != "secondary" {
+				t.Fatalf("sample without agent label: %v", labels)
+			}
+			if path, ok := labels["path"]; ok {
+				if labels["agent"] != agents[path] {
+					t.Fatalf("sample of %v labeled with agent %v", path, labels["agent"])
+				}
+				labeled++
+			}
+		}
+		if labeled == 0 {
+			t.Fatal("no samples of paths")
+		}
+	})
+
+	t.Run("logs", func(t *testing.T) {
+		scanner := bufio.NewScanner(strings.NewReader(out.String()))
+		lines := map[string]int{}
+		for scanner.Scan() {
+			line := scanner.Text()
+			for path, agent := range map[string]string{"secret/data/app": "primary", "secret/data/db": "secondary"} {
+				if !strings.Contains(line, "secret-path="+path) {
+					continue
+				}
+				if !strings.Contains(line, "agent="+agent) {
+					t.Fatalf("log of %v without agent=%v: %v", path, agent, line)
+				}
+				lines[path]++
+			}
+		}
+		if lines["secret/data/app"] == 0 || lines["secret/data/db"] == 0 {
+			t.Fatalf("no logs of the paths: %v", out.String())
+		}
+	})
+}
+
+func TestManagerRunStopsStartedAgents(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+
+	quiet := vaultsync.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	m := vaultsync.NewManager(quiet)
+	defer m.Stop()
+	started, err := m.Add("primary", configFile(t, s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	started.RegisterUpdateSecret("secret/data/app", vaultsynctest.NewRecorder())
+	// The admin address is taken, so the second agent fails to start.
+	if _, err := m.Add("secondary", configFile(t, s), vaultsync.WithAdminAddress(taken.Addr().String())); err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.Run(context.Background(), nil)
+	if err == nil || !strings.Contains(err.Error(), "agent secondary") {
+		t.Fatalf("got %v, want the error of the secondary agent", err)
+	}
+	stopped := make(chan struct{})
+	go func() {
+		started.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the agent that started was not stopped")
+	}
+}
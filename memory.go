@@ -0,0 +1,104 @@
+package vaultsync
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// WithLockedMemory function makes the agent keep the string values of synced secrets in locked memory.
+// On Linux the memory is locked with mlock so it is never swapped, and is excluded from core dumps. The memory is
+// overwritten when a value is replaced. Values are still copied to ordinary memory while they are read from Vault,
+// dispatched to receivers and returned by accessors such as Secret.
+func WithLockedMemory() AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.lockedMemory = true
+	}
+}
+
+// lockedBuffer struct holds a secret value outside the Go heap.
+type lockedBuffer struct {
+	b      []byte
+	binary bool // The value is a []byte rather than a string.
+}
+
+// newLockedBuffer function copies s into a new locked buffer.
+func newLockedBuffer(s string) (*lockedBuffer, error) {
+	if len(s) == 0 {
+		return &lockedBuffer{}, nil
+	}
+	b, err := allocLocked(len(s))
+	if err != nil {
+		return nil, err
+	}
+	copy(b, s)
+	return &lockedBuffer{b: b}, nil
+}
+
+// value method returns a copy of the value, a []byte for a binary value and a string otherwise.
+func (lb *lockedBuffer) value() interface{} {
+	if lb.binary {
+		return bytes.Clone(lb.b)
+	}
+	return lb.String()
+}
+
+// String method returns a copy of the value.
+func (lb *lockedBuffer) String() string {
+	return string(lb.b)
+}
+
+// destroy method overwrites and releases the buffer.
+func (lb *lockedBuffer) destroy() {
+	if lb.b == nil {
+		return
+	}
+	clear(lb.b)
+	freeLocked(lb.b)
+	lb.b = nil
+}
+
+// sealData method moves the string and []byte values of data into locked buffers if locked memory is enabled.
+func (a *Agent) sealData(data map[string]interface{}) (map[string]interface{}, error) {
+	if !a.lockedMemory {
+		return data, nil
+	}
+
+	sealed := make(map[string]interface{}, len(data))
+	for field, value := range data {
+		var s string
+		switch v := value.(type) {
+		case string:
+			s = v
+		case []byte:
+			s = string(v)
+		default:
+			sealed[field] = value
+			continue
+		}
+		lb, err := newLockedBuffer(s)
+		if err != nil {
+			destroyData(sealed)
+			return nil, fmt.Errorf("failed to lock memory for field %v:%w", field, err)
+		}
+		_, lb.binary = value.([]byte)
+		sealed[field] = lb
+	}
+	return sealed, nil
+}
+
+// unsealValue function returns the value held by a locked buffer, other values are returned as they are.
+func unsealValue(value interface{}) interface{} {
+	if lb, ok := value.(*lockedBuffer); ok {
+		return lb.value()
+	}
+	return value
+}
+
+// destroyData function releases the locked buffers of sealed data.
+func destroyData(data map[string]interface{}) {
+	for _, value := range data {
+		if lb, ok := value.(*lockedBuffer); ok {
+			lb.destroy()
+		}
+	}
+}
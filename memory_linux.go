@@ -0,0 +1,27 @@
+package vaultsync
+
+import "golang.org/x/sys/unix"
+
+// allocLocked function maps size bytes of anonymous memory, locks it in RAM and excludes it from core dumps.
+func allocLocked(size int) ([]byte, error) {
+	b, err := unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANON)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Mlock(b); err != nil {
+		unix.Munmap(b)
+		return nil, err
+	}
+	if err := unix.Madvise(b, unix.MADV_DONTDUMP); err != nil {
+		unix.Munlock(b)
+		unix.Munmap(b)
+		return nil, err
+	}
+	return b, nil
+}
+
+// freeLocked function unlocks and unmaps memory allocated by allocLocked.
+func freeLocked(b []byte) {
+	unix.Munlock(b)
+	unix.Munmap(b)
+}
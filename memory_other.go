@@ -0,0 +1,12 @@
+//go:build !linux
+
+package vaultsync
+
+// allocLocked function allocates size bytes. Memory locking is only supported on Linux,
+// elsewhere the values are only overwritten when they are released.
+func allocLocked(size int) ([]byte, error) {
+	return make([]byte, size), nil
+}
+
+// freeLocked function releases memory allocated by allocLocked.
+func freeLocked(b []byte) {}
@@ -0,0 +1,66 @@
+package vaultsync
+
+import "time"
+
+// Metric names reported to the metrics sink.
+const (
+	metricSyncCycles       = "vaultsync.sync.cycles"
+	metricFetchDuration    = "vaultsync.fetch.duration"
+	metricFetchErrors      = "vaultsync.fetch.errors"
+	metricRotations        = "vaultsync.rotations"
+	metricStalePaths       = "vaultsync.paths.stale"
+	metricTokenRenewals    = "vaultsync.token.renewals"
+	metricTokenRenewFails  = "vaultsync.token.renewal_failures"
+	metricExecFailures     = "vaultsync.exec.failures"
+	metricCircuitOpen      = "vaultsync.circuit.open"
+	metricInvalidSecrets   = "vaultsync.secrets.invalid"
+	metricDrift            = "vaultsync.drift"
+	metricDispatchTimeouts = "vaultsync.dispatch.timeouts"
+	metricSecretAge        = "vaultsync.secret.age"
+	metricLeaseRenewals    = "vaultsync.lease.renewals"
+	metricGroupsSkipped    = "vaultsync.groups.skipped"
+	metricAuthEventDrops   = "vaultsync.auth.events_dropped"
+)
+
+// MetricsSink interface defines a minimal sink for counters, gauges and timings.
+// Labels never contain secret values, only names such as the secret path.
+type MetricsSink interface {
+	IncrCounter(name string, value int64, labels map[string]string)
+	SetGauge(name string, value float64, labels map[string]string)
+	ObserveTiming(name string, duration time.Duration, labels map[string]string)
+}
+
+// nopMetricsSink struct is the default sink that discards all metrics.
+type nopMetricsSink struct{}
+
+func (nopMetricsSink) IncrCounter(string, int64, map[string]string)           {}
+func (nopMetricsSink) SetGauge(string, float64, map[string]string)            {}
+func (nopMetricsSink) ObserveTiming(string, time.Duration, map[string]string) {}
+
+// WithMetricsSink function sets the sink that receives the agent's metrics.
+func WithMetricsSink(sink MetricsSink) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.metrics = sink
+	}
+}
+
+// pathLabels function returns the metric labels for a secret path.
+func pathLabels(path string) map[string]string {
+	return map[string]string{"path": path}
+}
+
+// errorLabels function returns the metric labels for a sync error of a secret path.
+func errorLabels(path string, err error) map[string]string {
+	return map[string]string{"path": path, "kind": string(classifyError(err))}
+}
+
+// reportStalePaths method sets the gauge of stale paths.
+func (a *Agent) reportStalePaths() {
+	stale := 0
+	for _, status := range a.Status() {
+		if status.Stale {
+			stale++
+		}
+	}
+	a.metrics.SetGauge(metricStalePaths, float64(stale), nil)
+}
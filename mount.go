@@ -0,0 +1,191 @@
+package vaultsync
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// defaultKVVersion is the KV version of mounts that are not declared with kv_mounts or WithKVMount.
+const defaultKVVersion = 2
+
+// WithKVMount function declares the KV version, 1 or 2, of a secrets engine mount. It takes precedence over kv_mounts
+// in the configuration file. Mounts that are not declared are KV v2.
+func WithKVMount(mount string, version int) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		if opts.kvMounts == nil {
+			opts.kvMounts = make(map[string]int)
+		}
+		opts.kvMounts[strings.Trim(mount, "/")] = version
+	}
+}
+
+// KVDataPath function returns the API path of a secret in a KV mount of the given version,
+// e.g. KVDataPath("kv", "netpush/redis", 2) returns kv/data/netpush/redis.
+func KVDataPath(mount string, path string, version int) string {
+	mount = strings.Trim(mount, "/")
+	path = strings.Trim(path, "/")
+	if version == 1 {
+		return mount + "/" + path
+	}
+	return mount + "/data/" + path
+}
+
+// KVMetadataPath function returns the API path of the metadata of a secret in a KV v2 mount,
+// e.g. KVMetadataPath("kv", "netpush/redis") returns kv/metadata/netpush/redis.
+func KVMetadataPath(mount string, path string) string {
+	return strings.Trim(mount, "/") + "/metadata/" + strings.Trim(path, "/")
+}
+
+// SecretPath method returns the API path of a secret given by its mount and its path relative to the mount,
+// using the KV version of the mount.
+func (a *Agent) SecretPath(mount string, path string) string {
+	return a.expandPath(KVDataPath(mount, path, a.mountVersion(strings.Trim(mount, "/"))))
+}
+
+// RegisterSecret method registers a receiver for a secret given by its mount and its path relative to the mount,
+// so callers don't hardcode data/ segments that depend on the KV version:
+//
+//	path := vs.RegisterSecret("kv", "netpush/redis", redis)
+//
+// It returns the API path, e.g. kv/data/netpush/redis, which is the id passed to the receiver and used by Status, Secret and the other methods.
+func (a *Agent) RegisterSecret(mount string, path string, receiver SecretReceiver) string {
+	id := a.SecretPath(mount, path)
+	a.RegisterUpdateSecret(id, receiver)
+	return id
+}
+
+// RegisterKVSecret method registers a receiver for a secret given by its logical path, the mount followed by the path of
+// the secret in the mount, e.g. kv/netpush/redis, so callers need to know neither where the mount ends nor its KV version.
+// The mount and its version are looked up with DetectKVMount, unless the path is in a mount declared with kv_mounts or
+// WithKVMount. It returns the API path, e.g. kv/data/netpush/redis, which is the id passed to the receiver and used by
+// Status, Secret and the other methods. It must be called after New, which authenticates the agent.
+func (a *Agent) RegisterKVSecret(ctx context.Context, path string, receiver SecretReceiver) (string, error) {
+	path = strings.Trim(a.expandPath(path), "/")
+	mount, version, err := a.DetectKVMount(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	id := KVDataPath(mount, strings.TrimPrefix(path, mount+"/"), version)
+	a.RegisterUpdateSecret(id, receiver)
+	return id, nil
+}
+
+// DetectKVMount method returns the KV mount a logical path belongs to and its KV version. Paths in a mount declared with
+// kv_mounts or WithKVMount, or detected before, use the declared mount. Other mounts are looked up with
+// sys/internal/ui/mounts, which any token with a capability on the path may read, and remembered. If Vault denies the
+// lookup, the first segment of the path is taken as a KV v2 mount, as for mounts that are not declared.
+func (a *Agent) DetectKVMount(ctx context.Context, path string) (string, int, error) {
+	path = strings.Trim(path, "/")
+	if mount, version, ok := a.declaredMount(path); ok {
+		return mount, version, nil
+	}
+	if a.token.Load() == nil {
+		return "", 0, fmt.Errorf("secret path %v:%w", path, ErrNotAuthenticated)
+	}
+
+	_, api, err := a.pathClient(ctx, path)
+	if err != nil {
+		return "", 0, fmt.Errorf("secret path %v:%w", path, err)
+	}
+	secret, err := api.Read(ctx, "sys/internal/ui/mounts/"+path)
+	a.recordVaultCall(err)
+	if classifyError(err) == ErrorKindPermissionDenied {
+		mount, _, _ := strings.Cut(path, "/")
+		a.log.Warn("DetectKVMount", slog.String("secret-path", path), slog.String("status", "mount lookup denied, using the first segment as mount"), slog.String("mount", mount))
+		return mount, a.mountVersion(mount), nil
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("secret path %v:mount lookup:%w", path, err)
+	}
+
+	mount, version, err := kvMountInfo(secret)
+	if err != nil {
+		return "", 0, fmt.Errorf("secret path %v:%w", path, err)
+	}
+	if a.kvMounts == nil {
+		a.kvMounts = make(map[string]int)
+	}
+	a.kvMounts[mount] = version
+	a.log.Debug("DetectKVMount", slog.String("secret-path", path), slog.String("mount", mount), slog.Int("kv-version", version))
+	return mount, version, nil
+}
+
+// declaredMount method returns the longest declared or detected mount that prefixes a logical path, and its KV version.
+func (a *Agent) declaredMount(path string) (string, int, bool) {
+	var found string
+	for mount := range a.kvMounts {
+		if len(mount) > len(found) && strings.HasPrefix(path, mount+"/") {
+			found = mount
+		}
+	}
+	if found == "" {
+		return "", 0, false
+	}
+	return found, a.kvMounts[found], true
+}
+
+// kvMountInfo function returns the mount and the KV version from a sys/internal/ui/mounts response.
+// Generic mounts and KV mounts without a version option are KV v1.
+func kvMountInfo(secret *vault.Secret) (string, int, error) {
+	if secret == nil || secret.Data == nil {
+		return "", 0, fmt.Errorf("mount lookup returned no mount")
+	}
+	mount, _ := secret.Data["path"].(string)
+	mount = strings.Trim(mount, "/")
+	kind, _ := secret.Data["type"].(string)
+	if mount == "" {
+		return "", 0, fmt.Errorf("mount lookup returned no mount")
+	}
+	if kind != "kv" && kind != "generic" {
+		return "", 0, fmt.Errorf("mount %v is a %v secrets engine, not KV", mount, kind)
+	}
+
+	version := 1
+	if options, ok := secret.Data["options"].(map[string]interface{}); ok && fmt.Sprint(options["version"]) == "2" {
+		version = 2
+	}
+	return mount, version, nil
+}
+
+// mountVersion method returns the KV version of a mount.
+func (a *Agent) mountVersion(mount string) int {
+	if version, ok := a.kvMounts[mount]; ok {
+		return version
+	}
+	return defaultKVVersion
+}
+
+// pathVersion method returns the KV version of the mount an API path belongs to, the longest declared mount that prefixes the path.
+func (a *Agent) pathVersion(path string) int {
+	if _, version, ok := a.declaredMount(path); ok {
+		return version
+	}
+	return defaultKVVersion
+}
+
+// secretData method returns the fields of a secret read from path with the extractor registered for the path, or else
+// by the KV version of its mount: KV v2 nests them under data, KV v1 returns them as they are.
+// It returns ErrSecretNotFound if there is no secret, or if the current KV v2 version was deleted or destroyed.
+func (a *Agent) secretData(path string, secret *vault.Secret) (map[string]interface{}, error) {
+	if secret == nil {
+		return nil, ErrSecretNotFound
+	}
+	if extractor, ok := a.extractors[path]; ok {
+		return extractor(secret)
+	}
+	if a.pathVersion(path) == 1 {
+		if secret.Data == nil {
+			return nil, fmt.Errorf("secret has no data:%w", ErrSecretNotFound)
+		}
+		return secret.Data, nil
+	}
+	data, ok := secret.Data["data"].(map[string]interface{})
+	if !ok || data == nil {
+		return nil, fmt.Errorf("secret has no data, its current version was deleted or destroyed:%w", ErrSecretNotFound)
+	}
+	return data, nil
+}
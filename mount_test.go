@@ -0,0 +1,78 @@
+package vaultsync_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+func TestRegisterKVSecretDetectsMount(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetMount("team/legacy", 1)
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+
+	agent := vaultsynctest.NewAgent(t, s)
+	recorder := vaultsynctest.NewRecorder()
+	ctx := context.Background()
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"secret/app", "secret/data/app"},
+		{"/team/legacy/netpush/redis", "team/legacy/netpush/redis"},
+	}
+	for _, test := range tests {
+		id, err := agent.RegisterKVSecret(ctx, test.path, recorder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != test.want {
+			t.Fatalf("%v: got %v, want %v", test.path, id, test.want)
+		}
+	}
+
+	mount, version, err := agent.DetectKVMount(ctx, "team/legacy/other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mount != "team/legacy" || version != 1 {
+		t.Fatalf("got mount %v version %v, want team/legacy version 1", mount, version)
+	}
+
+	if err := agent.SyncOnce(ctx); err == nil {
+		t.Fatal("no error for the KV v1 secret the server doesn't have")
+	}
+	recorder.AssertValue(t, "secret/data/app", "password", "s3cret")
+}
+
+func TestDetectKVMountDenied(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetMount("team/legacy", 1)
+	s.SetMountLookupDenied(true)
+
+	agent := vaultsynctest.NewAgent(t, s, vaultsync.WithKVMount("old", 1))
+	ctx := context.Background()
+
+	tests := []struct {
+		path    string
+		mount   string
+		version int
+	}{
+		{"team/legacy/app", "team", 2},
+		{"old/app", "old", 1},
+	}
+	for _, test := range tests {
+		mount, version, err := agent.DetectKVMount(ctx, test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mount != test.mount || version != test.version {
+			t.Fatalf("%v: got mount %v version %v, want %v version %v", test.path, mount, version, test.mount, test.version)
+		}
+	}
+}
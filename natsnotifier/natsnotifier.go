@@ -0,0 +1,38 @@
+// Package natsnotifier provides a vaultsync.Notifier that publishes rotation events to NATS.
+package natsnotifier
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pergus/vaultsync"
+)
+
+// Notifier struct publishes rotation events as JSON messages on a NATS subject.
+type Notifier struct {
+	conn    *nats.Conn
+	subject string
+}
+
+// New function creates a notifier publishing on subject using an established NATS connection.
+// The connection is owned by the caller and is not closed by the notifier.
+func New(conn *nats.Conn, subject string) *Notifier {
+	return &Notifier{
+		conn:    conn,
+		subject: subject,
+	}
+}
+
+// Notify method publishes the rotation event and flushes the connection.
+func (n *Notifier) Notify(ctx context.Context, event vaultsync.RotationEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if err := n.conn.Publish(n.subject, data); err != nil {
+		return err
+	}
+	return n.conn.FlushWithContext(ctx)
+}
@@ -0,0 +1,127 @@
+package natsnotifier_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/natsnotifier"
+)
+
+// message struct is a message published to the fake server.
+type message struct {
+	subject string
+	data    []byte
+}
+
+// fakeServer function starts a NATS server that speaks enough of the protocol for a publishing client,
+// and returns its URL and the messages published to it.
+func fakeServer(t *testing.T) (string, <-chan message) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	messages := make(chan message, 16)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveConn(conn, messages)
+		}
+	}()
+	return "nats://" + listener.Addr().String(), messages
+}
+
+// serveConn function answers the pings of a client and collects the messages it publishes.
+func serveConn(conn net.Conn, messages chan<- message) {
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "INFO {\"server_id\":\"fake\",\"version\":\"2.10.0\",\"proto\":1,\"max_payload\":1048576}\r\n")
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "PING":
+			fmt.Fprintf(conn, "PONG\r\n")
+		case "PUB":
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil {
+				return
+			}
+			data := make([]byte, size+2)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return
+			}
+			messages <- message{subject: fields[1], data: data[:size]}
+		}
+	}
+}
+
+func TestNotifyPublishesEvent(t *testing.T) {
+	url, messages := fakeServer(t)
+	conn, err := nats.Connect(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	event := vaultsync.RotationEvent{CycleID: "cycle-1", Path: "secret/data/app", Fields: []string{"password"}, Version: 3, Timestamp: time.Now().UTC()}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := natsnotifier.New(conn, "vault.rotations").Notify(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+
+	// Notify flushes, so the message has reached the server when it returns.
+	select {
+	case msg := <-messages:
+		if msg.subject != "vault.rotations" {
+			t.Fatalf("got subject %v", msg.subject)
+		}
+		var got vaultsync.RotationEvent
+		if err := json.Unmarshal(msg.data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Path != event.Path || got.Version != event.Version || len(got.Fields) != 1 || got.Fields[0] != "password" {
+			t.Fatalf("got %+v, want %+v", got, event)
+		}
+	default:
+		t.Fatal("no message published when Notify returned")
+	}
+}
+
+func TestNotifyFailsOnClosedConnection(t *testing.T) {
+	url, _ := fakeServer(t)
+	conn, err := nats.Connect(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	err = natsnotifier.New(conn, "vault.rotations").Notify(context.Background(), vaultsync.RotationEvent{Path: "secret/data/app"})
+	if err == nil {
+		t.Fatal("Notify succeeded on a closed connection")
+	}
+}
@@ -0,0 +1,84 @@
+package vaultsync
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+)
+
+// notifyTimeout bounds how long a notifier may spend on a single rotation event, retries included.
+const notifyTimeout = 2 * time.Minute
+
+// Notifier interface defines the method for publishing rotation events.
+// Implementations must never require secret values, the event only carries metadata.
+type Notifier interface {
+	Notify(ctx context.Context, event RotationEvent) error
+}
+
+// WithNotifier function adds a notifier that is called whenever a secret rotates.
+func WithNotifier(notifier Notifier) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.notifiers = append(opts.notifiers, notifier)
+	}
+}
+
+// notifyRotation method publishes a rotation event to all notifiers.
+// Each notifier is called in its own goroutine so a slow endpoint doesn't delay the sync. The agent waits for them when it stops.
+func (a *Agent) notifyRotation(event RotationEvent) {
+	log := a.logs.dispatcher.With(slog.String("cycle", event.CycleID))
+	for _, notifier := range a.notifiers {
+		a.notifying.Add(1)
+		go func(notifier Notifier) {
+			defer a.notifying.Done()
+			ctx, cancel := context.WithTimeout(a.notifyCtx, notifyTimeout)
+			defer cancel()
+
+			if err := notifier.Notify(ctx, event); err != nil {
+				log.Error("notifyRotation", slog.String("notifier", notifierName(notifier)), slog.String("secret-path", event.Path), slog.Any("error", err))
+				return
+			}
+			log.Debug("notifyRotation", slog.String("notifier", notifierName(notifier)), slog.String("secret-path", event.Path))
+		}(notifier)
+	}
+}
+
+// waitNotifications method waits for the notifiers still publishing rotation events, so none publishes or logs after
+// the agent stopped. Notifiers still running after the shutdown timeout are cancelled.
+func (a *Agent) waitNotifications() {
+	done := make(chan struct{})
+	go func() {
+		a.notifying.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(shutdownTimeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return
+	case <-timer.C:
+	}
+	a.logs.dispatcher.Warn("waitNotifications", slog.String("status", "notifiers cancelled"), slog.Duration("timeout", shutdownTimeout))
+	a.stopNotify()
+	<-done
+}
+
+// notifierName function returns a name of the notifier suitable for logging.
+func notifierName(notifier Notifier) string {
+	if webhook, ok := notifier.(*webhookNotifier); ok {
+		return "webhook " + urlHost(webhook.config.URL)
+	}
+	return fmt.Sprintf("%T", notifier)
+}
+
+// urlHost function returns the scheme and host of a URL for logging, without the user, path and query,
+// which often carry tokens.
+func urlHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "[invalid url]"
+	}
+	return u.Scheme + "://" + u.Host
+}
@@ -0,0 +1,117 @@
+package vaultsync_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+// rotate function changes the secret at path on the server and waits until the running agent passed it to recorder.
+func rotate(t *testing.T, s *vaultsynctest.Server, agent *vaultsync.Agent, recorder *vaultsynctest.Recorder, path string, password string) {
+	t.Helper()
+
+	s.SetSecret(path, map[string]interface{}{"password": password})
+	agent.Refresh()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if value, _ := recorder.Value(path, "password"); value == password {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("secret at %v not rotated to %v", path, password)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// blockingNotifier struct is a notifier that takes delay to publish an event.
+type blockingNotifier struct {
+	delay     time.Duration
+	published atomic.Int32
+}
+
+// Notify method publishes the event after the delay.
+func (n *blockingNotifier) Notify(ctx context.Context, event vaultsync.RotationEvent) error {
+	select {
+	case <-time.After(n.delay):
+		n.published.Add(1)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestStopWaitsForNotifiers(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+	notifier := &blockingNotifier{delay: 300 * time.Millisecond}
+	agent := vaultsynctest.NewAgent(t, s, vaultsync.WithNotifier(notifier))
+	recorder := vaultsynctest.NewRecorder()
+	agent.RegisterUpdateSecret("secret/data/app", recorder)
+	if err := agent.Run(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	rotate(t, s, agent, recorder, "secret/data/app", "n3w")
+	agent.Stop()
+	if published := notifier.published.Load(); published != 1 {
+		t.Fatalf("got %d events published when Stop returned, want 1", published)
+	}
+}
+
+func TestWebhookURLNotLogged(t *testing.T) {
+	// The webhook drops the connection, so the error of the request is logged.
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer webhook.Close()
+
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+	extra := fmt.Sprintf(`  webhook {
+    url         = %q
+    max_retries = 1
+  }`, webhook.URL+"/hooks/T000/B000?token=xoxb-t0ps3cret")
+	filename, err := s.WriteConfig(t.TempDir(), 3600, extra)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	agent, err := vaultsync.New(vaultsync.WithConfigFile(filename), vaultsync.WithLogger(slog.New(slog.NewTextHandler(&out, nil))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := vaultsynctest.NewRecorder()
+	agent.RegisterUpdateSecret("secret/data/app", recorder)
+	if err := agent.Run(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	rotate(t, s, agent, recorder, "secret/data/app", "n3w")
+	agent.Stop()
+
+	logged := out.String()
+	if !strings.Contains(logged, "notifier=\"webhook "+webhook.URL+"\"") {
+		t.Fatalf("webhook failure not logged with its host: %s", logged)
+	}
+	for _, token := range []string{"t0ps3cret", "T000", "B000"} {
+		if strings.Contains(logged, token) {
+			t.Errorf("log contains %q of the webhook URL: %s", token, logged)
+		}
+	}
+}
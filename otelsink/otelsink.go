@@ -0,0 +1,95 @@
+// Package otelsink provides a vaultsync.MetricsSink backed by an OpenTelemetry meter.
+// Metrics reach an OTLP collector through whichever exporter the meter provider is configured with.
+package otelsink
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Sink struct records metrics using OpenTelemetry instruments, created on first use.
+type Sink struct {
+	meter metric.Meter
+
+	mu         sync.Mutex
+	counters   map[string]metric.Int64Counter
+	gauges     map[string]metric.Float64Gauge
+	histograms map[string]metric.Float64Histogram
+}
+
+// New function creates a sink recording metrics with the given meter.
+func New(meter metric.Meter) *Sink {
+	return &Sink{
+		meter:      meter,
+		counters:   make(map[string]metric.Int64Counter),
+		gauges:     make(map[string]metric.Float64Gauge),
+		histograms: make(map[string]metric.Float64Histogram),
+	}
+}
+
+// IncrCounter method adds value to a counter.
+func (s *Sink) IncrCounter(name string, value int64, labels map[string]string) {
+	s.mu.Lock()
+	counter, ok := s.counters[name]
+	if !ok {
+		var err error
+		counter, err = s.meter.Int64Counter(name)
+		if err != nil {
+			s.mu.Unlock()
+			return
+		}
+		s.counters[name] = counter
+	}
+	s.mu.Unlock()
+
+	counter.Add(context.Background(), value, metric.WithAttributes(attributes(labels)...))
+}
+
+// SetGauge method records the current value of a gauge.
+func (s *Sink) SetGauge(name string, value float64, labels map[string]string) {
+	s.mu.Lock()
+	gauge, ok := s.gauges[name]
+	if !ok {
+		var err error
+		gauge, err = s.meter.Float64Gauge(name)
+		if err != nil {
+			s.mu.Unlock()
+			return
+		}
+		s.gauges[name] = gauge
+	}
+	s.mu.Unlock()
+
+	gauge.Record(context.Background(), value, metric.WithAttributes(attributes(labels)...))
+}
+
+// ObserveTiming method records a duration in seconds in a histogram.
+func (s *Sink) ObserveTiming(name string, duration time.Duration, labels map[string]string) {
+	s.mu.Lock()
+	histogram, ok := s.histograms[name]
+	if !ok {
+		var err error
+		histogram, err = s.meter.Float64Histogram(name, metric.WithUnit("s"))
+		if err != nil {
+			s.mu.Unlock()
+			return
+		}
+		s.histograms[name] = histogram
+	}
+	s.mu.Unlock()
+
+	histogram.Record(context.Background(), duration.Seconds(), metric.WithAttributes(attributes(labels)...))
+}
+
+// attributes function converts labels to OpenTelemetry attributes.
+func attributes(labels map[string]string) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(labels))
+	for key, value := range labels {
+		attrs = append(attrs, attribute.String(key, value))
+	}
+	return attrs
+}
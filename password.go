@@ -0,0 +1,24 @@
+package vaultsync
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// GeneratePassword method generates a password with the Vault password policy policyName.
+// Passwords are generated by Vault, so they comply with the policy no matter where the rotation logic runs.
+func (a *Agent) GeneratePassword(ctx context.Context, policyName string) (string, error) {
+	secret, err := a.api.Read(ctx, "sys/policies/password/"+url.PathEscape(policyName)+"/generate")
+	if err != nil {
+		return "", fmt.Errorf("generate password with policy %v:%w", policyName, err)
+	}
+	if secret == nil {
+		return "", fmt.Errorf("generate password with policy %v: no response", policyName)
+	}
+	password, ok := secret.Data["password"].(string)
+	if !ok {
+		return "", fmt.Errorf("generate password with policy %v: response has no password", policyName)
+	}
+	return password, nil
+}
@@ -0,0 +1,127 @@
+package vaultsync
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+)
+
+// pathVariable matches a placeholder in a secret path, {name} or {env:NAME}.
+var pathVariable = regexp.MustCompile(`\{(env:)?([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// WithPathVariables function sets variables for placeholders in secret paths, e.g. secrets/data/{env}/{hostname}/db.
+// They take precedence over the variables of the configuration file.
+func WithPathVariables(vars map[string]string) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		if opts.pathVars == nil {
+			opts.pathVars = make(map[string]string)
+		}
+		for name, value := range vars {
+			opts.pathVars[name] = value
+		}
+	}
+}
+
+// resolvePathVariables method merges the variables of the configuration file and the hostname into the variables set by WithPathVariables.
+func (a *Agent) resolvePathVariables() {
+	vars := make(map[string]string)
+	if hostname, err := os.Hostname(); err == nil {
+		vars["hostname"] = hostname
+	}
+	for name, value := range a.config.Vault.Variables {
+		vars[name] = value
+	}
+	for name, value := range a.pathVars {
+		vars[name] = value
+	}
+	a.pathVars = vars
+}
+
+// ExpandPath method replaces the placeholders in a secret path. {name} is replaced by a variable set with WithPathVariables,
+// the variables of the configuration file or hostname, the name of the host. {env:NAME} is replaced by the environment variable NAME.
+// The register methods expand paths themselves, the other methods take expanded paths.
+func (a *Agent) ExpandPath(path string) (string, error) {
+	var missing error
+	expanded := pathVariable.ReplaceAllStringFunc(path, func(placeholder string) string {
+		match := pathVariable.FindStringSubmatch(placeholder)
+		var value string
+		var ok bool
+		if match[1] != "" {
+			value, ok = os.LookupEnv(match[2])
+		} else {
+			value, ok = a.pathVars[match[2]]
+		}
+		if !ok && missing == nil {
+			missing = fmt.Errorf("path %v: variable %v is not set", path, placeholder)
+		}
+		return value
+	})
+	if missing != nil {
+		return "", missing
+	}
+	return expanded, nil
+}
+
+// expandPath method expands the placeholders in a registered path. A path that can't be expanded is logged and kept as it is,
+// so it shows up as failing in the sync status.
+func (a *Agent) expandPath(path string) string {
+	expanded, err := a.ExpandPath(path)
+	if err != nil {
+		a.log.Error("expandPath", slog.Any("error", err))
+		return path
+	}
+	return expanded
+}
+
+// expandConfigPaths method expands the placeholders in the secret paths of the configuration file.
+func (a *Agent) expandConfigPaths() error {
+	v := &a.config.Vault
+
+	var paths []*string
+	for i := range v.Directories {
+		paths = append(paths, &v.Directories[i].Path)
+	}
+	for i := range v.EnvFiles {
+		for j := range v.EnvFiles[i].Secrets {
+			paths = append(paths, &v.EnvFiles[i].Secrets[j].Path)
+		}
+	}
+	if v.Child != nil {
+		for j := range v.Child.Secrets {
+			paths = append(paths, &v.Child.Secrets[j].Path)
+		}
+	}
+	for i := range v.Execs {
+		paths = append(paths, &v.Execs[i].Path)
+	}
+	for i := range v.Signals {
+		paths = append(paths, &v.Signals[i].Path)
+	}
+	for i := range v.Plugins {
+		for j := range v.Plugins[i].Paths {
+			paths = append(paths, &v.Plugins[i].Paths[j])
+		}
+	}
+	for i := range v.Identities {
+		for j := range v.Identities[i].Paths {
+			paths = append(paths, &v.Identities[i].Paths[j])
+		}
+	}
+	if v.PushServer != nil {
+		for i := range v.PushServer.Subscribers {
+			for j := range v.PushServer.Subscribers[i].Paths {
+				paths = append(paths, &v.PushServer.Subscribers[i].Paths[j])
+			}
+		}
+	}
+
+	for _, path := range paths {
+		expanded, err := a.ExpandPath(*path)
+		if err != nil {
+			return err
+		}
+		*path = expanded
+	}
+	return nil
+}
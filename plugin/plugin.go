@@ -0,0 +1,205 @@
+// Package plugin lets receivers run as separate binaries that vaultsync starts and talks to with hashicorp/go-plugin over gRPC,
+// so sinks can be added without rebuilding the service that embeds the agent. A plugin binary calls Serve from its main function:
+//
+//	func main() {
+//		plugin.Serve(&applianceReceiver{})
+//	}
+//
+// The protocol is defined in proto/receiver.proto, plugins in other languages implement it together with the go-plugin handshake.
+package plugin
+
+//go:generate protoc --proto_path=proto --go_out=proto --go_opt=paths=source_relative --go-grpc_out=proto --go-grpc_opt=paths=source_relative receiver.proto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	goplugin "github.com/hashicorp/go-plugin"
+	"github.com/pergus/vaultsync/plugin/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Name is the name the receiver is dispensed under.
+const Name = "receiver"
+
+// Handshake is the handshake between vaultsync and its plugins. A binary started without it refuses to run as a plugin.
+var Handshake = goplugin.HandshakeConfig{
+	ProtocolVersion:  1,
+	MagicCookieKey:   "VAULTSYNC_PLUGIN",
+	MagicCookieValue: "receiver",
+}
+
+// DefaultTimeout is how long a call to a plugin may take unless OpenTimeout is given another timeout.
+const DefaultTimeout = 30 * time.Second
+
+// Receiver interface is implemented by plugins. Configure is called once with the config of the plugin block,
+// UpdateSecret for every field of a synced path, and Flush once a sync cycle has passed all fields.
+type Receiver interface {
+	Configure(config map[string]string) error
+	UpdateSecret(path string, field string, value interface{}) error
+	Flush() error
+}
+
+// Serve function serves a receiver from the main function of a plugin binary. It returns when vaultsync stops the plugin.
+func Serve(receiver Receiver) {
+	goplugin.Serve(&goplugin.ServeConfig{
+		HandshakeConfig: Handshake,
+		Plugins:         goplugin.PluginSet{Name: &ReceiverPlugin{Impl: receiver}},
+		GRPCServer:      goplugin.DefaultGRPCServer,
+	})
+}
+
+// Client struct is a running plugin binary.
+type Client struct {
+	client   *goplugin.Client
+	receiver Receiver
+}
+
+// Open function starts a plugin binary and connects to its receiver with mutual TLS. The lines the plugin writes to its stderr
+// are copied to stderr. Calls to the receiver fail after DefaultTimeout. Close the client to stop the plugin.
+func Open(path string, stderr io.Writer) (*Client, error) {
+	return OpenTimeout(path, stderr, DefaultTimeout)
+}
+
+// OpenTimeout function is Open with the time a call to the receiver may take, so a hung plugin cannot block the caller.
+func OpenTimeout(path string, stderr io.Writer, timeout time.Duration) (*Client, error) {
+	client := goplugin.NewClient(&goplugin.ClientConfig{
+		HandshakeConfig:  Handshake,
+		Plugins:          goplugin.PluginSet{Name: &ReceiverPlugin{Timeout: timeout}},
+		Cmd:              exec.Command(path),
+		AllowedProtocols: []goplugin.Protocol{goplugin.ProtocolGRPC},
+		AutoMTLS:         true,
+		Logger:           hclog.NewNullLogger(),
+		Stderr:           stderr,
+	})
+
+	rpcClient, err := client.Client()
+	if err != nil {
+		client.Kill()
+		return nil, fmt.Errorf("plugin %v:%w", path, err)
+	}
+	raw, err := rpcClient.Dispense(Name)
+	if err != nil {
+		client.Kill()
+		return nil, fmt.Errorf("plugin %v:%w", path, err)
+	}
+	return &Client{client: client, receiver: raw.(Receiver)}, nil
+}
+
+// Receiver method returns the receiver of the plugin.
+func (c *Client) Receiver() Receiver {
+	return c.receiver
+}
+
+// Exited method reports whether the plugin process has exited.
+func (c *Client) Exited() bool {
+	return c.client.Exited()
+}
+
+// Close method stops the plugin.
+func (c *Client) Close() {
+	c.client.Kill()
+}
+
+// ReceiverPlugin struct implements goplugin.GRPCPlugin for a Receiver. Impl is only set in the plugin binary,
+// Timeout is the time a call of vaultsync to the plugin may take, DefaultTimeout if it is not set.
+type ReceiverPlugin struct {
+	goplugin.NetRPCUnsupportedPlugin
+	Impl    Receiver
+	Timeout time.Duration
+}
+
+// GRPCServer method registers the receiver with the gRPC server of the plugin binary.
+func (p *ReceiverPlugin) GRPCServer(broker *goplugin.GRPCBroker, s *grpc.Server) error {
+	proto.RegisterReceiverServer(s, &grpcServer{impl: p.Impl})
+	return nil
+}
+
+// GRPCClient method returns a Receiver that calls the plugin binary.
+func (p *ReceiverPlugin) GRPCClient(ctx context.Context, broker *goplugin.GRPCBroker, c *grpc.ClientConn) (interface{}, error) {
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &grpcClient{client: proto.NewReceiverClient(c), timeout: timeout}, nil
+}
+
+// grpcClient struct is the Receiver used by vaultsync, it forwards the calls to the plugin.
+type grpcClient struct {
+	client  proto.ReceiverClient
+	timeout time.Duration
+}
+
+// context method returns the context of a call, which fails once the timeout passed.
+func (c *grpcClient) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), c.timeout)
+}
+
+// Configure method passes the config of the plugin block to the plugin.
+func (c *grpcClient) Configure(config map[string]string) error {
+	ctx, cancel := c.context()
+	defer cancel()
+	_, err := c.client.Configure(ctx, &proto.ConfigureRequest{Config: config})
+	return err
+}
+
+// UpdateSecret method passes a field to the plugin.
+func (c *grpcClient) UpdateSecret(path string, field string, value interface{}) error {
+	v, err := toValue(value)
+	if err != nil {
+		return fmt.Errorf("field %v:%w", field, err)
+	}
+	ctx, cancel := c.context()
+	defer cancel()
+	_, err = c.client.UpdateSecret(ctx, &proto.UpdateSecretRequest{Path: path, Field: field, Value: v})
+	return err
+}
+
+// Flush method asks the plugin to write the fields of the cycle.
+func (c *grpcClient) Flush() error {
+	ctx, cancel := c.context()
+	defer cancel()
+	_, err := c.client.Flush(ctx, &proto.FlushRequest{})
+	return err
+}
+
+// grpcServer struct serves the Receiver of a plugin binary.
+type grpcServer struct {
+	proto.UnimplementedReceiverServer
+	impl Receiver
+}
+
+// Configure method configures the receiver.
+func (s *grpcServer) Configure(ctx context.Context, req *proto.ConfigureRequest) (*proto.ConfigureResponse, error) {
+	return &proto.ConfigureResponse{}, s.impl.Configure(req.GetConfig())
+}
+
+// UpdateSecret method passes a field to the receiver.
+func (s *grpcServer) UpdateSecret(ctx context.Context, req *proto.UpdateSecretRequest) (*proto.UpdateSecretResponse, error) {
+	return &proto.UpdateSecretResponse{}, s.impl.UpdateSecret(req.GetPath(), req.GetField(), req.GetValue().AsInterface())
+}
+
+// Flush method flushes the receiver.
+func (s *grpcServer) Flush(ctx context.Context, req *proto.FlushRequest) (*proto.FlushResponse, error) {
+	return &proto.FlushResponse{}, s.impl.Flush()
+}
+
+// toValue function converts a field value to a protobuf value. Values are converted through JSON,
+// so numbers decoded from Vault responses as json.Number become numbers.
+func toValue(value interface{}) (*structpb.Value, error) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return nil, err
+	}
+	return structpb.NewValue(decoded)
+}
@@ -0,0 +1,210 @@
+// Protocol between vaultsync and receiver plugins. Plugins written in Go use the plugin package,
+// plugins in other languages implement the Receiver service and the go-plugin handshake.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v28.3.0
+// source: receiver.proto
+
+package proto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Receiver_Configure_FullMethodName    = "/vaultsync.plugin.v1.Receiver/Configure"
+	Receiver_UpdateSecret_FullMethodName = "/vaultsync.plugin.v1.Receiver/UpdateSecret"
+	Receiver_Flush_FullMethodName        = "/vaultsync.plugin.v1.Receiver/Flush"
+)
+
+// ReceiverClient is the client API for Receiver service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Receiver is implemented by a plugin that gets synced secrets.
+type ReceiverClient interface {
+	// Configure passes the config of the plugin block once, before any secret.
+	Configure(ctx context.Context, in *ConfigureRequest, opts ...grpc.CallOption) (*ConfigureResponse, error)
+	// UpdateSecret passes a field of a synced secret path.
+	UpdateSecret(ctx context.Context, in *UpdateSecretRequest, opts ...grpc.CallOption) (*UpdateSecretResponse, error)
+	// Flush is called once a sync cycle has passed all fields, so the plugin can write them at once.
+	Flush(ctx context.Context, in *FlushRequest, opts ...grpc.CallOption) (*FlushResponse, error)
+}
+
+type receiverClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewReceiverClient(cc grpc.ClientConnInterface) ReceiverClient {
+	return &receiverClient{cc}
+}
+
+func (c *receiverClient) Configure(ctx context.Context, in *ConfigureRequest, opts ...grpc.CallOption) (*ConfigureResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ConfigureResponse)
+	err := c.cc.Invoke(ctx, Receiver_Configure_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *receiverClient) UpdateSecret(ctx context.Context, in *UpdateSecretRequest, opts ...grpc.CallOption) (*UpdateSecretResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateSecretResponse)
+	err := c.cc.Invoke(ctx, Receiver_UpdateSecret_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *receiverClient) Flush(ctx context.Context, in *FlushRequest, opts ...grpc.CallOption) (*FlushResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FlushResponse)
+	err := c.cc.Invoke(ctx, Receiver_Flush_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ReceiverServer is the server API for Receiver service.
+// All implementations must embed UnimplementedReceiverServer
+// for forward compatibility.
+//
+// Receiver is implemented by a plugin that gets synced secrets.
+type ReceiverServer interface {
+	// Configure passes the config of the plugin block once, before any secret.
+	Configure(context.Context, *ConfigureRequest) (*ConfigureResponse, error)
+	// UpdateSecret passes a field of a synced secret path.
+	UpdateSecret(context.Context, *UpdateSecretRequest) (*UpdateSecretResponse, error)
+	// Flush is called once a sync cycle has passed all fields, so the plugin can write them at once.
+	Flush(context.Context, *FlushRequest) (*FlushResponse, error)
+	mustEmbedUnimplementedReceiverServer()
+}
+
+// UnimplementedReceiverServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedReceiverServer struct{}
+
+func (UnimplementedReceiverServer) Configure(context.Context, *ConfigureRequest) (*ConfigureResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Configure not implemented")
+}
+func (UnimplementedReceiverServer) UpdateSecret(context.Context, *UpdateSecretRequest) (*UpdateSecretResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateSecret not implemented")
+}
+func (UnimplementedReceiverServer) Flush(context.Context, *FlushRequest) (*FlushResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Flush not implemented")
+}
+func (UnimplementedReceiverServer) mustEmbedUnimplementedReceiverServer() {}
+func (UnimplementedReceiverServer) testEmbeddedByValue()                  {}
+
+// UnsafeReceiverServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ReceiverServer will
+// result in compilation errors.
+type UnsafeReceiverServer interface {
+	mustEmbedUnimplementedReceiverServer()
+}
+
+func RegisterReceiverServer(s grpc.ServiceRegistrar, srv ReceiverServer) {
+	// If the following call pancis, it indicates UnimplementedReceiverServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Receiver_ServiceDesc, srv)
+}
+
+func _Receiver_Configure_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfigureRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReceiverServer).Configure(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Receiver_Configure_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReceiverServer).Configure(ctx, req.(*ConfigureRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Receiver_UpdateSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReceiverServer).UpdateSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Receiver_UpdateSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReceiverServer).UpdateSecret(ctx, req.(*UpdateSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Receiver_Flush_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FlushRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReceiverServer).Flush(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Receiver_Flush_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReceiverServer).Flush(ctx, req.(*FlushRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Receiver_ServiceDesc is the grpc.ServiceDesc for Receiver service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Receiver_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "vaultsync.plugin.v1.Receiver",
+	HandlerType: (*ReceiverServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Configure",
+			Handler:    _Receiver_Configure_Handler,
+		},
+		{
+			MethodName: "UpdateSecret",
+			Handler:    _Receiver_UpdateSecret_Handler,
+		},
+		{
+			MethodName: "Flush",
+			Handler:    _Receiver_Flush_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "receiver.proto",
+}
@@ -0,0 +1,248 @@
+package vaultsync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sync"
+
+	"github.com/pergus/vaultsync/plugin"
+)
+
+// pluginConfig struct defines a receiver plugin, a binary named like the block in plugin_directory that gets the given paths.
+type pluginConfig struct {
+	Name    string            `hcl:"name,label"`
+	Paths   []string          `hcl:"paths"`
+	Config  map[string]string `hcl:"config,optional"`
+	Timeout duration          `hcl:"timeout,optional"`
+}
+
+// validate method checks the plugin configuration.
+func (pc pluginConfig) validate(directory string) error {
+	if directory == "" {
+		return fmt.Errorf("plugin %v: plugin_directory is not set", pc.Name)
+	}
+	if pc.Name == "" || pc.Name != filepath.Base(pc.Name) {
+		return fmt.Errorf("plugin %q: the name must be a file name in plugin_directory", pc.Name)
+	}
+	if len(pc.Paths) == 0 {
+		return fmt.Errorf("plugin %v has no paths", pc.Name)
+	}
+	if _, err := pc.Timeout.parse(); err != nil {
+		return fmt.Errorf("plugin %v: timeout:%w", pc.Name, err)
+	}
+	return nil
+}
+
+// pluginSink struct is a SecretSink that forwards fields to a receiver plugin. Calls to the plugin fail after the timeout of
+// the plugin block, so a hung plugin cannot stall the sync. A plugin that exited or failed a call is started again and gets
+// the fields passed since the last flush before it is flushed, so it never writes part of a cycle.
+type pluginSink struct {
+	config pluginConfig
+	binary string
+	log    *slog.Logger
+
+	mu     sync.Mutex
+	client *plugin.Client
+	// pending are the fields passed since the last successful flush, replayed to a plugin that is started again.
+	pending map[string]map[string]interface{}
+	// failed is set when a call to the plugin failed, the fields of the cycle are only kept until the plugin is
+	// started again at the flush.
+	failed bool
+}
+
+// newPluginSink function starts a receiver plugin and configures it.
+func newPluginSink(directory string, config pluginConfig, log *slog.Logger) (*pluginSink, error) {
+	ps := &pluginSink{
+		config:  config,
+		binary:  filepath.Join(directory, config.Name),
+		log:     log.With(slog.String("plugin", config.Name)),
+		pending: make(map[string]map[string]interface{}),
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if err := ps.start(); err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+// start method starts the plugin, configures it and passes it the pending fields, stopping the plugin that ran before.
+// It must be called with mu held.
+func (ps *pluginSink) start() error {
+	if ps.client != nil {
+		ps.client.Close()
+		ps.client = nil
+	}
+
+	timeout := ps.config.Timeout.value()
+	if timeout <= 0 {
+		timeout = plugin.DefaultTimeout
+	}
+	client, err := plugin.OpenTimeout(ps.binary, newLogWriter(ps.log), timeout)
+	if err != nil {
+		return err
+	}
+	if err := client.Receiver().Configure(ps.config.Config); err != nil {
+		client.Close()
+		return fmt.Errorf("plugin %v:%w", ps.config.Name, err)
+	}
+	for path, fields := range ps.pending {
+		for field, value := range fields {
+			if err := client.Receiver().UpdateSecret(path, field, value); err != nil {
+				client.Close()
+				return fmt.Errorf("plugin %v: secret path %v:%w", ps.config.Name, path, err)
+			}
+		}
+	}
+	ps.client = client
+	return nil
+}
+
+// receiver method returns the receiver of the plugin, starting the plugin again if it exited or failed a call.
+// It reports whether the plugin was started, it then already got the pending fields. It must be called with mu held.
+func (ps *pluginSink) receiver() (plugin.Receiver, bool, error) {
+	if ps.client != nil && !ps.client.Exited() && !ps.failed {
+		return ps.client.Receiver(), false, nil
+	}
+	ps.log.Warn("receiver", slog.String("status", "starting the plugin again"), slog.Int("pending paths", len(ps.pending)))
+	if err := ps.start(); err != nil {
+		ps.failed = true
+		return nil, false, err
+	}
+	ps.failed = false
+	return ps.client.Receiver(), true, nil
+}
+
+// UpdateSecret method passes a field to the plugin, logging failures. After a failure the fields of the cycle are
+// passed again when the plugin is started again at the flush.
+func (ps *pluginSink) UpdateSecret(id string, fieldName string, value interface{}) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.pending[id] == nil {
+		ps.pending[id] = make(map[string]interface{})
+	}
+	ps.pending[id][fieldName] = value
+	if ps.failed {
+		return
+	}
+
+	receiver, started, err := ps.receiver()
+	if err == nil && !started {
+		err = receiver.UpdateSecret(id, fieldName, value)
+	}
+	if err != nil {
+		ps.failed = true
+		ps.log.Error("UpdateSecret", slog.String("secret-path", id), slog.String("field", fieldName), slog.Any("error", err))
+	}
+}
+
+// Flush method asks the plugin to write the fields of the cycle. A plugin that exited or failed a call is started again
+// and gets all fields passed since the last flush first. The fields are kept until a flush succeeds.
+func (ps *pluginSink) Flush() error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	receiver, _, err := ps.receiver()
+	if err != nil {
+		return err
+	}
+	if err := receiver.Flush(); err != nil {
+		ps.failed = true
+		return fmt.Errorf("plugin %v:%w", ps.config.Name, err)
+	}
+	ps.pending = make(map[string]map[string]interface{})
+	return nil
+}
+
+// close method stops the plugin.
+func (ps *pluginSink) close() {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.client != nil {
+		ps.client.Close()
+		ps.client = nil
+	}
+}
+
+// startPlugins method starts the receiver plugins of the configuration and registers them as sinks.
+func (a *Agent) startPlugins() error {
+	for _, pc := range a.config.Vault.Plugins {
+		ps, err := newPluginSink(a.config.Vault.PluginDirectory, pc, a.logs.sinks)
+		if err != nil {
+			a.closePlugins()
+			return err
+		}
+		a.plugins = append(a.plugins, ps)
+		a.RegisterSink(ps, pc.Paths...)
+		a.logs.sinks.Info("startPlugins", slog.String("plugin", pc.Name), slog.Any("paths", pc.Paths))
+	}
+	return nil
+}
+
+// closePlugins method stops the receiver plugins.
+func (a *Agent) closePlugins() {
+	for _, ps := range a.plugins {
+		ps.close()
+	}
+	a.plugins = nil
+}
+
+// logWriter struct logs each line written to it, for the stderr of plugins.
+type logWriter struct {
+	log *slog.Logger
+
+	mu   sync.Mutex
+	line []byte
+}
+
+// newLogWriter function returns a writer that logs each line written to it.
+func newLogWriter(log *slog.Logger) *logWriter {
+	return &logWriter{log: log}
+}
+
+// Write method logs the complete lines of p and keeps the rest for the next write.
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.line = append(w.line, p...)
+	for {
+		i := bytes.IndexByte(w.line, '\n')
+		if i < 0 {
+			break
+		}
+		w.logLine(w.line[:i])
+		w.line = w.line[i+1:]
+	}
+	return len(p), nil
+}
+
+// logLine method logs a line of the plugin. The go-plugin library of the plugin writes JSON lines with a level and a message,
+// other lines are logged at info level.
+func (w *logWriter) logLine(line []byte) {
+	var record struct {
+		Level   string `json:"@level"`
+		Message string `json:"@message"`
+	}
+	if err := json.Unmarshal(line, &record); err != nil || record.Message == "" {
+		w.log.Info("plugin", slog.String("stderr", string(line)))
+		return
+	}
+
+	level := slog.LevelInfo
+	switch record.Level {
+	case "trace", "debug":
+		level = slog.LevelDebug
+	case "warn":
+		level = slog.LevelWarn
+	case "error":
+		level = slog.LevelError
+	}
+	w.log.Log(context.Background(), level, "plugin", slog.String("message", record.Message))
+}
@@ -0,0 +1,148 @@
+package vaultsync_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+// pluginDirectory is the plugin_directory holding the receiver plugin of testdata/plugin, built by TestMain.
+var pluginDirectory string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "vaultsync-plugins")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	build := exec.Command("go", "build", "-o", filepath.Join(dir, "receiver"), "./testdata/plugin")
+	build.Stdout, build.Stderr = os.Stderr, os.Stderr
+	if err := build.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, "building the test plugin:", err)
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+	pluginDirectory = dir
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// newPluginAgent function creates an agent of the fake whose paths go to the receiver plugin with the given settings.
+func newPluginAgent(t *testing.T, s *vaultsynctest.Server, settings string) *vaultsync.Agent {
+	t.Helper()
+
+	extra := fmt.Sprintf(`  plugin_directory = %q
+  plugin "receiver" {
+    paths = ["secret/data/app"]
+%s
+  }`, pluginDirectory, settings)
+	filename, err := s.WriteConfig(t.TempDir(), 3600, extra)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newConfigAgent(t, filename)
+}
+
+// newConfigAgent function creates an agent from a configuration file that logs nothing, stopped when the test ends.
+func newConfigAgent(t *testing.T, filename string, opts ...vaultsync.AgentOptFunc) *vaultsync.Agent {
+	t.Helper()
+
+	opts = append([]vaultsync.AgentOptFunc{vaultsync.WithConfigFile(filename), vaultsync.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))}, opts...)
+	agent, err := vaultsync.New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(agent.Stop)
+	return agent
+}
+
+// readPluginFile function returns the fields the plugin wrote for secret/data/app.
+func readPluginFile(t *testing.T, dir string) map[string]interface{} {
+	t.Helper()
+
+	b, err := os.ReadFile(filepath.Join(dir, "secret_data_app.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+	return fields
+}
+
+func TestPluginReceivesFields(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"user": "app", "password": "s3cret"})
+	out := t.TempDir()
+	agent := newPluginAgent(t, s, fmt.Sprintf("    config = { directory = %q }", out))
+
+	vaultsynctest.Sync(t, agent)
+	if fields := readPluginFile(t, out); fields["user"] != "app" || fields["password"] != "s3cret" {
+		t.Fatalf("got %v", fields)
+	}
+
+	s.SetSecret("secret/data/app", map[string]interface{}{"user": "app", "password": "n3w"})
+	vaultsynctest.Sync(t, agent)
+	if fields := readPluginFile(t, out); fields["password"] != "n3w" {
+		t.Fatalf("got %v after rotation", fields)
+	}
+}
+
+func TestPluginRestartedMidCycleGetsAllFields(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	out := t.TempDir()
+	crashFile := filepath.Join(t.TempDir(), "crash")
+	agent := newPluginAgent(t, s, fmt.Sprintf("    config = { directory = %q, crash_file = %q }", out, crashFile))
+
+	// The plugin exits while it gets the fields of the cycle, the agent starts it again and passes all fields again.
+	if err := os.WriteFile(crashFile, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	s.SetSecret("secret/data/app", map[string]interface{}{"user": "app", "password": "s3cret", "host": "db", "crash": "now"})
+	vaultsynctest.Sync(t, agent)
+
+	if _, err := os.Stat(crashFile); !os.IsNotExist(err) {
+		t.Fatal("the plugin did not crash")
+	}
+	fields := readPluginFile(t, out)
+	for field, want := range map[string]string{"user": "app", "password": "s3cret", "host": "db", "crash": "now"} {
+		if fields[field] != want {
+			t.Errorf("field %v is %v, want %v, the plugin flushed part of the cycle: %v", field, fields[field], want, fields)
+		}
+	}
+}
+
+func TestHungPluginDoesNotStallSync(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+	out := t.TempDir()
+	agent := newPluginAgent(t, s, fmt.Sprintf("    config = { directory = %q, hang = \"flush\" }\n    timeout = \"200ms\"", out))
+	recorder := vaultsynctest.NewRecorder()
+	agent.RegisterUpdateSecret("secret/data/app", recorder)
+
+	start := time.Now()
+	vaultsynctest.Sync(t, agent)
+	vaultsynctest.Sync(t, agent)
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("two syncs took %v with a hung plugin", elapsed)
+	}
+	recorder.AssertValue(t, "secret/data/app", "password", "s3cret")
+	if _, err := os.Stat(filepath.Join(out, "secret_data_app.json")); !os.IsNotExist(err) {
+		t.Fatal("the hung plugin wrote its file")
+	}
+}
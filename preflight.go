@@ -0,0 +1,154 @@
+package vaultsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// PreflightResult struct reports whether the agent's token can read a secret path.
+type PreflightResult struct {
+	Path         string   // Vault secret path.
+	Capabilities []string // Capabilities of the token on the path.
+	Readable     bool     // True if the capabilities allow reading the path.
+	Err          error    // Error of the capabilities lookup, if any.
+}
+
+// Preflight method checks, using sys/capabilities-self, that the token can read every registered path, paths with an identity
+// are checked with the token of the identity.
+// No secrets are read and nothing is dispatched to receivers. Preflight returns a result per path, sorted by path,
+// and an error naming the paths that are not readable.
+func (a *Agent) Preflight(ctx context.Context) ([]PreflightResult, error) {
+	var results []PreflightResult
+	var failed []string
+
+	for _, path := range a.trackedPaths() {
+		result := PreflightResult{Path: path}
+		client, _, err := a.pathClient(ctx, path)
+		if err == nil {
+			result.Capabilities, err = client.Sys().CapabilitiesSelfWithContext(ctx, path)
+		}
+		result.Err = err
+		result.Readable = result.Err == nil && canRead(result.Capabilities)
+		results = append(results, result)
+
+		if !result.Readable {
+			failed = append(failed, path)
+			a.log.Warn("Preflight", slog.String("secret-path", path), slog.Any("capabilities", result.Capabilities), slog.Any("error", result.Err))
+			continue
+		}
+		a.log.Debug("Preflight", slog.String("secret-path", path), slog.Any("capabilities", result.Capabilities))
+	}
+
+	if len(failed) > 0 {
+		return results, fmt.Errorf("token can't read secret paths: %s", strings.Join(failed, ", "))
+	}
+	return results, nil
+}
+
+// ErrNotAuthenticated is returned by RegisterUpdateSecretChecked when the agent has no token, because it started from its cache.
+var ErrNotAuthenticated = errors.New("agent is not authenticated")
+
+// RegisterUpdateSecretChecked method registers a secret receiver like RegisterUpdateSecret, after checking that the path
+// exists, holds data and is readable by the token of the agent. If not, the receiver is not registered and the error
+// is returned, so a mistyped path is reported to the caller instead of failing every sync. KV v2 secrets are checked
+// with Subkeys, without reading their values, other paths and tokens that may not read the subkeys are checked by
+// reading the path. The data read is not dispatched, the receiver gets it on the first sync. It must be called after New,
+// which authenticates the agent.
+func (a *Agent) RegisterUpdateSecretChecked(ctx context.Context, id string, receiver SecretReceiver) error {
+	path := a.expandPath(id)
+	if a.token.Load() == nil {
+		return fmt.Errorf("secret path %v:%w", path, ErrNotAuthenticated)
+	}
+
+	if _, ok := a.extractors[path]; !ok && a.pathVersion(path) == 2 {
+		if _, _, err := a.Subkeys(ctx, path); err == nil {
+			a.RegisterUpdateSecret(path, receiver)
+			return nil
+		}
+	}
+
+	_, api, err := a.pathClient(ctx, path)
+	if err != nil {
+		return fmt.Errorf("secret path %v:%w", path, err)
+	}
+	secret, err := api.Read(ctx, path)
+	a.recordVaultCall(err)
+	if err == nil && secret == nil {
+		err = ErrSecretNotFound
+	}
+	if err == nil {
+		_, err = a.secretData(path, secret)
+	}
+	if err != nil {
+		a.log.Warn("RegisterUpdateSecretChecked", slog.String("secret-path", path), slog.Any("error", err))
+		return fmt.Errorf("secret path %v:%w", path, err)
+	}
+
+	a.RegisterUpdateSecret(path, receiver)
+	return nil
+}
+
+// Values of startup_preflight.
+const (
+	startupPreflightWarn = "warn"
+	startupPreflightFail = "fail"
+)
+
+// WithStartupPreflight function makes Run check with Preflight that the token can read every registered path before it syncs them.
+// If fail is true Run returns an error naming the paths that are not readable, otherwise they are logged as a warning.
+// It takes precedence over startup_preflight in the configuration file.
+func WithStartupPreflight(fail bool) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.preflightMode = startupPreflightWarn
+		if fail {
+			opts.preflightMode = startupPreflightFail
+		}
+	}
+}
+
+// validateStartupPreflight function checks the value of startup_preflight.
+func validateStartupPreflight(mode string) error {
+	switch mode {
+	case "", startupPreflightWarn, startupPreflightFail:
+		return nil
+	}
+	return fmt.Errorf("invalid startup_preflight %q, use %v or %v", mode, startupPreflightWarn, startupPreflightFail)
+}
+
+// startupPreflight method runs the preflight check of Run. It returns an error only if the check fails and should stop Run.
+// It is skipped when the agent started from its cache, there is no token to check.
+func (a *Agent) startupPreflight(ctx context.Context) error {
+	mode := a.preflightMode
+	if mode == "" {
+		mode = a.config.Vault.StartupPreflight
+	}
+	if mode == "" || a.token.Load() == nil {
+		return nil
+	}
+
+	_, err := a.Preflight(ctx)
+	if err == nil {
+		return nil
+	}
+	if mode == startupPreflightFail {
+		return fmt.Errorf("preflight failed:%w", err)
+	}
+	a.log.Warn("Run", slog.String("status", "preflight failed, syncing anyway"), slog.Any("error", err))
+	return nil
+}
+
+// canRead function reports whether the capabilities allow reading.
+func canRead(capabilities []string) bool {
+	for _, capability := range capabilities {
+		switch capability {
+		case "deny":
+			return false
+		case "read", "root":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package vaultsync_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+func TestRegisterUpdateSecretChecked(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+
+	agent := vaultsynctest.NewAgent(t, s)
+	recorder := vaultsynctest.NewRecorder()
+	ctx := context.Background()
+
+	if err := agent.RegisterUpdateSecretChecked(ctx, "secret/data/missing", recorder); !errors.Is(err, vaultsync.ErrSecretNotFound) {
+		t.Fatalf("missing path: got %v, want ErrSecretNotFound", err)
+	}
+	if err := agent.RegisterUpdateSecretChecked(ctx, "secret/data/app", recorder); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.SyncOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+	recorder.AssertValue(t, "secret/data/app", "password", "s3cret")
+	if _, ok := recorder.Value("secret/data/missing", "password"); ok {
+		t.Fatal("receiver of the missing path was registered")
+	}
+}
@@ -0,0 +1,125 @@
+package vaultsync
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsimple"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// ProfileEnv is the environment variable that selects the profile of the configuration file unless WithProfile is set.
+const ProfileEnv = "VAULTSYNC_PROFILE"
+
+// WithProfile function selects a profile, a labeled config block such as config "prod" { ... }, of the configuration file.
+// It takes precedence over the VAULTSYNC_PROFILE environment variable. Without a profile the unlabeled config block is used.
+func WithProfile(profile string) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.profile = profile
+	}
+}
+
+// selectedProfile method returns the profile set with WithProfile or, if not set, by VAULTSYNC_PROFILE.
+func (a *Agent) selectedProfile() string {
+	if a.profile != "" {
+		return a.profile
+	}
+	return os.Getenv(ProfileEnv)
+}
+
+// decodeConfigFile function decodes the config block of profile from an HCL file into c. An empty profile selects the unlabeled config block.
+// JSON files have a single config block and no profiles.
+func decodeConfigFile(filename string, profile string, c *config) error {
+	if strings.HasSuffix(filename, ".json") {
+		if profile != "" {
+			return fmt.Errorf("profile %v: profiles are not supported in JSON files", profile)
+		}
+		return hclsimple.DecodeFile(filename, nil, c)
+	}
+
+	profiles, body, err := parseProfiles(filename)
+	if err != nil {
+		return err
+	}
+	block, ok := profiles[profile]
+	if !ok {
+		if profile == "" {
+			return fmt.Errorf("no unlabeled config block, select one of the profiles %v", strings.Join(profileNames(profiles), ", "))
+		}
+		return fmt.Errorf("profile %v is not defined", profile)
+	}
+	return decodeProfile(body, block, c)
+}
+
+// parseProfiles function parses an HCL file and returns its config blocks by profile, the unlabeled block under "", and the body of the file.
+func parseProfiles(filename string) (map[string]*hclsyntax.Block, *hclsyntax.Body, error) {
+	file, diags := hclparse.NewParser().ParseHCLFile(filename)
+	if diags.HasErrors() {
+		return nil, nil, diags
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, nil, fmt.Errorf("unexpected body type %T", file.Body)
+	}
+
+	profiles := make(map[string]*hclsyntax.Block)
+	for _, block := range body.Blocks {
+		if block.Type != "config" {
+			continue
+		}
+		if len(block.Labels) > 1 {
+			return nil, nil, fmt.Errorf("%v: config block has more than one label", block.DefRange())
+		}
+		var profile string
+		if len(block.Labels) == 1 {
+			profile = block.Labels[0]
+			if profile == "" {
+				return nil, nil, fmt.Errorf("%v: config block has an empty label", block.DefRange())
+			}
+		}
+		if _, ok := profiles[profile]; ok {
+			if profile == "" {
+				return nil, nil, fmt.Errorf("%v: duplicate unlabeled config block", block.DefRange())
+			}
+			return nil, nil, fmt.Errorf("%v: duplicate config block for profile %v", block.DefRange(), profile)
+		}
+		profiles[profile] = block
+	}
+	return profiles, body, nil
+}
+
+// decodeProfile function decodes a config block into c as if it were the only, unlabeled, config block of the file.
+func decodeProfile(body *hclsyntax.Body, block *hclsyntax.Block, c *config) error {
+	unlabeled := *block
+	unlabeled.Labels = nil
+	unlabeled.LabelRanges = nil
+
+	blocks := hclsyntax.Blocks{&unlabeled}
+	for _, b := range body.Blocks {
+		if b.Type != "config" {
+			blocks = append(blocks, b)
+		}
+	}
+	single := &hclsyntax.Body{Attributes: body.Attributes, Blocks: blocks, SrcRange: body.SrcRange, EndRange: body.EndRange}
+
+	if diags := gohcl.DecodeBody(single, nil, c); diags.HasErrors() {
+		return diags
+	}
+	return nil
+}
+
+// profileNames function returns the sorted names of the labeled config blocks.
+func profileNames(profiles map[string]*hclsyntax.Block) []string {
+	var names []string
+	for name := range profiles {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,406 @@
+package vaultsync
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pergus/vaultsync/push/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// pushBufferSize is the number of updates queued for a subscriber. A subscriber that falls further behind is disconnected.
+const pushBufferSize = 64
+
+// pushServerConfig struct defines the push server that streams synced secrets to remote subscribers over mutual TLS.
+type pushServerConfig struct {
+	Address      string                 `hcl:"address"`
+	CertFile     string                 `hcl:"cert_file"`
+	KeyFile      string                 `hcl:"key_file"`
+	ClientCAFile string                 `hcl:"client_ca_file"`
+	Subscribers  []pushSubscriberConfig `hcl:"subscriber,block"`
+}
+
+// pushSubscriberConfig struct defines the paths a subscriber may read. The name is matched against the common name,
+// DNS names and URIs of the client certificate, the paths are path.Match patterns such as secret/data/app/*.
+type pushSubscriberConfig struct {
+	Name  string   `hcl:"name,label"`
+	Paths []string `hcl:"paths"`
+}
+
+// validate method checks the push server configuration.
+func (pc *pushServerConfig) validate() error {
+	if pc.Address == "" {
+		return fmt.Errorf("push_server needs an address")
+	}
+	if pc.CertFile == "" || pc.KeyFile == "" {
+		return fmt.Errorf("push_server needs a cert_file and a key_file")
+	}
+	if pc.ClientCAFile == "" {
+		return fmt.Errorf("push_server needs a client_ca_file, subscribers authenticate with client certificates")
+	}
+	for _, sc := range pc.Subscribers {
+		if sc.Name == "" {
+			return fmt.Errorf("push_server subscriber without a name")
+		}
+		for _, pattern := range sc.Paths {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("push_server subscriber %v: invalid path %q:%w", sc.Name, pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// tlsConfig method loads the server certificate and the CA of the client certificates.
+func (pc *pushServerConfig) tlsConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(pc.CertFile, pc.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("push_server:%w", err)
+	}
+	caPEM, err := os.ReadFile(pc.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("push_server:%w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("push_server: no certificates in %v", pc.ClientCAFile)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// pushServer struct serves the Push service of push/proto. Each subscriber gets the current fields of its paths
+// followed by every sync of them.
+type pushServer struct {
+	proto.UnimplementedPushServer
+	agent     *Agent
+	config    *pushServerConfig
+	tlsConfig *tls.Config
+	listener  net.Listener
+
+	mu   sync.Mutex
+	subs map[*pushSubscription]bool
+}
+
+// pushSubscription struct is a connected subscriber.
+type pushSubscription struct {
+	paths   map[string]bool
+	updates chan *proto.SecretUpdate
+}
+
+// newPushServer method creates the push server of the configuration and tracks the paths its subscribers may read,
+// patterns only match paths that are tracked otherwise.
+func (a *Agent) newPushServer() error {
+	pc := a.config.Vault.PushServer
+	if pc == nil {
+		return nil
+	}
+	tlsConfig, err := pc.tlsConfig()
+	if err != nil {
+		return err
+	}
+	for _, sc := range pc.Subscribers {
+		for _, pattern := range sc.Paths {
+			if !isPattern(pattern) {
+				a.trackPath(pattern)
+			}
+		}
+	}
+	a.push = &pushServer{agent: a, config: pc, tlsConfig: tlsConfig, subs: make(map[*pushSubscription]bool)}
+	return nil
+}
+
+// isPattern function reports whether a subscriber path contains path.Match meta characters.
+func isPattern(p string) bool {
+	return strings.ContainsAny(p, `*?[\`)
+}
+
+// listenPush method opens the listener of the push server, so Run fails before starting anything if the address is taken.
+func (a *Agent) listenPush() error {
+	if a.push == nil {
+		return nil
+	}
+	listener, err := net.Listen("tcp", a.push.config.Address)
+	if err != nil {
+		return fmt.Errorf("push_server:%w", err)
+	}
+	a.push.listener = listener
+	return nil
+}
+
+// servePush method serves the push server until ctx is done.
+func (a *Agent) servePush(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(a.push.tlsConfig)))
+	proto.RegisterPushServer(server, a.push)
+
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-ctx.Done():
+			// Subscriptions never end on their own, so the server is stopped instead of drained.
+			server.Stop()
+		case <-stopped:
+		}
+	}()
+
+	a.log.Info("servePush", slog.String("address", a.push.listener.Addr().String()))
+	if err := server.Serve(a.push.listener); err != nil {
+		a.log.Error("servePush", slog.Any("error", err))
+	}
+	a.log.Info("servePush", slog.String("status", "cancel"))
+}
+
+// Subscribe method streams the current fields of the requested paths and every later sync of them to a subscriber.
+func (ps *pushServer) Subscribe(req *proto.SubscribeRequest, stream proto.Push_SubscribeServer) error {
+	names, err := peerNames(stream.Context())
+	if err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	identity := names[0]
+
+	paths, err := ps.authorize(names, req.GetPaths())
+	if err != nil {
+		ps.agent.logs.dispatcher.Warn("Subscribe", slog.String("subscriber", identity), slog.Any("paths", req.GetPaths()), slog.Any("error", err))
+		return err
+	}
+
+	sub := ps.subscribe(paths)
+	defer ps.unsubscribe(sub)
+	ps.agent.logs.dispatcher.Info("Subscribe", slog.String("subscriber", identity), slog.Any("paths", paths))
+
+	// Updates published while the snapshot is sent are queued and sent after it.
+	for _, p := range paths {
+		update, err := ps.agent.pushSnapshot(p)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		if update == nil {
+			continue
+		}
+		if err := stream.Send(update); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			ps.agent.logs.dispatcher.Info("Subscribe", slog.String("subscriber", identity), slog.String("status", "disconnected"))
+			return nil
+		case update, ok := <-sub.updates:
+			if !ok {
+				ps.agent.logs.dispatcher.Warn("Subscribe", slog.String("subscriber", identity), slog.String("status", "too slow, disconnected"))
+				return status.Error(codes.ResourceExhausted, "subscriber does not keep up with the updates")
+			}
+			if err := stream.Send(update); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// authorize method returns the paths a subscriber gets. Without requested paths these are all tracked paths the subscriber may read,
+// otherwise the requested paths, all of which must be tracked and readable.
+func (ps *pushServer) authorize(names []string, requested []string) ([]string, error) {
+	tracked := ps.agent.trackedPaths()
+	if len(requested) == 0 {
+		var paths []string
+		for _, p := range tracked {
+			if ps.allowed(names, p) {
+				paths = append(paths, p)
+			}
+		}
+		if len(paths) == 0 {
+			return nil, status.Error(codes.PermissionDenied, "no paths may be read")
+		}
+		return paths, nil
+	}
+
+	known := make(map[string]bool, len(tracked))
+	for _, p := range tracked {
+		known[p] = true
+	}
+	for _, p := range requested {
+		if !ps.allowed(names, p) {
+			return nil, status.Errorf(codes.PermissionDenied, "path %v may not be read", p)
+		}
+		if !known[p] {
+			return nil, status.Errorf(codes.NotFound, "path %v is not synced", p)
+		}
+	}
+	return requested, nil
+}
+
+// allowed method reports whether a subscriber with one of names may read path.
+func (ps *pushServer) allowed(names []string, p string) bool {
+	for _, sc := range ps.config.Subscribers {
+		if !containsString(names, sc.Name) {
+			continue
+		}
+		for _, pattern := range sc.Paths {
+			if ok, _ := path.Match(pattern, p); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsString function reports whether s is one of list.
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// peerNames function returns the common name, DNS names and URIs of the verified client certificate of a stream.
+func peerNames(ctx context.Context) ([]string, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("no peer")
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return nil, fmt.Errorf("no verified client certificate")
+	}
+	cert := tlsInfo.State.VerifiedChains[0][0]
+
+	var names []string
+	if cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+	names = append(names, cert.DNSNames...)
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("client certificate has no name")
+	}
+	return names, nil
+}
+
+// subscribe method adds a subscription for paths.
+func (ps *pushServer) subscribe(paths []string) *pushSubscription {
+	sub := &pushSubscription{
+		paths:   make(map[string]bool, len(paths)),
+		updates: make(chan *proto.SecretUpdate, pushBufferSize),
+	}
+	for _, p := range paths {
+		sub.paths[p] = true
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.subs[sub] = true
+	return sub
+}
+
+// unsubscribe method removes a subscription.
+func (ps *pushServer) unsubscribe(sub *pushSubscription) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	delete(ps.subs, sub)
+}
+
+// publish method queues a sync of path for its subscribers. Subscribers whose queue is full are dropped, closing their queue
+// ends their stream. It does nothing without a push server.
+func (ps *pushServer) publish(update *proto.SecretUpdate) {
+	if ps == nil {
+		return
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	for sub := range ps.subs {
+		if !sub.paths[update.GetPath()] {
+			continue
+		}
+		select {
+		case sub.updates <- update:
+		default:
+			delete(ps.subs, sub)
+			close(sub.updates)
+		}
+	}
+}
+
+// publishSync method publishes a sync of path to the subscribers of the push server.
+func (a *Agent) publishSync(log *slog.Logger, path string, version int, data map[string]interface{}) {
+	if a.push == nil {
+		return
+	}
+	update, err := newSecretUpdate(path, version, a.clock.Now(), data)
+	if err != nil {
+		log.Error("publishSync", slog.String("secret-path", path), slog.Any("error", err))
+		return
+	}
+	a.push.publish(update)
+}
+
+// pushSnapshot method returns the last sync of path as an update, nil if the path has not been synced.
+func (a *Agent) pushSnapshot(path string) (*proto.SecretUpdate, error) {
+	a.mu.RLock()
+	state, ok := a.paths[path]
+	if !ok || state.data == nil {
+		a.mu.RUnlock()
+		return nil, nil
+	}
+	data := make(map[string]interface{}, len(state.data))
+	for field, value := range state.data {
+		data[field] = unsealValue(value)
+	}
+	version, syncedAt := state.version, state.syncedAt()
+	a.mu.RUnlock()
+
+	return newSecretUpdate(path, version, syncedAt, data)
+}
+
+// newSecretUpdate function converts the fields of a path to an update. Values are converted through JSON,
+// so numbers decoded from Vault responses as json.Number become numbers.
+func newSecretUpdate(path string, version int, syncedAt time.Time, data map[string]interface{}) (*proto.SecretUpdate, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return nil, err
+	}
+	fields, err := structpb.NewStruct(decoded)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.SecretUpdate{
+		Path:     path,
+		Fields:   fields.GetFields(),
+		Version:  int64(version),
+		SyncedAt: timestamppb.New(syncedAt),
+	}, nil
+}
@@ -0,0 +1,134 @@
+// Protocol between the push server of a vaultsync agent and remote subscribers.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v28.3.0
+// source: push.proto
+
+package proto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Push_Subscribe_FullMethodName = "/vaultsync.push.v1.Push/Subscribe"
+)
+
+// PushClient is the client API for Push service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Push streams synced secrets to subscribers authenticated with mutual TLS.
+type PushClient interface {
+	// Subscribe streams the current fields of the paths, then every update of them.
+	// Without paths all paths the subscriber may read are streamed.
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SecretUpdate], error)
+}
+
+type pushClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPushClient(cc grpc.ClientConnInterface) PushClient {
+	return &pushClient{cc}
+}
+
+func (c *pushClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SecretUpdate], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Push_ServiceDesc.Streams[0], Push_Subscribe_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeRequest, SecretUpdate]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Push_SubscribeClient = grpc.ServerStreamingClient[SecretUpdate]
+
+// PushServer is the server API for Push service.
+// All implementations must embed UnimplementedPushServer
+// for forward compatibility.
+//
+// Push streams synced secrets to subscribers authenticated with mutual TLS.
+type PushServer interface {
+	// Subscribe streams the current fields of the paths, then every update of them.
+	// Without paths all paths the subscriber may read are streamed.
+	Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[SecretUpdate]) error
+	mustEmbedUnimplementedPushServer()
+}
+
+// UnimplementedPushServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPushServer struct{}
+
+func (UnimplementedPushServer) Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[SecretUpdate]) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedPushServer) mustEmbedUnimplementedPushServer() {}
+func (UnimplementedPushServer) testEmbeddedByValue()              {}
+
+// UnsafePushServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PushServer will
+// result in compilation errors.
+type UnsafePushServer interface {
+	mustEmbedUnimplementedPushServer()
+}
+
+func RegisterPushServer(s grpc.ServiceRegistrar, srv PushServer) {
+	// If the following call pancis, it indicates UnimplementedPushServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Push_ServiceDesc, srv)
+}
+
+func _Push_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PushServer).Subscribe(m, &grpc.GenericServerStream[SubscribeRequest, SecretUpdate]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Push_SubscribeServer = grpc.ServerStreamingServer[SecretUpdate]
+
+// Push_ServiceDesc is the grpc.ServiceDesc for Push service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Push_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "vaultsync.push.v1.Push",
+	HandlerType: (*PushServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _Push_Subscribe_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "push.proto",
+}
@@ -0,0 +1,66 @@
+// Package push subscribes to the push server of a vaultsync agent, so several services on a host can share one agent
+// instead of each embedding its own. The subscriber authenticates with a client certificate and gets the fields of the
+// paths it may read, first their current values and then every sync of them:
+//
+//	err := push.Subscribe(ctx, "localhost:8201", tlsConfig, []string{"secret/data/app"}, &appConfig)
+//
+// The protocol is defined in proto/push.proto.
+package push
+
+//go:generate protoc --proto_path=proto --go_out=proto --go_opt=paths=source_relative --go-grpc_out=proto --go-grpc_opt=paths=source_relative push.proto
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+
+	"github.com/pergus/vaultsync/push/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Receiver interface is implemented by the receivers of a subscription, vaultsync.SecretReceiver satisfies it.
+// If the receiver also has a Flush method, like vaultsync.SecretSink, Flush is called once all fields of an update were passed.
+type Receiver interface {
+	UpdateSecret(id string, fieldName string, value interface{})
+}
+
+// flusher interface is implemented by receivers that write the fields of an update at once.
+type flusher interface {
+	Flush() error
+}
+
+// Subscribe function connects to the push server at address and passes the fields of paths to receiver until ctx is done,
+// when it returns nil. Without paths all paths the subscriber may read are passed. It returns an error if the connection fails
+// or the server ends the subscription, callers that want to stay subscribed call it again.
+func Subscribe(ctx context.Context, address string, tlsConfig *tls.Config, paths []string, receiver Receiver) error {
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	if err != nil {
+		return fmt.Errorf("push server %v:%w", address, err)
+	}
+	defer conn.Close()
+
+	stream, err := proto.NewPushClient(conn).Subscribe(ctx, &proto.SubscribeRequest{Paths: paths})
+	if err != nil {
+		return fmt.Errorf("push server %v:%w", address, err)
+	}
+
+	for {
+		update, err := stream.Recv()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("push server %v:%w", address, err)
+		}
+
+		for field, value := range update.GetFields() {
+			receiver.UpdateSecret(update.GetPath(), field, value.AsInterface())
+		}
+		if f, ok := receiver.(flusher); ok {
+			if err := f.Flush(); err != nil {
+				return fmt.Errorf("path %v:%w", update.GetPath(), err)
+			}
+		}
+	}
+}
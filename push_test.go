@@ -0,0 +1,334 @@
+package vaultsync_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/push/proto"
+	"github.com/pergus/vaultsync/vaultsynctest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// testCA struct is a certificate authority issuing the certificates of the push server and its subscribers.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+	pem  []byte
+}
+
+// newTestCA function creates a self-signed certificate authority.
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "vaultsync test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue method returns a certificate with the common name for a server on 127.0.0.1 or a client.
+func (ca *testCA) issue(t *testing.T, commonName string, server bool) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if server {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// writeKeyPair function writes a certificate and its key as PEM files to dir and returns their names.
+func writeKeyPair(t *testing.T, dir string, cert tls.Certificate) (string, string) {
+	t.Helper()
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, "push.crt"), filepath.Join(dir, "push.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// freeAddress function returns a loopback address nothing listens on.
+func freeAddress(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+// startPushAgent function runs an agent of the fake with a push server with the subscriber blocks, after registering paths,
+// and returns it with the address of the push server.
+func startPushAgent(t *testing.T, s *vaultsynctest.Server, ca *testCA, subscribers string, paths ...string) (*vaultsync.Agent, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	certFile, keyFile := writeKeyPair(t, dir, ca.issue(t, "push server", true))
+	caFile := filepath.Join(dir, "ca.crt")
+	if err := os.WriteFile(caFile, ca.pem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	address := freeAddress(t)
+	extra := fmt.Sprintf(`  push_server {
+    address        = %q
+    cert_file      = %q
+    key_file       = %q
+    client_ca_file = %q
+%s
+  }`, address, certFile, keyFile, caFile, subscribers)
+	filename, err := s.WriteConfig(t.TempDir(), 3600, extra)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent := newConfigAgent(t, filename)
+	for _, p := range paths {
+		agent.RegisterUpdateSecret(p, vaultsynctest.NewRecorder())
+	}
+	if err := agent.Run(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	return agent, address
+}
+
+// pushClient function connects to the push server of ca at address with a client certificate for commonName issued by issuer.
+func pushClient(t *testing.T, address string, ca *testCA, issuer *testCA, commonName string) proto.PushClient {
+	t.Helper()
+
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{issuer.issue(t, commonName, false)}, RootCAs: ca.pool}
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return proto.NewPushClient(conn)
+}
+
+// subscribeCode function subscribes to paths and returns the status code the subscription ends with before sending an update.
+func subscribeCode(t *testing.T, client proto.PushClient, paths ...string) codes.Code {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.Subscribe(ctx, &proto.SubscribeRequest{Paths: paths})
+	if err != nil {
+		return status.Code(err)
+	}
+	update, err := stream.Recv()
+	if err == nil {
+		t.Fatalf("got an update of %v", update.GetPath())
+	}
+	return status.Code(err)
+}
+
+// receivePaths function returns the paths of the next n updates of stream, sorted.
+func receivePaths(t *testing.T, stream proto.Push_SubscribeClient, n int) []string {
+	t.Helper()
+
+	var paths []string
+	for i := 0; i < n; i++ {
+		update, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, update.GetPath())
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+const billingSubscriber = `    subscriber "billing" {
+      paths = ["secret/data/billing/*", "secret/data/shared"]
+    }`
+
+func TestPushDeniesPathOutsidePatterns(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+	s.SetSecret("secret/data/billing/db", map[string]interface{}{"password": "b1lling"})
+	ca := newTestCA(t)
+	_, address := startPushAgent(t, s, ca, billingSubscriber, "secret/data/app", "secret/data/billing/db")
+	client := pushClient(t, address, ca, ca, "billing")
+
+	if code := subscribeCode(t, client, "secret/data/app"); code != codes.PermissionDenied {
+		t.Fatalf("got %v for a path outside the patterns, want PermissionDenied", code)
+	}
+	if code := subscribeCode(t, client, "secret/data/billing/db", "secret/data/app"); code != codes.PermissionDenied {
+		t.Fatalf("got %v when one path is outside the patterns, want PermissionDenied", code)
+	}
+	if code := subscribeCode(t, client, "secret/data/billing/other"); code != codes.NotFound {
+		t.Fatalf("got %v for a path that is not synced, want NotFound", code)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.Subscribe(ctx, &proto.SubscribeRequest{Paths: []string{"secret/data/billing/db"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	update, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update.GetPath() != "secret/data/billing/db" || update.GetFields()["password"].GetStringValue() != "b1lling" {
+		t.Fatalf("got %v %v", update.GetPath(), update.GetFields())
+	}
+}
+
+func TestPushRejectsUnknownSubscriber(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/billing/db", map[string]interface{}{"password": "b1lling"})
+	ca := newTestCA(t)
+	_, address := startPushAgent(t, s, ca, billingSubscriber, "secret/data/billing/db")
+
+	// A certificate of the CA whose name has no subscriber block.
+	client := pushClient(t, address, ca, ca, "stranger")
+	if code := subscribeCode(t, client); code != codes.PermissionDenied {
+		t.Fatalf("got %v for an unknown subscriber, want PermissionDenied", code)
+	}
+	if code := subscribeCode(t, client, "secret/data/billing/db"); code != codes.PermissionDenied {
+		t.Fatalf("got %v for an unknown subscriber requesting a path, want PermissionDenied", code)
+	}
+
+	// A certificate for the subscriber's name from another CA fails the handshake.
+	client = pushClient(t, address, ca, newTestCA(t), "billing")
+	if code := subscribeCode(t, client); code != codes.Unavailable {
+		t.Fatalf("got %v for a certificate of another CA, want Unavailable", code)
+	}
+}
+
+func TestPushExpandsPatternsWithoutRequestedPaths(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+	s.SetSecret("secret/data/billing/db", map[string]interface{}{"password": "b1lling"})
+	s.SetSecret("secret/data/billing/cache", map[string]interface{}{"password": "c4che"})
+	s.SetSecret("secret/data/shared", map[string]interface{}{"password": "sh4red"})
+	ca := newTestCA(t)
+	// secret/data/shared is synced because a subscriber names it, the patterns only match registered paths.
+	_, address := startPushAgent(t, s, ca, billingSubscriber, "secret/data/app", "secret/data/billing/db", "secret/data/billing/cache")
+	client := pushClient(t, address, ca, ca, "billing")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.Subscribe(ctx, &proto.SubscribeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := receivePaths(t, stream, 3)
+	if want := []string{"secret/data/billing/cache", "secret/data/billing/db", "secret/data/shared"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("got snapshot of %v, want %v", got, want)
+	}
+}
+
+func TestPushDisconnectsSlowSubscriber(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/billing/db", map[string]interface{}{"password": "b1lling"})
+	s.SetSecret("secret/data/shared", map[string]interface{}{"password": "sh4red"})
+	ca := newTestCA(t)
+	agent, address := startPushAgent(t, s, ca, billingSubscriber, "secret/data/billing/db")
+	client := pushClient(t, address, ca, ca, "billing")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	stream, err := client.Subscribe(ctx, &proto.SubscribeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	receivePaths(t, stream, 2)
+
+	// The subscriber reads nothing while updates larger than the flow control window of the stream
+	// block the server and then fill the queue of the subscription.
+	const rotations = 80
+	padding := strings.Repeat("x", 128*1024)
+	for i := 0; i < rotations; i++ {
+		s.SetSecret("secret/data/billing/db", map[string]interface{}{"password": fmt.Sprintf("%v-%d", padding, i)})
+		vaultsynctest.Sync(t, agent)
+	}
+
+	received := 0
+	for {
+		_, err := stream.Recv()
+		if err != nil {
+			if code := status.Code(err); code != codes.ResourceExhausted {
+				t.Fatalf("subscription ended with %v, want ResourceExhausted", err)
+			}
+			break
+		}
+		received++
+	}
+	if received >= rotations {
+		t.Fatalf("got all %d updates, the slow subscriber was not disconnected", received)
+	}
+}
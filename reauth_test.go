@@ -0,0 +1,49 @@
+package vaultsync_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+// countCalls function returns the number of calls of method to the mock, with path if it is not empty.
+func countCalls(m *vaultsynctest.MockClient, method string, path string) int {
+	n := 0
+	for _, call := range m.Calls() {
+		if call.Method == method && (path == "" || call.Path == path) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSyncAfterLogin(t *testing.T) {
+	m := vaultsynctest.NewMockClient()
+	m.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+	clock := vaultsynctest.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	agent := vaultsynctest.NewMockAgent(t, m, vaultsync.WithClock(clock))
+	recorder := vaultsynctest.NewRecorder()
+	agent.RegisterUpdateSecret("secret/data/app", recorder)
+	if err := agent.Run(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Stop()
+
+	// The token of the mock is not renewable, the agent logs in again after two thirds of its TTL of an hour,
+	// and syncs at once instead of at the end of the renew period of an hour.
+	m.SetSecret("secret/data/app", map[string]interface{}{"password": "n3w"})
+	for elapsed := time.Duration(0); ; elapsed += time.Minute {
+		if elapsed >= 59*time.Minute {
+			t.Fatalf("no sync after the login, %d logins", countCalls(m, "Login", ""))
+		}
+		clock.Advance(time.Minute)
+		time.Sleep(20 * time.Millisecond)
+		if countCalls(m, "Login", "") >= 2 && countCalls(m, "Read", "secret/data/app") >= 2 {
+			break
+		}
+	}
+	recorder.AssertValue(t, "secret/data/app", "password", "n3w")
+}
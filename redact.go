@@ -0,0 +1,402 @@
+package vaultsync
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// redacted replaces secret values in log records.
+const redacted = "[REDACTED]"
+
+// sensitiveLogKeys are attribute keys whose values are always redacted.
+var sensitiveLogKeys = map[string]bool{
+	"password":    true,
+	"secret":      true,
+	"secret_id":   true,
+	"token":       true,
+	"hmac_secret": true,
+	"value":       true,
+}
+
+// minRedactLength is the length from which a secret value is also redacted where it appears inside a longer string.
+// Shorter values are only redacted where they are the whole value, so a secret such as "1" does not mangle every log record.
+const minRedactLength = 4
+
+// secretValues struct holds the secret values the redacting handler looks for. Values are counted, a value synced at
+// several paths stays redacted until the last of them drops it, and the values of a path are added and removed without
+// walking the others, so a sync cycle over many paths stays linear. Values of minRedactLength or more are indexed by
+// their first bytes to find them inside longer strings without hashing or marshalling values when logging.
+type secretValues struct {
+	mu       sync.RWMutex
+	counts   map[string]int
+	prefixes map[string][]string
+}
+
+// newSecretValues function prepares the redaction of values.
+func newSecretValues(values []string) *secretValues {
+	sv := &secretValues{counts: make(map[string]int), prefixes: make(map[string][]string)}
+	sv.replace(nil, values)
+	return sv
+}
+
+// replace method removes the values of a path that were synced before and adds its current values.
+func (sv *secretValues) replace(old []string, current []string) {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+
+	for _, value := range current {
+		if value == "" {
+			continue
+		}
+		sv.counts[value]++
+		if sv.counts[value] == 1 && len(value) >= minRedactLength {
+			prefix := value[:minRedactLength]
+			// Longer values first, so a value containing another is replaced as a whole.
+			values := append(sv.prefixes[prefix], value)
+			sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+			sv.prefixes[prefix] = values
+		}
+	}
+	for _, value := range old {
+		if value == "" || sv.counts[value] == 0 {
+			continue
+		}
+		sv.counts[value]--
+		if sv.counts[value] > 0 {
+			continue
+		}
+		delete(sv.counts, value)
+		if len(value) >= minRedactLength {
+			prefix := value[:minRedactLength]
+			values := sv.prefixes[prefix]
+			for i := range values {
+				if values[i] == value {
+					values = append(values[:i:i], values[i+1:]...)
+					break
+				}
+			}
+			if len(values) == 0 {
+				delete(sv.prefixes, prefix)
+			} else {
+				sv.prefixes[prefix] = values
+			}
+		}
+	}
+}
+
+// reset method forgets all values.
+func (sv *secretValues) reset() {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	sv.counts = make(map[string]int)
+	sv.prefixes = make(map[string][]string)
+}
+
+// redact method replaces the secret values in s and reports whether it replaced any.
+func (sv *secretValues) redact(s string) (string, bool) {
+	if sv == nil || s == "" {
+		return s, false
+	}
+	sv.mu.RLock()
+	defer sv.mu.RUnlock()
+
+	if sv.counts[s] > 0 {
+		return redacted, true
+	}
+	if len(sv.prefixes) == 0 {
+		return s, false
+	}
+	var clean strings.Builder
+	last := 0
+	for i := 0; i+minRedactLength <= len(s); {
+		match := ""
+		for _, value := range sv.prefixes[s[i:i+minRedactLength]] {
+			if strings.HasPrefix(s[i:], value) {
+				match = value
+				break
+			}
+		}
+		if match == "" {
+			i++
+			continue
+		}
+		clean.WriteString(s[last:i])
+		clean.WriteString(redacted)
+		i += len(match)
+		last = i
+	}
+	if last == 0 {
+		return s, false
+	}
+	clean.WriteString(s[last:])
+	return clean.String(), true
+}
+
+// secretStrings function returns the string forms of the values of a secret, descending into nested maps and lists.
+func secretStrings(data map[string]interface{}) []string {
+	var values []string
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch v := value.(type) {
+		case nil, bool:
+		case string:
+			values = append(values, v)
+		case []byte:
+			values = append(values, string(v))
+		case map[string]interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		case []interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		default:
+			values = append(values, fmt.Sprint(v))
+		}
+	}
+	for _, value := range data {
+		walk(unsealValue(value))
+	}
+	return values
+}
+
+// redactingHandler struct is a slog.Handler that prevents secret values from reaching the wrapped handler.
+// Attributes with a sensitive key are redacted. Synced secret values are replaced wherever they appear in the message,
+// in string values, in errors and in the maps, lists and other values logged with slog.Any, also inside groups.
+type redactingHandler struct {
+	next   slog.Handler
+	values func() *secretValues
+}
+
+// newRedactingHandler function wraps a handler. values returns the secret values to redact.
+func newRedactingHandler(next slog.Handler, values func() *secretValues) *redactingHandler {
+	return &redactingHandler{
+		next:   next,
+		values: values,
+	}
+}
+
+// Enabled method reports whether the wrapped handler handles records at the given level.
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle method redacts the record message and attributes and passes the record to the wrapped handler.
+func (h *redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	values := h.values()
+	message, _ := values.redact(record.Message)
+	clean := slog.NewRecord(record.Time, record.Level, message, record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		clean.AddAttrs(redactAttr(attr, values))
+		return true
+	})
+	return h.next.Handle(ctx, clean)
+}
+
+// WithAttrs method returns a handler whose attributes are redacted before they are added.
+// The attributes are redacted with the secret values known when the handler is created.
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	values := h.values()
+	clean := make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		clean = append(clean, redactAttr(attr, values))
+	}
+	return newRedactingHandler(h.next.WithAttrs(clean), h.values)
+}
+
+// WithGroup method returns a handler that starts a group.
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return newRedactingHandler(h.next.WithGroup(name), h.values)
+}
+
+// levelHandler struct is a slog.Handler that drops the records below the level of the agent before the wrapped handler.
+type levelHandler struct {
+	next  slog.Handler
+	level slog.Leveler
+}
+
+// newLevelHandler function wraps a handler with a level.
+func newLevelHandler(next slog.Handler, level slog.Leveler) *levelHandler {
+	return &levelHandler{
+		next:  next,
+		level: level,
+	}
+}
+
+// Enabled method reports whether the level is enabled by the agent and by the wrapped handler.
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() && h.next.Enabled(ctx, level)
+}
+
+// Handle method passes the record to the wrapped handler.
+func (h *levelHandler) Handle(ctx context.Context, record slog.Record) error {
+	return h.next.Handle(ctx, record)
+}
+
+// WithAttrs method returns a handler with the attributes added to the wrapped handler.
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return newLevelHandler(h.next.WithAttrs(attrs), h.level)
+}
+
+// WithGroup method returns a handler that starts a group.
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return newLevelHandler(h.next.WithGroup(name), h.level)
+}
+
+// redactAttr function returns the attribute with secret values replaced, descending into groups.
+func redactAttr(attr slog.Attr, values *secretValues) slog.Attr {
+	attr.Value = attr.Value.Resolve()
+
+	if sensitiveLogKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, redacted)
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindGroup:
+		group := attr.Value.Group()
+		clean := make([]any, 0, len(group))
+		for _, a := range group {
+			clean = append(clean, redactAttr(a, values))
+		}
+		return slog.Group(attr.Key, clean...)
+
+	case slog.KindString:
+		if clean, ok := values.redact(attr.Value.String()); ok {
+			return slog.String(attr.Key, clean)
+		}
+
+	case slog.KindAny:
+		if clean, ok := redactAny(attr.Value.Any(), values); ok {
+			return slog.Any(attr.Key, clean)
+		}
+	}
+
+	return attr
+}
+
+// redactAny function returns a copy of a value logged with slog.Any with the secret values replaced, and reports whether
+// it contained any. Maps and lists are redacted element by element, errors and other values by their string form.
+func redactAny(value any, values *secretValues) (any, bool) {
+	if values == nil {
+		return value, false
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return v, false
+	case string:
+		return values.redact(v)
+	case []byte:
+		return values.redact(string(v))
+	case error:
+		return values.redact(v.Error())
+	case map[string]interface{}:
+		clean := make(map[string]interface{}, len(v))
+		found := false
+		for key, item := range v {
+			if sensitiveLogKeys[strings.ToLower(key)] {
+				clean[key], found = redacted, true
+				continue
+			}
+			var ok bool
+			clean[key], ok = redactAny(item, values)
+			found = found || ok
+		}
+		return clean, found
+	case map[string]string:
+		clean := make(map[string]string, len(v))
+		found := false
+		for key, item := range v {
+			var ok bool
+			if sensitiveLogKeys[strings.ToLower(key)] {
+				clean[key], ok = redacted, true
+			} else {
+				clean[key], ok = values.redact(item)
+			}
+			found = found || ok
+		}
+		return clean, found
+	case []interface{}:
+		clean := make([]interface{}, len(v))
+		found := false
+		for i, item := range v {
+			var ok bool
+			clean[i], ok = redactAny(item, values)
+			found = found || ok
+		}
+		return clean, found
+	case []string:
+		clean := make([]string, len(v))
+		found := false
+		for i, item := range v {
+			var ok bool
+			clean[i], ok = values.redact(item)
+			found = found || ok
+		}
+		return clean, found
+	}
+
+	return values.redact(fmt.Sprintf("%+v", value))
+}
+
+// fingerprintKey is the key of the fingerprints logged with WithValueFingerprints. It is random per process, so a logged
+// fingerprint cannot be brute-forced offline to recover a short password. Fingerprints can only be compared within one run.
+var fingerprintKey = newFingerprintKey()
+
+// newFingerprintKey function returns a random key for the logged fingerprints.
+func newFingerprintKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("vaultsync: no randomness for the fingerprint key: %v", err))
+	}
+	return key
+}
+
+// shortFingerprint function returns an abbreviated HMAC-SHA256 of a secret value, keyed per process, for troubleshooting.
+func shortFingerprint(value interface{}) string {
+	mac := hmac.New(sha256.New, fingerprintKey)
+	b, err := json.Marshal(value)
+	if err != nil {
+		b = []byte(fmt.Sprint(value))
+	}
+	mac.Write(b)
+	return hex.EncodeToString(mac.Sum(nil))[:12]
+}
+
+// WithValueFingerprints function logs a short keyed hash of every synced field value at debug level.
+// The hash lets operators see whether a value changed during a run without revealing it.
+func WithValueFingerprints() AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.valueFingerprints = true
+	}
+}
+
+// LogValue method implements slog.LogValuer so the configuration can be logged without credentials.
+// Webhooks are logged by their scheme and host, their user, path and query often carry tokens.
+func (c *config) LogValue() slog.Value {
+	webhooks := make([]string, 0, len(c.Vault.Webhooks))
+	for _, webhook := range c.Vault.Webhooks {
+		webhooks = append(webhooks, urlHost(webhook.URL))
+	}
+
+	return slog.GroupValue(
+		slog.String("server", c.Vault.Server),
+		slog.String("authmethod", c.Vault.AuthMethod),
+		slog.String("username", c.Vault.Username),
+		slog.String("password", redacted),
+		slog.String("renew_secrets_period", string(c.Vault.RenewSecretsPeriod)),
+		slog.String("stale_threshold", string(c.Vault.StaleThreshold)),
+		slog.String("audit_file", c.Vault.AuditFile),
+		slog.Any("webhooks", webhooks),
+	)
+}
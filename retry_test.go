@@ -0,0 +1,136 @@
+package vaultsync_test
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+// waitStatus function waits until cond holds for the status of the only path of agent, without moving the clock.
+func waitStatus(t *testing.T, agent *vaultsync.Agent, cond func(vaultsync.PathStatus) bool) vaultsync.PathStatus {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status := agent.Status()[0]
+		if cond(status) {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("status not reached, last status %+v", status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	m := vaultsynctest.NewMockClient()
+	var failing atomic.Bool
+	var reads atomic.Int32
+	failing.Store(true)
+	m.ReadFunc = func(ctx context.Context, path string) (*vault.Secret, error) {
+		reads.Add(1)
+		if failing.Load() {
+			return nil, &vault.ResponseError{StatusCode: http.StatusServiceUnavailable}
+		}
+		return &vault.Secret{Data: map[string]interface{}{"data": map[string]interface{}{"password": "s3cret"}}}, nil
+	}
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := vaultsynctest.NewFakeClock(start)
+	agent := vaultsynctest.NewMockAgent(t, m, vaultsync.WithClock(clock))
+	agent.RegisterUpdateSecret("secret/data/app", vaultsynctest.NewRecorder())
+	if err := agent.Run(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Stop()
+
+	// The failed initial sync is retried after 5 seconds, then after 10, long before the renew period of an hour.
+	status := waitStatus(t, agent, func(s vaultsync.PathStatus) bool { return !s.NextRetry.IsZero() })
+	if status.ErrorKind != vaultsync.ErrorKindUnavailable || !status.NextRetry.Equal(start.Add(5*time.Second)) {
+		t.Fatalf("got %v retry at %v, want unavailable retry at +5s", status.ErrorKind, status.NextRetry.Sub(start))
+	}
+	clock.Advance(5 * time.Second)
+	status = waitStatus(t, agent, func(s vaultsync.PathStatus) bool { return s.NextRetry.After(start.Add(5 * time.Second)) })
+	if !status.NextRetry.Equal(start.Add(15 * time.Second)) {
+		t.Fatalf("second retry at %v, want +15s", status.NextRetry.Sub(start))
+	}
+
+	// Once Vault answers again the retry syncs the path.
+	failing.Store(false)
+	clock.Advance(10 * time.Second)
+	status = waitStatus(t, agent, func(s vaultsync.PathStatus) bool { return !s.LastSync.IsZero() })
+	if !status.NextRetry.IsZero() || status.LastError != nil {
+		t.Fatalf("synced path still has retry %v and error %v", status.NextRetry, status.LastError)
+	}
+	if n := reads.Load(); n != 3 {
+		t.Fatalf("got %d reads, want the initial sync and two retries", n)
+	}
+}
+
+func TestPermissionDeniedIsNotRetried(t *testing.T) {
+	m := vaultsynctest.NewMockClient()
+	m.ReadFunc = func(ctx context.Context, path string) (*vault.Secret, error) {
+		return nil, &vault.ResponseError{StatusCode: http.StatusForbidden}
+	}
+	agent := vaultsynctest.NewMockAgent(t, m)
+	agent.RegisterUpdateSecret("secret/data/app", vaultsynctest.NewRecorder())
+
+	if err := agent.SyncOnce(context.Background()); err == nil {
+		t.Fatal("sync of a forbidden path succeeded")
+	}
+	status := agent.Status()[0]
+	if status.ErrorKind != vaultsync.ErrorKindPermissionDenied || !status.NextRetry.IsZero() {
+		t.Fatalf("got %v with retry at %v, want permission_denied without retry", status.ErrorKind, status.NextRetry)
+	}
+}
+
+func TestRetryKeptWhilePaused(t *testing.T) {
+	m := vaultsynctest.NewMockClient()
+	var failing atomic.Bool
+	var reads atomic.Int32
+	failing.Store(true)
+	m.ReadFunc = func(ctx context.Context, path string) (*vault.Secret, error) {
+		reads.Add(1)
+		if failing.Load() {
+			return nil, &vault.ResponseError{StatusCode: http.StatusServiceUnavailable}
+		}
+		return &vault.Secret{Data: map[string]interface{}{"data": map[string]interface{}{"password": "s3cret"}}}, nil
+	}
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := vaultsynctest.NewFakeClock(start)
+	agent := vaultsynctest.NewMockAgent(t, m, vaultsync.WithClock(clock))
+	agent.RegisterUpdateSecret("secret/data/app", vaultsynctest.NewRecorder())
+	if err := agent.Run(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Stop()
+	waitStatus(t, agent, func(s vaultsync.PathStatus) bool { return !s.NextRetry.IsZero() })
+
+	// The retry comes due while the syncs are paused, it is neither run nor dropped.
+	agent.Pause()
+	failing.Store(false)
+	clock.Advance(5 * time.Second)
+	time.Sleep(50 * time.Millisecond)
+	if n := reads.Load(); n != 1 {
+		t.Fatalf("got %d reads while paused, want 1", n)
+	}
+	if status := agent.Status()[0]; !status.NextRetry.Equal(start.Add(5 * time.Second)) {
+		t.Fatalf("retry at %v while paused, want it kept at +5s", status.NextRetry)
+	}
+
+	// Once resumed the path is retried at the next wake up of the sync loop.
+	agent.Resume()
+	clock.Advance(time.Hour)
+	status := waitStatus(t, agent, func(s vaultsync.PathStatus) bool { return !s.LastSync.IsZero() })
+	if !status.NextRetry.IsZero() || status.LastError != nil {
+		t.Fatalf("synced path still has retry %v and error %v", status.NextRetry, status.LastError)
+	}
+}
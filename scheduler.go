@@ -0,0 +1,181 @@
+package vaultsync
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// WithSchedule function sets a cron expression, such as "0 2 * * *" or "@hourly", at which all paths are synced instead of every renew period.
+// It takes precedence over sync_schedule in the configuration file. Expressions are in local time unless they start with CRON_TZ=.
+func WithSchedule(expr string) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.schedule = expr
+	}
+}
+
+// WithPathSchedule function sets a cron expression at which a path is synced, instead of the schedule of the other paths.
+// It takes precedence over path_schedules in the configuration file.
+func WithPathSchedule(path string, expr string) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		if opts.pathSchedules == nil {
+			opts.pathSchedules = make(map[string]string)
+		}
+		opts.pathSchedules[path] = expr
+	}
+}
+
+// maxWait is the wait of a scheduler whose schedules never fire.
+const maxWait = time.Duration(1<<63 - 1)
+
+// parseSchedule function parses a standard five field cron expression, a descriptor such as @daily, or @every with a duration.
+func parseSchedule(expr string) (cron.Schedule, error) {
+	schedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q:%w", expr, err)
+	}
+	return schedule, nil
+}
+
+// scheduler struct decides when paths are synced. Paths with a schedule of their own are synced when it fires,
+// the other paths when the global schedule fires or, without one, every renew period.
+// It is used by the renew goroutine only.
+type scheduler struct {
+	period   time.Duration
+	schedule cron.Schedule
+	paths    map[string]cron.Schedule
+
+	// next holds the time each schedule fires next, the global schedule under "".
+	next map[string]time.Time
+}
+
+// newScheduler function creates a scheduler from the renew period, the global cron expression and the cron expressions of paths.
+// Empty expressions are not scheduled.
+func newScheduler(period time.Duration, expr string, pathExprs map[string]string) (*scheduler, error) {
+	s := &scheduler{period: period, paths: make(map[string]cron.Schedule)}
+	if expr != "" {
+		schedule, err := parseSchedule(expr)
+		if err != nil {
+			return nil, err
+		}
+		s.schedule = schedule
+	}
+	for path, expr := range pathExprs {
+		schedule, err := parseSchedule(expr)
+		if err != nil {
+			return nil, fmt.Errorf("path %v: %w", path, err)
+		}
+		s.paths[path] = schedule
+	}
+	return s, nil
+}
+
+// start method schedules the next run of every schedule from now.
+func (s *scheduler) start(now time.Time) {
+	s.next = map[string]time.Time{"": s.nextRun("", now)}
+	for path := range s.paths {
+		s.next[path] = s.nextRun(path, now)
+	}
+}
+
+// restart method reschedules the global schedule after all paths were synced outside of it.
+// With a renew period the period starts over, a cron schedule keeps its time.
+func (s *scheduler) restart(now time.Time) {
+	if s.schedule == nil {
+		s.next[""] = s.nextRun("", now)
+	}
+}
+
+// nextRun method returns the time the schedule of key fires next after now, "" is the global schedule.
+func (s *scheduler) nextRun(key string, now time.Time) time.Time {
+	if schedule, ok := s.paths[key]; ok {
+		return schedule.Next(now)
+	}
+	if s.schedule != nil {
+		return s.schedule.Next(now)
+	}
+	return now.Add(s.period)
+}
+
+// wait method returns the time until the next schedule fires. Schedules that never fire, such as 0 0 30 2 *, are ignored.
+func (s *scheduler) wait(now time.Time) time.Duration {
+	var first time.Time
+	for _, next := range s.next {
+		if !next.IsZero() && (first.IsZero() || next.Before(first)) {
+			first = next
+		}
+	}
+	if first.IsZero() {
+		return maxWait
+	}
+	return first.Sub(now)
+}
+
+// due method returns the paths of tracked whose schedule has fired and schedules their next run.
+func (s *scheduler) due(now time.Time, tracked []string) []string {
+	fired := make(map[string]bool)
+	for key, next := range s.next {
+		if !next.IsZero() && !next.After(now) {
+			fired[key] = true
+			s.next[key] = s.nextRun(key, now)
+		}
+	}
+
+	var paths []string
+	for _, path := range tracked {
+		if _, ok := s.paths[path]; ok {
+			if fired[path] {
+				paths = append(paths, path)
+			}
+		} else if fired[""] {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// interval method returns the time between two syncs of path, used to tell when the path is stale.
+func (s *scheduler) interval(path string, now time.Time) time.Duration {
+	schedule, ok := s.paths[path]
+	if !ok {
+		schedule = s.schedule
+	}
+	if schedule == nil {
+		return s.period
+	}
+	next := schedule.Next(now)
+	after := schedule.Next(next)
+	if next.IsZero() || after.IsZero() {
+		return maxWait
+	}
+	return after.Sub(next)
+}
+
+// newScheduler method creates the scheduler of the agent from the options and the configuration file, options taking precedence.
+func (a *Agent) newScheduler() error {
+	expr := a.schedule
+	if expr == "" {
+		expr = a.config.Vault.SyncSchedule
+	}
+
+	pathExprs := make(map[string]string)
+	for path, expr := range a.config.Vault.PathSchedules {
+		path, err := a.ExpandPath(path)
+		if err != nil {
+			return err
+		}
+		pathExprs[path] = expr
+	}
+	for path, expr := range a.pathSchedules {
+		path, err := a.ExpandPath(path)
+		if err != nil {
+			return err
+		}
+		pathExprs[path] = expr
+	}
+
+	var err error
+	a.scheduler, err = newScheduler(a.config.Vault.RenewSecretsPeriod.value(), expr, pathExprs)
+	return err
+}
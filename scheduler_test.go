@@ -0,0 +1,128 @@
+package vaultsync_test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+// readLog struct records the paths a mock client read.
+type readLog struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+// read method is the ReadFunc of the mock client.
+func (l *readLog) read(ctx context.Context, path string) (*vault.Secret, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.paths = append(l.paths, path)
+	return &vault.Secret{Data: map[string]interface{}{"data": map[string]interface{}{"password": "s3cret"}}}, nil
+}
+
+// take method returns the paths read since the last call, sorted, once n paths were read.
+func (l *readLog) take(t *testing.T, n int) []string {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		l.mu.Lock()
+		got := len(l.paths)
+		l.mu.Unlock()
+		if got >= n {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d reads, want %d", got, n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// Reads that should not happen have a moment to show up.
+	time.Sleep(50 * time.Millisecond)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	paths := l.paths
+	l.paths = nil
+	sort.Strings(paths)
+	return paths
+}
+
+func TestCronSchedules(t *testing.T) {
+	// The mock agent only takes the schedules from a configuration file, the fake is never contacted.
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	configFile := func(schedule string, tlsSchedule string) vaultsync.AgentOptFunc {
+		filename, err := s.WriteConfig(t.TempDir(), 3600, fmt.Sprintf(`  sync_schedule  = %q
+  path_schedules = { "secret/data/tls" = %q }`, schedule, tlsSchedule))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return vaultsync.WithConfigFile(filename)
+	}
+
+	for _, tc := range []struct {
+		name string
+		opts []vaultsync.AgentOptFunc
+	}{
+		{"options", []vaultsync.AgentOptFunc{
+			vaultsync.WithSchedule("CRON_TZ=UTC 0 2 * * *"),
+			vaultsync.WithPathSchedule("secret/data/tls", "CRON_TZ=UTC 30 * * * *"),
+		}},
+		{"configuration file", []vaultsync.AgentOptFunc{
+			configFile("CRON_TZ=UTC 0 2 * * *", "CRON_TZ=UTC 30 * * * *"),
+		}},
+		{"options take precedence", []vaultsync.AgentOptFunc{
+			configFile("CRON_TZ=UTC 0 1 * * *", "CRON_TZ=UTC 0 * * * *"),
+			vaultsync.WithSchedule("CRON_TZ=UTC 0 2 * * *"),
+			vaultsync.WithPathSchedule("secret/data/tls", "CRON_TZ=UTC 30 * * * *"),
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reads := &readLog{}
+			m := vaultsynctest.NewMockClient()
+			m.ReadFunc = reads.read
+			// The token outlives the test, a login would sync all paths.
+			m.LoginFunc = func(ctx context.Context, authMethod vault.AuthMethod) (*vault.Secret, error) {
+				return &vault.Secret{Auth: &vault.SecretAuth{ClientToken: "hvs.vaultsynctest-mock", LeaseDuration: int((24 * time.Hour).Seconds())}}, nil
+			}
+			start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+			clock := vaultsynctest.NewFakeClock(start)
+			agent := vaultsynctest.NewMockAgent(t, m, append(tc.opts, vaultsync.WithClock(clock))...)
+			for _, path := range []string{"secret/data/app", "secret/data/db", "secret/data/tls"} {
+				agent.RegisterUpdateSecret(path, vaultsynctest.NewRecorder())
+			}
+			if err := agent.Run(context.Background(), nil); err != nil {
+				t.Fatal(err)
+			}
+			defer agent.Stop()
+			reads.take(t, 3)
+
+			// secret/data/tls is synced at half past every hour, the other paths at two o'clock.
+			for _, step := range []struct {
+				at   time.Duration
+				want []string
+			}{
+				{29 * time.Minute, nil},
+				{30 * time.Minute, []string{"secret/data/tls"}},
+				{90 * time.Minute, []string{"secret/data/tls"}},
+				{119 * time.Minute, nil},
+				{120 * time.Minute, []string{"secret/data/app", "secret/data/db"}},
+				{150 * time.Minute, []string{"secret/data/tls"}},
+			} {
+				clock.Advance(start.Add(step.at).Sub(clock.Now()))
+				if got := reads.take(t, len(step.want)); strings.Join(got, ",") != strings.Join(step.want, ",") {
+					t.Fatalf("at %v read %v, want %v", clock.Now().Format("15:04"), got, step.want)
+				}
+			}
+		})
+	}
+}
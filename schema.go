@@ -0,0 +1,103 @@
+package vaultsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FieldType type is the expected type of a field in a Schema.
+type FieldType int
+
+// Field types of a Schema. Values are checked as they are decoded from Vault, after the transforms of the path.
+const (
+	AnyField    FieldType = iota // Any value.
+	StringField                  // A string.
+	NumberField                  // A number.
+	BoolField                    // true or false.
+	ObjectField                  // A JSON object, e.g. a field decoded with DecodeJSONField.
+	ArrayField                   // A JSON array.
+	BytesField                   // Binary data, e.g. a field decoded with DecodeBase64.
+)
+
+// String method returns the name of the field type.
+func (t FieldType) String() string {
+	switch t {
+	case StringField:
+		return "string"
+	case NumberField:
+		return "number"
+	case BoolField:
+		return "bool"
+	case ObjectField:
+		return "object"
+	case ArrayField:
+		return "array"
+	case BytesField:
+		return "bytes"
+	}
+	return "any"
+}
+
+// Schema struct declares the fields a secret path is expected to have.
+type Schema struct {
+	Fields     map[string]FieldType // Required fields and their types.
+	AllowExtra bool                 // Allow fields that are not declared in Fields.
+}
+
+// RegisterSchema method declares the fields a secret path must have. Missing, mistyped and, unless AllowExtra is set,
+// undeclared fields reject the data like a failed validator, so receivers keep the values of the last successful sync.
+//
+//	vs.RegisterSchema("secrets/data/app/db", vaultsync.Schema{
+//		Fields: map[string]vaultsync.FieldType{"username": vaultsync.StringField, "password": vaultsync.StringField},
+//	})
+func (a *Agent) RegisterSchema(path string, schema Schema) {
+	a.RegisterValidator(path, schema.Validator())
+}
+
+// Validator method returns a validator that checks data against the schema. The error lists every mismatch.
+func (s Schema) Validator() Validator {
+	return func(data map[string]interface{}) error {
+		var problems []string
+		for field, fieldType := range s.Fields {
+			value, ok := data[field]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("missing field %v", field))
+			} else if !fieldType.matches(value) {
+				problems = append(problems, fmt.Sprintf("field %v is not a %v", field, fieldType))
+			}
+		}
+		if !s.AllowExtra {
+			for field := range data {
+				if _, ok := s.Fields[field]; !ok {
+					problems = append(problems, fmt.Sprintf("unexpected field %v", field))
+				}
+			}
+		}
+		if len(problems) > 0 {
+			sort.Strings(problems)
+			return fmt.Errorf("schema: %s", strings.Join(problems, ", "))
+		}
+		return nil
+	}
+}
+
+// matches method reports whether a decoded value has the field type.
+func (t FieldType) matches(value interface{}) bool {
+	switch value.(type) {
+	case string:
+		return t == AnyField || t == StringField
+	case json.Number, float64, int, int64:
+		return t == AnyField || t == NumberField
+	case bool:
+		return t == AnyField || t == BoolField
+	case map[string]interface{}:
+		return t == AnyField || t == ObjectField
+	case []interface{}:
+		return t == AnyField || t == ArrayField
+	case []byte:
+		return t == AnyField || t == BytesField
+	}
+	return t == AnyField
+}
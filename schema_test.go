@@ -0,0 +1,92 @@
+package vaultsync_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+func TestSchemaValidator(t *testing.T) {
+	schema := vaultsync.Schema{Fields: map[string]vaultsync.FieldType{"username": vaultsync.StringField, "port": vaultsync.NumberField}}
+	for _, tc := range []struct {
+		name   string
+		schema vaultsync.Schema
+		data   map[string]interface{}
+		want   string // Error message, empty if the data is valid.
+	}{
+		{"valid", schema, map[string]interface{}{"username": "app", "port": json.Number("5432")}, ""},
+		{"missing field", schema, map[string]interface{}{"username": "app"}, "schema: missing field port"},
+		{"extra field", schema, map[string]interface{}{"username": "app", "port": 5432.0, "debug": true}, "schema: unexpected field debug"},
+		{"extra field allowed", vaultsync.Schema{Fields: schema.Fields, AllowExtra: true}, map[string]interface{}{"username": "app", "port": 5432.0, "debug": true}, ""},
+		{"mistyped field", schema, map[string]interface{}{"username": 42.0, "port": "5432"}, "schema: field port is not a number, field username is not a string"},
+		{"all problems listed", schema, map[string]interface{}{"port": true, "debug": true}, "schema: field port is not a number, missing field username, unexpected field debug"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.schema.Validator()(tc.data)
+			if tc.want == "" {
+				if err != nil {
+					t.Fatalf("got %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.want {
+				t.Fatalf("got %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestSchemaFieldTypes(t *testing.T) {
+	types := []vaultsync.FieldType{vaultsync.StringField, vaultsync.NumberField, vaultsync.BoolField, vaultsync.ObjectField, vaultsync.ArrayField, vaultsync.BytesField}
+	for _, tc := range []struct {
+		value interface{}
+		want  vaultsync.FieldType
+	}{
+		{"s3cret", vaultsync.StringField},
+		// Vault responses decode numbers as json.Number, JSON decoded by a transform as float64.
+		{json.Number("5432"), vaultsync.NumberField},
+		{json.Number("0.5"), vaultsync.NumberField},
+		{5432.0, vaultsync.NumberField},
+		{5432, vaultsync.NumberField},
+		{int64(5432), vaultsync.NumberField},
+		{true, vaultsync.BoolField},
+		{map[string]interface{}{"host": "db"}, vaultsync.ObjectField},
+		{[]interface{}{"a", "b"}, vaultsync.ArrayField},
+		{[]byte{0xfe, 0xed}, vaultsync.BytesField},
+	} {
+		for _, fieldType := range types {
+			err := vaultsync.Schema{Fields: map[string]vaultsync.FieldType{"field": fieldType}}.Validator()(map[string]interface{}{"field": tc.value})
+			if matches := err == nil; matches != (fieldType == tc.want) {
+				t.Errorf("%#v as %v: got %v", tc.value, fieldType, err)
+			}
+		}
+		if err := (vaultsync.Schema{Fields: map[string]vaultsync.FieldType{"field": vaultsync.AnyField}}).Validator()(map[string]interface{}{"field": tc.value}); err != nil {
+			t.Errorf("%#v as any: got %v", tc.value, err)
+		}
+	}
+}
+
+func TestRegisterSchemaRejectsMismatch(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/db", map[string]interface{}{"username": "app", "port": 5432})
+	agent := vaultsynctest.NewAgent(t, s)
+	recorder := vaultsynctest.NewRecorder()
+	agent.RegisterUpdateSecret("secret/data/db", recorder)
+	agent.RegisterSchema("secret/data/db", vaultsync.Schema{Fields: map[string]vaultsync.FieldType{"username": vaultsync.StringField, "port": vaultsync.NumberField}})
+
+	// The port is decoded from the response of Vault as a json.Number.
+	vaultsynctest.Sync(t, agent)
+	recorder.AssertValue(t, "secret/data/db", "username", "app")
+
+	s.SetSecret("secret/data/db", map[string]interface{}{"username": "other", "port": "5432"})
+	err := agent.SyncOnce(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "field port is not a number") {
+		t.Fatalf("got %v", err)
+	}
+	recorder.AssertValue(t, "secret/data/db", "username", "app")
+}
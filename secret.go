@@ -0,0 +1,55 @@
+package vaultsync
+
+// Secret method returns a copy of the fields of a synced secret path.
+// It returns false if the path is not registered or has not been synced yet.
+func (a *Agent) Secret(path string) (map[string]interface{}, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	state, ok := a.paths[path]
+	if !ok || state.data == nil {
+		return nil, false
+	}
+
+	data := make(map[string]interface{}, len(state.data))
+	for key, value := range state.data {
+		data[key] = unsealValue(value)
+	}
+	return data, true
+}
+
+// SecretField method returns a single field of a synced secret path.
+// It returns false if the path has not been synced or the secret has no such field.
+func (a *Agent) SecretField(path string, field string) (interface{}, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	state, ok := a.paths[path]
+	if !ok || state.data == nil {
+		return nil, false
+	}
+	value, ok := state.data[field]
+	return unsealValue(value), ok
+}
+
+// SecretFields method returns the named fields of a synced secret path in the order they are given.
+// The fields are read together, so they belong to the same version of the secret even while it rotates.
+// It returns false if the path has not been synced or the secret lacks one of the fields.
+func (a *Agent) SecretFields(path string, fields ...string) ([]interface{}, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	state, ok := a.paths[path]
+	if !ok || state.data == nil {
+		return nil, false
+	}
+	values := make([]interface{}, len(fields))
+	for i, field := range fields {
+		value, ok := state.data[field]
+		if !ok {
+			return nil, false
+		}
+		values[i] = unsealValue(value)
+	}
+	return values, true
+}
@@ -0,0 +1,129 @@
+package vaultsync
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// shutdownTimeout is how long the cleanup after the agent stopped may take.
+const shutdownTimeout = 10 * time.Second
+
+// WithSignalHandling function makes Run install SIGINT and SIGTERM handlers that shut the agent down gracefully.
+// Embedders then only need to call Run and Wait:
+//
+//	vs.Run(context.Background(), nil)
+//	vs.Wait()
+func WithSignalHandling() AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.signalHandling = true
+	}
+}
+
+// handleSignals method stops the agent on SIGINT or SIGTERM if signal handling is enabled.
+// It returns a function that removes the signal handlers.
+func (a *Agent) handleSignals(ctx context.Context) func() {
+	if !a.signalHandling {
+		return func() {}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-signals:
+			a.log.Info("handleSignals", slog.String("signal", sig.String()), slog.String("status", "shutting down"))
+			a.cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+	}
+}
+
+// WithRevokeTokenOnStop function makes the agent revoke its Vault token when it stops,
+// instead of leaving the token valid until its TTL expires. It can also be enabled with revoke_token_on_stop in the configuration file.
+func WithRevokeTokenOnStop() AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.revokeTokenOnStop = true
+	}
+}
+
+// shutdown method cleans up after the background goroutines of Run have stopped.
+func (a *Agent) shutdown() {
+	// The notifiers get the rotations of the last cycles before the token is revoked and the secrets are dropped.
+	a.waitNotifications()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// The leases are no longer renewed once the agent stops.
+	a.leases.stopAll()
+
+	// Leases are revoked first, revoking them needs a valid token.
+	if a.revokeLeasesOnStop {
+		a.revokeLeases(ctx)
+	}
+
+	if a.revokeTokenOnStop {
+		a.revokeIdentities(ctx)
+	}
+	if a.revokeTokenOnStop && (a.config.Vault.AuthMethod == "token_file" || a.config.Vault.AuthMethod == "agent_proxy") {
+		a.logs.auth.Warn("shutdown", slog.String("status", "token not revoked, it belongs to the Vault Agent"))
+	} else if a.revokeTokenOnStop && a.config.Vault.AuthMethod == "token" {
+		a.logs.auth.Warn("shutdown", slog.String("status", "token not revoked, it belongs to the user"))
+	} else if a.revokeTokenOnStop {
+		if err := a.api.RevokeSelf(ctx); err != nil {
+			a.logs.auth.Error("shutdown", slog.String("status", "failed to revoke token"), slog.Any("error", err))
+		} else {
+			a.client.ClearToken()
+			a.logs.auth.Info("shutdown", slog.String("status", "token revoked"))
+		}
+	}
+
+	a.dropSecrets()
+	a.dropFieldFiles()
+	a.closePlugins()
+}
+
+// dropSecrets method releases the values of all synced paths, overwriting values kept in locked memory,
+// so old credentials do not linger in memory after the agent stopped. Secret and FS return no values until the next sync.
+func (a *Agent) dropSecrets() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, state := range a.paths {
+		destroyData(state.data)
+		state.data = nil
+		state.secretStrings = nil
+		for i := range state.history {
+			destroyData(state.history[i].Data)
+			state.history[i].Data = nil
+		}
+	}
+	a.secretValues.reset()
+}
+
+// Stop method stops the agent started by Run and waits for its goroutines to finish.
+func (a *Agent) Stop() {
+	if a.cancel == nil {
+		return
+	}
+	a.cancel()
+	a.Wait()
+}
+
+// Wait method blocks until the agent started by Run has stopped, either because its context was cancelled,
+// Stop was called, or a signal was received with signal handling enabled. It returns at once if Run was not called.
+func (a *Agent) Wait() {
+	if a.done == nil {
+		return
+	}
+	<-a.done
+}
@@ -0,0 +1,83 @@
+package vaultsync
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// signalConfig struct defines a signal sent to a process when the secret at path rotates.
+// The process is given by pid or by a pidfile that is read each time the signal is sent.
+type signalConfig struct {
+	Path    string `hcl:"path"`
+	Signal  string `hcl:"signal"`
+	PID     int    `hcl:"pid,optional"`
+	PIDFile string `hcl:"pidfile,optional"`
+}
+
+// validate method checks that the signal is supported and that a process is given.
+func (sc signalConfig) validate() error {
+	if _, err := parseSignal(sc.Signal); err != nil {
+		return err
+	}
+	if sc.PID <= 0 && sc.PIDFile == "" {
+		return fmt.Errorf("signal for %v has neither pid nor pidfile", sc.Path)
+	}
+	return nil
+}
+
+// send method sends the signal to the configured process.
+func (sc signalConfig) send() error {
+	sig, err := parseSignal(sc.Signal)
+	if err != nil {
+		return err
+	}
+
+	pid, err := sc.pid()
+	if err != nil {
+		return err
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Signal(sig)
+}
+
+// pid method returns the pid of the configured process, read from the pidfile if one is given.
+// A pid of 0 or below is rejected, it would signal a whole process group or every process the agent may signal.
+func (sc signalConfig) pid() (int, error) {
+	pid := sc.PID
+	if sc.PIDFile != "" {
+		b, err := os.ReadFile(sc.PIDFile)
+		if err != nil {
+			return 0, err
+		}
+		pid, err = strconv.Atoi(strings.TrimSpace(string(b)))
+		if err != nil {
+			return 0, fmt.Errorf("invalid pidfile %v: %w", sc.PIDFile, err)
+		}
+	}
+	if pid <= 0 {
+		return 0, fmt.Errorf("invalid pid %v for signal %v", pid, sc.Signal)
+	}
+	return pid, nil
+}
+
+// runSignalHooks method sends the signals configured for the rotated path.
+func (a *Agent) runSignalHooks(log *slog.Logger, event RotationEvent) {
+	for _, sc := range a.config.Vault.Signals {
+		if sc.Path != event.Path {
+			continue
+		}
+
+		if err := sc.send(); err != nil {
+			log.Error("runSignalHooks", slog.String("secret-path", event.Path), slog.String("signal", sc.Signal), slog.Any("error", err))
+			continue
+		}
+		log.Info("runSignalHooks", slog.String("secret-path", event.Path), slog.String("signal", sc.Signal))
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package vaultsync
+
+import (
+	"fmt"
+	"os"
+)
+
+// parseSignal function returns an error since signaling processes is only supported on unix.
+func parseSignal(name string) (os.Signal, error) {
+	return nil, fmt.Errorf("signal %v is not supported on this platform", name)
+}
@@ -0,0 +1,20 @@
+package vaultsync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSignalRejectsInvalidPID(t *testing.T) {
+	pidfile := filepath.Join(t.TempDir(), "app.pid")
+	for _, pid := range []string{"0", "-1"} {
+		if err := os.WriteFile(pidfile, []byte(pid+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		sc := signalConfig{Path: "secret/data/app", Signal: "SIGHUP", PIDFile: pidfile}
+		if _, err := sc.pid(); err == nil {
+			t.Fatalf("pid %v accepted", pid)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+//go:build unix
+
+package vaultsync
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// signals maps the supported signal names to signals.
+var signals = map[string]syscall.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+}
+
+// parseSignal function returns the signal with the given name, with or without the SIG prefix.
+func parseSignal(name string) (os.Signal, error) {
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	sig, ok := signals[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported signal %v", name)
+	}
+	return sig, nil
+}
@@ -0,0 +1,215 @@
+package vaultsync
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// SecretSink interface defines a receiver that writes synced secrets somewhere outside the process.
+// Fields are collected through UpdateSecret and written by Flush once a sync cycle completes,
+// so the sink sees all fields of a cycle at once.
+type SecretSink interface {
+	SecretReceiver
+	Flush() error
+}
+
+// RegisterSink method registers a sink as receiver of the given paths.
+func (a *Agent) RegisterSink(sink SecretSink, paths ...string) {
+	for _, path := range paths {
+		a.RegisterUpdateSecret(path, sink)
+	}
+	a.sinks = append(a.sinks, sink)
+}
+
+// flushSinks method flushes all sinks, logging failures.
+func (a *Agent) flushSinks(log *slog.Logger) {
+	for _, s := range a.sinks {
+		if err := s.Flush(); err != nil {
+			log.Error("flushSinks", slog.String("sink", fmt.Sprintf("%T", s)), slog.Any("error", err))
+		}
+	}
+}
+
+// secretFields struct collects the fields of secret paths and tracks whether they changed since the last flush.
+// Fields of a path that are not received again in a cycle where the path was synced are considered removed.
+type secretFields struct {
+	data    map[string]map[string]interface{}
+	dirty   bool
+	changed map[string]bool
+	seen    map[string]map[string]bool
+}
+
+// newSecretFields function creates an empty field collection.
+func newSecretFields() secretFields {
+	return secretFields{
+		data:    make(map[string]map[string]interface{}),
+		changed: make(map[string]bool),
+		seen:    make(map[string]map[string]bool),
+	}
+}
+
+// set method stores a field value and marks the collection dirty if the value changed.
+func (f *secretFields) set(path string, field string, value interface{}) {
+	fields, ok := f.data[path]
+	if !ok {
+		fields = make(map[string]interface{})
+		f.data[path] = fields
+	}
+	if f.seen[path] == nil {
+		f.seen[path] = make(map[string]bool)
+	}
+	f.seen[path][field] = true
+
+	if old, ok := fields[field]; !ok || !reflect.DeepEqual(old, value) {
+		fields[field] = value
+		f.dirty = true
+		f.changed[field] = true
+	}
+}
+
+// prune method removes the fields of paths synced in this cycle that were not received again.
+// It marks the collection dirty if fields were removed and returns their names.
+// prune must be called once per cycle, before clean.
+func (f *secretFields) prune() []string {
+	var removed []string
+	for path, seen := range f.seen {
+		for field := range f.data[path] {
+			if !seen[field] {
+				delete(f.data[path], field)
+				removed = append(removed, field)
+				f.changed[field] = true
+				f.dirty = true
+			}
+		}
+	}
+	sort.Strings(removed)
+
+	f.seen = make(map[string]map[string]bool)
+	return removed
+}
+
+// clean method marks the collection as flushed and returns the sorted names of the fields changed since the last flush.
+func (f *secretFields) clean() []string {
+	changed := make([]string, 0, len(f.changed))
+	for field := range f.changed {
+		changed = append(changed, field)
+	}
+	sort.Strings(changed)
+
+	f.dirty = false
+	f.changed = make(map[string]bool)
+	return changed
+}
+
+// markChanged method marks fields as changed again after they could not be flushed, so the next flush retries them.
+func (f *secretFields) markChanged(fields ...string) {
+	for _, field := range fields {
+		f.changed[field] = true
+		f.dirty = true
+	}
+}
+
+// has method reports whether fields have been received for all given paths.
+func (f *secretFields) has(paths ...string) bool {
+	for _, path := range paths {
+		if _, ok := f.data[path]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldText function returns the text of a field value written by a sink, the raw bytes of a []byte decoded
+// by DecodeBase64 and the formatted value of other types.
+func fieldText(value interface{}) string {
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(value)
+}
+
+// fileOwner struct defines the permissions and ownership of a file written by a sink.
+type fileOwner struct {
+	mode os.FileMode
+	uid  int
+	gid  int
+}
+
+// parseFileOwner function parses an octal mode and an optional user and group, given by name or id.
+// An empty mode defaults to defaultMode and an empty user or group leaves the ownership unchanged.
+func parseFileOwner(mode string, owner string, group string, defaultMode os.FileMode) (fileOwner, error) {
+	fo := fileOwner{mode: defaultMode, uid: -1, gid: -1}
+
+	if mode != "" {
+		m, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil {
+			return fo, fmt.Errorf("invalid file mode %q: %w", mode, err)
+		}
+		fo.mode = os.FileMode(m)
+	}
+
+	if owner != "" {
+		id := owner
+		if _, err := strconv.Atoi(owner); err != nil {
+			u, err := user.Lookup(owner)
+			if err != nil {
+				return fo, err
+			}
+			id = u.Uid
+		}
+		fo.uid, _ = strconv.Atoi(id)
+	}
+
+	if group != "" {
+		id := group
+		if _, err := strconv.Atoi(group); err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return fo, err
+			}
+			id = g.Gid
+		}
+		fo.gid, _ = strconv.Atoi(id)
+	}
+
+	return fo, nil
+}
+
+// writeFileAtomic function writes data to a temporary file in the destination directory and renames it into place,
+// so readers never observe a partially written file.
+func writeFileAtomic(filename string, data []byte, fo fileOwner) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), fo.mode); err != nil {
+		return err
+	}
+	if fo.uid != -1 || fo.gid != -1 {
+		if err := os.Chown(tmp.Name(), fo.uid, fo.gid); err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
@@ -0,0 +1,230 @@
+// Package sqlconnector provides a database/sql connector that opens connections with credentials synced by a vaultsync agent.
+//
+// Every new connection uses the current username and password. When the credentials rotate,
+// connections opened with the old credentials are discarded by the pool instead of being reused.
+// The connector is a sink of the agent, it takes both fields at once when a sync cycle completes,
+// so a connection is never opened with a new username and an old password.
+package sqlconnector
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pergus/vaultsync"
+)
+
+// Config struct defines where the credentials are read from and how the data source name is built.
+type Config struct {
+	// Path is the secret path holding the credentials.
+	Path string
+	// UsernameField and PasswordField name the fields of the secret, they default to username and password.
+	UsernameField string
+	PasswordField string
+	// DSN builds the data source name passed to the driver from the current credentials.
+	DSN func(username string, password string) string
+}
+
+// Connector struct implements driver.Connector using credentials from a synced secret.
+type Connector struct {
+	agent  *vaultsync.Agent
+	driver driver.Driver
+	config Config
+
+	// generation is only changed with mu held, together with the credentials.
+	generation atomic.Int64
+
+	mu       sync.Mutex
+	values   map[string]interface{}
+	username string
+	password string
+	synced   bool
+}
+
+// New function creates a connector and registers it as a sink of the agent to learn about credential rotations.
+// Use it with sql.OpenDB.
+func New(agent *vaultsync.Agent, drv driver.Driver, config Config) *Connector {
+	if config.UsernameField == "" {
+		config.UsernameField = "username"
+	}
+	if config.PasswordField == "" {
+		config.PasswordField = "password"
+	}
+
+	c := &Connector{
+		agent:  agent,
+		driver: drv,
+		config: config,
+		values: make(map[string]interface{}),
+	}
+	agent.RegisterSink(c, config.Path)
+	return c
+}
+
+// credentials method returns the current username and password and their generation, read together.
+// Until the connector has received a sync cycle, the credentials are taken from the secret already synced by the agent.
+func (c *Connector) credentials() (string, string, int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.synced {
+		values, ok := c.agent.SecretFields(c.config.Path, c.config.UsernameField, c.config.PasswordField)
+		if !ok {
+			return "", "", 0, fmt.Errorf("credentials %v are not synced", c.config.Path)
+		}
+		c.username, c.password, c.synced = fmt.Sprint(values[0]), fmt.Sprint(values[1]), true
+	}
+	return c.username, c.password, c.generation.Load(), nil
+}
+
+// Connect method opens a connection with the current credentials. It implements driver.Connector.
+func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
+	username, password, generation, err := c.credentials()
+	if err != nil {
+		return nil, err
+	}
+	dsn := c.config.DSN(username, password)
+
+	var conn driver.Conn
+	if dc, ok := c.driver.(driver.DriverContext); ok {
+		var connector driver.Connector
+		connector, err = dc.OpenConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+		conn, err = connector.Connect(ctx)
+	} else {
+		conn, err = c.driver.Open(dsn)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &rotatingConn{Conn: conn, connector: c, generation: generation}, nil
+}
+
+// Driver method returns the wrapped driver. It implements driver.Connector.
+func (c *Connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// UpdateSecret method collects the username and password of a sync cycle. It implements vaultsync.SecretReceiver.
+func (c *Connector) UpdateSecret(id string, fieldName string, value interface{}) {
+	if fieldName != c.config.UsernameField && fieldName != c.config.PasswordField {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values[fieldName] = value
+}
+
+// Flush method takes the username and password collected in the sync cycle as the current credentials and starts
+// a new credential generation when they changed. It implements vaultsync.SecretSink.
+func (c *Connector) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	username, ok := c.values[c.config.UsernameField]
+	if !ok {
+		return nil
+	}
+	password, ok := c.values[c.config.PasswordField]
+	if !ok {
+		return nil
+	}
+
+	u, p := fmt.Sprint(username), fmt.Sprint(password)
+	if c.synced && u == c.username && p == c.password {
+		return nil
+	}
+	if c.synced {
+		c.generation.Add(1)
+	}
+	c.username, c.password, c.synced = u, p, true
+	return nil
+}
+
+// rotatingConn struct wraps a connection and reports it as invalid once the credentials it was opened with have rotated.
+// It forwards the optional driver interfaces of the wrapped connection.
+type rotatingConn struct {
+	driver.Conn
+	connector  *Connector
+	generation int64
+}
+
+// IsValid method reports whether the connection was opened with the current credentials. It implements driver.Validator.
+func (rc *rotatingConn) IsValid() bool {
+	if rc.generation != rc.connector.generation.Load() {
+		return false
+	}
+	if v, ok := rc.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+// ResetSession method rejects connections with rotated credentials before they are reused. It implements driver.SessionResetter.
+func (rc *rotatingConn) ResetSession(ctx context.Context) error {
+	if rc.generation != rc.connector.generation.Load() {
+		return driver.ErrBadConn
+	}
+	if r, ok := rc.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+// Ping method implements driver.Pinger.
+func (rc *rotatingConn) Ping(ctx context.Context) error {
+	if p, ok := rc.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// PrepareContext method implements driver.ConnPrepareContext.
+func (rc *rotatingConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if p, ok := rc.Conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+	return rc.Conn.Prepare(query)
+}
+
+// BeginTx method implements driver.ConnBeginTx.
+func (rc *rotatingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := rc.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != 0 || opts.ReadOnly {
+		return nil, fmt.Errorf("driver does not support transaction options")
+	}
+	return rc.Conn.Begin()
+}
+
+// ExecContext method implements driver.ExecerContext.
+func (rc *rotatingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if e, ok := rc.Conn.(driver.ExecerContext); ok {
+		return e.ExecContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+// QueryContext method implements driver.QueryerContext.
+func (rc *rotatingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if q, ok := rc.Conn.(driver.QueryerContext); ok {
+		return q.QueryContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+// CheckNamedValue method implements driver.NamedValueChecker.
+func (rc *rotatingConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if c, ok := rc.Conn.(driver.NamedValueChecker); ok {
+		return c.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
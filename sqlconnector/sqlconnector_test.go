@@ -0,0 +1,69 @@
+package sqlconnector_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/pergus/vaultsync/sqlconnector"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+// fakeDriver struct records the data source names it opens connections with.
+type fakeDriver struct {
+	dsn string
+}
+
+func (d *fakeDriver) Open(dsn string) (driver.Conn, error) {
+	d.dsn = dsn
+	return fakeConn{}, nil
+}
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+func TestConnectorRotation(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/db", map[string]interface{}{"username": "app", "password": "s3cret"})
+
+	agent := vaultsynctest.NewAgent(t, s)
+	drv := &fakeDriver{}
+	connector := sqlconnector.New(agent, drv, sqlconnector.Config{
+		Path: "secret/data/db",
+		DSN:  func(username string, password string) string { return username + ":" + password },
+	})
+
+	if _, err := connector.Connect(context.Background()); err == nil {
+		t.Fatal("connected before the credentials were synced")
+	}
+
+	vaultsynctest.Sync(t, agent)
+	conn, err := connector.Connect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if drv.dsn != "app:s3cret" {
+		t.Fatalf("dsn is %q, want app:s3cret", drv.dsn)
+	}
+	if !conn.(driver.Validator).IsValid() {
+		t.Fatal("connection with the current credentials is invalid")
+	}
+
+	// Both fields rotate, the old connection is discarded and the next one uses the new pair.
+	s.SetSecret("secret/data/db", map[string]interface{}{"username": "app2", "password": "n3w"})
+	vaultsynctest.Sync(t, agent)
+	if conn.(driver.Validator).IsValid() {
+		t.Fatal("connection with rotated credentials is still valid")
+	}
+	if _, err := connector.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if drv.dsn != "app2:n3w" {
+		t.Fatalf("dsn is %q, want app2:n3w", drv.dsn)
+	}
+}
@@ -0,0 +1,44 @@
+package vaultsync
+
+import (
+	"context"
+	"log/slog"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// readSecret method reads a secret path for a sync. With read_server configured the read is sent to that server,
+// typically a performance standby, and retried on the active server if the standby is unavailable.
+// Writes and authentication always use the active server. Paths with an identity are read with the token of the identity.
+func (a *Agent) readSecret(ctx context.Context, log *slog.Logger, path string) (*vault.Secret, error) {
+	pathClient, api, err := a.pathClient(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := a.readSecretWith(ctx, log, path, pathClient, api)
+	a.checkIdentityError(path, err)
+	return secret, err
+}
+
+// readSecretWith method reads a secret path with the given clients, trying read_server first if it is configured.
+func (a *Agent) readSecretWith(ctx context.Context, log *slog.Logger, path string, pathClient *vault.Client, api Client) (*vault.Secret, error) {
+	if a.config.Vault.ReadServer == "" {
+		return api.Read(ctx, path)
+	}
+
+	client, err := pathClient.CloneWithHeaders()
+	if err == nil {
+		err = client.SetAddress(a.config.Vault.ReadServer)
+	}
+	if err == nil {
+		client.SetToken(pathClient.Token())
+		var secret *vault.Secret
+		secret, err = a.newClient(client).Read(ctx, path)
+		if !classifyError(err).unavailable() {
+			return secret, err
+		}
+	}
+
+	log.Warn("readSecret", slog.String("secret-path", path), slog.String("status", "read server unavailable, reading from active server"), slog.Any("error", err))
+	return api.Read(ctx, path)
+}
@@ -0,0 +1,63 @@
+package vaultsync
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// startupAuthMaxWait is the longest wait between two logins while New waits for Vault to become available.
+const startupAuthMaxWait = 30 * time.Second
+
+// WithStartupAuthTimeout function makes New retry the login for up to timeout while Vault is unreachable, sealed or unavailable,
+// for example when Vault and the application start at the same time during boot. The wait between logins doubles from 5 to 30 seconds.
+// Errors such as wrong credentials are returned at once. It takes precedence over startup_auth_timeout in the configuration file.
+func WithStartupAuthTimeout(timeout time.Duration) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.startupAuthTimeout = timeout
+	}
+}
+
+// startupAuthTimeoutOf method returns how long New retries the login, 0 if it does not retry.
+func (a *Agent) startupAuthTimeoutOf() time.Duration {
+	if a.startupAuthTimeout > 0 {
+		return a.startupAuthTimeout
+	}
+	return a.config.Vault.StartupAuthTimeout.value()
+}
+
+// startupLogin method logs in to Vault, retrying with backoff until the startup auth timeout passes if Vault is not available.
+func (a *Agent) startupLogin(ctx context.Context) error {
+	err := a.login(ctx)
+	timeout := a.startupAuthTimeoutOf()
+	if err == nil || timeout <= 0 {
+		return err
+	}
+
+	deadline := a.clock.Now().Add(timeout)
+	for failures := 1; classifyError(err).retryable(); failures++ {
+		remaining := deadline.Sub(a.clock.Now())
+		if remaining <= 0 {
+			return fmt.Errorf("vault not available within %v:%w", timeout, err)
+		}
+		wait := retryWait(failures, startupAuthMaxWait)
+		if wait > remaining {
+			wait = remaining
+		}
+		a.logs.auth.Warn("startupLogin", slog.String("status", "vault not available, retrying"), slog.Duration("retry in", wait), slog.Any("error", err))
+
+		timer := a.clock.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C():
+		}
+
+		if err = a.login(ctx); err == nil {
+			return nil
+		}
+	}
+	return err
+}
@@ -0,0 +1,165 @@
+package vaultsync_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+// loginCounter struct is a client counting the login attempts.
+type loginCounter struct {
+	vaultsync.Client
+	logins atomic.Int32
+}
+
+// Login method counts the attempt and logs in.
+func (c *loginCounter) Login(ctx context.Context, authMethod vault.AuthMethod) (*vault.Secret, error) {
+	c.logins.Add(1)
+	return c.Client.Login(ctx, authMethod)
+}
+
+// newResult struct is what New returned.
+type newResult struct {
+	agent *vaultsync.Agent
+	err   error
+}
+
+// startNew function calls New in the background with the startup auth timeout and the fake clock, counting the logins.
+func startNew(t *testing.T, filename string, timeout time.Duration, clock *vaultsynctest.FakeClock) (*loginCounter, <-chan newResult) {
+	counter := &loginCounter{}
+	result := make(chan newResult, 1)
+	go func() {
+		agent, err := vaultsync.New(
+			vaultsync.WithConfigFile(filename),
+			vaultsync.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+			vaultsync.WithClock(clock),
+			vaultsync.WithMaxRetries(0),
+			vaultsync.WithStartupAuthTimeout(timeout),
+			vaultsync.WithClient(func(c vaultsync.Client) vaultsync.Client {
+				counter.Client = c
+				return counter
+			}))
+		if agent != nil {
+			t.Cleanup(agent.Stop)
+		}
+		result <- newResult{agent: agent, err: err}
+	}()
+	return counter, result
+}
+
+// waitResult function waits for New to return.
+func waitResult(t *testing.T, result <-chan newResult) newResult {
+	t.Helper()
+
+	select {
+	case r := <-result:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("New did not return")
+	}
+	return newResult{}
+}
+
+// assertLogins function checks the number of login attempts once the agent waits for the next one.
+func assertLogins(t *testing.T, clock *vaultsynctest.FakeClock, counter *loginCounter, want int32) {
+	t.Helper()
+
+	clock.BlockUntil(1)
+	if got := counter.logins.Load(); got != want {
+		t.Fatalf("got %d logins, want %d", got, want)
+	}
+}
+
+func TestStartupLoginWaitsForVault(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetFailing(true)
+	filename, err := s.WriteConfig(t.TempDir(), 3600, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := vaultsynctest.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	counter, result := startNew(t, filename, time.Minute, clock)
+
+	// The wait between logins doubles from 5 seconds.
+	assertLogins(t, clock, counter, 1)
+	clock.Advance(5 * time.Second)
+	assertLogins(t, clock, counter, 2)
+	clock.Advance(9 * time.Second)
+	time.Sleep(20 * time.Millisecond)
+	assertLogins(t, clock, counter, 2)
+
+	// Vault comes up, the next login succeeds.
+	s.SetFailing(false)
+	clock.Advance(time.Second)
+	r := waitResult(t, result)
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if got := counter.logins.Load(); got != 3 || s.Tokens() != 1 {
+		t.Fatalf("got %d logins and %d tokens, want 3 logins and a token", got, s.Tokens())
+	}
+}
+
+func TestStartupLoginGivesUpAtDeadline(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetFailing(true)
+	filename, err := s.WriteConfig(t.TempDir(), 3600, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := vaultsynctest.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	counter, result := startNew(t, filename, 20*time.Second, clock)
+
+	// Logins at 0, 5 and 15 seconds, the last wait is cut to the 5 seconds left, then New gives up.
+	for i, wait := range []time.Duration{5 * time.Second, 10 * time.Second, 5 * time.Second} {
+		assertLogins(t, clock, counter, int32(i+1))
+		clock.Advance(wait)
+	}
+	r := waitResult(t, result)
+	if r.err == nil || !strings.Contains(r.err.Error(), "vault not available within 20s") {
+		t.Fatalf("got %v, want the deadline error", r.err)
+	}
+	if got := counter.logins.Load(); got != 4 {
+		t.Fatalf("got %d logins, want 4", got)
+	}
+}
+
+func TestStartupLoginReturnsAuthErrors(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	filename, err := s.WriteConfig(t.TempDir(), 3600, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong := strings.Replace(string(config), strconv.Quote(vaultsynctest.Password), `"wrong-secret-id"`, 1)
+	if err := os.WriteFile(filename, []byte(wrong), 0600); err != nil {
+		t.Fatal(err)
+	}
+	clock := vaultsynctest.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	counter, result := startNew(t, filename, time.Minute, clock)
+
+	// Wrong credentials are not retried, New returns without the clock moving.
+	r := waitResult(t, result)
+	if r.err == nil {
+		t.Fatal("New succeeded with wrong credentials")
+	}
+	if got := counter.logins.Load(); got != 1 {
+		t.Fatalf("got %d logins, want 1", got)
+	}
+}
@@ -0,0 +1,99 @@
+// Package statsdsink provides a vaultsync.MetricsSink that sends metrics to a statsd server over UDP.
+package statsdsink
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sink struct sends metrics in the statsd line protocol.
+// Metrics are sent fire-and-forget, write errors are ignored as is customary for statsd.
+type Sink struct {
+	conn   net.Conn
+	prefix string
+	tags   bool
+}
+
+// New function creates a sink sending plain statsd metrics to addr (host:port).
+// Labels are appended to the metric name since plain statsd has no tags.
+func New(addr, prefix string) (*Sink, error) {
+	return newSink(addr, prefix, false)
+}
+
+// NewDogStatsD function creates a sink sending DogStatsD metrics to addr (host:port) with labels as tags.
+func NewDogStatsD(addr, prefix string) (*Sink, error) {
+	return newSink(addr, prefix, true)
+}
+
+// newSink function dials the statsd server.
+func newSink(addr, prefix string, tags bool) (*Sink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{
+		conn:   conn,
+		prefix: prefix,
+		tags:   tags,
+	}, nil
+}
+
+// IncrCounter method sends a counter increment.
+func (s *Sink) IncrCounter(name string, value int64, labels map[string]string) {
+	s.send(name, fmt.Sprintf("%d|c", value), labels)
+}
+
+// SetGauge method sends a gauge value.
+func (s *Sink) SetGauge(name string, value float64, labels map[string]string) {
+	s.send(name, fmt.Sprintf("%g|g", value), labels)
+}
+
+// ObserveTiming method sends a timing in milliseconds.
+func (s *Sink) ObserveTiming(name string, duration time.Duration, labels map[string]string) {
+	s.send(name, fmt.Sprintf("%g|ms", float64(duration)/float64(time.Millisecond)), labels)
+}
+
+// Close method closes the UDP connection.
+func (s *Sink) Close() error {
+	return s.conn.Close()
+}
+
+// send method formats and writes a single metric line.
+func (s *Sink) send(name, value string, labels map[string]string) {
+	if s.prefix != "" {
+		name = s.prefix + "." + name
+	}
+
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var line string
+	if s.tags {
+		tags := make([]string, 0, len(keys))
+		for _, key := range keys {
+			tags = append(tags, sanitize(key)+":"+sanitize(labels[key]))
+		}
+		line = name + ":" + value
+		if len(tags) > 0 {
+			line += "|#" + strings.Join(tags, ",")
+		}
+	} else {
+		for _, key := range keys {
+			name += "." + sanitize(labels[key])
+		}
+		line = name + ":" + value
+	}
+
+	s.conn.Write([]byte(line))
+}
+
+// sanitize function replaces characters that have a meaning in the statsd protocol.
+func sanitize(s string) string {
+	return strings.NewReplacer(":", "_", "|", "_", ",", "_", "#", "_", "/", "_", "@", "_").Replace(s)
+}
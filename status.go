@@ -0,0 +1,300 @@
+package vaultsync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// PathStatus struct describes the synchronization state of a registered secret path.
+type PathStatus struct {
+	Path          string          // Vault secret path.
+	LastSync      time.Time       // Time of the last successful sync, zero if the path never synced.
+	CachedAt      time.Time       // Time the values loaded from the cache at startup were synced, zero once the path synced with Vault.
+	LastError     error           // Error of the last failed sync, nil if the last sync succeeded.
+	LastErrorTime time.Time       // Time of the last failed sync.
+	ErrorKind     ErrorKind       // Kind of the error of the last failed sync, ErrorKindNone if the last sync succeeded.
+	NextRetry     time.Time       // Time a path that failed with a transient error is retried, zero if it waits for its next scheduled sync.
+	Version       int             // KV v2 version of the last synced secret, 0 if unknown.
+	LastChange    time.Time       // Time the secret last changed, the creation time of its KV v2 version if known, zero if unknown.
+	Age           time.Duration   // Time since the secret last changed, 0 if unknown.
+	ExpiresAt     time.Time       // Time the lease or certificate of the secret expires, zero if it does not expire.
+	Stale         bool            // True if the last successful sync is older than the staleness threshold.
+	StaleSince    time.Time       // Time of the first failed sync since the last successful one, zero if the last sync succeeded.
+	History       []VersionRecord // Recent versions of the path, oldest first, see WithVersionHistory.
+}
+
+// SyncSummary struct describes the outcome of a sync cycle.
+type SyncSummary struct {
+	CycleID  string           // Correlation ID of the cycle.
+	Start    time.Time        // Time the cycle started.
+	Duration time.Duration    // Total duration of the cycle.
+	Fetched  int              // Number of paths fetched successfully.
+	Changed  int              // Number of fetched paths whose secret rotated.
+	Failed   int              // Number of paths that failed to sync.
+	Errors   map[string]error // Sync error of each failed path.
+}
+
+// SyncError struct is returned when one or more paths failed to sync.
+type SyncError struct {
+	Errors map[string]error // Sync error of each failed path.
+}
+
+// Error method lists the failed paths and their errors, sorted by path.
+func (e *SyncError) Error() string {
+	paths := make([]string, 0, len(e.Errors))
+	for path := range e.Errors {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	msgs := make([]string, 0, len(paths))
+	for _, path := range paths {
+		msgs = append(msgs, fmt.Sprintf("%v: %v", path, e.Errors[path]))
+	}
+	return fmt.Sprintf("failed to sync %d path(s): %s", len(paths), strings.Join(msgs, "; "))
+}
+
+// pathState struct holds the internal synchronization state of a secret path.
+type pathState struct {
+	lastSync      time.Time
+	cachedAt      time.Time
+	lastError     error
+	lastErrorTime time.Time
+	version       int
+	failingSince  time.Time
+	fingerprints  map[string]string
+	secretStrings []string
+	data          map[string]interface{}
+	leaseID       string
+	history       []VersionRecord
+	errorKind     ErrorKind
+	failures      int
+	retryAt       time.Time
+	changedAt     time.Time
+	ageReported   bool
+	expiresAt     time.Time
+	expiryWarned  bool
+}
+
+// syncedAt method returns when the data of the path was synced from Vault, by this run or, for data loaded from the cache,
+// by the run that wrote the cache.
+func (s *pathState) syncedAt() time.Time {
+	if s.lastSync.IsZero() {
+		return s.cachedAt
+	}
+	return s.lastSync
+}
+
+// trackPath method starts tracking the synchronization state of a path.
+func (a *Agent) trackPath(path string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.paths[path]; !ok {
+		a.paths[path] = &pathState{}
+	}
+}
+
+// trackedPaths method returns all tracked paths, sorted.
+func (a *Agent) trackedPaths() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	paths := make([]string, 0, len(a.paths))
+	for path := range a.paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// recordSync method records a successful sync of a path and keeps its data.
+func (a *Agent) recordSync(path string, version int, data map[string]interface{}) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state, ok := a.paths[path]
+	if !ok {
+		state = &pathState{}
+		a.paths[path] = state
+	}
+	state.lastSync = a.clock.Now()
+	state.cachedAt = time.Time{}
+	state.lastError = nil
+	state.failingSince = time.Time{}
+	state.errorKind = ErrorKindNone
+	state.failures = 0
+	state.retryAt = time.Time{}
+	state.version = version
+	destroyData(state.data)
+	state.data = data
+
+	// Wake up WaitReady.
+	close(a.synced)
+	a.synced = make(chan struct{})
+	a.checkReady()
+}
+
+// WaitReady method blocks until every registered path has been synced successfully at least once.
+// If ctx expires first it returns an error naming the paths that have not been synced and their last errors.
+func (a *Agent) WaitReady(ctx context.Context) error {
+	for {
+		a.mu.RLock()
+		var pending []string
+		for path, state := range a.paths {
+			if state.lastSync.IsZero() {
+				if state.lastError != nil {
+					pending = append(pending, fmt.Sprintf("%v (%v)", path, state.lastError))
+				} else {
+					pending = append(pending, path)
+				}
+			}
+		}
+		synced := a.synced
+		a.mu.RUnlock()
+
+		if len(pending) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			sort.Strings(pending)
+			return fmt.Errorf("secret paths not synced: %s: %w", strings.Join(pending, ", "), ctx.Err())
+		case <-synced:
+		}
+	}
+}
+
+// recordSyncError method records a failed sync of a path. The receivers keep the values of the last successful sync.
+// If the error is transient the path is retried with backoff before its next scheduled sync.
+func (a *Agent) recordSyncError(path string, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state, ok := a.paths[path]
+	if !ok {
+		state = &pathState{}
+		a.paths[path] = state
+	}
+	state.lastError = err
+	state.lastErrorTime = a.clock.Now()
+	if state.failingSince.IsZero() {
+		state.failingSince = state.lastErrorTime
+	}
+	state.errorKind = classifyError(err)
+	state.failures++
+	state.retryAt = time.Time{}
+	if state.errorKind.retryable() {
+		state.retryAt = state.lastErrorTime.Add(retryWait(state.failures, a.scheduler.interval(path, state.lastErrorTime)))
+	}
+}
+
+// recordSummary method stores the summary of the most recent sync cycle.
+func (a *Agent) recordSummary(summary SyncSummary) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.lastCycle = summary
+}
+
+// LastSync method returns the summary of the most recent sync cycle.
+// The summary is zero if no cycle has completed yet.
+func (a *Agent) LastSync() SyncSummary {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.lastCycle
+}
+
+// staleThreshold method returns the age after which a synced path is considered stale.
+// It defaults to three renew periods, or three intervals of the schedule of the path, if stale_threshold is not configured.
+func (a *Agent) staleThreshold(path string, now time.Time) time.Duration {
+	if threshold := a.config.Vault.StaleThreshold.value(); threshold > 0 {
+		return threshold
+	}
+	interval := a.scheduler.interval(path, now)
+	if interval > maxWait/3 {
+		return maxWait
+	}
+	return 3 * interval
+}
+
+// Status method returns the synchronization state of all registered paths, sorted by path.
+func (a *Agent) Status() []PathStatus {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	now := a.clock.Now()
+
+	status := make([]PathStatus, 0, len(a.paths))
+	for path, state := range a.paths {
+		var age time.Duration
+		if !state.changedAt.IsZero() {
+			age = now.Sub(state.changedAt)
+		}
+		status = append(status, PathStatus{
+			Path:          path,
+			LastSync:      state.lastSync,
+			CachedAt:      state.cachedAt,
+			LastError:     state.lastError,
+			LastErrorTime: state.lastErrorTime,
+			ErrorKind:     state.errorKind,
+			NextRetry:     state.retryAt,
+			Version:       state.version,
+			LastChange:    state.changedAt,
+			Age:           age,
+			ExpiresAt:     state.expiresAt,
+			Stale:         state.lastSync.IsZero() || now.Sub(state.lastSync) > a.staleThreshold(path, now),
+			StaleSince:    state.failingSince,
+			History:       copyHistory(state.history),
+		})
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Path < status[j].Path })
+
+	return status
+}
+
+// Health method returns an error naming all stale paths, or nil if every path is fresh.
+func (a *Agent) Health() error {
+	var stale []string
+	for _, status := range a.Status() {
+		if status.Stale {
+			stale = append(stale, status.Path)
+		}
+	}
+	if len(stale) > 0 {
+		return fmt.Errorf("stale secret paths: %s", strings.Join(stale, ", "))
+	}
+	return nil
+}
+
+// secretVersion function returns the KV v2 version from the secret metadata, or 0 if it is missing.
+func secretVersion(secret *vault.Secret) int {
+	if secret == nil {
+		return 0
+	}
+	metadata, ok := secret.Data["metadata"].(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	return versionNumber(metadata["version"])
+}
+
+// versionNumber function converts a KV version number decoded from a Vault response to an int.
+func versionNumber(value interface{}) int {
+	switch version := value.(type) {
+	case json.Number:
+		v, _ := version.Int64()
+		return int(v)
+	case float64:
+		return int(version)
+	}
+	return 0
+}
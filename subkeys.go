@@ -0,0 +1,61 @@
+package vaultsync
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Subkeys method returns the keys of a KV v2 secret without reading its values, using the subkeys endpoint of
+// Vault 1.10 and later. Fields holding a JSON object are maps of their keys, other fields are nil. It also returns
+// the version of the secret. The path is the data path of the secret, e.g. secrets/data/app/db, and the token needs
+// read capability on the subkeys path, e.g. secrets/subkeys/app/db. Since no values leave Vault, presence checks
+// with Subkeys and Schema.CheckKeys expose less than reading the secret.
+func (a *Agent) Subkeys(ctx context.Context, path string) (map[string]interface{}, int, error) {
+	subkeysPath, err := kvOperationPath(path, "subkeys")
+	if err != nil {
+		return nil, 0, err
+	}
+	_, api, err := a.pathClient(ctx, path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("subkeys %v:%w", path, err)
+	}
+	secret, err := api.Read(ctx, subkeysPath)
+	a.recordVaultCall(err)
+	if err != nil {
+		return nil, 0, fmt.Errorf("subkeys %v:%w", path, err)
+	}
+	if secret == nil {
+		return nil, 0, fmt.Errorf("subkeys %v:%w", path, ErrSecretNotFound)
+	}
+	subkeys, ok := secret.Data["subkeys"].(map[string]interface{})
+	if !ok {
+		return nil, 0, fmt.Errorf("subkeys %v:%w", path, ErrSecretNotFound)
+	}
+	return subkeys, secretVersion(secret), nil
+}
+
+// CheckKeys method checks the keys returned by Subkeys against the schema: missing fields and, unless AllowExtra is set,
+// undeclared fields. The types of the fields are not checked, that needs their values. The error wraps ErrInvalidSecret
+// and lists every mismatch.
+func (s Schema) CheckKeys(subkeys map[string]interface{}) error {
+	var problems []string
+	for field := range s.Fields {
+		if _, ok := subkeys[field]; !ok {
+			problems = append(problems, fmt.Sprintf("missing field %v", field))
+		}
+	}
+	if !s.AllowExtra {
+		for field := range subkeys {
+			if _, ok := s.Fields[field]; !ok {
+				problems = append(problems, fmt.Sprintf("unexpected field %v", field))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("%w:schema: %s", ErrInvalidSecret, strings.Join(problems, ", "))
+	}
+	return nil
+}
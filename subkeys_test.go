@@ -0,0 +1,96 @@
+package vaultsync_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+func TestSubkeys(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+	s.SetSecret("secret/data/app", map[string]interface{}{
+		"user":     "app",
+		"password": "s3cret",
+		"tls":      map[string]interface{}{"cert": "pem", "key": "pem"},
+	})
+
+	agent := vaultsynctest.NewAgent(t, s)
+	ctx := context.Background()
+
+	subkeys, version, err := agent.Subkeys(ctx, "secret/data/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"user":     nil,
+		"password": nil,
+		"tls":      map[string]interface{}{"cert": nil, "key": nil},
+	}
+	if !reflect.DeepEqual(subkeys, want) {
+		t.Fatalf("got subkeys %v, want %v", subkeys, want)
+	}
+	if version != 2 {
+		t.Fatalf("got version %v, want 2", version)
+	}
+	if reads := s.Reads("secret/data/app"); reads != 0 {
+		t.Fatalf("values were read %v times", reads)
+	}
+
+	if _, _, err := agent.Subkeys(ctx, "secret/data/missing"); !errors.Is(err, vaultsync.ErrSecretNotFound) {
+		t.Fatalf("missing path: got %v, want ErrSecretNotFound", err)
+	}
+	if _, _, err := agent.Subkeys(ctx, "secret/app"); err == nil {
+		t.Fatal("no error for a path that is not a KV v2 data path")
+	}
+}
+
+func TestSchemaCheckKeys(t *testing.T) {
+	schema := vaultsync.Schema{Fields: map[string]vaultsync.FieldType{
+		"user":     vaultsync.StringField,
+		"password": vaultsync.StringField,
+	}}
+
+	if err := schema.CheckKeys(map[string]interface{}{"user": nil, "password": nil}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := schema.CheckKeys(map[string]interface{}{"user": nil, "pasword": nil})
+	if !errors.Is(err, vaultsync.ErrInvalidSecret) {
+		t.Fatalf("got %v, want ErrInvalidSecret", err)
+	}
+	for _, problem := range []string{"missing field password", "unexpected field pasword"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Fatalf("error %q does not report %q", err, problem)
+		}
+	}
+
+	schema.AllowExtra = true
+	if err := schema.CheckKeys(map[string]interface{}{"user": nil, "password": nil, "port": nil}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegisterUpdateSecretCheckedUsesSubkeys(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+
+	agent := vaultsynctest.NewAgent(t, s)
+	recorder := vaultsynctest.NewRecorder()
+
+	if err := agent.RegisterUpdateSecretChecked(context.Background(), "secret/data/app", recorder); err != nil {
+		t.Fatal(err)
+	}
+	if reads := s.Reads("secret/data/app"); reads != 0 {
+		t.Fatalf("values were read %v times by the check", reads)
+	}
+	vaultsynctest.Sync(t, agent)
+	recorder.AssertValue(t, "secret/data/app", "password", "s3cret")
+}
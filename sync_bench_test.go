@@ -0,0 +1,43 @@
+package vaultsync_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+// BenchmarkSyncCycle measures the steady state sync cycle of an agent syncing many paths whose secrets don't change,
+// the common case between rotations. Allocations per cycle should grow with the number of paths, not faster.
+func BenchmarkSyncCycle(b *testing.B) {
+	for _, paths := range []int{100, 500} {
+		b.Run(fmt.Sprintf("paths=%d", paths), func(b *testing.B) {
+			m := vaultsynctest.NewMockClient()
+			agent := vaultsynctest.NewMockAgent(b, m)
+			recorder := vaultsynctest.NewRecorder()
+			for i := 0; i < paths; i++ {
+				path := fmt.Sprintf("secret/data/app%d", i)
+				m.SetSecret(path, map[string]interface{}{
+					"username": fmt.Sprintf("app%d", i),
+					"password": fmt.Sprintf("s3cret%d", i),
+					"host":     "db.example.com",
+					"port":     "5432",
+				})
+				agent.RegisterUpdateSecret(path, recorder)
+			}
+			ctx := context.Background()
+			if err := agent.SyncOnce(ctx); err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := agent.SyncOnce(ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
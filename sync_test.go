@@ -0,0 +1,34 @@
+package vaultsync_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+func TestRotatedValuesAreRedacted(t *testing.T) {
+	m := vaultsynctest.NewMockClient()
+	m.SetSecret("secret/data/app", map[string]interface{}{"password": "first-s3cret"})
+
+	var out bytes.Buffer
+	agent := vaultsynctest.NewMockAgent(t, m, vaultsync.WithLogger(slog.New(slog.NewTextHandler(&out, nil))))
+	agent.RegisterUpdateSecret("secret/data/app", vaultsynctest.NewRecorder())
+
+	vaultsynctest.Sync(t, agent)
+	vaultsynctest.Sync(t, agent)
+	agent.Logger().Info("unchanged first-s3cret", slog.String("detail", "first-s3cret"))
+
+	m.SetSecret("secret/data/app", map[string]interface{}{"password": "second-s3cret"})
+	vaultsynctest.Sync(t, agent)
+	agent.Logger().Info("rotated second-s3cret", slog.String("detail", "second-s3cret"))
+
+	for _, secret := range []string{"first-s3cret", "second-s3cret"} {
+		if strings.Contains(out.String(), secret) {
+			t.Errorf("log contains %q: %s", secret, out.String())
+		}
+	}
+}
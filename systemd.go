@@ -0,0 +1,120 @@
+package vaultsync
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WithSystemdNotify function makes the agent report its state to systemd with the sd_notify protocol.
+// READY=1 is sent once every registered path has been synced from Vault, and if the unit has WatchdogSec set,
+// WATCHDOG=1 is sent at half the watchdog interval as long as the sync loop is alive.
+// The option has no effect if the process is not started by systemd with NOTIFY_SOCKET set.
+func WithSystemdNotify() AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.systemdNotify = true
+	}
+}
+
+// sdNotify function sends a state to the systemd notify socket. It does nothing if NOTIFY_SOCKET is not set.
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// watchdogInterval function returns the systemd watchdog interval, or 0 if the watchdog is not enabled for this process.
+func watchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// notifySystemdReady method starts the goroutine that tells systemd the agent is ready and sends the watchdog heartbeats.
+func (a *Agent) notifySystemdReady(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go a.systemdWatchdog(ctx, wg, watchdogInterval())
+}
+
+// systemdWatchdog method sends READY=1 once every path has been synced from Vault, so systemd does not consider an agent
+// that only has cached values or failed its initial sync as started. Until then it reports the agent as waiting for Vault.
+// It sends watchdog heartbeats at half the interval while the sync loop is alive, and STOPPING=1 when ctx is cancelled.
+// Heartbeats do not depend on Health: a stale path is a problem of Vault or of the path, restarting the agent does not fix it.
+// No heartbeats are sent if interval is 0.
+func (a *Agent) systemdWatchdog(ctx context.Context, wg *sync.WaitGroup, interval time.Duration) {
+	defer wg.Done()
+
+	ready := a.Ready()
+	select {
+	case <-ready:
+	default:
+		if err := sdNotify("STATUS=waiting for the initial sync from vault"); err != nil {
+			a.log.Error("systemdWatchdog", slog.Any("error", err))
+		}
+	}
+
+	var heartbeat <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval / 2)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			sdNotify("STOPPING=1")
+			return
+
+		case <-ready:
+			ready = nil
+			summary := a.LastSync()
+			status := fmt.Sprintf("STATUS=synced %d paths, %d failed", summary.Fetched, summary.Failed)
+			if err := sdNotify("READY=1\n" + status); err != nil {
+				a.log.Error("systemdWatchdog", slog.Any("error", err))
+			}
+
+		case <-heartbeat:
+			if err := a.syncLoopAlive(interval); err != nil {
+				a.log.Warn("systemdWatchdog", slog.String("status", "sync loop stuck, skipping heartbeat"), slog.Any("error", err))
+				continue
+			}
+			if err := sdNotify("WATCHDOG=1"); err != nil {
+				a.log.Error("systemdWatchdog", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// syncLoopAlive method returns an error if the sync loop has been syncing for longer than limit, which means it is stuck
+// and no longer schedules syncs. A loop waiting for its next sync is alive however long it waits.
+func (a *Agent) syncLoopAlive(limit time.Duration) error {
+	ls, ok := a.loopState(loopRenewSecrets)
+	if !ok || ls.State != loopStateSyncing {
+		return nil
+	}
+	if since := a.clock.Now().Sub(ls.Since); since > limit {
+		return fmt.Errorf("sync loop has been syncing for %v", since.Round(time.Second))
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+//go:build unix
+
+package vaultsync_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+func TestSystemdReadyAfterSync(t *testing.T) {
+	// The socket path must fit in sockaddr_un, the test directory may be too long.
+	dir, err := os.MkdirTemp("", "sd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", socket)
+
+	messages := make(chan string, 16)
+	go func() {
+		b := make([]byte, 1024)
+		for {
+			n, err := conn.Read(b)
+			if err != nil {
+				return
+			}
+			messages <- string(b[:n])
+		}
+	}()
+
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+	clock := vaultsynctest.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	agent := vaultsynctest.NewAgent(t, s, vaultsync.WithClock(clock), vaultsync.WithMaxRetries(0), vaultsync.WithSystemdNotify())
+	agent.RegisterUpdateSecret("secret/data/app", vaultsynctest.NewRecorder())
+
+	// The initial sync fails, the agent is not ready.
+	s.SetFailing(true)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := agent.Run(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Stop()
+
+	select {
+	case msg := <-messages:
+		if strings.Contains(msg, "READY=1") {
+			t.Fatalf("READY sent after a failed sync: %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no status sent")
+	}
+
+	// Once the retry syncs the path, READY=1 is sent.
+	s.SetFailing(false)
+	deadline := time.After(10 * time.Second)
+	for ready := false; !ready; {
+		select {
+		case msg := <-messages:
+			ready = strings.Contains(msg, "READY=1")
+		case <-deadline:
+			t.Fatal("READY not sent after the retry synced the path")
+		case <-time.After(10 * time.Millisecond):
+			clock.Advance(10 * time.Second)
+		}
+	}
+}
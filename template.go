@@ -0,0 +1,209 @@
+package vaultsync
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+)
+
+// templateConfig struct defines a template that is rendered to a file whenever the secrets it references change.
+// The template is read from source or given inline with contents. The optional command runs after every render.
+type templateConfig struct {
+	Source      string `hcl:"source,optional"`
+	Contents    string `hcl:"contents,optional"`
+	Destination string `hcl:"destination"`
+	Perms       string `hcl:"perms,optional"`
+	Owner       string `hcl:"owner,optional"`
+	Group       string `hcl:"group,optional"`
+
+	Command        []string `hcl:"command,optional"`
+	CommandTimeout duration `hcl:"command_timeout,optional"`
+}
+
+// templateSink struct renders a Go text/template with synced secrets to a destination file.
+// Secrets are accessed in the template with the secret function, e.g. {{ with secret "secrets/data/app/db" }}{{ .password }}{{ end }}.
+type templateSink struct {
+	config  templateConfig
+	tmpl    *template.Template
+	paths   []string
+	expand  func(string) (string, error)
+	owner   fileOwner
+	command commandHook
+
+	mu     sync.Mutex
+	fields secretFields
+}
+
+// newTemplateSink function parses the template and discovers the secret paths it references.
+// If expand is not nil the placeholders in the paths are expanded with it.
+func newTemplateSink(config templateConfig, expand func(string) (string, error)) (*templateSink, error) {
+	text := config.Contents
+	if config.Source != "" {
+		b, err := os.ReadFile(config.Source)
+		if err != nil {
+			return nil, err
+		}
+		text = string(b)
+	}
+
+	owner, err := parseFileOwner(config.Perms, config.Owner, config.Group, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	ts := &templateSink{
+		config:  config,
+		expand:  expand,
+		owner:   owner,
+		command: newCommandHook(config.Command, config.CommandTimeout.value()),
+		fields:  newSecretFields(),
+	}
+
+	ts.tmpl, err = template.New(config.Destination).Option("missingkey=error").Funcs(template.FuncMap{
+		"secret": ts.secret,
+	}).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	ts.paths = templatePaths(ts.tmpl)
+	if len(ts.paths) == 0 {
+		return nil, fmt.Errorf("template %v references no secrets", config.Destination)
+	}
+	if expand != nil {
+		for i, path := range ts.paths {
+			if ts.paths[i], err = expand(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return ts, nil
+}
+
+// secret method is the template function returning the fields of a secret path.
+// It is only called during render, while mu is held.
+func (ts *templateSink) secret(path string) (map[string]interface{}, error) {
+	if ts.expand != nil {
+		var err error
+		if path, err = ts.expand(path); err != nil {
+			return nil, err
+		}
+	}
+	fields, ok := ts.fields.data[path]
+	if !ok {
+		return nil, fmt.Errorf("secret %v is not synced", path)
+	}
+	return fields, nil
+}
+
+// UpdateSecret method collects a field of a referenced secret.
+func (ts *templateSink) UpdateSecret(id string, fieldName string, value interface{}) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if b, ok := value.([]byte); ok {
+		value = string(b)
+	}
+	ts.fields.set(id, fieldName, value)
+}
+
+// Flush method renders the template if a referenced secret changed and all referenced secrets are synced.
+// The command, if configured, runs after the file has been written.
+func (ts *templateSink) Flush() error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.fields.prune()
+	if !ts.fields.dirty || !ts.fields.has(ts.paths...) {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := ts.tmpl.Execute(&buf, nil); err != nil {
+		return fmt.Errorf("render %v: %w", ts.config.Destination, err)
+	}
+	err := writeFileAtomic(ts.config.Destination, buf.Bytes(), ts.owner)
+	clear(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("write %v: %w", ts.config.Destination, err)
+	}
+	changed := ts.fields.clean()
+
+	return ts.command.run(map[string]string{
+		"VAULTSYNC_DESTINATION":    ts.config.Destination,
+		"VAULTSYNC_CHANGED_FIELDS": strings.Join(changed, ","),
+	})
+}
+
+// templatePaths function returns the string arguments of all calls to the secret function in the template.
+func templatePaths(tmpl *template.Template) []string {
+	found := make(map[string]bool)
+
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+		case *parse.ChainNode:
+			walk(n.Node)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			if len(n.Args) >= 2 {
+				if ident, ok := n.Args[0].(*parse.IdentifierNode); ok && ident.Ident == "secret" {
+					if str, ok := n.Args[1].(*parse.StringNode); ok {
+						found[str.Text] = true
+					}
+				}
+			}
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		}
+	}
+
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			walk(t.Tree.Root)
+		}
+	}
+
+	paths := make([]string, 0, len(found))
+	for path := range found {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
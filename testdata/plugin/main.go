@@ -0,0 +1,62 @@
+// Command plugin is the receiver plugin of the tests. It writes the fields of each path as a JSON file to the directory
+// set in its config. With crash_file set it exits when it gets a field named crash while the file exists, removing
+// the file first, so it crashes once. With hang set to flush its Flush never returns.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pergus/vaultsync/plugin"
+)
+
+type testReceiver struct {
+	config  map[string]string
+	secrets map[string]map[string]interface{}
+}
+
+func (r *testReceiver) Configure(config map[string]string) error {
+	if config["directory"] == "" {
+		return fmt.Errorf("directory is not set")
+	}
+	r.config = config
+	r.secrets = make(map[string]map[string]interface{})
+	return nil
+}
+
+func (r *testReceiver) UpdateSecret(path string, field string, value interface{}) error {
+	if field == "crash" && r.config["crash_file"] != "" {
+		if err := os.Remove(r.config["crash_file"]); err == nil {
+			os.Exit(1)
+		}
+	}
+	if r.secrets[path] == nil {
+		r.secrets[path] = make(map[string]interface{})
+	}
+	r.secrets[path][field] = value
+	return nil
+}
+
+func (r *testReceiver) Flush() error {
+	if r.config["hang"] == "flush" {
+		select {}
+	}
+	for path, fields := range r.secrets {
+		b, err := json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		filename := filepath.Join(r.config["directory"], strings.ReplaceAll(path, "/", "_")+".json")
+		if err := os.WriteFile(filename, b, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func main() {
+	plugin.Serve(&testReceiver{})
+}
@@ -0,0 +1,79 @@
+package vaultsync
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sync"
+)
+
+// Field names of PEM encoded certificates and keys read by the TLS helpers, matching the PKI secrets engine.
+const (
+	CertificateField = "certificate"
+	PrivateKeyField  = "private_key"
+)
+
+// certificateCache struct holds the certificate parsed from a secret path and reparses it when the PEM data changes.
+type certificateCache struct {
+	agent *Agent
+	path  string
+
+	mu      sync.Mutex
+	certPEM string
+	keyPEM  string
+	cert    *tls.Certificate
+}
+
+// GetCertificateFunc method returns a function for tls.Config.GetCertificate that serves the certificate and private key
+// stored in the certificate and private_key fields of a secret path. New handshakes use the rotated certificate without a restart.
+func (a *Agent) GetCertificateFunc(path string) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cache := a.newCertificateCache(path)
+	return func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return cache.certificate()
+	}
+}
+
+// GetClientCertificateFunc method returns a function for tls.Config.GetClientCertificate that presents the certificate and private key
+// stored in the certificate and private_key fields of a secret path.
+func (a *Agent) GetClientCertificateFunc(path string) func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	cache := a.newCertificateCache(path)
+	return func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return cache.certificate()
+	}
+}
+
+// newCertificateCache method creates a certificate cache and tracks its path.
+func (a *Agent) newCertificateCache(path string) *certificateCache {
+	a.trackPath(path)
+	return &certificateCache{agent: a, path: path}
+}
+
+// certificate method returns the parsed certificate, parsing it again if the secret changed.
+func (c *certificateCache) certificate() (*tls.Certificate, error) {
+	certPEM, ok := c.agent.SecretField(c.path, CertificateField)
+	if !ok {
+		return nil, fmt.Errorf("field %v of secret %v is not synced", CertificateField, c.path)
+	}
+	keyPEM, ok := c.agent.SecretField(c.path, PrivateKeyField)
+	if !ok {
+		return nil, fmt.Errorf("field %v of secret %v is not synced", PrivateKeyField, c.path)
+	}
+	certString, ok1 := certPEM.(string)
+	keyString, ok2 := keyPEM.(string)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("certificate fields of secret %v are not strings", c.path)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cert != nil && c.certPEM == certString && c.keyPEM == keyString {
+		return c.cert, nil
+	}
+
+	cert, err := tls.X509KeyPair([]byte(certString), []byte(keyString))
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate in secret %v:%w", c.path, err)
+	}
+	c.certPEM, c.keyPEM, c.cert = certString, keyString, &cert
+	return c.cert, nil
+}
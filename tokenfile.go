@@ -0,0 +1,104 @@
+package vaultsync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// tokenFilePollInterval is how often the token file of a Vault Agent is checked for a new token.
+const tokenFilePollInterval = 5 * time.Second
+
+// tokenFileAuth struct implements vault.AuthMethod by reading the token a Vault Agent writes to its file sink.
+// The Vault Agent renews and replaces the token, so the agent never renews it.
+type tokenFileAuth struct {
+	filename string
+}
+
+// Login method reads the token from the sink file and looks it up to learn its TTL.
+func (t *tokenFileAuth) Login(ctx context.Context, client *vault.Client) (*vault.Secret, error) {
+	token, err := readTokenFile(t.filename)
+	if err != nil {
+		return nil, err
+	}
+	return lookupToken(ctx, client, token, t.filename)
+}
+
+// lookupToken function looks up a token read from source and returns it as a non-renewable login secret with the TTL of the token.
+func lookupToken(ctx context.Context, client *vault.Client, token string, source string) (*vault.Secret, error) {
+	lookupClient, err := client.Clone()
+	if err != nil {
+		return nil, err
+	}
+	lookupClient.SetToken(token)
+	self, err := lookupClient.Auth().Token().LookupSelfWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("token from %v is not valid:%w", source, err)
+	}
+	ttl, err := self.TokenTTL()
+	if err != nil {
+		return nil, err
+	}
+
+	return &vault.Secret{
+		Auth: &vault.SecretAuth{
+			ClientToken:   token,
+			Renewable:     false,
+			LeaseDuration: int(ttl.Seconds()),
+		},
+	}, nil
+}
+
+// readTokenFile function reads a token from a file, ignoring surrounding white space.
+func readTokenFile(filename string) (string, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(b))
+	clear(b)
+	if token == "" {
+		return "", fmt.Errorf("token file %v is empty", filename)
+	}
+	return token, nil
+}
+
+// watchTokenFile method waits until the Vault Agent wrote a new token to the sink file, so the agent can read it.
+func (a *Agent) watchTokenFile(ctx context.Context) error {
+	filename := a.config.Vault.TokenFile
+	current, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	defer clear(current)
+
+	timer := a.clock.NewTimer(tokenFilePollInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-a.reauth:
+			return nil
+		case <-timer.C():
+			timer.Reset(tokenFilePollInterval)
+		}
+
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			// The Vault Agent may be replacing the file.
+			continue
+		}
+		changed := !bytes.Equal(b, current)
+		clear(b)
+		if changed {
+			return nil
+		}
+	}
+}
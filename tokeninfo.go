@@ -0,0 +1,63 @@
+package vaultsync
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TokenInfo struct describes the authentication token of the agent as Vault sees it: the identity and the policies the
+// agent runs with. It never contains the token.
+type TokenInfo struct {
+	Accessor         string        `json:"accessor"`          // Accessor of the token, to look it up or revoke it without the token.
+	DisplayName      string        `json:"display_name"`      // Display name of the token, derived from the auth method.
+	EntityID         string        `json:"entity_id"`         // ID of the identity entity of the token, empty if it has none.
+	Policies         []string      `json:"policies"`          // Policies attached to the token.
+	IdentityPolicies []string      `json:"identity_policies"` // Policies inherited from the entity and its groups.
+	TTL              time.Duration `json:"ttl"`               // Remaining TTL of the token, 0 if it does not expire.
+	Renewable        bool          `json:"renewable"`         // Whether the token can be renewed.
+	ExpireTime       time.Time     `json:"expire_time"`       // Time the token expires, zero if it does not expire.
+}
+
+// TokenInfo method looks up the token of the agent with auth/token/lookup-self, so the application can log or alert on
+// the identity and policies it actually runs with. It returns ErrNotAuthenticated if the agent has no token, because
+// it started from its cache.
+func (a *Agent) TokenInfo(ctx context.Context) (TokenInfo, error) {
+	if a.token.Load() == nil {
+		return TokenInfo{}, ErrNotAuthenticated
+	}
+	secret, err := a.api.LookupSelf(ctx)
+	a.recordVaultCall(err)
+	if err != nil {
+		return TokenInfo{}, fmt.Errorf("token lookup:%w", err)
+	}
+	if secret == nil || secret.Data == nil {
+		return TokenInfo{}, fmt.Errorf("token lookup returned no data")
+	}
+
+	var info TokenInfo
+	if info.Accessor, err = secret.TokenAccessor(); err != nil {
+		return TokenInfo{}, fmt.Errorf("token lookup:%w", err)
+	}
+	if _, err = secret.TokenPolicies(); err != nil {
+		return TokenInfo{}, fmt.Errorf("token lookup:%w", err)
+	}
+	if secret.Auth != nil {
+		info.Policies = secret.Auth.TokenPolicies
+		info.IdentityPolicies = secret.Auth.IdentityPolicies
+	}
+	if info.TTL, err = secret.TokenTTL(); err != nil {
+		return TokenInfo{}, fmt.Errorf("token lookup:%w", err)
+	}
+	if info.Renewable, err = secret.TokenIsRenewable(); err != nil {
+		return TokenInfo{}, fmt.Errorf("token lookup:%w", err)
+	}
+	info.DisplayName, _ = secret.Data["display_name"].(string)
+	info.EntityID, _ = secret.Data["entity_id"].(string)
+	if expire, ok := secret.Data["expire_time"].(string); ok && expire != "" {
+		if info.ExpireTime, err = time.Parse(time.RFC3339Nano, expire); err != nil {
+			return TokenInfo{}, fmt.Errorf("token lookup: expire_time:%w", err)
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,33 @@
+package vaultsync_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+func TestTokenInfo(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	agent := vaultsynctest.NewAgent(t, s)
+
+	info, err := agent.TokenInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(info.Accessor, "accessor.") {
+		t.Fatalf("got accessor %q", info.Accessor)
+	}
+	if info.EntityID != vaultsynctest.EntityID {
+		t.Fatalf("got entity %q, want %q", info.EntityID, vaultsynctest.EntityID)
+	}
+	if !reflect.DeepEqual(info.Policies, []string{"default"}) || !reflect.DeepEqual(info.IdentityPolicies, []string{"app"}) {
+		t.Fatalf("got policies %v and identity policies %v", info.Policies, info.IdentityPolicies)
+	}
+	if info.TTL <= 0 || !info.Renewable {
+		t.Fatalf("got ttl %v renewable %v, want a renewable token with a ttl", info.TTL, info.Renewable)
+	}
+}
@@ -0,0 +1,105 @@
+package vaultsync
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Transform type is a function that rewrites the data of a secret path between reading it from Vault and dispatching it.
+// It may modify data in place and return it, or return new data. An error fails the sync of the path,
+// the receivers keep the values of the last successful sync.
+type Transform func(data map[string]interface{}) (map[string]interface{}, error)
+
+// RegisterTransform method adds transforms to a secret path. They run in the order they were registered,
+// each on the output of the previous one. Receivers, sinks, Secret and FS see the transformed data,
+// and rotations are detected on the transformed data.
+func (a *Agent) RegisterTransform(path string, transforms ...Transform) {
+	path = a.expandPath(path)
+	a.transforms[path] = append(a.transforms[path], transforms...)
+}
+
+// transform method runs the transforms registered for path on data.
+func (a *Agent) transform(path string, data map[string]interface{}) (map[string]interface{}, error) {
+	for _, transform := range a.transforms[path] {
+		var err error
+		data, err = transform(data)
+		if err != nil {
+			return nil, fmt.Errorf("transform:%w", err)
+		}
+		if data == nil {
+			return nil, fmt.Errorf("transform returned no data")
+		}
+	}
+	return data, nil
+}
+
+// DecodeJSONField function returns a transform that replaces a string field holding JSON with the decoded value,
+// for example a map for a JSON object. The field must exist.
+func DecodeJSONField(field string) Transform {
+	return func(data map[string]interface{}) (map[string]interface{}, error) {
+		s, ok := data[field].(string)
+		if !ok {
+			return nil, fmt.Errorf("field %v is not a string", field)
+		}
+		var value interface{}
+		if err := json.Unmarshal([]byte(s), &value); err != nil {
+			return nil, fmt.Errorf("field %v is not valid JSON:%w", field, err)
+		}
+		data[field] = value
+		return data, nil
+	}
+}
+
+// DecodeBase64 function returns a transform that replaces string fields holding base64 with the decoded bytes,
+// for keystores, PKCS#12 bundles and other binary material stored in KV. Receivers get a []byte, the file, directory,
+// template, env file and Kubernetes sinks write the raw bytes. The fields must exist.
+func DecodeBase64(fields ...string) Transform {
+	return func(data map[string]interface{}) (map[string]interface{}, error) {
+		for _, field := range fields {
+			s, ok := data[field].(string)
+			if !ok {
+				return nil, fmt.Errorf("field %v is not a string", field)
+			}
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return nil, fmt.Errorf("field %v is not valid base64:%w", field, err)
+			}
+			data[field] = b
+		}
+		return data, nil
+	}
+}
+
+// TrimSpace function returns a transform that removes leading and trailing white space from string fields,
+// such as the trailing newline of a value pasted into the Vault UI. Without fields all string fields are trimmed.
+func TrimSpace(fields ...string) Transform {
+	return func(data map[string]interface{}) (map[string]interface{}, error) {
+		for field, value := range data {
+			s, ok := value.(string)
+			if !ok || (len(fields) > 0 && !slices.Contains(fields, field)) {
+				continue
+			}
+			data[field] = strings.TrimSpace(s)
+		}
+		return data, nil
+	}
+}
+
+// DeriveField function returns a transform that adds a field computed from the other fields, for example a DSN:
+//
+//	vaultsync.DeriveField("dsn", func(data map[string]interface{}) (interface{}, error) {
+//		return fmt.Sprintf("postgres://%v:%v@%v/app", data["username"], data["password"], data["host"]), nil
+//	})
+func DeriveField(field string, derive func(data map[string]interface{}) (interface{}, error)) Transform {
+	return func(data map[string]interface{}) (map[string]interface{}, error) {
+		value, err := derive(data)
+		if err != nil {
+			return nil, fmt.Errorf("field %v:%w", field, err)
+		}
+		data[field] = value
+		return data, nil
+	}
+}
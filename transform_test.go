@@ -0,0 +1,144 @@
+package vaultsync_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+func TestDecodeBase64(t *testing.T) {
+	keystore := []byte{0xfe, 0xed, 0xfe, 0xed, 0x00, 0x02}
+	data, err := vaultsync.DecodeBase64("keystore")(map[string]interface{}{"keystore": "/u3+7QAC", "password": "changeit"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, ok := data["keystore"].([]byte); !ok || !bytes.Equal(b, keystore) {
+		t.Fatalf("got %#v, want the decoded bytes", data["keystore"])
+	}
+	if data["password"] != "changeit" {
+		t.Fatalf("field that is not decoded changed to %#v", data["password"])
+	}
+
+	for _, bad := range []map[string]interface{}{{"keystore": "not base64!"}, {"keystore": 1}, {}} {
+		if _, err := vaultsync.DecodeBase64("keystore")(bad); err == nil {
+			t.Errorf("decoding %v succeeded", bad)
+		}
+	}
+}
+
+func TestDecodeJSONField(t *testing.T) {
+	data, err := vaultsync.DecodeJSONField("config")(map[string]interface{}{"config": `{"host": "db", "ports": [5432]}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"host": "db", "ports": []interface{}{5432.0}}
+	if !reflect.DeepEqual(data["config"], want) {
+		t.Fatalf("got %#v, want %#v", data["config"], want)
+	}
+
+	for _, bad := range []map[string]interface{}{{"config": "{not json"}, {"config": 1}, {}} {
+		if _, err := vaultsync.DecodeJSONField("config")(bad); err == nil {
+			t.Errorf("decoding %v succeeded", bad)
+		}
+	}
+}
+
+func TestTrimSpace(t *testing.T) {
+	data, _ := vaultsync.TrimSpace()(map[string]interface{}{"user": " app\n", "password": "s3cret\n", "port": 5432})
+	if data["user"] != "app" || data["password"] != "s3cret" || data["port"] != 5432 {
+		t.Fatalf("got %#v", data)
+	}
+
+	data, _ = vaultsync.TrimSpace("password")(map[string]interface{}{"user": " app\n", "password": "s3cret\n"})
+	if data["user"] != " app\n" || data["password"] != "s3cret" {
+		t.Fatalf("got %#v with only password trimmed", data)
+	}
+}
+
+func TestDeriveField(t *testing.T) {
+	dsn := vaultsync.DeriveField("dsn", func(data map[string]interface{}) (interface{}, error) {
+		if data["password"] == nil {
+			return nil, errors.New("no password")
+		}
+		return fmt.Sprintf("postgres://%v:%v@db/app", data["username"], data["password"]), nil
+	})
+	data, err := dsn(map[string]interface{}{"username": "app", "password": "s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data["dsn"] != "postgres://app:s3cret@db/app" || data["password"] != "s3cret" {
+		t.Fatalf("got %#v", data)
+	}
+	if _, err := dsn(map[string]interface{}{"username": "app"}); err == nil || !strings.Contains(err.Error(), "field dsn") {
+		t.Fatalf("got %v, want the error of the derived field", err)
+	}
+}
+
+func TestTransformsAppliedBeforeDispatchAndChangeDetection(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/db", map[string]interface{}{
+		"config":   `{"host": "db"}`,
+		"keystore": "/u3+7QAC",
+		"username": "app\n",
+		"password": " s3cret\n",
+	})
+	agent := vaultsynctest.NewAgent(t, s)
+	recorder := vaultsynctest.NewRecorder()
+	agent.RegisterUpdateSecret("secret/data/db", recorder)
+	agent.RegisterTransform("secret/data/db",
+		vaultsync.DecodeJSONField("config"),
+		vaultsync.DecodeBase64("keystore"),
+		vaultsync.TrimSpace(),
+		vaultsync.DeriveField("dsn", func(data map[string]interface{}) (interface{}, error) {
+			host := data["config"].(map[string]interface{})["host"]
+			return fmt.Sprintf("postgres://%v:%v@%v/app", data["username"], data["password"], host), nil
+		}))
+	var changes []vaultsync.SecretChange
+	agent.OnChange("secret/data/db", func(change vaultsync.SecretChange) { changes = append(changes, change) })
+
+	vaultsynctest.Sync(t, agent)
+	recorder.AssertValue(t, "secret/data/db", "config", map[string]interface{}{"host": "db"})
+	recorder.AssertValue(t, "secret/data/db", "keystore", []byte{0xfe, 0xed, 0xfe, 0xed, 0x00, 0x02})
+	recorder.AssertValue(t, "secret/data/db", "username", "app")
+	recorder.AssertValue(t, "secret/data/db", "password", "s3cret")
+	recorder.AssertValue(t, "secret/data/db", "dsn", "postgres://app:s3cret@db/app")
+
+	// Only white space changed in Vault, the transformed data is the same.
+	s.SetSecret("secret/data/db", map[string]interface{}{
+		"config":   `{"host": "db"}`,
+		"keystore": "/u3+7QAC",
+		"username": "app",
+		"password": "s3cret",
+	})
+	vaultsynctest.Sync(t, agent)
+	if len(changes) != 0 {
+		t.Fatalf("got changes %+v when only white space changed", changes)
+	}
+
+	s.SetSecret("secret/data/db", map[string]interface{}{
+		"config":   `{"host": "db"}`,
+		"keystore": "/u3+7QAC",
+		"username": "app",
+		"password": "n3w\n",
+	})
+	vaultsynctest.Sync(t, agent)
+	recorder.AssertValue(t, "secret/data/db", "password", "n3w")
+	recorder.AssertValue(t, "secret/data/db", "dsn", "postgres://app:n3w@db/app")
+	if len(changes) != 1 {
+		t.Fatalf("got %d changes, want 1", len(changes))
+	}
+	fields := changes[0].Fields
+	if len(fields) != 2 || fields[0].Field != "dsn" || fields[1].Field != "password" {
+		t.Fatalf("got changed fields %+v, want dsn and password", fields)
+	}
+	if fields[1].Old != vaultsync.Fingerprint("s3cret") || fields[1].New != vaultsync.Fingerprint("n3w") {
+		t.Fatal("the change was not detected on the transformed values")
+	}
+}
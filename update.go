@@ -0,0 +1,88 @@
+package vaultsync
+
+import (
+	"fmt"
+	"time"
+)
+
+// SecretUpdate struct is an update of a field of a secret passed to a SecretUpdateReceiver.
+type SecretUpdate struct {
+	Path      string      // Vault secret path, the id the receiver was registered with.
+	Field     string      // Name of the field.
+	Value     interface{} // Value of the field, nil if the field was deleted.
+	Version   int         // KV v2 version of the secret, 0 if unknown.
+	Timestamp time.Time   // Time the secret was dispatched.
+	Deleted   bool        // True if the field was removed from the secret.
+}
+
+// SecretUpdateReceiver interface is version 2 of SecretReceiver. It receives the fields of a secret with their context:
+// the version of the secret, the time of the update and the fields that were removed from the secret.
+type SecretUpdateReceiver interface {
+	ReceiveUpdate(update SecretUpdate)
+}
+
+// RegisterSecretUpdates method registers a SecretUpdateReceiver for a secret path. Like the receivers registered with
+// RegisterUpdateSecret it gets every field of the secret on each sync, and in addition an update with Deleted set for
+// each field that was removed from the secret since the previous sync.
+func (a *Agent) RegisterSecretUpdates(id string, receiver SecretUpdateReceiver) {
+	a.RegisterUpdateSecret(id, updateReceiver{receiver: receiver})
+}
+
+// updateReceiver struct registers a SecretUpdateReceiver with the receivers of a path.
+type updateReceiver struct {
+	receiver SecretUpdateReceiver
+}
+
+// UpdateSecret method passes a field without its context. The agent calls ReceiveUpdate instead.
+func (u updateReceiver) UpdateSecret(id string, fieldName string, value interface{}) {
+	u.receiver.ReceiveUpdate(SecretUpdate{Path: id, Field: fieldName, Value: value})
+}
+
+// receiverOf function returns the receiver registered by the application, unwrapping a SecretUpdateReceiver.
+func receiverOf(receiver SecretReceiver) interface{} {
+	if u, ok := receiver.(updateReceiver); ok {
+		return u.receiver
+	}
+	return receiver
+}
+
+// receiverName function returns the type of a receiver for logs and events.
+func receiverName(receiver SecretReceiver) string {
+	return fmt.Sprintf("%T", receiverOf(receiver))
+}
+
+// secretDispatch struct is the context of the fields of a path passed to the receivers.
+type secretDispatch struct {
+	path      string
+	version   int
+	deleted   []string
+	timestamp time.Time
+}
+
+// deliver method passes fields to a receiver, with their context and the deleted fields to a SecretUpdateReceiver.
+func (d secretDispatch) deliver(receiver SecretReceiver, fields map[string]interface{}) {
+	u, ok := receiver.(updateReceiver)
+	if !ok {
+		for field, value := range fields {
+			receiver.UpdateSecret(d.path, field, value)
+		}
+		return
+	}
+	for field, value := range fields {
+		u.receiver.ReceiveUpdate(SecretUpdate{Path: d.path, Field: field, Value: value, Version: d.version, Timestamp: d.timestamp})
+	}
+	for _, field := range d.deleted {
+		u.receiver.ReceiveUpdate(SecretUpdate{Path: d.path, Field: field, Version: d.version, Timestamp: d.timestamp, Deleted: true})
+	}
+}
+
+// deletedFields function returns the changed fields that are not in data.
+func deletedFields(changed []string, data map[string]interface{}) []string {
+	var deleted []string
+	for _, field := range changed {
+		if _, ok := data[field]; !ok {
+			deleted = append(deleted, field)
+		}
+	}
+	return deleted
+}
@@ -0,0 +1,57 @@
+package vaultsync_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+// updateRecorder struct records the updates passed to a SecretUpdateReceiver.
+type updateRecorder struct {
+	mu      sync.Mutex
+	updates map[string]vaultsync.SecretUpdate
+}
+
+func (r *updateRecorder) ReceiveUpdate(update vaultsync.SecretUpdate) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.updates[update.Field] = update
+}
+
+func (r *updateRecorder) update(field string) vaultsync.SecretUpdate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.updates[field]
+}
+
+func TestSecretUpdateReceiver(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"username": "app", "password": "s3cret"})
+
+	clock := vaultsynctest.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	agent := vaultsynctest.NewAgent(t, s, vaultsync.WithClock(clock))
+	recorder := &updateRecorder{updates: make(map[string]vaultsync.SecretUpdate)}
+	agent.RegisterSecretUpdates("secret/data/app", recorder)
+	vaultsynctest.Sync(t, agent)
+
+	want := vaultsync.SecretUpdate{Path: "secret/data/app", Field: "password", Value: "s3cret", Version: 1, Timestamp: clock.Now()}
+	if got := recorder.update("password"); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	// A field removed from the secret is passed as deleted.
+	clock.Advance(time.Minute)
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "rotated"})
+	vaultsynctest.Sync(t, agent)
+	want = vaultsync.SecretUpdate{Path: "secret/data/app", Field: "username", Version: 2, Timestamp: clock.Now(), Deleted: true}
+	if got := recorder.update("username"); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if got := recorder.update("password"); got.Value != "rotated" || got.Version != 2 || got.Deleted {
+		t.Fatalf("got %+v, want the rotated value", got)
+	}
+}
@@ -0,0 +1,70 @@
+package vaultsync
+
+import (
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidSecret is returned for a secret path whose data was rejected by a validator.
+var ErrInvalidSecret = errors.New("invalid secret")
+
+// Validator type is a function that checks the data of a secret path before it is dispatched.
+// It must not modify data.
+type Validator func(data map[string]interface{}) error
+
+// RegisterValidator method adds validators to a secret path. They run after the transforms of the path.
+// If a validator returns an error the new data is rejected: receivers, sinks, Secret and FS keep the values
+// of the last successful sync, the error is logged, recorded in the status of the path and passed to the error handler.
+func (a *Agent) RegisterValidator(path string, validators ...Validator) {
+	path = a.expandPath(path)
+	a.validators[path] = append(a.validators[path], validators...)
+}
+
+// WithErrorHandler function sets a function that is called with the path and the error whenever a secret path fails to sync,
+// including when its data is rejected by a validator. It is called from the sync goroutine and must not block.
+func WithErrorHandler(handler func(path string, err error)) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.errorHandler = handler
+	}
+}
+
+// validate method runs the validators registered for path on data.
+func (a *Agent) validate(path string, data map[string]interface{}) error {
+	for _, validator := range a.validators[path] {
+		if err := validator(data); err != nil {
+			return fmt.Errorf("%w:%w", ErrInvalidSecret, err)
+		}
+	}
+	return nil
+}
+
+// NotEmpty function returns a validator that requires the fields to be present and, for strings, not empty.
+func NotEmpty(fields ...string) Validator {
+	return func(data map[string]interface{}) error {
+		for _, field := range fields {
+			value, ok := data[field]
+			if !ok || value == nil || value == "" {
+				return fmt.Errorf("field %v is empty", field)
+			}
+		}
+		return nil
+	}
+}
+
+// ValidPEM function returns a validator that requires the fields to be strings holding at least one PEM block,
+// such as a certificate or a private key.
+func ValidPEM(fields ...string) Validator {
+	return func(data map[string]interface{}) error {
+		for _, field := range fields {
+			s, ok := data[field].(string)
+			if !ok {
+				return fmt.Errorf("field %v is not a string", field)
+			}
+			if block, _ := pem.Decode([]byte(s)); block == nil {
+				return fmt.Errorf("field %v is not PEM encoded", field)
+			}
+		}
+		return nil
+	}
+}
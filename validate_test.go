@@ -0,0 +1,104 @@
+package vaultsync_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+const testCertificate = `-----BEGIN CERTIFICATE-----
+MIIBBzCBrqADAgECAgEBMAoGCCqGSM49BAMCMAAwHhcNMjYwMTAxMDAwMDAwWhcN
+-----END CERTIFICATE-----
+`
+
+// errorRecorder struct records the calls of the error handler of an agent.
+type errorRecorder struct {
+	mu     sync.Mutex
+	errors map[string][]error
+}
+
+// handle method is the error handler.
+func (r *errorRecorder) handle(path string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.errors == nil {
+		r.errors = make(map[string][]error)
+	}
+	r.errors[path] = append(r.errors[path], err)
+}
+
+// get method returns the errors passed for path.
+func (r *errorRecorder) get(path string) []error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.errors[path]
+}
+
+// pathStatus function returns the status of path.
+func pathStatus(t *testing.T, agent *vaultsync.Agent, path string) vaultsync.PathStatus {
+	t.Helper()
+	for _, status := range agent.Status() {
+		if status.Path == path {
+			return status
+		}
+	}
+	t.Fatalf("no status of %v", path)
+	return vaultsync.PathStatus{}
+}
+
+func TestValidatorRejectsRotation(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		invalid map[string]interface{}
+	}{
+		{"empty password", map[string]interface{}{"password": "", "certificate": testCertificate}},
+		{"missing password", map[string]interface{}{"certificate": testCertificate}},
+		{"certificate not PEM", map[string]interface{}{"password": "n3w", "certificate": "not a certificate"}},
+		{"certificate not a string", map[string]interface{}{"password": "n3w", "certificate": 42}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := vaultsynctest.NewServer()
+			defer s.Close()
+			s.SetSecret("secret/data/tls", map[string]interface{}{"password": "s3cret", "certificate": testCertificate})
+			handler := &errorRecorder{}
+			agent := vaultsynctest.NewAgent(t, s, vaultsync.WithErrorHandler(handler.handle))
+			recorder := vaultsynctest.NewRecorder()
+			agent.RegisterUpdateSecret("secret/data/tls", recorder)
+			agent.RegisterValidator("secret/data/tls", vaultsync.NotEmpty("password"), vaultsync.ValidPEM("certificate"))
+			vaultsynctest.Sync(t, agent)
+			recorder.AssertValue(t, "secret/data/tls", "password", "s3cret")
+
+			s.SetSecret("secret/data/tls", tc.invalid)
+			if err := agent.SyncOnce(context.Background()); err == nil {
+				t.Fatal("sync of an invalid secret succeeded")
+			}
+
+			// The receivers and Secret keep the values of the last valid sync.
+			recorder.AssertValue(t, "secret/data/tls", "password", "s3cret")
+			recorder.AssertValue(t, "secret/data/tls", "certificate", testCertificate)
+			if data, ok := agent.Secret("secret/data/tls"); !ok || data["password"] != "s3cret" {
+				t.Fatalf("Secret returned %v", data)
+			}
+
+			if status := pathStatus(t, agent, "secret/data/tls"); !errors.Is(status.LastError, vaultsync.ErrInvalidSecret) {
+				t.Fatalf("status records %v, want ErrInvalidSecret", status.LastError)
+			}
+			errs := handler.get("secret/data/tls")
+			if len(errs) != 1 || !errors.Is(errs[0], vaultsync.ErrInvalidSecret) {
+				t.Fatalf("error handler got %v, want one ErrInvalidSecret", errs)
+			}
+
+			// A valid rotation is dispatched again.
+			s.SetSecret("secret/data/tls", map[string]interface{}{"password": "n3w", "certificate": testCertificate})
+			vaultsynctest.Sync(t, agent)
+			recorder.AssertValue(t, "secret/data/tls", "password", "n3w")
+			if status := pathStatus(t, agent, "secret/data/tls"); status.LastError != nil {
+				t.Fatalf("status records %v after a valid sync", status.LastError)
+			}
+		})
+	}
+}
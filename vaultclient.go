@@ -0,0 +1,89 @@
+package vaultsync
+
+import (
+	"context"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// Client interface is the part of the Vault API the agent uses to authenticate, renew its token and read and write secrets.
+// Health checks, capability lookups, lease revocation and the token lifetime watcher use the Vault client directly.
+type Client interface {
+	Login(ctx context.Context, authMethod vault.AuthMethod) (*vault.Secret, error)
+	LookupSelf(ctx context.Context) (*vault.Secret, error)
+	RenewSelf(ctx context.Context, increment int) (*vault.Secret, error)
+	RevokeSelf(ctx context.Context) error
+	Read(ctx context.Context, path string) (*vault.Secret, error)
+	ReadWithData(ctx context.Context, path string, data map[string][]string) (*vault.Secret, error)
+	Write(ctx context.Context, path string, data map[string]interface{}) (*vault.Secret, error)
+	Patch(ctx context.Context, path string, data map[string]interface{}) (*vault.Secret, error)
+	Delete(ctx context.Context, path string) (*vault.Secret, error)
+}
+
+// WithClient function replaces the client the agent talks to Vault with. wrap receives the client backed by the Vault API
+// and returns the client the agent uses, so it can intercept calls, for example to log or fault inject them,
+// or ignore its argument and return a mock such as vaultsynctest.MockClient.
+func WithClient(wrap func(Client) Client) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.clientWrap = wrap
+	}
+}
+
+// apiClient struct implements Client with the Vault API client.
+type apiClient struct {
+	client *vault.Client
+}
+
+// Login method authenticates with authMethod. The caller sets the token of the client.
+func (c *apiClient) Login(ctx context.Context, authMethod vault.AuthMethod) (*vault.Secret, error) {
+	return c.client.Auth().Login(ctx, authMethod)
+}
+
+// LookupSelf method returns the properties of the client's token.
+func (c *apiClient) LookupSelf(ctx context.Context) (*vault.Secret, error) {
+	return c.client.Auth().Token().LookupSelfWithContext(ctx)
+}
+
+// RenewSelf method renews the client's token by increment seconds, 0 uses the default of the auth mount.
+func (c *apiClient) RenewSelf(ctx context.Context, increment int) (*vault.Secret, error) {
+	return c.client.Auth().Token().RenewSelfWithContext(ctx, increment)
+}
+
+// RevokeSelf method revokes the client's token.
+func (c *apiClient) RevokeSelf(ctx context.Context) error {
+	return c.client.Auth().Token().RevokeSelfWithContext(ctx, "")
+}
+
+// Read method reads a secret path.
+func (c *apiClient) Read(ctx context.Context, path string) (*vault.Secret, error) {
+	return c.client.Logical().ReadWithContext(ctx, path)
+}
+
+// ReadWithData method reads a secret path with query parameters, such as the version of a KV v2 secret.
+func (c *apiClient) ReadWithData(ctx context.Context, path string, data map[string][]string) (*vault.Secret, error) {
+	return c.client.Logical().ReadWithDataWithContext(ctx, path, data)
+}
+
+// Write method writes data to a secret path.
+func (c *apiClient) Write(ctx context.Context, path string, data map[string]interface{}) (*vault.Secret, error) {
+	return c.client.Logical().WriteWithContext(ctx, path, data)
+}
+
+// Patch method applies data as a JSON merge patch to a secret path.
+func (c *apiClient) Patch(ctx context.Context, path string, data map[string]interface{}) (*vault.Secret, error) {
+	return c.client.Logical().JSONMergePatch(ctx, path, data)
+}
+
+// Delete method deletes a secret path.
+func (c *apiClient) Delete(ctx context.Context, path string) (*vault.Secret, error) {
+	return c.client.Logical().DeleteWithContext(ctx, path)
+}
+
+// newClient method wraps a Vault API client in the Client interface and applies WithClient.
+func (a *Agent) newClient(client *vault.Client) Client {
+	var c Client = &apiClient{client: client}
+	if a.clientWrap != nil {
+		c = a.clientWrap(c)
+	}
+	return c
+}
@@ -2,14 +2,21 @@ package vaultsync
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"expvar"
 	"fmt"
+	"io"
 	"log/slog"
+	"net/http"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/hashicorp/hcl/v2/hclsimple"
+	"github.com/hashicorp/go-secure-stdlib/parseutil"
 	vault "github.com/hashicorp/vault/api"
 	"github.com/hashicorp/vault/api/auth/approle"
 	"github.com/hashicorp/vault/api/auth/ldap"
@@ -23,13 +30,64 @@ type config struct {
 
 // vaultConfig struct defines the configuration for connecting to Vault.
 type vaultConfig struct {
-	Server             string `hcl:"server"`
-	AuthMethod         string `hcl:"authmethod"`
-	Username           string `hcl:"username"`
-	Password           string `hcl:"password"`
-	RenewSecretsPeriod int64  `hcl:"renew_secrets_period"`
+	Server             string   `hcl:"server,optional"`
+	AuthMethod         string   `hcl:"authmethod,optional"`
+	Username           string   `hcl:"username,optional"`
+	Password           string   `hcl:"password,optional"`
+	TokenFile          string   `hcl:"token_file,optional"`
+	RenewSecretsPeriod duration `hcl:"renew_secrets_period,optional"`
+	StaleThreshold     duration `hcl:"stale_threshold,optional"`
+	AuditFile          string   `hcl:"audit_file,optional"`
+	RevokeTokenOnStop  bool     `hcl:"revoke_token_on_stop,optional"`
+	RevokeLeasesOnStop bool     `hcl:"revoke_leases_on_stop,optional"`
+	ManageLeases       bool     `hcl:"manage_leases,optional"`
+	StartupPreflight   string   `hcl:"startup_preflight,optional"`
+	StartupAuthTimeout duration `hcl:"startup_auth_timeout,optional"`
+	LazyConnect        bool     `hcl:"lazy_connect,optional"`
+	MaxSecretSize      int      `hcl:"max_secret_size,optional"`
+	MaxSecretKeys      int      `hcl:"max_secret_keys,optional"`
+	MaxSecretAge       duration `hcl:"max_secret_age,optional"`
+	ExpiryWarning      duration `hcl:"expiry_warning,optional"`
+
+	FailoverServers []string `hcl:"failover_servers,optional"`
+	ReadServer      string   `hcl:"read_server,optional"`
+
+	Headers    map[string]string `hcl:"headers,optional"`
+	KVMounts   map[string]int    `hcl:"kv_mounts,optional"`
+	Extractors map[string]string `hcl:"extractors,optional"`
+	Variables  map[string]string `hcl:"variables,optional"`
+
+	SyncSchedule  string            `hcl:"sync_schedule,optional"`
+	PathSchedules map[string]string `hcl:"path_schedules,optional"`
+
+	PluginDirectory string         `hcl:"plugin_directory,optional"`
+	Plugins         []pluginConfig `hcl:"plugin,block"`
+
+	PushServer *pushServerConfig `hcl:"push_server,block"`
+	Admin      *adminConfig      `hcl:"admin,block"`
+
+	Identities []identityConfig `hcl:"identity,block"`
+
+	TokenRenewIncrement duration `hcl:"token_renew_increment,optional"`
+	TokenRenewBehavior  string   `hcl:"token_renew_behavior,optional"`
+	TokenGraceThreshold duration `hcl:"token_grace_threshold,optional"`
+
+	Webhooks    []webhookConfig   `hcl:"webhook,block"`
+	Templates   []templateConfig  `hcl:"template,block"`
+	Execs       []execConfig      `hcl:"exec,block"`
+	Signals     []signalConfig    `hcl:"signal,block"`
+	EnvFiles    []envFileConfig   `hcl:"env_file,block"`
+	Directories []directoryConfig `hcl:"directory,block"`
+	Child       *childConfig      `hcl:"child,block"`
+	Cache       *cacheConfig      `hcl:"cache,block"`
+
+	CircuitBreaker *circuitBreakerConfig `hcl:"circuit_breaker,block"`
+	HTTP           *httpConfig           `hcl:"http,block"`
 }
 
+// ErrSecretNotFound is returned when a secret path does not exist.
+var ErrSecretNotFound = errors.New("secret not found")
+
 // SecretReceiver interface defines the method for updating secrets.
 type SecretReceiver interface {
 	UpdateSecret(id string, filedName string, value interface{})
@@ -47,32 +105,144 @@ type AgentOptFunc func(*AgentOpts)
 type AgentOpts struct {
 	log         *slog.Logger
 	logLevelVar *slog.LevelVar
-	configFile  string
+	logOutput   io.Writer
+	agentName   string
+
+	componentLogLevels map[string]string
+	configFile         string
+	profile            string
+	notifiers          []Notifier
+	metrics            MetricsSink
+
+	valueFingerprints  bool
+	systemdNotify      bool
+	signalHandling     bool
+	revokeTokenOnStop  bool
+	revokeLeasesOnStop bool
+	manageLeases       bool
+	lockedMemory       bool
+	rollbackSync       bool
+	historyValues      bool
+	historySize        int
+	httpClient         *http.Client
+	headers            http.Header
+	timeout            *time.Duration
+	maxRetries         *int
+	clientWrap         func(Client) Client
+	clock              Clock
+	errorHandler       func(path string, err error)
+	driftHandler       func(DriftEvent)
+	kvMounts           map[string]int
+	schedule           string
+	pathSchedules      map[string]string
+
+	dispatchConcurrency int
+	dispatchTimeout     time.Duration
+	pathVars            map[string]string
+	adminAddress        string
+	debugEndpoints      bool
+	identityOpts        []*identity
+	preflightMode       string
+	startupAuthTimeout  time.Duration
+	lazyConnect         bool
+	fieldFileDir        string
+	maxSecretSize       int
+	maxSecretKeys       int
+	maxSecretAge        time.Duration
+	maxAgeHandler       func(path string, age time.Duration)
+	expiryWarning       time.Duration
+}
+
+// authToken struct is the token of the agent, replaced as a whole by every login so the goroutines of the agent never see a torn token.
+type authToken struct {
+	secret *vault.Secret
+	period time.Duration // Period of a periodic token, 0 if the token is not periodic.
 }
 
 // Agent struct represents the Agent with its options and configuration.
 type Agent struct {
 	AgentOpts
-	config     *config
-	client     *vault.Client
-	secret     *vault.Secret
-	secretSync *SecretSync
+	config *config
+	client *vault.Client
+	api    Client
+	token  atomic.Pointer[authToken]
+	// secretValues are the synced values redacted from the logs.
+	secretValues *secretValues
+	authMu       sync.Mutex
+	secretSync   *SecretSync
+	transforms   map[string][]Transform
+	extractors   map[string]Extractor
+	validators   map[string][]Validator
+	fileFields   map[string][]string
+	fieldFiles   fieldFiles
+	sinks        []SecretSink
+	child        *childSink
+	plugins      []*pluginSink
+	identities   []*identity
+	leases       *leaseManager
+	push         *pushServer
+
+	beforeSync   []func(cycleID string)
+	afterSync    []func(summary SyncSummary)
+	authRenewed  []func(info AuthInfo)
+	authEvents   chan AuthEvent
+	expiringSoon []func(path string, remaining time.Duration)
+	changeHooks  map[string][]func(change SecretChange)
+	groups       []*secretGroup
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	done   chan struct{}
+
+	// notifying tracks the notifier goroutines, stopNotify cancels them if they outlast the shutdown.
+	notifying  sync.WaitGroup
+	notifyCtx  context.Context
+	stopNotify context.CancelFunc
+
+	mu          sync.RWMutex
+	paths       map[string]*pathState
+	auditLog    []RotationEvent
+	lastCycle   SyncSummary
+	synced      chan struct{}
+	ready       chan struct{}
+	isReady     bool
+	cached      map[string]cacheEntry
+	breaker     *circuitBreaker
+	dispatching map[receiverKey]bool
+	scheduler   *scheduler
+	reauth      chan struct{}
+	resync      chan struct{}
+	// expiryChanged wakes up the expiry goroutine when the expiry of a secret changed.
+	expiryChanged chan struct{}
+	paused        bool
+
+	// logs are the loggers of the components of the agent, see WithComponentLogLevel.
+	logs            componentLoggers
+	componentLevels map[string]*componentLevel
+
+	// stats and loops are shown by the debug endpoints.
+	stats expvar.Map
+	loops expvar.Map
 }
 
 // defaultAgentOpts function creates default options for the Agent.
 func defaultAgentOpts() AgentOpts {
 	agentOpts := AgentOpts{}
 
-	// Logging
+	// Logging, the logger is built by New once all options are applied.
 	agentOpts.logLevelVar = &slog.LevelVar{}
-	loggerOpts := &slog.HandlerOptions{
-		Level: agentOpts.logLevelVar,
-	}
 	agentOpts.logLevelVar.Set(slog.LevelDebug) // Set debug as default.
-	agentOpts.log = slog.New(slog.NewTextHandler(os.Stdout, loggerOpts))
+	agentOpts.logOutput = os.Stdout
 
 	// default vault config file
-	agentOpts.configFile = "vault-config.hcl"
+	agentOpts.configFile = defaultConfigFile
+
+	// Metrics are discarded unless a sink is configured.
+	agentOpts.metrics = nopMetricsSink{}
+
+	agentOpts.clock = systemClock{}
+
+	agentOpts.historySize = defaultHistorySize
 
 	return agentOpts
 }
@@ -84,14 +254,24 @@ func WithConfigFile(file string) AgentOptFunc {
 	}
 }
 
-// WithLogger function sets the logger.
+// WithLogger function sets the logger. Its handler decides the format and output of the logs, WithLogOutput is ignored.
+// The level of WithLogLevel and SetLogLevel applies on top of the level of the handler, so a record is logged only if
+// both enable it.
 func WithLogger(log *slog.Logger) AgentOptFunc {
 	return func(opts *AgentOpts) {
 		opts.log = log
 	}
 }
 
-// WithLogLevel function sets the log level.
+// WithLogOutput function sets where the default text logger writes, os.Stdout if not set. It has no effect with WithLogger.
+func WithLogOutput(w io.Writer) AgentOptFunc {
+	return func(opts *AgentOpts) {
+		opts.logOutput = w
+	}
+}
+
+// WithLogLevel function sets the log level, debug if not set. It applies to the default logger and to the logger of
+// WithLogger alike, whatever the order of the options. SetLogLevel changes it while the agent runs.
 func WithLogLevel(logLevel string) AgentOptFunc {
 	return func(opts *AgentOpts) {
 		switch strings.ToUpper(logLevel) {
@@ -111,6 +291,16 @@ func WithLogLevel(logLevel string) AgentOptFunc {
 	}
 }
 
+// SetLogLevel method changes the log level of the running agent.
+func (a *Agent) SetLogLevel(level slog.Level) {
+	a.logLevelVar.Set(level)
+}
+
+// LogLevel method returns the current log level of the agent.
+func (a *Agent) LogLevel() slog.Level {
+	return a.logLevelVar.Level()
+}
+
 // newSecretSync function creates a new SecretSync.
 func newSecretSync() *SecretSync {
 	return &SecretSync{
@@ -118,22 +308,36 @@ func newSecretSync() *SecretSync {
 	}
 }
 
+// Logger method returns the logger of the agent. Synced secret values are redacted from the records logged with it.
+func (a *Agent) Logger() *slog.Logger {
+	return a.log
+}
+
 // RegisterUpdateSecret method registers a secret receiver.
 func (a *Agent) RegisterUpdateSecret(id string, receiver SecretReceiver) {
+	id = a.expandPath(id)
 	a.secretSync.receivers[id] = append(a.secretSync.receivers[id], receiver)
-}
-
-// setSecret method sets a secret value for a receiver.
-func (a *Agent) setSecret(id string, fieldName string, value interface{}) {
-	for _, receiver := range a.secretSync.receivers[id] {
-		receiver.UpdateSecret(id, fieldName, value)
-	}
+	a.trackPath(id)
 }
 
 // New function creates a new Vault sync agent with provided options.
 func New(opts ...AgentOptFunc) (*Agent, error) {
 	agent := &Agent{}
 	agent.secretSync = newSecretSync()
+	agent.paths = make(map[string]*pathState)
+	agent.transforms = make(map[string][]Transform)
+	agent.extractors = make(map[string]Extractor)
+	agent.validators = make(map[string][]Validator)
+	agent.fileFields = make(map[string][]string)
+	agent.changeHooks = make(map[string][]func(change SecretChange))
+	agent.secretValues = newSecretValues(nil)
+	agent.notifyCtx, agent.stopNotify = context.WithCancel(context.Background())
+	agent.synced = make(chan struct{})
+	agent.ready = make(chan struct{})
+	agent.reauth = make(chan struct{}, 1)
+	agent.resync = make(chan struct{}, 1)
+	agent.expiryChanged = make(chan struct{}, 1)
+	agent.leases = newLeaseManager()
 	var err error
 
 	agentOpts := defaultAgentOpts()
@@ -142,7 +346,14 @@ func New(opts ...AgentOptFunc) (*Agent, error) {
 	}
 	agent.AgentOpts = agentOpts
 
-	agent.log.Info("NewAgent", slog.String("config file", agent.configFile))
+	if agent.log == nil {
+		agent.log = slog.New(slog.NewTextHandler(agent.logOutput, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+	if err := agent.newLoggers(agent.log.Handler()); err != nil {
+		return nil, err
+	}
+
+	agent.log.Info("NewAgent", slog.String("config file", agent.configFile), slog.String("profile", agent.selectedProfile()))
 
 	// Load configuration from file
 	err = agent.loadConfig(agent.configFile)
@@ -152,113 +363,338 @@ func New(opts ...AgentOptFunc) (*Agent, error) {
 
 	agent.log.Debug("NewAgent", slog.Any("config", agent.config))
 
+	agent.config.setDefaults()
+	err = agent.config.validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration file %v:%v", agent.configFile, err)
+	}
+
+	err = agent.applyConfig()
+	if err != nil {
+		return nil, err
+	}
+
 	// Create vault agent and auhtenticate
 	err = agent.createVaultAgent()
 	if err != nil {
-		return nil, fmt.Errorf("authentication failed:%v", err)
+		// With a cache, or in lazy connect mode, the agent can start while Vault is unreachable and logs in later.
+		lazy := agent.lazyConnectOf() && classifyError(err).retryable()
+		if agent.client == nil || (agent.config.Vault.Cache == nil && !lazy) {
+			agent.closePlugins()
+			return nil, fmt.Errorf("authentication failed:%v", err)
+		}
+		if agent.config.Vault.Cache != nil {
+			if cacheErr := agent.loadCache(); cacheErr != nil {
+				if !lazy {
+					agent.closePlugins()
+					return nil, fmt.Errorf("authentication failed:%v, cache unavailable:%v", err, cacheErr)
+				}
+				agent.log.Warn("NewAgent", slog.String("status", "cache unavailable"), slog.Any("error", cacheErr))
+			}
+		}
+		if agent.cached != nil {
+			agent.log.Warn("NewAgent", slog.String("status", "authentication failed, starting from cache"), slog.Any("error", err))
+		} else {
+			agent.log.Warn("NewAgent", slog.String("status", "authentication failed, connecting in the background"), slog.Any("error", err))
+		}
 	}
 
 	return agent, nil
 }
 
 // Run method starts the Agent. Once Run returns secrets should be available by the caller.
+// The background goroutines stop when ctx is cancelled or Stop is called. If wg is not nil it is
+// done once they have all stopped, Wait can be used instead.
 func (a *Agent) Run(ctx context.Context, wg *sync.WaitGroup) error {
+	if err := a.startupPreflight(ctx); err != nil {
+		return err
+	}
+	if err := a.listenPush(); err != nil {
+		return err
+	}
+	adminListener, err := a.listenAdmin()
+	if err != nil {
+		if a.push != nil {
+			a.push.listener.Close()
+		}
+		return err
+	}
+	ctx, a.cancel = context.WithCancel(ctx)
+	stopSignals := a.handleSignals(ctx)
+	a.done = make(chan struct{})
+	offline := a.token.Load() == nil
+	a.leases.start()
 
-	wg.Add(2)
-	go a.renewAuthToken(ctx, wg)
-	go a.renewSecrets(ctx, wg)
+	a.wg.Add(1)
+	go a.renewAuthToken(ctx, &a.wg)
 
 	// Update all registered secret paths before returning to the caller.
 	// This should make sure that variables in all registred structs has a vaule
-	// after Run() returns.
-	a.renewSecretPaths()
+	// after Run() returns. Paths that fail are retried in the background, use WaitReady
+	// to block until they have been synced.
+	if offline {
+		if a.cached != nil {
+			a.startFromCache(a.log)
+		}
+	} else {
+		summary := a.renewSecretPaths(ctx)
+		if summary.Failed > 0 {
+			a.log.Warn("Run", slog.String("status", "not all secret paths synced"), slog.Int("failed", summary.Failed))
+		}
+		// An agent without paths is ready once it has logged in.
+		a.mu.Lock()
+		a.checkReady()
+		a.mu.Unlock()
+	}
+
+	// The sync loop starts after the initial sync, so its first wait includes the retries of the paths that failed.
+	a.wg.Add(1)
+	go a.renewSecrets(ctx, &a.wg)
+
+	if len(a.expiringSoon) > 0 {
+		a.wg.Add(1)
+		go a.watchExpiry(ctx, &a.wg)
+	}
+	if a.push != nil {
+		a.wg.Add(1)
+		go a.servePush(ctx, &a.wg)
+	}
+	if adminListener != nil {
+		a.wg.Add(1)
+		go a.serveAdmin(ctx, &a.wg, adminListener)
+	}
+
+	if a.systemdNotify {
+		a.notifySystemdReady(ctx, &a.wg)
+	}
+
+	if wg != nil {
+		wg.Add(1)
+	}
+	go func() {
+		a.wg.Wait()
+		stopSignals()
+		a.shutdown()
+		a.closeAuthEvents()
+		a.log.Info("Run", slog.String("status", "stopped"))
+		close(a.done)
+		if wg != nil {
+			wg.Done()
+		}
+	}()
 
 	return nil
 }
 
-// loadConfig method loads vault agent configuration from the given filename.
+// SyncOnce method syncs all registered paths once, dispatching to receivers and flushing sinks, without
+// starting the background renewal. It is meant for cron jobs and init containers. If any path fails to sync
+// SyncOnce returns a *SyncError holding the error of each failed path.
+func (a *Agent) SyncOnce(ctx context.Context) error {
+	summary := a.renewSecretPaths(ctx)
+	if summary.Failed > 0 {
+		return &SyncError{Errors: summary.Errors}
+	}
+	return nil
+}
+
+// loadConfig method loads vault agent configuration from the given filename. If the default configuration file
+// does not exist the agent runs without one, with the settings taken from the environment.
 func (a *Agent) loadConfig(filename string) error {
-	_, err := os.Stat(filename)
-	if !os.IsNotExist(err) {
-		a.config = &config{}
-		err := hclsimple.DecodeFile(filename, nil, a.config)
-		if err != nil {
-			return err
+	a.config = &config{}
+	if _, err := os.Stat(filename); err != nil {
+		if os.IsNotExist(err) && filename == defaultConfigFile && a.selectedProfile() == "" {
+			a.log.Info("loadConfig", slog.String("status", "no configuration file, using defaults"))
+			return nil
 		}
+		return err
 	}
-
-	return nil
+	return decodeConfigFile(filename, a.selectedProfile(), a.config)
 }
 
 // createVaultAgent creates as vault agent and handles authentication.
-// Possible values for authMethod is: "approle", "ldap", "userpass".
+// Possible values for authMethod is: "approle", "ldap", "userpass", "token", "token_file", "agent_proxy".
 // If the authentication method is "approle", then username contains the role_id and the password the secret_id.
+// If the authentication method is "token", the token is read from VAULT_TOKEN or the token helper of the Vault CLI.
+// If the authentication method is "token_file", the token is read from token_file, the file sink of a Vault Agent.
+// If the authentication method is "agent_proxy", server is the API proxy of a Vault Agent that adds its token to the requests.
 func (a *Agent) createVaultAgent() error {
 	var err error
 
 	// Create vault client
-	a.client, err = vault.NewClient(&vault.Config{
-		Address: a.config.Vault.Server,
-	})
+	cfg, err := a.newVaultConfig()
 	if err != nil {
 		return err
 	}
+	a.client, err = vault.NewClient(cfg)
+	if err != nil {
+		return err
+	}
+	a.addHeaders()
+	a.api = a.newClient(a.client)
 
 	// Authenticate against vault and get an authentication token.
-	switch a.config.Vault.AuthMethod {
+	return a.startupLogin(context.TODO())
+}
+
+// authMethod method creates the vault authentication method configured by authmethod.
+func (a *Agent) authMethod() (vault.AuthMethod, error) {
+	v := a.config.Vault
+	authMethod, err := newAuthMethod(v.AuthMethod, v.Username, v.Password, v.TokenFile)
+	if err != nil {
+		a.logs.auth.Error("authMethod", slog.String("error", "undefined vault authentication method"))
+	}
+	return authMethod, err
+}
+
+// newAuthMethod function creates a vault authentication method from its name and credentials.
+func newAuthMethod(method string, username string, password string, tokenFile string) (vault.AuthMethod, error) {
+	switch method {
 	case "approle":
-		authMethod, err := approle.NewAppRoleAuth(a.config.Vault.Username, &approle.SecretID{FromString: a.config.Vault.Password})
-		if err != nil {
-			return err
-		}
-		a.secret, err = a.client.Auth().Login(context.TODO(), authMethod)
-		if err != nil {
-			return err
-		}
-		a.log.Info("createVaultAgent", slog.String("AuthMethod", "approle"))
+		return approle.NewAppRoleAuth(username, &approle.SecretID{FromString: password})
 
 	case "ldap":
-		authMethod, err := ldap.NewLDAPAuth(a.config.Vault.Username, &ldap.Password{FromString: a.config.Vault.Password})
-		if err != nil {
-			return err
-		}
-		a.secret, err = a.client.Auth().Login(context.TODO(), authMethod)
-		if err != nil {
-			return err
-		}
-		a.log.Info("createVaultAgent", slog.String("AuthMethod", "ldap"))
+		return ldap.NewLDAPAuth(username, &ldap.Password{FromString: password})
 
 	case "userpass":
-		authMethod, err := userpass.NewUserpassAuth(a.config.Vault.Username, &userpass.Password{FromString: a.config.Vault.Password})
-		if err != nil {
-			return err
-		}
-		a.secret, err = a.client.Auth().Login(context.TODO(), authMethod)
-		if err != nil {
-			return err
-		}
-		a.log.Info("createVaultAgent", slog.String("AuthMethod", "userpass"))
+		return userpass.NewUserpassAuth(username, &userpass.Password{FromString: password})
+
+	case "token":
+		return &tokenAuth{}, nil
+
+	case "token_file":
+		return &tokenFileAuth{filename: tokenFile}, nil
 
 	default:
-		a.log.Error("createVaultAgent", slog.String("error", "undefined vault authentication method"))
-		return fmt.Errorf("undefined vault authentication method")
+		return nil, fmt.Errorf("undefined vault authentication method")
+	}
+}
+
+// login method authenticates against vault and sets the token of the client.
+// Logins are serialized, the token renewal and a failover may log in at the same time.
+func (a *Agent) login(ctx context.Context) error {
+	a.authMu.Lock()
+	defer a.authMu.Unlock()
+
+	if a.config.Vault.AuthMethod == "agent_proxy" {
+		// The Vault Agent API proxy adds its own token to the requests.
+		a.client.ClearToken()
+		a.token.Store(&authToken{secret: &vault.Secret{Auth: &vault.SecretAuth{}}})
+		a.logs.auth.Info("login", slog.String("AuthMethod", "agent_proxy"))
+		return nil
+	}
+
+	authMethod, err := a.authMethod()
+	if err != nil {
+		return err
+	}
+
+	if err := a.breaker.allow(); err != nil {
+		return err
+	}
+	secret, err := a.api.Login(ctx, authMethod)
+	a.recordVaultCall(err)
+	if err != nil {
+		return err
+	}
+	if secret == nil || secret.Auth == nil {
+		return fmt.Errorf("login returned no authentication token")
 	}
 
-	token, err := a.secret.TokenID()
+	token, err := secret.TokenID()
 	if err != nil {
 		return err
 	}
 	a.client.SetToken(token)
+	// Only the auth of the login response is kept, the watcher of the token needs nothing else.
+	current := &authToken{secret: &vault.Secret{Auth: secret.Auth}}
+	if secret.Auth.Renewable {
+		current.period = a.tokenPeriod(ctx)
+	}
+	a.token.Store(current)
+	a.logs.auth.Info("login", slog.String("AuthMethod", a.config.Vault.AuthMethod), slog.Bool("renewable", secret.Auth.Renewable), slog.Duration("period", current.period))
 
 	return nil
 }
 
-// renewAuthToken method renews the authentication token.
+// renewAuthToken method keeps the authentication token valid. Renewable tokens are renewed until renewal fails or
+// the token reaches its max TTL, non-renewable tokens such as batch tokens are replaced before they expire.
+// In both cases the agent logs in again.
 func (a *Agent) renewAuthToken(ctx context.Context, wg *sync.WaitGroup) error {
 	defer wg.Done()
 
+	for {
+		// There is no token yet if the agent started from its cache.
+		if token := a.token.Load(); token != nil {
+			a.setLoopState(loopRenewAuth, loopStateRenewing)
+			var err error
+			switch {
+			case a.config.Vault.AuthMethod == "token_file":
+				err = a.watchTokenFile(ctx)
+			case token.period > 0:
+				err = a.renewPeriodicToken(ctx, token)
+			case token.secret.Auth.Renewable:
+				err = a.watchAuthToken(ctx, token)
+			default:
+				err = a.waitAuthTokenExpiry(ctx, token)
+			}
+			if ctx.Err() != nil {
+				a.logs.auth.Info("renewAuthToken", slog.String("status", "cancel"))
+				return nil
+			}
+			if a.token.Load() != token {
+				// A failover logged in to another server, renew the new token.
+				continue
+			}
+			if err != nil {
+				// Leases created by a token get revoked when the token is revoked.
+				a.metrics.IncrCounter(metricTokenRenewFails, 1, nil)
+				a.logs.auth.Info("renewAuthToken", slog.String("status", "renewal of auth token failed"), slog.Any("error", err))
+			}
+			a.logs.auth.Info("renewAuthToken", slog.String("status", "logging in again"))
+		}
+
+		for {
+			a.setLoopState(loopRenewAuth, loopStateLogin)
+			err := a.login(ctx)
+			if err == nil {
+				a.loggedInAgain()
+				break
+			}
+			a.logs.auth.Error("renewAuthToken", slog.String("status", "login failed"), slog.Any("error", err))
+			a.setLoopState(loopRenewAuth, loopStateRetry)
+			select {
+			case <-ctx.Done():
+				a.logs.auth.Info("renewAuthToken", slog.String("status", "cancel"))
+				return nil
+			case <-after(a.clock, loginRetryInterval):
+			}
+		}
+	}
+}
+
+// loggedInAgain method runs the auth renewed hooks after the agent got a new token and asks for a sync of all paths.
+// Policies or secrets may have changed across the login, and an agent that started from its cache or without Vault
+// has not synced yet, so the paths are not left until the next renew period.
+func (a *Agent) loggedInAgain() {
+	auth := a.token.Load().secret.Auth
+	a.runAuthRenewed(true, auth.Renewable, auth.LeaseDuration)
+	a.requestSync()
+}
+
+// loginRetryInterval is the time between login attempts after the token could not be renewed.
+const loginRetryInterval = 10 * time.Second
+
+// watchAuthToken method renews a renewable token with a lifetime watcher. It returns when the context is done or
+// when the token can no longer be renewed.
+func (a *Agent) watchAuthToken(ctx context.Context, token *authToken) error {
+	behavior, err := parseRenewBehavior(a.config.Vault.TokenRenewBehavior)
+	if err != nil {
+		return err
+	}
 	authTokenWatcher, err := a.client.NewLifetimeWatcher(&vault.LifetimeWatcherInput{
-		Secret: a.secret,
+		Secret:        token.secret,
+		Increment:     int(a.config.Vault.TokenRenewIncrement.value().Seconds()),
+		RenewBehavior: behavior,
 	})
 	if err != nil {
 		return fmt.Errorf("unable to initialize auth token lifetime watcher: %w", err)
@@ -266,12 +702,15 @@ func (a *Agent) renewAuthToken(ctx context.Context, wg *sync.WaitGroup) error {
 
 	go authTokenWatcher.Start()
 	defer authTokenWatcher.Stop()
+	expires := a.clock.Now().Add(time.Duration(token.secret.Auth.LeaseDuration) * time.Second)
 
 	// monitor events from watcher
 	for {
 		select {
 		case <-ctx.Done():
-			a.log.Info("renewAuthToken", slog.String("status", "cancel"))
+			return nil
+
+		case <-a.reauth:
 			return nil
 
 		// DoneCh will return if renewal fails, or if the remaining lease
@@ -280,46 +719,309 @@ func (a *Agent) renewAuthToken(ctx context.Context, wg *sync.WaitGroup) error {
 		// should attempt a re-read of the secret. Clients should check the
 		// return value of the channel to see if renewal was successful.
 		case err := <-authTokenWatcher.DoneCh():
-			// Leases created by a token get revoked when the token is revoked.
-			a.log.Info("renewAuthToken", slog.String("status", "renewal of auth token failed"), slog.Any("error", err))
+			a.authExpiring(token.secret.Auth.Renewable, expires.Sub(a.clock.Now()), err)
 			return err
 
 		// RenewCh is a channel that receives a message when a successful
 		// renewal takes place and includes metadata about the renewal.
 		case info := <-authTokenWatcher.RenewCh():
-			a.log.Info("renewAuthToken", slog.String("status", "renewed"), slog.Any("remaining duration", info.Secret.Auth.LeaseDuration))
+			a.metrics.IncrCounter(metricTokenRenewals, 1, nil)
+			a.logs.auth.Info("renewAuthToken", slog.String("status", "renewed"), slog.Any("remaining duration", info.Secret.Auth.LeaseDuration))
+			a.runAuthRenewed(false, info.Secret.Auth.Renewable, info.Secret.Auth.LeaseDuration)
+			expires = a.clock.Now().Add(time.Duration(info.Secret.Auth.LeaseDuration) * time.Second)
+			if a.belowGraceThreshold(info.Secret.Auth.LeaseDuration) {
+				a.logs.auth.Info("renewAuthToken", slog.String("status", "remaining duration below grace threshold"))
+				a.authExpiring(info.Secret.Auth.Renewable, expires.Sub(a.clock.Now()), nil)
+				return nil
+			}
+		}
+	}
+}
+
+// tokenPeriod method looks up the period of the current token, it returns 0 if the token is not periodic or the lookup fails.
+func (a *Agent) tokenPeriod(ctx context.Context) time.Duration {
+	secret, err := a.api.LookupSelf(ctx)
+	if err != nil || secret == nil {
+		a.logs.auth.Warn("tokenPeriod", slog.String("status", "token lookup failed"), slog.Any("error", err))
+		return 0
+	}
+	period, err := parseutil.ParseDurationSecond(secret.Data["period"])
+	if err != nil {
+		return 0
+	}
+	return period
+}
+
+// renewPeriodicToken method renews a periodic token every half period. Periodic tokens have no max TTL,
+// so they are renewed for as long as the agent runs. It returns when the context is done or renewal fails.
+func (a *Agent) renewPeriodicToken(ctx context.Context, token *authToken) error {
+	expires := a.clock.Now().Add(time.Duration(token.secret.Auth.LeaseDuration) * time.Second)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-a.reauth:
+			return nil
+		case <-after(a.clock, token.period/2):
+		}
+
+		if err := a.breaker.allow(); err != nil {
+			// Keep the token, it is renewed at the next half period.
+			a.logs.auth.Warn("renewAuthToken", slog.Any("error", err))
+			continue
+		}
+		secret, err := a.api.RenewSelf(ctx, int(a.config.Vault.TokenRenewIncrement.value().Seconds()))
+		a.recordVaultCall(err)
+		if err == nil && (secret == nil || secret.Auth == nil) {
+			err = fmt.Errorf("token renewal returned no authentication data")
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				a.authExpiring(true, expires.Sub(a.clock.Now()), err)
+			}
+			return err
+		}
+		a.metrics.IncrCounter(metricTokenRenewals, 1, nil)
+		a.logs.auth.Info("renewAuthToken", slog.String("status", "renewed"), slog.Any("remaining duration", secret.Auth.LeaseDuration))
+		a.runAuthRenewed(false, secret.Auth.Renewable, secret.Auth.LeaseDuration)
+		expires = a.clock.Now().Add(time.Duration(secret.Auth.LeaseDuration) * time.Second)
+	}
+}
+
+// belowGraceThreshold method reports whether a token with leaseDuration seconds remaining should be replaced by logging in again.
+func (a *Agent) belowGraceThreshold(leaseDuration int) bool {
+	grace := a.config.Vault.TokenGraceThreshold.value()
+	return grace > 0 && time.Duration(leaseDuration)*time.Second < grace
+}
+
+// waitAuthTokenExpiry method waits until two thirds of the TTL of a non-renewable token, such as a batch token, have passed,
+// or until only token_grace_threshold seconds remain.
+// Such tokens cannot be renewed, so the agent logs in again instead.
+func (a *Agent) waitAuthTokenExpiry(ctx context.Context, token *authToken) error {
+	ttl := time.Duration(token.secret.Auth.LeaseDuration) * time.Second
+	if ttl <= 0 {
+		// Tokens without TTL, such as root tokens, never expire.
+		select {
+		case <-ctx.Done():
+		case <-a.reauth:
 		}
+		return nil
+	}
+
+	wait := ttl * 2 / 3
+	if grace := a.config.Vault.TokenGraceThreshold.value(); grace > 0 && grace < ttl {
+		wait = ttl - grace
 	}
+	a.logs.auth.Info("renewAuthToken", slog.String("status", "token not renewable, login scheduled"), slog.Duration("in", wait))
+	select {
+	case <-ctx.Done():
+	case <-a.reauth:
+	case <-after(a.clock, wait):
+		a.authExpiring(false, ttl-wait, nil)
+	}
+	return nil
 }
 
 // renewSecretPaths reads secrets from vault and then executes the registerd update secrets functions for each vault secret.
-func (a *Agent) renewSecretPaths() {
-	for path := range a.secretSync.receivers {
-		secret, _ := a.client.Logical().Read(path)
-		for key, value := range secret.Data["data"].(map[string]interface{}) {
-			a.setSecret(path, key, value)
+// Every cycle gets a correlation ID that is attached to all log records of the cycle.
+func (a *Agent) renewSecretPaths(ctx context.Context) SyncSummary {
+	return a.syncPaths(ctx, a.trackedPaths())
+}
+
+// syncPaths method runs a sync cycle for paths.
+func (a *Agent) syncPaths(ctx context.Context, paths []string) SyncSummary {
+	summary := SyncSummary{
+		CycleID: newCycleID(),
+		Start:   a.clock.Now(),
+		Errors:  make(map[string]error),
+	}
+	logs := a.logs.with(slog.String("cycle", summary.CycleID))
+	log := logs.scheduler
+	paths = a.withGroupMembers(paths)
+
+	a.runBeforeSync(summary.CycleID)
+	a.metrics.IncrCounter(metricSyncCycles, 1, nil)
+	defer a.reportStalePaths()
+	defer a.reportSecretAges(log)
+
+	a.checkFailover(ctx, log)
+
+	for _, path := range paths {
+		rotated, err := a.syncPath(ctx, summary.CycleID, logs, path)
+		if err != nil {
+			summary.Failed++
+			summary.Errors[path] = err
+			if a.errorHandler != nil {
+				a.errorHandler(path, err)
+			}
+			continue
+		}
+		summary.Fetched++
+		if rotated {
+			summary.Changed++
 		}
-		a.log.Info("renewSecrets", slog.String("secret-path", path), slog.Any("seconds until next renew secret", a.config.Vault.RenewSecretsPeriod))
 	}
+
+	a.dispatchGroups(logs.dispatcher, paths, summary.Errors)
+	a.flushSinks(logs.sinks)
+	if summary.Fetched > 0 {
+		a.writeCache(log)
+	}
+
+	summary.Duration = a.clock.Now().Sub(summary.Start)
+	a.recordSummary(summary)
+	a.stats.Add("cycles", 1)
+	a.stats.Add("paths_fetched", int64(summary.Fetched))
+	a.stats.Add("paths_changed", int64(summary.Changed))
+	a.stats.Add("paths_failed", int64(summary.Failed))
+	log.Info("renewSecretPaths", slog.Int("fetched", summary.Fetched), slog.Int("changed", summary.Changed), slog.Int("failed", summary.Failed), slog.Duration("duration", summary.Duration))
+	a.runAfterSync(summary)
+
+	return summary
+}
+
+// syncPath method reads a secret path and dispatches its fields to the registered receivers, logging with the loggers of the cycle.
+// It returns true if the secret was rotated since the previous sync.
+func (a *Agent) syncPath(ctx context.Context, cycleID string, logs componentLoggers, path string) (bool, error) {
+	log := logs.fetcher
+	if err := a.breaker.allow(); err != nil {
+		a.recordSyncError(path, err)
+		log.Warn("renewSecrets", slog.String("secret-path", path), slog.Any("error", err))
+		return false, err
+	}
+
+	start := a.clock.Now()
+	secret, err := a.readSecret(ctx, log, path)
+	a.metrics.ObserveTiming(metricFetchDuration, a.clock.Now().Sub(start), pathLabels(path))
+	a.recordVaultCall(err)
+	if err == nil && secret == nil {
+		err = ErrSecretNotFound
+	}
+	if err != nil {
+		a.metrics.IncrCounter(metricFetchErrors, 1, errorLabels(path, err))
+		a.recordSyncError(path, err)
+		log.Error("renewSecrets", slog.String("secret-path", path), slog.Any("error", err))
+		return false, err
+	}
+	if secret.LeaseID != "" {
+		a.recordLease(path, secret.LeaseID)
+	}
+
+	data, err := a.secretData(path, secret)
+	if err == nil {
+		err = a.checkLimits(data)
+	}
+	if err != nil {
+		a.metrics.IncrCounter(metricFetchErrors, 1, errorLabels(path, err))
+		a.recordSyncError(path, err)
+		log.Error("renewSecrets", slog.String("secret-path", path), slog.Any("error", err))
+		return false, err
+	}
+
+	data, err = a.transform(path, data)
+	if err == nil {
+		if err = a.validate(path, data); err != nil {
+			a.metrics.IncrCounter(metricInvalidSecrets, 1, pathLabels(path))
+		}
+	}
+	if err != nil {
+		a.recordSyncError(path, err)
+		log.Error("renewSecrets", slog.String("secret-path", path), slog.Any("error", err))
+		return false, err
+	}
+
+	stored, err := a.sealData(data)
+	if err != nil {
+		a.recordSyncError(path, err)
+		log.Error("renewSecrets", slog.String("secret-path", path), slog.Any("error", err))
+		return false, err
+	}
+
+	a.checkDrift(cycleID, logs.dispatcher, path)
+	changes, rotated := a.detectChanges(path, data)
+	changed := changedFields(changes)
+	if a.valueFingerprints {
+		for key, value := range data {
+			log.Debug("renewSecrets", slog.String("secret-path", path), slog.String("field", key), slog.String("fingerprint", shortFingerprint(value)))
+		}
+	}
+	version := secretVersion(secret)
+	a.dispatch(logs.dispatcher, path, data, version, deletedFields(changed, data))
+	a.recordSync(path, version, stored)
+	a.recordChange(path, secretCreatedTime(secret), rotated)
+	a.recordExpiry(path, secretExpiry(secret, a.clock.Now()))
+	a.manageLease(ctx, log, path, secret)
+	a.recordHistory(path, version, changed, rotated, data)
+	a.publishSync(logs.dispatcher, path, version, data)
+	if rotated {
+		a.metrics.IncrCounter(metricRotations, 1, pathLabels(path))
+		event := a.recordRotation(cycleID, path, changed, version)
+		a.notifyRotation(event)
+		a.runExecHooks(logs.dispatcher, event)
+		a.runSignalHooks(logs.dispatcher, event)
+		a.runChangeHooks(event, changes)
+	}
+	log.Info("renewSecrets", slog.String("secret-path", path), slog.Duration("time until next renew secret", a.scheduler.interval(path, a.clock.Now())))
+
+	return rotated, nil
+}
+
+// newCycleID function returns a random correlation ID for a sync cycle.
+func newCycleID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
 }
 
 // renewSecrets method renew secrets periodically.
 func (a *Agent) renewSecrets(ctx context.Context, wg *sync.WaitGroup) error {
 	defer wg.Done()
 
-	sleepDuration := time.Duration(a.config.Vault.RenewSecretsPeriod) * time.Second
-	timer := time.NewTimer(sleepDuration)
+	a.scheduler.start(a.clock.Now())
+	timer := a.clock.NewTimer(a.nextWait(a.clock.Now()))
 
 	for {
+		a.setLoopState(loopRenewSecrets, loopStateWaiting)
 		select {
 		case <-ctx.Done():
-			a.log.Info("reneswSecrets", slog.String("status", "cancel"))
+			a.logs.scheduler.Info("reneswSecrets", slog.String("status", "cancel"))
 			return nil
 
-		case <-timer.C:
-			a.renewSecretPaths()
+		case <-timer.C():
+			now := a.clock.Now()
+			due := a.leases.unmanaged(a.scheduler.due(now, a.trackedPaths()))
+			// Retries are only taken while the syncs run, so the paths that failed are retried once they resume.
+			if !a.Paused() {
+				if paths := mergePaths(due, a.retryDue(now)); len(paths) > 0 {
+					a.setLoopState(loopRenewSecrets, loopStateSyncing)
+					a.syncPaths(ctx, paths)
+				}
+			}
 			// Reset the timer for the next iteration
-			timer.Reset(sleepDuration)
+			timer.Reset(a.nextWait(a.clock.Now()))
+
+		case <-a.leases.wake:
+			if paths := a.leases.takeExpired(); len(paths) > 0 && !a.Paused() {
+				a.setLoopState(loopRenewSecrets, loopStateSyncing)
+				a.syncPaths(ctx, paths)
+			}
+
+		case <-a.resync:
+			timer.Stop()
+			a.setLoopState(loopRenewSecrets, loopStateSyncing)
+			a.renewSecretPaths(ctx)
+			a.scheduler.restart(a.clock.Now())
+			timer.Reset(a.nextWait(a.clock.Now()))
 		}
 	}
 }
+
+// requestSync method asks the running agent to start a sync cycle now instead of at the end of the renew period.
+func (a *Agent) requestSync() {
+	select {
+	case a.resync <- struct{}{}:
+	default:
+	}
+}
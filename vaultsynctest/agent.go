@@ -0,0 +1,147 @@
+package vaultsynctest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/pergus/vaultsync"
+)
+
+// WriteConfig method writes a configuration file for an agent that logs in to the fake with approle,
+// and returns its name. extra is added to the config block, for example template or exec blocks.
+func (s *Server) WriteConfig(dir string, renewSecretsPeriod int, extra string) (string, error) {
+	return writeConfig(dir, s.URL, renewSecretsPeriod, extra)
+}
+
+// writeConfig function writes a configuration file for an agent that logs in to server with approle.
+func writeConfig(dir string, server string, renewSecretsPeriod int, extra string) (string, error) {
+	filename := filepath.Join(dir, "vaultsync.hcl")
+	config := fmt.Sprintf(`config {
+  server               = %q
+  authmethod           = "approle"
+  username             = %q
+  password             = %q
+  renew_secrets_period = %d
+%s
+}
+`, server, Username, Password, renewSecretsPeriod, extra)
+	return filename, os.WriteFile(filename, []byte(config), 0600)
+}
+
+// NewAgent function creates an agent logged in to the fake. The agent logs nothing unless a logger is passed in opts.
+// Call SyncOnce on the agent after changing secrets on the server to dispatch them without waiting for a renew period.
+func NewAgent(t testing.TB, s *Server, opts ...vaultsync.AgentOptFunc) *vaultsync.Agent {
+	t.Helper()
+
+	filename, err := s.WriteConfig(t.TempDir(), 3600, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newAgent(t, filename, opts)
+}
+
+// newAgent function creates an agent from a configuration file that logs nothing unless a logger is passed in opts.
+func newAgent(t testing.TB, filename string, opts []vaultsync.AgentOptFunc) *vaultsync.Agent {
+	t.Helper()
+
+	opts = append([]vaultsync.AgentOptFunc{
+		vaultsync.WithConfigFile(filename),
+		vaultsync.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	}, opts...)
+	agent, err := vaultsync.New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return agent
+}
+
+// Recorder struct is a secret receiver that records every update, for asserting what a receiver would see.
+type Recorder struct {
+	mu      sync.Mutex
+	values  map[string]map[string]interface{}
+	updates int
+}
+
+// NewRecorder function creates an empty recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{values: make(map[string]map[string]interface{})}
+}
+
+// UpdateSecret method records an update. It implements vaultsync.SecretReceiver.
+func (r *Recorder) UpdateSecret(id string, fieldName string, value interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.values[id] == nil {
+		r.values[id] = make(map[string]interface{})
+	}
+	r.values[id][fieldName] = value
+	r.updates++
+}
+
+// Value method returns the last value received for a field of a path.
+func (r *Recorder) Value(path string, field string) (interface{}, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	value, ok := r.values[path][field]
+	return value, ok
+}
+
+// SecretFingerprint method returns the fingerprint of the last value received for a field of a path. It implements vaultsync.DriftChecker.
+func (r *Recorder) SecretFingerprint(path string, field string) (string, bool) {
+	value, ok := r.Value(path, field)
+	if !ok {
+		return "", false
+	}
+	return vaultsync.Fingerprint(value), true
+}
+
+// Modify method changes the recorded value of a field, to simulate code that modifies a secret behind the agent's back.
+func (r *Recorder) Modify(path string, field string, value interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.values[path] == nil {
+		r.values[path] = make(map[string]interface{})
+	}
+	r.values[path][field] = value
+}
+
+// Updates method returns the number of updates received.
+func (r *Recorder) Updates() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.updates
+}
+
+// AssertValue method fails the test if the last value received for a field of a path is not want.
+func (r *Recorder) AssertValue(t testing.TB, path string, field string, want interface{}) {
+	t.Helper()
+
+	got, ok := r.Value(path, field)
+	if !ok {
+		t.Errorf("no value received for field %v of %v", field, path)
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("field %v of %v is %v, want %v", field, path, got, want)
+	}
+}
+
+// Sync function syncs the agent once and fails the test if a path could not be synced.
+func Sync(t testing.TB, agent *vaultsync.Agent) {
+	t.Helper()
+
+	if err := agent.SyncOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,120 @@
+package vaultsynctest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pergus/vaultsync"
+)
+
+// FakeClock struct is a vaultsync.Clock whose time only moves when Advance is called.
+// Pass it to the agent with vaultsync.WithClock to test renew periods, token TTLs and backoff without sleeping.
+type FakeClock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	pending []*fakeTimer
+}
+
+// fakeTimer struct is a timer of a FakeClock.
+type fakeTimer struct {
+	clock    *FakeClock
+	deadline time.Time
+	c        chan time.Time
+}
+
+// NewFakeClock function creates a fake clock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now method returns the current time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// NewTimer method creates a timer that fires once the clock has been advanced by d.
+func (c *FakeClock) NewTimer(d time.Duration) vaultsync.Timer {
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// Advance method moves the clock forward by d and fires every timer that expires on the way.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := c.pending[:0]
+	for _, t := range c.pending {
+		if t.deadline.After(c.now) {
+			pending = append(pending, t)
+			continue
+		}
+		select {
+		case t.c <- c.now:
+		default:
+		}
+	}
+	c.pending = pending
+	c.cond.Broadcast()
+}
+
+// BlockUntil method waits until at least n timers are pending, so a test knows the agent is waiting before it calls Advance.
+// Timers the agent stopped waiting for, for example a token expiry wait ended by a re-login, stay pending until they fire.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.pending) < n {
+		c.cond.Wait()
+	}
+}
+
+// C method returns the channel the time is delivered on when the timer fires.
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+// Stop method stops the timer. It returns false if the timer already fired or was stopped.
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, p := range c.pending {
+		if p == t {
+			c.pending = append(c.pending[:i], c.pending[i+1:]...)
+			c.cond.Broadcast()
+			return true
+		}
+	}
+	return false
+}
+
+// Reset method restarts the timer to fire once the clock has been advanced by d. It returns true if the timer was pending.
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	active := t.Stop()
+
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t.deadline = c.now.Add(d)
+	if d <= 0 {
+		select {
+		case t.c <- c.now:
+		default:
+		}
+		return active
+	}
+	c.pending = append(c.pending, t)
+	c.cond.Broadcast()
+	return active
+}
@@ -0,0 +1,83 @@
+package vaultsynctest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+func TestFakeClockTimers(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := vaultsynctest.NewFakeClock(start)
+	timer := clock.NewTimer(time.Minute)
+
+	clock.Advance(59 * time.Second)
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired early")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	select {
+	case now := <-timer.C():
+		if !now.Equal(start.Add(time.Minute)) {
+			t.Fatalf("timer fired at %v", now)
+		}
+	default:
+		t.Fatal("timer did not fire")
+	}
+
+	// A stopped timer does not fire, a reset one fires after the new duration.
+	if timer.Reset(time.Minute) {
+		t.Fatal("reset of a fired timer reported it pending")
+	}
+	if !timer.Stop() {
+		t.Fatal("stop of a pending timer returned false")
+	}
+	clock.Advance(time.Hour)
+	select {
+	case <-timer.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+}
+
+func TestRenewPeriodWithFakeClock(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+
+	clock := vaultsynctest.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	agent := vaultsynctest.NewAgent(t, s, vaultsync.WithClock(clock))
+	recorder := vaultsynctest.NewRecorder()
+	agent.RegisterUpdateSecret("secret/data/app", recorder)
+	if err := agent.Run(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Stop()
+	if reads := s.Reads("secret/data/app"); reads != 1 {
+		t.Fatalf("got %d reads after Run, want 1", reads)
+	}
+
+	// The path is synced again once the renew period of an hour has passed, not before.
+	s.SetField("secret/data/app", "password", "n3w")
+	clock.Advance(59 * time.Minute)
+	if reads := s.Reads("secret/data/app"); reads != 1 {
+		t.Fatalf("got %d reads before the renew period, want 1", reads)
+	}
+	deadline := time.After(5 * time.Second)
+	for synced := false; !synced; {
+		select {
+		case <-deadline:
+			t.Fatal("path not synced after the renew period")
+		case <-time.After(10 * time.Millisecond):
+			clock.Advance(time.Minute)
+			value, _ := recorder.Value("secret/data/app", "password")
+			synced = value == "n3w"
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package vaultsynctest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// dynamicSecret struct is a secret of a dynamic engine, every read issues a new lease.
+type dynamicSecret struct {
+	data      map[string]interface{}
+	ttl       time.Duration
+	maxTTL    time.Duration
+	renewable bool
+}
+
+// lease struct is a lease issued by a read of a dynamic secret.
+type lease struct {
+	issued time.Time
+	expiry time.Time
+	secret *dynamicSecret
+}
+
+// SetDynamicSecret method serves data at path like a dynamic secrets engine, such as database/creds/app. Every read
+// issues a new lease of ttl that can be renewed with sys/leases/renew, if renewable, up to maxTTL after the read.
+// The data is returned at the top level, register ExtractData for the path.
+func (s *Server) SetDynamicSecret(path string, data map[string]interface{}, ttl time.Duration, maxTTL time.Duration, renewable bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dynamic[path] = &dynamicSecret{data: copyData(data), ttl: ttl, maxTTL: maxTTL, renewable: renewable}
+}
+
+// LeaseRenewals method returns how often leases were renewed.
+func (s *Server) LeaseRenewals() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.renewals
+}
+
+// readDynamic method issues a lease of a dynamic secret. It must be called with mu held.
+func (s *Server) readDynamic(w http.ResponseWriter, path string, secret *dynamicSecret) {
+	s.reads[path]++
+	s.nextID++
+	leaseID := fmt.Sprintf("%v/%d", path, s.nextID)
+	now := time.Now()
+	s.leases[leaseID] = &lease{issued: now, expiry: now.Add(secret.ttl), secret: secret}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"lease_id":       leaseID,
+		"lease_duration": int(secret.ttl.Seconds()),
+		"renewable":      secret.renewable,
+		"data":           secret.data,
+	})
+}
+
+// renewLease method extends a lease by the TTL of its secret, without going past its max TTL.
+// It must be called with mu held.
+func (s *Server) renewLease(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		LeaseID string `json:"lease_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	l, ok := s.leases[body.LeaseID]
+	now := time.Now()
+	if !ok || now.After(l.expiry) {
+		writeErrors(w, http.StatusBadRequest, "lease not found or lease is not renewable")
+		return
+	}
+	if !l.secret.renewable {
+		writeErrors(w, http.StatusBadRequest, "lease is not renewable")
+		return
+	}
+
+	s.renewals++
+	l.expiry = now.Add(l.secret.ttl)
+	if maxExpiry := l.issued.Add(l.secret.maxTTL); l.expiry.After(maxExpiry) {
+		l.expiry = maxExpiry
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"lease_id":       body.LeaseID,
+		"lease_duration": int(l.expiry.Sub(now).Seconds()),
+		"renewable":      true,
+	})
+}
@@ -0,0 +1,230 @@
+package vaultsynctest
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"testing"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/pergus/vaultsync"
+)
+
+// Call struct records a call made by the agent to a MockClient.
+type Call struct {
+	Method string // Name of the vaultsync.Client method, e.g. Read.
+	Path   string // Secret path of the call, empty for token calls.
+}
+
+// MockClient struct is a vaultsync.Client that keeps KV v2 secrets in memory and records every call, so sync and dispatch
+// logic can be tested without any Vault API. Set one of the Func fields to replace the behavior of a method,
+// for example to return an error.
+type MockClient struct {
+	LoginFunc  func(ctx context.Context, authMethod vault.AuthMethod) (*vault.Secret, error)
+	RenewFunc  func(ctx context.Context, increment int) (*vault.Secret, error)
+	ReadFunc   func(ctx context.Context, path string) (*vault.Secret, error)
+	WriteFunc  func(ctx context.Context, path string, data map[string]interface{}) (*vault.Secret, error)
+	DeleteFunc func(ctx context.Context, path string) (*vault.Secret, error)
+
+	mu      sync.Mutex
+	secrets map[string][]map[string]interface{}
+	calls   []Call
+}
+
+// NewMockClient function creates a mock client without secrets.
+func NewMockClient() *MockClient {
+	return &MockClient{
+		secrets: make(map[string][]map[string]interface{}),
+	}
+}
+
+// NewMockAgent function creates an agent that uses m instead of a Vault server. The agent logs nothing unless a logger is passed in opts.
+func NewMockAgent(t testing.TB, m *MockClient, opts ...vaultsync.AgentOptFunc) *vaultsync.Agent {
+	t.Helper()
+
+	// The server is never contacted, the mock answers every call.
+	filename, err := writeConfig(t.TempDir(), "http://127.0.0.1:8200", 3600, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts = append(opts, vaultsync.WithClient(func(vaultsync.Client) vaultsync.Client { return m }))
+	return newAgent(t, filename, opts)
+}
+
+// SetSecret method stores data as a new version of the KV v2 secret at path and returns the new version.
+func (m *MockClient) SetSecret(path string, data map[string]interface{}) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.store(path, copyData(data))
+}
+
+// Calls method returns the calls made so far, in order.
+func (m *MockClient) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Call(nil), m.calls...)
+}
+
+// record method appends a call to the call log.
+func (m *MockClient) record(method string, path string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls = append(m.calls, Call{Method: method, Path: path})
+}
+
+// store method stores a new version of a secret and returns it. It must be called with mu held.
+func (m *MockClient) store(path string, data map[string]interface{}) int {
+	m.secrets[path] = append(m.secrets[path], data)
+	return len(m.secrets[path])
+}
+
+// latest method returns the latest data of a secret, or nil if it does not exist. It must be called with mu held.
+func (m *MockClient) latest(path string) map[string]interface{} {
+	versions := m.secrets[path]
+	if len(versions) == 0 {
+		return nil
+	}
+	return versions[len(versions)-1]
+}
+
+// Login method returns a non-renewable token valid for TokenTTL, unless LoginFunc is set.
+func (m *MockClient) Login(ctx context.Context, authMethod vault.AuthMethod) (*vault.Secret, error) {
+	m.record("Login", "")
+	if m.LoginFunc != nil {
+		return m.LoginFunc(ctx, authMethod)
+	}
+	return &vault.Secret{Auth: &vault.SecretAuth{
+		ClientToken:   "hvs.vaultsynctest-mock",
+		LeaseDuration: int(TokenTTL.Seconds()),
+	}}, nil
+}
+
+// LookupSelf method returns the properties of a token that is not periodic.
+func (m *MockClient) LookupSelf(ctx context.Context) (*vault.Secret, error) {
+	m.record("LookupSelf", "")
+	return &vault.Secret{Data: map[string]interface{}{
+		"ttl":    json.Number(strconv.Itoa(int(TokenTTL.Seconds()))),
+		"period": json.Number("0"),
+	}}, nil
+}
+
+// RenewSelf method renews the token for TokenTTL, unless RenewFunc is set.
+func (m *MockClient) RenewSelf(ctx context.Context, increment int) (*vault.Secret, error) {
+	m.record("RenewSelf", "")
+	if m.RenewFunc != nil {
+		return m.RenewFunc(ctx, increment)
+	}
+	return &vault.Secret{Auth: &vault.SecretAuth{
+		ClientToken:   "hvs.vaultsynctest-mock",
+		Renewable:     true,
+		LeaseDuration: int(TokenTTL.Seconds()),
+	}}, nil
+}
+
+// RevokeSelf method records the revocation of the token.
+func (m *MockClient) RevokeSelf(ctx context.Context) error {
+	m.record("RevokeSelf", "")
+	return nil
+}
+
+// Read method returns the latest version of the secret at path in the form of a KV v2 read, or nil if there is none.
+func (m *MockClient) Read(ctx context.Context, path string) (*vault.Secret, error) {
+	m.record("Read", path)
+	if m.ReadFunc != nil {
+		return m.ReadFunc(ctx, path)
+	}
+	return m.readVersion(path, 0), nil
+}
+
+// ReadWithData method returns the version of the secret at path given by the version parameter, or the latest version without it.
+func (m *MockClient) ReadWithData(ctx context.Context, path string, data map[string][]string) (*vault.Secret, error) {
+	m.record("Read", path)
+	if m.ReadFunc != nil {
+		return m.ReadFunc(ctx, path)
+	}
+	version := 0
+	if v := data["version"]; len(v) > 0 {
+		version, _ = strconv.Atoi(v[0])
+	}
+	return m.readVersion(path, version), nil
+}
+
+// readVersion method returns a version of the secret at path in the form of a KV v2 read, 0 is the latest version.
+// It returns nil if the version does not exist.
+func (m *MockClient) readVersion(path string, version int) *vault.Secret {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	versions := m.secrets[path]
+	if version == 0 {
+		version = len(versions)
+	}
+	if version < 1 || version > len(versions) {
+		return nil
+	}
+	return &vault.Secret{Data: map[string]interface{}{
+		"data":     copyData(versions[version-1]),
+		"metadata": map[string]interface{}{"version": json.Number(strconv.Itoa(version))},
+	}}
+}
+
+// Write method stores the data of a KV v2 write as a new version of the secret at path. Check-and-set options are ignored.
+func (m *MockClient) Write(ctx context.Context, path string, data map[string]interface{}) (*vault.Secret, error) {
+	m.record("Write", path)
+	if m.WriteFunc != nil {
+		return m.WriteFunc(ctx, path, data)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	secretData, _ := data["data"].(map[string]interface{})
+	version := m.store(path, copyData(secretData))
+	return versionResponse(version), nil
+}
+
+// Patch method merges the data of a KV v2 patch into the secret at path. Fields set to nil are removed.
+func (m *MockClient) Patch(ctx context.Context, path string, data map[string]interface{}) (*vault.Secret, error) {
+	m.record("Patch", path)
+	if m.WriteFunc != nil {
+		return m.WriteFunc(ctx, path, data)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	merged := copyData(m.latest(path))
+	patch, _ := data["data"].(map[string]interface{})
+	for field, value := range patch {
+		if value == nil {
+			delete(merged, field)
+		} else {
+			merged[field] = value
+		}
+	}
+	version := m.store(path, merged)
+	return versionResponse(version), nil
+}
+
+// Delete method removes the secret at path and all its versions.
+func (m *MockClient) Delete(ctx context.Context, path string) (*vault.Secret, error) {
+	m.record("Delete", path)
+	if m.DeleteFunc != nil {
+		return m.DeleteFunc(ctx, path)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.secrets, path)
+	return nil, nil
+}
+
+// versionResponse function returns the response of a KV v2 write that created version.
+func versionResponse(version int) *vault.Secret {
+	return &vault.Secret{Data: map[string]interface{}{"version": json.Number(strconv.Itoa(version))}}
+}
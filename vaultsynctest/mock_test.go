@@ -0,0 +1,45 @@
+package vaultsynctest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+func TestMockClient(t *testing.T) {
+	m := vaultsynctest.NewMockClient()
+	m.SetSecret("secret/data/app", map[string]interface{}{"username": "app", "password": "s3cret"})
+
+	agent := vaultsynctest.NewMockAgent(t, m)
+	recorder := vaultsynctest.NewRecorder()
+	agent.RegisterUpdateSecret("secret/data/app", recorder)
+
+	vaultsynctest.Sync(t, agent)
+	recorder.AssertValue(t, "secret/data/app", "password", "s3cret")
+
+	// Writes through the agent go to the mock and are seen by the next sync.
+	version, err := agent.PatchSecret(context.Background(), "secret/data/app", map[string]interface{}{"password": "n3w"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 2 {
+		t.Fatalf("patch created version %d, want 2", version)
+	}
+	vaultsynctest.Sync(t, agent)
+	recorder.AssertValue(t, "secret/data/app", "password", "n3w")
+	recorder.AssertValue(t, "secret/data/app", "username", "app")
+
+	var reads, patches int
+	for _, call := range m.Calls() {
+		switch {
+		case call.Method == "Read" && call.Path == "secret/data/app":
+			reads++
+		case call.Method == "Patch" && call.Path == "secret/data/app":
+			patches++
+		}
+	}
+	if reads != 2 || patches != 1 {
+		t.Fatalf("got %d reads and %d patches, want 2 and 1: %v", reads, patches, m.Calls())
+	}
+}
@@ -0,0 +1,481 @@
+// Package vaultsynctest provides an in-memory fake Vault and helpers for testing code that uses vaultsync,
+// without a real Vault server.
+//
+// The fake implements the parts of the Vault HTTP API used by the agent: approle, userpass and ldap login,
+// token lookup, renewal and revocation, KV v2 secrets and their subkeys, dynamic secrets with renewable leases, sys/health,
+// sys/capabilities-self and the mount lookup of sys/internal/ui/mounts.
+//
+// MockClient replaces the Vault API altogether, for tests that need to control or inspect every call the agent makes.
+package vaultsynctest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials accepted by the fake for every auth method.
+const (
+	Username = "vaultsynctest"
+	Password = "vaultsynctest-password"
+)
+
+// TokenTTL is the TTL of the tokens issued by the fake.
+const TokenTTL = time.Hour
+
+// EntityID is the ID of the identity entity of the tokens issued by the fake.
+const EntityID = "vaultsynctest-entity"
+
+// Server struct is an in-memory fake Vault serving HTTP on a local address.
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	tokens  map[string]bool
+	secrets map[string]*kvSecret
+	reads   map[string]int
+	nextID  int
+	failing bool
+
+	dynamic  map[string]*dynamicSecret
+	leases   map[string]*lease
+	renewals int
+
+	mounts       map[string]int
+	mountsDenied bool
+}
+
+// kvSecret struct is a KV v2 secret with all its versions.
+type kvSecret struct {
+	versions []kvVersion
+}
+
+// kvVersion struct is a version of a KV v2 secret.
+type kvVersion struct {
+	data      map[string]interface{}
+	created   time.Time
+	deleted   bool
+	destroyed bool
+}
+
+// NewServer function starts a fake Vault. Close it when done.
+func NewServer() *Server {
+	s := &Server{
+		tokens:  make(map[string]bool),
+		secrets: make(map[string]*kvSecret),
+		reads:   make(map[string]int),
+		dynamic: make(map[string]*dynamicSecret),
+		leases:  make(map[string]*lease),
+		mounts:  make(map[string]int),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// SetSecret method stores data as a new version of the KV v2 secret at path, e.g. secrets/data/app/db,
+// as if the secret was rotated. It returns the new version.
+func (s *Server) SetSecret(path string, data map[string]interface{}) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.writeVersion(path, copyData(data))
+}
+
+// SetField method rotates a single field of the secret at path, keeping the other fields. It returns the new version.
+func (s *Server) SetField(path string, field string, value interface{}) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data := make(map[string]interface{})
+	if v := s.current(path); v != nil {
+		data = copyData(v.data)
+	}
+	data[field] = value
+	return s.writeVersion(path, data)
+}
+
+// Secret method returns the current data of the secret at path.
+func (s *Server) Secret(path string) (map[string]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v := s.current(path)
+	if v == nil {
+		return nil, false
+	}
+	return copyData(v.data), true
+}
+
+// DeleteSecret method removes the secret at path and all its versions.
+func (s *Server) DeleteSecret(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.secrets, path)
+}
+
+// Reads method returns how often the secret at path was read.
+func (s *Server) Reads(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.reads[path]
+}
+
+// SetFailing method makes every request fail with 503 Service Unavailable, to simulate an outage.
+func (s *Server) SetFailing(failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failing = failing
+}
+
+// RevokeTokens method revokes all issued tokens, so the agent has to log in again.
+func (s *Server) RevokeTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens = make(map[string]bool)
+}
+
+// Tokens method returns the number of issued tokens that have not been revoked.
+func (s *Server) Tokens() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.tokens)
+}
+
+// SetMount method declares a KV mount, e.g. team/kv, and its KV version for the mount lookup. Paths that are not in
+// a declared mount are looked up as KV v2 secrets in the mount named by their first segment.
+func (s *Server) SetMount(mount string, version int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mounts[strings.Trim(mount, "/")] = version
+}
+
+// SetMountLookupDenied method makes the mount lookup fail with permission denied, as for a token without access to it.
+func (s *Server) SetMountLookupDenied(denied bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mountsDenied = denied
+}
+
+// writeVersion method appends a version to a secret. It must be called with mu held.
+func (s *Server) writeVersion(path string, data map[string]interface{}) int {
+	secret, ok := s.secrets[path]
+	if !ok {
+		secret = &kvSecret{}
+		s.secrets[path] = secret
+	}
+	secret.versions = append(secret.versions, kvVersion{data: data, created: time.Now().UTC()})
+	return len(secret.versions)
+}
+
+// current method returns the latest version of a secret, or nil if it does not exist or is deleted.
+// It must be called with mu held.
+func (s *Server) current(path string) *kvVersion {
+	v, _ := s.version(path, 0)
+	return v
+}
+
+// version method returns a version of a secret and its number, 0 is the latest version.
+// It returns nil if the version does not exist or is deleted. It must be called with mu held.
+func (s *Server) version(path string, version int) (*kvVersion, int) {
+	secret, ok := s.secrets[path]
+	if !ok || version < 0 || version > len(secret.versions) || len(secret.versions) == 0 {
+		return nil, 0
+	}
+	if version == 0 {
+		version = len(secret.versions)
+	}
+	v := &secret.versions[version-1]
+	if v.deleted || v.destroyed {
+		return nil, 0
+	}
+	return v, version
+}
+
+// serveHTTP method routes a Vault API request.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failing {
+		writeErrors(w, http.StatusServiceUnavailable, "vault is unavailable")
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+	switch {
+	case path == "sys/health":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"initialized": true, "sealed": false, "version": "vaultsynctest"})
+		return
+	case path == "auth/approle/login" || strings.HasPrefix(path, "auth/userpass/login/") || strings.HasPrefix(path, "auth/ldap/login/"):
+		s.login(w, r, path)
+		return
+	}
+
+	token := r.Header.Get("X-Vault-Token")
+	if !s.tokens[token] {
+		writeErrors(w, http.StatusForbidden, "permission denied")
+		return
+	}
+
+	switch {
+	case path == "auth/token/lookup-self":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{
+			"id": token, "accessor": "accessor." + strings.TrimPrefix(token, "hvs."), "display_name": "approle", "entity_id": EntityID,
+			"policies": []string{"default"}, "identity_policies": []string{"app"},
+			"ttl": int(TokenTTL.Seconds()), "period": 0, "renewable": true,
+		}})
+	case path == "auth/token/renew-self":
+		writeJSON(w, http.StatusOK, authResponse(token))
+	case path == "auth/token/revoke-self":
+		delete(s.tokens, token)
+		w.WriteHeader(http.StatusNoContent)
+	case path == "sys/capabilities-self":
+		s.capabilities(w, r)
+	case strings.HasPrefix(path, "sys/internal/ui/mounts/"):
+		s.mount(w, strings.TrimPrefix(path, "sys/internal/ui/mounts/"))
+	case path == "sys/leases/renew":
+		s.renewLease(w, r)
+	case s.dynamic[path] != nil:
+		s.readDynamic(w, path, s.dynamic[path])
+	case strings.Contains(path, "/subkeys/"):
+		s.subkeys(w, strings.Replace(path, "/subkeys/", "/data/", 1))
+	case strings.Contains(path, "/data/"):
+		s.kv(w, r, path)
+	case strings.Contains(path, "/delete/") || strings.Contains(path, "/undelete/") || strings.Contains(path, "/destroy/"):
+		s.kvVersions(w, r, path)
+	default:
+		writeErrors(w, http.StatusNotFound, "unsupported path "+path)
+	}
+}
+
+// login method issues a token if the credentials match Username and Password. It must be called with mu held.
+func (s *Server) login(w http.ResponseWriter, r *http.Request, path string) {
+	var body map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var ok bool
+	if path == "auth/approle/login" {
+		ok = body["role_id"] == Username && body["secret_id"] == Password
+	} else {
+		ok = path[strings.LastIndex(path, "/")+1:] == Username && body["password"] == Password
+	}
+	if !ok {
+		writeErrors(w, http.StatusBadRequest, "invalid credentials")
+		return
+	}
+
+	s.nextID++
+	token := fmt.Sprintf("hvs.vaultsynctest%d", s.nextID)
+	s.tokens[token] = true
+	writeJSON(w, http.StatusOK, authResponse(token))
+}
+
+// kv method serves reads, writes, patches and deletes of KV v2 secrets. It must be called with mu held.
+func (s *Server) kv(w http.ResponseWriter, r *http.Request, path string) {
+	switch r.Method {
+	case http.MethodGet:
+		s.reads[path]++
+		requested, _ := strconv.Atoi(r.URL.Query().Get("version"))
+		v, version := s.version(path, requested)
+		if v == nil {
+			writeErrors(w, http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{
+			"data":     v.data,
+			"metadata": map[string]interface{}{"version": version, "created_time": v.created},
+		}})
+
+	case http.MethodPut, http.MethodPost, http.MethodPatch:
+		var body struct {
+			Data    map[string]interface{} `json:"data"`
+			Options map[string]interface{} `json:"options"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeErrors(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		versions := 0
+		if secret, ok := s.secrets[path]; ok {
+			versions = len(secret.versions)
+		}
+		if cas, ok := body.Options["cas"].(float64); ok && int(cas) != versions {
+			writeErrors(w, http.StatusBadRequest, "check-and-set parameter did not match the current version")
+			return
+		}
+
+		data := body.Data
+		if r.Method == http.MethodPatch {
+			v := s.current(path)
+			if v == nil {
+				writeErrors(w, http.StatusNotFound)
+				return
+			}
+			data = copyData(v.data)
+			for field, value := range body.Data {
+				if value == nil {
+					delete(data, field)
+				} else {
+					data[field] = value
+				}
+			}
+		}
+		version := s.writeVersion(path, data)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"version": version}})
+
+	case http.MethodDelete:
+		if v := s.current(path); v != nil {
+			v.deleted = true
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeErrors(w, http.StatusMethodNotAllowed)
+	}
+}
+
+// subkeys method serves the keys of the current version of a KV v2 secret without their values.
+// Nested objects are maps of their keys, other values are null. It must be called with mu held.
+func (s *Server) subkeys(w http.ResponseWriter, path string) {
+	v, version := s.version(path, 0)
+	if v == nil {
+		writeErrors(w, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{
+		"subkeys":  subkeysOf(v.data),
+		"metadata": map[string]interface{}{"version": version, "created_time": v.created},
+	}})
+}
+
+// subkeysOf function returns the keys of data, with the keys of nested objects.
+func subkeysOf(data map[string]interface{}) map[string]interface{} {
+	keys := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		if nested, ok := value.(map[string]interface{}); ok {
+			keys[key] = subkeysOf(nested)
+		} else {
+			keys[key] = nil
+		}
+	}
+	return keys
+}
+
+// kvVersions method serves the delete, undelete and destroy endpoints of KV v2. It must be called with mu held.
+func (s *Server) kvVersions(w http.ResponseWriter, r *http.Request, path string) {
+	mount, rest, _ := strings.Cut(path, "/")
+	operation, name, _ := strings.Cut(rest, "/")
+	secret, ok := s.secrets[mount+"/data/"+name]
+	if !ok {
+		writeErrors(w, http.StatusNotFound)
+		return
+	}
+
+	var body struct {
+		Versions []int `json:"versions"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, version := range body.Versions {
+		if version < 1 || version > len(secret.versions) {
+			continue
+		}
+		v := &secret.versions[version-1]
+		switch operation {
+		case "delete":
+			v.deleted = true
+		case "undelete":
+			v.deleted = false
+		case "destroy":
+			v.destroyed = true
+			v.data = nil
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// mount method serves the mount lookup of a path: the longest mount declared with SetMount that prefixes it,
+// or else the first segment of the path as a KV v2 mount. It must be called with mu held.
+func (s *Server) mount(w http.ResponseWriter, path string) {
+	if s.mountsDenied {
+		writeErrors(w, http.StatusForbidden, "permission denied")
+		return
+	}
+	mount, _, _ := strings.Cut(path, "/")
+	version, longest := 2, 0
+	for m, v := range s.mounts {
+		if len(m) > longest && strings.HasPrefix(path, m+"/") {
+			mount, version, longest = m, v, len(m)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{
+		"path":    mount + "/",
+		"type":    "kv",
+		"options": map[string]interface{}{"version": strconv.Itoa(version)},
+	}})
+}
+
+// capabilities method grants read on every path. It must be called with mu held.
+func (s *Server) capabilities(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Paths []string `json:"paths"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	data := map[string]interface{}{"capabilities": []string{"read"}}
+	for _, path := range body.Paths {
+		data[path] = []string{"read"}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": data})
+}
+
+// authResponse function returns a login or renewal response for token.
+func authResponse(token string) map[string]interface{} {
+	return map[string]interface{}{"auth": map[string]interface{}{
+		"client_token":   token,
+		"renewable":      true,
+		"lease_duration": int(TokenTTL.Seconds()),
+		"policies":       []string{"default"},
+	}}
+}
+
+// writeJSON function writes a JSON response.
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeErrors function writes a Vault error response.
+func writeErrors(w http.ResponseWriter, status int, errors ...string) {
+	if errors == nil {
+		errors = []string{}
+	}
+	writeJSON(w, status, map[string]interface{}{"errors": errors})
+}
+
+// copyData function returns a shallow copy of secret data.
+func copyData(data map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		c[k] = v
+	}
+	return c
+}
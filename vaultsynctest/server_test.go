@@ -0,0 +1,67 @@
+package vaultsynctest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+func TestServerSync(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"username": "app", "password": "s3cret"})
+
+	agent := vaultsynctest.NewAgent(t, s)
+	recorder := vaultsynctest.NewRecorder()
+	agent.RegisterUpdateSecret("secret/data/app", recorder)
+
+	vaultsynctest.Sync(t, agent)
+	recorder.AssertValue(t, "secret/data/app", "username", "app")
+	recorder.AssertValue(t, "secret/data/app", "password", "s3cret")
+	if reads := s.Reads("secret/data/app"); reads != 1 {
+		t.Fatalf("got %d reads, want 1", reads)
+	}
+
+	// A rotated field is a new version of the secret.
+	if version := s.SetField("secret/data/app", "password", "n3w"); version != 2 {
+		t.Fatalf("rotation created version %d, want 2", version)
+	}
+	vaultsynctest.Sync(t, agent)
+	recorder.AssertValue(t, "secret/data/app", "password", "n3w")
+	recorder.AssertValue(t, "secret/data/app", "username", "app")
+	if status := agent.Status()[0]; status.Version != 2 {
+		t.Fatalf("synced version %d, want 2", status.Version)
+	}
+
+	// A deleted secret fails the sync as not found and the receiver keeps the last values.
+	s.DeleteSecret("secret/data/app")
+	if err := agent.SyncOnce(context.Background()); err == nil {
+		t.Fatal("sync of a deleted secret succeeded")
+	}
+	if kind := agent.Status()[0].ErrorKind; kind != vaultsync.ErrorKindNotFound {
+		t.Fatalf("got %v, want not_found", kind)
+	}
+	recorder.AssertValue(t, "secret/data/app", "password", "n3w")
+}
+
+func TestServerFailing(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+
+	agent := vaultsynctest.NewAgent(t, s, vaultsync.WithMaxRetries(0))
+	agent.RegisterUpdateSecret("secret/data/app", vaultsynctest.NewRecorder())
+
+	s.SetFailing(true)
+	if err := agent.SyncOnce(context.Background()); err == nil {
+		t.Fatal("sync succeeded while the server fails")
+	}
+	if kind := agent.Status()[0].ErrorKind; kind != vaultsync.ErrorKindUnavailable {
+		t.Fatalf("got %v, want unavailable", kind)
+	}
+
+	s.SetFailing(false)
+	vaultsynctest.Sync(t, agent)
+}
@@ -0,0 +1,181 @@
+// Package vaultcontainer runs a Vault dev server in Docker with testcontainers-go for integration tests of code that uses vaultsync.
+//
+// Start mounts a KV v2 secrets engine at secrets/, enables approle with a role that can read and write it,
+// and returns a Container whose NewAgent creates an agent logged in with that role:
+//
+//	c := vaultcontainer.Start(t)
+//	c.SetSecret(t, "secrets/data/app/db", map[string]interface{}{"password": "secret"})
+//	agent := c.NewAgent(t)
+//
+// The test is skipped if Docker is not available.
+package vaultcontainer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/pergus/vaultsync"
+	"github.com/testcontainers/testcontainers-go"
+	vaultmodule "github.com/testcontainers/testcontainers-go/modules/vault"
+)
+
+// DefaultImage is the Vault image started by Start.
+const DefaultImage = "hashicorp/vault:1.16"
+
+// RootToken is the root token of the dev server.
+const RootToken = "vaultsync-root"
+
+// Names of the objects created in the dev server.
+const (
+	Mount  = "secrets"
+	Policy = "vaultsync"
+	Role   = "vaultsync"
+)
+
+// policy grants the role access to the KV v2 mount, password generation and lease revocation.
+const policy = `
+path "secrets/*" {
+  capabilities = ["create", "read", "update", "patch", "delete", "list"]
+}
+path "sys/policies/password/+/generate" {
+  capabilities = ["read"]
+}
+path "sys/leases/revoke" {
+  capabilities = ["update"]
+}
+`
+
+// Container struct is a running Vault dev server set up for vaultsync.
+type Container struct {
+	*vaultmodule.VaultContainer
+
+	Address  string        // Address of the server, e.g. http://localhost:32768.
+	Root     *vault.Client // Client authenticated with RootToken, for setting up secrets and policies.
+	RoleID   string        // Role ID of the approle the agents log in with.
+	SecretID string        // Secret ID of the approle the agents log in with.
+}
+
+// Start function starts a Vault dev server with DefaultImage and sets it up for vaultsync. The container is terminated when the test ends.
+// opts customize the container, for example testcontainers.WithImage to use another Vault version.
+func Start(t *testing.T, opts ...testcontainers.ContainerCustomizer) *Container {
+	t.Helper()
+	skipWithoutDocker(t)
+
+	ctx := context.Background()
+	opts = append([]testcontainers.ContainerCustomizer{vaultmodule.WithToken(RootToken)}, opts...)
+	vc, err := vaultmodule.Run(ctx, DefaultImage, opts...)
+	testcontainers.CleanupContainer(t, vc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Container{VaultContainer: vc}
+	if err := c.setup(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// skipWithoutDocker function skips the test if Docker is not available. testcontainers panics if it finds no Docker host at all.
+func skipWithoutDocker(t *testing.T) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			t.Skipf("Docker is not available: %v", r)
+		}
+	}()
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+}
+
+// setup method creates the root client, the KV v2 mount and the approle role.
+func (c *Container) setup(ctx context.Context) error {
+	var err error
+	if c.Address, err = c.HttpHostAddress(ctx); err != nil {
+		return err
+	}
+
+	cfg := vault.DefaultConfig()
+	cfg.Address = c.Address
+	if c.Root, err = vault.NewClient(cfg); err != nil {
+		return err
+	}
+	c.Root.SetToken(RootToken)
+
+	if err := c.Root.Sys().MountWithContext(ctx, Mount, &vault.MountInput{Type: "kv", Options: map[string]string{"version": "2"}}); err != nil {
+		return fmt.Errorf("mount %v:%w", Mount, err)
+	}
+	if err := c.Root.Sys().EnableAuthWithOptionsWithContext(ctx, "approle", &vault.EnableAuthOptions{Type: "approle"}); err != nil {
+		return fmt.Errorf("enable approle:%w", err)
+	}
+	if err := c.Root.Sys().PutPolicyWithContext(ctx, Policy, policy); err != nil {
+		return fmt.Errorf("policy %v:%w", Policy, err)
+	}
+
+	rolePath := "auth/approle/role/" + Role
+	if _, err := c.Root.Logical().WriteWithContext(ctx, rolePath, map[string]interface{}{"token_policies": Policy, "token_ttl": "1h"}); err != nil {
+		return fmt.Errorf("role %v:%w", Role, err)
+	}
+	roleID, err := c.Root.Logical().ReadWithContext(ctx, rolePath+"/role-id")
+	if err != nil {
+		return fmt.Errorf("role id:%w", err)
+	}
+	secretID, err := c.Root.Logical().WriteWithContext(ctx, rolePath+"/secret-id", nil)
+	if err != nil {
+		return fmt.Errorf("secret id:%w", err)
+	}
+	c.RoleID, _ = roleID.Data["role_id"].(string)
+	c.SecretID, _ = secretID.Data["secret_id"].(string)
+
+	return nil
+}
+
+// SetSecret method writes data as a new version of the KV v2 secret at path, e.g. secrets/data/app/db.
+func (c *Container) SetSecret(t testing.TB, path string, data map[string]interface{}) {
+	t.Helper()
+
+	if _, err := c.Root.Logical().WriteWithContext(context.Background(), path, map[string]interface{}{"data": data}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// WriteConfig method writes a configuration file for an agent that logs in with the approle role, and returns its name.
+// extra is added to the config block, for example template or exec blocks.
+func (c *Container) WriteConfig(dir string, renewSecretsPeriod int, extra string) (string, error) {
+	filename := filepath.Join(dir, "vaultsync.hcl")
+	config := fmt.Sprintf(`config {
+  server               = %q
+  authmethod           = "approle"
+  username             = %q
+  password             = %q
+  renew_secrets_period = %d
+%s
+}
+`, c.Address, c.RoleID, c.SecretID, renewSecretsPeriod, extra)
+	return filename, os.WriteFile(filename, []byte(config), 0600)
+}
+
+// NewAgent method creates an agent logged in to the dev server. The agent logs nothing unless a logger is passed in opts.
+func (c *Container) NewAgent(t testing.TB, opts ...vaultsync.AgentOptFunc) *vaultsync.Agent {
+	t.Helper()
+
+	filename, err := c.WriteConfig(t.TempDir(), 3600, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts = append([]vaultsync.AgentOptFunc{
+		vaultsync.WithConfigFile(filename),
+		vaultsync.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	}, opts...)
+	agent, err := vaultsync.New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return agent
+}
@@ -0,0 +1,39 @@
+//go:build integration
+
+package vaultcontainer_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pergus/vaultsync/vaultsynctest"
+	"github.com/pergus/vaultsync/vaultsynctest/vaultcontainer"
+)
+
+// The test needs Docker and pulls the Vault image, run it with go test -tags integration.
+func TestContainerSync(t *testing.T) {
+	c := vaultcontainer.Start(t)
+	c.SetSecret(t, "secrets/data/app/db", map[string]interface{}{"username": "app", "password": "s3cret"})
+
+	agent := c.NewAgent(t)
+	recorder := vaultsynctest.NewRecorder()
+	agent.RegisterUpdateSecret("secrets/data/app/db", recorder)
+
+	vaultsynctest.Sync(t, agent)
+	recorder.AssertValue(t, "secrets/data/app/db", "username", "app")
+	recorder.AssertValue(t, "secrets/data/app/db", "password", "s3cret")
+
+	// A rotation written with the role of the agent is seen by the next sync.
+	version, err := agent.PatchSecret(context.Background(), "secrets/data/app/db", map[string]interface{}{"password": "n3w"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 2 {
+		t.Fatalf("patch created version %d, want 2", version)
+	}
+	vaultsynctest.Sync(t, agent)
+	recorder.AssertValue(t, "secrets/data/app/db", "password", "n3w")
+	if status := agent.Status()[0]; status.Version != 2 {
+		t.Fatalf("synced version %d, want 2", status.Version)
+	}
+}
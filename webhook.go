@@ -0,0 +1,114 @@
+package vaultsync
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// webhookConfig struct defines a webhook that is notified when a secret rotates.
+type webhookConfig struct {
+	URL        string   `hcl:"url"`
+	HMACSecret string   `hcl:"hmac_secret,optional"`
+	MaxRetries int      `hcl:"max_retries,optional"`
+	Timeout    duration `hcl:"timeout,optional"`
+}
+
+const (
+	// webhookSignatureHeader is the header carrying the HMAC-SHA256 signature of the request body.
+	webhookSignatureHeader = "X-Vaultsync-Signature"
+
+	// defaultWebhookRetries is the number of retries if max_retries is not configured.
+	defaultWebhookRetries = 3
+
+	// defaultWebhookTimeout is the request timeout if timeout is not configured.
+	defaultWebhookTimeout = 10 * time.Second
+)
+
+// webhookNotifier struct is a Notifier that posts rotation events as JSON to a webhook.
+type webhookNotifier struct {
+	config webhookConfig
+	client *http.Client
+	clock  Clock
+}
+
+// newWebhookNotifier function creates a webhook notifier from its configuration.
+func newWebhookNotifier(config webhookConfig, clock Clock) *webhookNotifier {
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = defaultWebhookRetries
+	}
+	timeout := config.Timeout.value()
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	return &webhookNotifier{
+		config: config,
+		client: &http.Client{Timeout: timeout},
+		clock:  clock,
+	}
+}
+
+// Notify method posts the event to the webhook, retrying with exponential backoff on failure.
+func (w *webhookNotifier) Notify(ctx context.Context, event RotationEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	backoff := time.Second
+	for attempt := 0; attempt <= w.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-after(w.clock, backoff):
+			}
+			backoff *= 2
+		}
+
+		err = w.send(ctx, body)
+		if err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("giving up after %d attempts: %w", w.config.MaxRetries+1, err)
+}
+
+// send method performs a single webhook request.
+func (w *webhookNotifier) send(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.config.HMACSecret != "" {
+		mac := hmac.New(sha256.New, []byte(w.config.HMACSecret))
+		mac.Write(body)
+		req.Header.Set(webhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		// The error is logged, it must not carry tokens of the URL.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = urlHost(urlErr.URL)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package vaultsync_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pergus/vaultsync"
+	"github.com/pergus/vaultsync/vaultsynctest"
+)
+
+// webhookServer struct is a webhook that answers with the given statuses in turn, then with the last one,
+// and records the requests it got.
+type webhookServer struct {
+	*httptest.Server
+	secret   string
+	statuses []int
+
+	mu       sync.Mutex
+	bodies   [][]byte
+	badSigns int
+}
+
+// newWebhookServer function starts a webhook that checks the signature of its requests with secret.
+func newWebhookServer(t *testing.T, secret string, statuses ...int) *webhookServer {
+	w := &webhookServer{secret: secret, statuses: statuses}
+	w.Server = httptest.NewServer(http.HandlerFunc(w.serve))
+	t.Cleanup(w.Close)
+	return w
+}
+
+// serve method records a request and answers with the next status.
+func (w *webhookServer) serve(rw http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	mac := hmac.New(sha256.New, []byte(w.secret))
+	mac.Write(body)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if r.Header.Get("X-Vaultsync-Signature") != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		w.badSigns++
+	}
+	w.bodies = append(w.bodies, body)
+	status := w.statuses[min(len(w.bodies), len(w.statuses))-1]
+	rw.WriteHeader(status)
+}
+
+// requests method returns the number of requests the webhook got.
+func (w *webhookServer) requests() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.bodies)
+}
+
+// waitRequests method waits until the webhook got n requests.
+func (w *webhookServer) waitRequests(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for w.requests() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("webhook got %d requests, want %d", w.requests(), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// assertRequests method checks that the webhook still got n requests a moment later.
+func (w *webhookServer) assertRequests(t *testing.T, n int) {
+	t.Helper()
+	time.Sleep(50 * time.Millisecond)
+	if got := w.requests(); got != n {
+		t.Fatalf("webhook got %d requests, want %d", got, n)
+	}
+}
+
+// newWebhookAgent function creates an agent with the fake clock that notifies the webhook of rotations of secret/data/app.
+func newWebhookAgent(t *testing.T, s *vaultsynctest.Server, webhook *webhookServer, maxRetries int, clock *vaultsynctest.FakeClock) *vaultsync.Agent {
+	t.Helper()
+
+	extra := fmt.Sprintf(`  webhook {
+    url         = %q
+    hmac_secret = %q
+    max_retries = %d
+  }`, webhook.URL, webhook.secret, maxRetries)
+	filename, err := s.WriteConfig(t.TempDir(), 3600, extra)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent := newConfigAgent(t, filename, vaultsync.WithClock(clock))
+	agent.RegisterUpdateSecret("secret/data/app", vaultsynctest.NewRecorder())
+	return agent
+}
+
+func TestWebhookSignedAndRetriedWithBackoff(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+	webhook := newWebhookServer(t, "shared-secret", http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK)
+	clock := vaultsynctest.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	agent := newWebhookAgent(t, s, webhook, 3, clock)
+
+	vaultsynctest.Sync(t, agent)
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "n3w"})
+	vaultsynctest.Sync(t, agent)
+	webhook.waitRequests(t, 1)
+
+	// The retries wait one and then two seconds.
+	clock.BlockUntil(1)
+	clock.Advance(999 * time.Millisecond)
+	webhook.assertRequests(t, 1)
+	clock.Advance(time.Millisecond)
+	webhook.waitRequests(t, 2)
+
+	clock.BlockUntil(1)
+	clock.Advance(1999 * time.Millisecond)
+	webhook.assertRequests(t, 2)
+	clock.Advance(time.Millisecond)
+	webhook.waitRequests(t, 3)
+
+	// The third request succeeded, there are no more retries.
+	clock.Advance(time.Hour)
+	webhook.assertRequests(t, 3)
+
+	webhook.mu.Lock()
+	defer webhook.mu.Unlock()
+	if webhook.badSigns != 0 {
+		t.Fatalf("%d requests with a wrong signature", webhook.badSigns)
+	}
+	var event vaultsync.RotationEvent
+	if err := json.Unmarshal(webhook.bodies[0], &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Path != "secret/data/app" || len(event.Fields) != 1 || event.Fields[0] != "password" {
+		t.Fatalf("got event %+v", event)
+	}
+	if strings.Contains(string(webhook.bodies[0]), "n3w") {
+		t.Fatal("the webhook got the secret value")
+	}
+}
+
+func TestWebhookGivesUpAfterMaxRetries(t *testing.T) {
+	s := vaultsynctest.NewServer()
+	defer s.Close()
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "s3cret"})
+	webhook := newWebhookServer(t, "shared-secret", http.StatusServiceUnavailable)
+	clock := vaultsynctest.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	agent := newWebhookAgent(t, s, webhook, 2, clock)
+
+	vaultsynctest.Sync(t, agent)
+	s.SetSecret("secret/data/app", map[string]interface{}{"password": "n3w"})
+	vaultsynctest.Sync(t, agent)
+
+	for n := 1; n <= 3; n++ {
+		webhook.waitRequests(t, n)
+		if n < 3 {
+			clock.BlockUntil(1)
+			clock.Advance(time.Duration(1<<(n-1)) * time.Second)
+		}
+	}
+	clock.Advance(time.Hour)
+	webhook.assertRequests(t, 3)
+}